@@ -0,0 +1,15 @@
+/* This table contains a row for each page reverted by an author revert. */
+CREATE TABLE authorRevertPages (
+	/* Id of the author revert. FK into authorReverts. */
+	authorRevertId BIGINT NOT NULL,
+	/* Id of the reverted page. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* The author's edit that was live before the revert. FK into pages. */
+	revertedEdit INT NOT NULL,
+	/* The edit the page was reverted to. FK into pages. */
+	toEdit INT NOT NULL,
+	/* Id of the revertEdit change log. FK into changeLogs. */
+	changeLogId BIGINT NOT NULL,
+
+	PRIMARY KEY(authorRevertId, pageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
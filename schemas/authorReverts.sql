@@ -0,0 +1,22 @@
+/* This table contains a row for each batch revert of a user's edits in a domain.
+	All the change logs of the batch are listed in authorRevertPages. */
+CREATE TABLE authorReverts (
+	/* Id of this revert. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the domain whose pages were reverted. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* Id of the user whose edits were reverted. FK into users. */
+	authorId VARCHAR(32) NOT NULL,
+	/* Only live edits created after this time were reverted. */
+	since DATETIME NOT NULL,
+	/* Set to true if the author was also banned from the domain. */
+	isAuthorBanned BOOLEAN NOT NULL,
+	/* Number of pages that were reverted. */
+	pagesReverted INT NOT NULL,
+	/* Id of the user who did the revert. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When the revert was done. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
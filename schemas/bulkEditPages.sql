@@ -0,0 +1,21 @@
+/* This table contains a row for each page changed by a bulk edit. */
+CREATE TABLE bulkEditPages (
+	/* Id of the bulk edit. FK into bulkEdits. */
+	bulkEditId BIGINT NOT NULL,
+	/* Id of the page that was changed. FK into pages. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Live edit before the bulk edit. */
+	prevEdit INT NOT NULL,
+	/* Edit created by the bulk edit. */
+	newEdit INT NOT NULL,
+	/* Edit created when undoing the bulk edit. 0 if it wasn't undone. */
+	undoEdit INT NOT NULL,
+	/* If the page couldn't be undone, the reason why. */
+	undoFailure VARCHAR(512) NOT NULL,
+	/* Number of replacements made in the page's text. */
+	replacements INT NOT NULL,
+	/* When the page was changed. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(bulkEditId, pageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,37 @@
+/* This table contains a row for each bulk find-and-replace run by an admin. */
+CREATE TABLE bulkEdits (
+	/* Id of this bulk edit. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the admin who started this bulk edit. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When this bulk edit was started. */
+	createdAt DATETIME NOT NULL,
+	/* When this bulk edit was last updated. */
+	updatedAt DATETIME NOT NULL,
+
+	/* Text or regexp to look for. */
+	findText MEDIUMTEXT NOT NULL,
+	/* Replacement text. For regexps, can reference groups with $1, etc... */
+	replaceText MEDIUMTEXT NOT NULL,
+	/* If true, findText is a regexp. */
+	isRegexp BOOLEAN NOT NULL,
+	/* If set, only pages in this domain are edited. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* If set, comma separated list of the only pages that are edited. */
+	pageIds MEDIUMTEXT NOT NULL,
+	/* Edit summary used for all the new edits. */
+	editSummary VARCHAR(512) NOT NULL,
+
+	/* One of: running, finished, undoing, undone. */
+	status VARCHAR(32) NOT NULL,
+	/* Id of the last page that was processed. Used to resume the bulk edit. */
+	lastPageId VARCHAR(32) NOT NULL,
+	/* Number of pages looked at so far. */
+	pagesScanned INT NOT NULL,
+	/* Number of pages that got a new edit. */
+	pagesChanged INT NOT NULL,
+	/* Number of pages whose edit was undone. */
+	pagesUndone INT NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
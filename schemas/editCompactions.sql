@@ -0,0 +1,29 @@
+/* This table contains a row for each run of the edit history compaction. */
+CREATE TABLE editCompactions (
+	/* Id of this compaction. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the admin who started this compaction. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When this compaction was started. */
+	createdAt DATETIME NOT NULL,
+	/* When this compaction was last updated. */
+	updatedAt DATETIME NOT NULL,
+	/* If true, nothing is deleted; we only report what would be. */
+	isDryRun BOOLEAN NOT NULL,
+	/* Minor edits created before this time can be collapsed. */
+	minorEditCutoff DATETIME NOT NULL,
+	/* Id of the last page that was processed. Used to resume the compaction. */
+	lastPageId VARCHAR(32) NOT NULL,
+	/* Number of pages processed so far. */
+	pagesProcessed INT NOT NULL,
+	/* Number of stale autosaves deleted. */
+	autosavesDeleted INT NOT NULL,
+	/* Number of minor edits collapsed into a later edit. */
+	editsCollapsed INT NOT NULL,
+	/* Number of bytes taken by the deleted edits. */
+	bytesReclaimed BIGINT NOT NULL,
+	/* Set to true when all pages have been processed. */
+	isFinished BOOLEAN NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,21 @@
+/* This table contains comments left on specific lines of edit proposal diffs. */
+CREATE TABLE editProposalComments (
+	/* Id of this comment. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the proposal's change log. FK into changeLogs. */
+	changeLogId BIGINT NOT NULL,
+	/* Id of the page the proposal is for. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Id of the user who left the comment. FK into users. */
+	userId VARCHAR(32) NOT NULL,
+	/* Line number in the text the proposal is based on. 0 for added lines. */
+	oldLineNum INT NOT NULL,
+	/* Line number in the proposed text. 0 for deleted lines. */
+	newLineNum INT NOT NULL,
+	/* Text of the comment. */
+	text TEXT NOT NULL,
+	/* When this comment was left. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
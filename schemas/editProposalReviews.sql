@@ -0,0 +1,23 @@
+/* This table contains the actions reviewers took on edit proposals. */
+CREATE TABLE editProposalReviews (
+	/* Id of this review. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the proposal's change log. FK into changeLogs. */
+	changeLogId BIGINT NOT NULL,
+	/* Id of the page the proposal is for. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Id of the user who reviewed the proposal. FK into users. */
+	reviewerId VARCHAR(32) NOT NULL,
+	/* What the reviewer did, e.g. "requestChanges", "reject", "partialAccept". */
+	action VARCHAR(32) NOT NULL,
+	/* Reason given by the reviewer. */
+	reason TEXT NOT NULL,
+	/* For partial accepts, comma separated indexes of the accepted diff hunks. */
+	acceptedHunks VARCHAR(1024) NOT NULL,
+	/* For accepts, the edit that was published as a result. */
+	resultEdit INT NOT NULL,
+	/* When this review happened. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,15 @@
+/* This table contains all the operations applied in a collaborative editing session. */
+CREATE TABLE editSessionOps (
+	/* Id of the page being edited. FK into editSessions. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Revision of the text after this operation was applied. */
+	revision INT NOT NULL,
+	/* Id of the user who made the change. FK into users. */
+	userId VARCHAR(32) NOT NULL,
+	/* JSON encoded list of retain/insert/delete components. */
+	ops MEDIUMTEXT NOT NULL,
+	/* When the operation was applied. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(pageId,revision)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,15 @@
+/* This table contains who is currently taking part in a collaborative editing session. */
+CREATE TABLE editSessionPresences (
+	/* Id of the page being edited. FK into editSessions. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Id of the editing user. FK into users. */
+	userId VARCHAR(32) NOT NULL,
+	/* Start of the user's selection (or cursor position) in the session text, in characters. */
+	selectionStart INT NOT NULL,
+	/* End of the user's selection. Equal to selectionStart if nothing is selected. */
+	selectionEnd INT NOT NULL,
+	/* Last time the user polled the session. */
+	updatedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(pageId,userId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
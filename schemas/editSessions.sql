@@ -0,0 +1,21 @@
+/* This table contains the shared document of each collaborative editing session. */
+CREATE TABLE editSessions (
+	/* Id of the page being edited. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Number of operations applied to the text so far. */
+	revision INT NOT NULL,
+	/* Current text of the page in this session. */
+	text MEDIUMTEXT NOT NULL,
+	/* Edit the session started from (or the edit that was last published). */
+	baseEdit INT NOT NULL,
+	/* Id of the user who started the session. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When the session was started. */
+	createdAt DATETIME NOT NULL,
+	/* When the last operation was applied. */
+	updatedAt DATETIME NOT NULL,
+	/* When the text was last saved as an autosave. */
+	lastAutosaveAt DATETIME NOT NULL,
+
+	PRIMARY KEY(pageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
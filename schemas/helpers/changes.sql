@@ -125,3 +125,560 @@ CREATE TABLE aliasRedirects (
 ) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
 
 alter table pageInfos add column votesAnonymous bool not null;
+
+/* This table contains the tag and page type rules used to rank search results
+ in a domain. */
+CREATE TABLE searchRankingRules (
+	/* Id of the domain these rules apply to. 0 for site-wide rules. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* Type of the rule: "tag" or "pageType". */
+	type VARCHAR(32) NOT NULL,
+	/* Tag page id for "tag" rules. Page type for "pageType" rules. */
+	ruleKey VARCHAR(32) NOT NULL,
+	/* Search score of a matching page is multiplied by this. */
+	multiplier DOUBLE NOT NULL,
+	/* Id of the user who last updated this rule. FK into users. */
+	updatedBy VARCHAR(32) NOT NULL,
+	/* When this rule was last updated. */
+	updatedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(domainId,type,ruleKey)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains the settings used to rank search results in a domain. */
+CREATE TABLE searchRankingSettings (
+	/* Id of the domain these settings apply to. 0 for site-wide settings. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* Score is multiplied by log(likeScore) / likeScoreDivisor + 1. 0 to ignore likes. */
+	likeScoreDivisor DOUBLE NOT NULL,
+	/* Score multiplier if the current user liked the page. */
+	myLikeMultiplier DOUBLE NOT NULL,
+	/* Score multiplier if the current user is one of the page's creators. */
+	creatorMultiplier DOUBLE NOT NULL,
+	/* Score is halved every this many days since the last edit. 0 for no decay. */
+	recencyHalfLifeDays INT NOT NULL,
+	/* Recency decay never makes the multiplier go below this value. */
+	recencyMinMultiplier DOUBLE NOT NULL,
+	/* Id of the user who last updated these settings. FK into users. */
+	updatedBy VARCHAR(32) NOT NULL,
+	/* When these settings were last updated. */
+	updatedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(domainId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains a row for each run of the search index consistency check. */
+CREATE TABLE searchIndexChecks (
+	/* Id of this check. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the admin who started this check. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When this check was started. */
+	createdAt DATETIME NOT NULL,
+	/* When this check was last updated. */
+	updatedAt DATETIME NOT NULL,
+	/* If true, the problems found will be fixed, not just reported. */
+	repair BOOLEAN NOT NULL,
+	/* Id of the last page that was checked. Used to resume the check. */
+	lastPageId VARCHAR(32) NOT NULL,
+	/* Number of pages checked so far. */
+	pagesChecked INT NOT NULL,
+	/* Number of published pages that are missing from the index. */
+	missingCount INT NOT NULL,
+	/* Number of pages whose indexed data doesn't match the db. */
+	mismatchCount INT NOT NULL,
+	/* Number of indexed pages that shouldn't be in the index. */
+	extraCount INT NOT NULL,
+	/* Number of problems that were fixed. */
+	repairedCount INT NOT NULL,
+	/* Set to true when all pages have been checked. */
+	isFinished BOOLEAN NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains a row for each problem found by a search index check. */
+CREATE TABLE searchIndexCheckFindings (
+	/* Id of the check that found this problem. FK into searchIndexChecks. */
+	checkId BIGINT NOT NULL,
+	/* Id of the page with the problem. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Type of the problem: "missing", "mismatch", or "extra". */
+	type VARCHAR(32) NOT NULL,
+	/* Description of what doesn't match. */
+	details VARCHAR(1024) NOT NULL,
+	/* True iff the problem was fixed. */
+	repaired BOOLEAN NOT NULL,
+	/* When this problem was found. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(checkId,pageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains groups of terms that should be treated as equivalent when searching. */
+CREATE TABLE searchSynonyms (
+	/* Id of this synonym group. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the domain this group applies to. 0 for site-wide synonyms. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* Comma separated list of equivalent terms, e.g. "maximiser,maximizer". */
+	terms VARCHAR(1024) NOT NULL,
+	/* Id of the user who added this group. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When this group was added. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains, for each page, the pages with the most similar text.
+ It's recomputed periodically by ComputeSimilarPagesTask. */
+CREATE TABLE similarPages (
+	/* Id of the page. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Id of a page similar to it. FK into pageInfos. */
+	similarPageId VARCHAR(32) NOT NULL,
+	/* Cosine similarity of the pages' TF-IDF vectors, between 0 and 1. */
+	score DOUBLE NOT NULL,
+	/* When this similarity was computed. */
+	computedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(pageId,similarPageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains the shared document of each collaborative editing session. */
+CREATE TABLE editSessions (
+	/* Id of the page being edited. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Number of operations applied to the text so far. */
+	revision INT NOT NULL,
+	/* Current text of the page in this session. */
+	text MEDIUMTEXT NOT NULL,
+	/* Edit the session started from (or the edit that was last published). */
+	baseEdit INT NOT NULL,
+	/* Id of the user who started the session. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When the session was started. */
+	createdAt DATETIME NOT NULL,
+	/* When the last operation was applied. */
+	updatedAt DATETIME NOT NULL,
+	/* When the text was last saved as an autosave. */
+	lastAutosaveAt DATETIME NOT NULL,
+
+	PRIMARY KEY(pageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains all the operations applied in a collaborative editing session. */
+CREATE TABLE editSessionOps (
+	/* Id of the page being edited. FK into editSessions. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Revision of the text after this operation was applied. */
+	revision INT NOT NULL,
+	/* Id of the user who made the change. FK into users. */
+	userId VARCHAR(32) NOT NULL,
+	/* JSON encoded list of retain/insert/delete components. */
+	ops MEDIUMTEXT NOT NULL,
+	/* When the operation was applied. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(pageId,revision)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains who is currently taking part in a collaborative editing session. */
+CREATE TABLE editSessionPresences (
+	/* Id of the page being edited. FK into editSessions. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Id of the editing user. FK into users. */
+	userId VARCHAR(32) NOT NULL,
+	/* Start of the user's selection (or cursor position) in the session text, in characters. */
+	selectionStart INT NOT NULL,
+	/* End of the user's selection. Equal to selectionStart if nothing is selected. */
+	selectionEnd INT NOT NULL,
+	/* Last time the user polled the session. */
+	updatedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(pageId,userId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains the actions reviewers took on edit proposals. */
+CREATE TABLE editProposalReviews (
+	/* Id of this review. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the proposal's change log. FK into changeLogs. */
+	changeLogId BIGINT NOT NULL,
+	/* Id of the page the proposal is for. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Id of the user who reviewed the proposal. FK into users. */
+	reviewerId VARCHAR(32) NOT NULL,
+	/* What the reviewer did, e.g. "requestChanges", "reject", "partialAccept". */
+	action VARCHAR(32) NOT NULL,
+	/* Reason given by the reviewer. */
+	reason TEXT NOT NULL,
+	/* For partial accepts, comma separated indexes of the accepted diff hunks. */
+	acceptedHunks VARCHAR(1024) NOT NULL,
+	/* For accepts, the edit that was published as a result. */
+	resultEdit INT NOT NULL,
+	/* When this review happened. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains comments left on specific lines of edit proposal diffs. */
+CREATE TABLE editProposalComments (
+	/* Id of this comment. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the proposal's change log. FK into changeLogs. */
+	changeLogId BIGINT NOT NULL,
+	/* Id of the page the proposal is for. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Id of the user who left the comment. FK into users. */
+	userId VARCHAR(32) NOT NULL,
+	/* Line number in the text the proposal is based on. 0 for added lines. */
+	oldLineNum INT NOT NULL,
+	/* Line number in the proposed text. 0 for deleted lines. */
+	newLineNum INT NOT NULL,
+	/* Text of the comment. */
+	text TEXT NOT NULL,
+	/* When this comment was left. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains edits which are scheduled to become live at a given time. */
+CREATE TABLE scheduledPublishes (
+	/* Id of this scheduled publish. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the page. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Edit to publish. It's saved as a snapshot until then. FK into pages. */
+	edit INT NOT NULL,
+	/* Id of the user who scheduled the publish. FK into users. */
+	userId VARCHAR(32) NOT NULL,
+	/* Live edit at the time the publish was scheduled. */
+	baseEdit INT NOT NULL,
+	/* When the edit should go live. */
+	publishAt DATETIME NOT NULL,
+	/* One of: "pending", "published", "cancelled", "failed". */
+	status VARCHAR(32) NOT NULL,
+	/* If the publish failed, this is why. */
+	failureReason VARCHAR(1024) NOT NULL,
+	/* When the publish was scheduled. */
+	createdAt DATETIME NOT NULL,
+	/* When the status last changed. */
+	updatedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains a row for each bulk find-and-replace run by an admin. */
+CREATE TABLE bulkEdits (
+	/* Id of this bulk edit. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the admin who started this bulk edit. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When this bulk edit was started. */
+	createdAt DATETIME NOT NULL,
+	/* When this bulk edit was last updated. */
+	updatedAt DATETIME NOT NULL,
+
+	/* Text or regexp to look for. */
+	findText MEDIUMTEXT NOT NULL,
+	/* Replacement text. For regexps, can reference groups with $1, etc... */
+	replaceText MEDIUMTEXT NOT NULL,
+	/* If true, findText is a regexp. */
+	isRegexp BOOLEAN NOT NULL,
+	/* If set, only pages in this domain are edited. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* If set, comma separated list of the only pages that are edited. */
+	pageIds MEDIUMTEXT NOT NULL,
+	/* Edit summary used for all the new edits. */
+	editSummary VARCHAR(512) NOT NULL,
+
+	/* One of: running, finished, undoing, undone. */
+	status VARCHAR(32) NOT NULL,
+	/* Id of the last page that was processed. Used to resume the bulk edit. */
+	lastPageId VARCHAR(32) NOT NULL,
+	/* Number of pages looked at so far. */
+	pagesScanned INT NOT NULL,
+	/* Number of pages that got a new edit. */
+	pagesChanged INT NOT NULL,
+	/* Number of pages whose edit was undone. */
+	pagesUndone INT NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains a row for each page changed by a bulk edit. */
+CREATE TABLE bulkEditPages (
+	/* Id of the bulk edit. FK into bulkEdits. */
+	bulkEditId BIGINT NOT NULL,
+	/* Id of the page that was changed. FK into pages. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Live edit before the bulk edit. */
+	prevEdit INT NOT NULL,
+	/* Edit created by the bulk edit. */
+	newEdit INT NOT NULL,
+	/* Edit created when undoing the bulk edit. 0 if it wasn't undone. */
+	undoEdit INT NOT NULL,
+	/* If the page couldn't be undone, the reason why. */
+	undoFailure VARCHAR(512) NOT NULL,
+	/* Number of replacements made in the page's text. */
+	replacements INT NOT NULL,
+	/* When the page was changed. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(bulkEditId, pageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains a row for each run of the edit history compaction. */
+CREATE TABLE editCompactions (
+	/* Id of this compaction. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the admin who started this compaction. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When this compaction was started. */
+	createdAt DATETIME NOT NULL,
+	/* When this compaction was last updated. */
+	updatedAt DATETIME NOT NULL,
+	/* If true, nothing is deleted; we only report what would be. */
+	isDryRun BOOLEAN NOT NULL,
+	/* Minor edits created before this time can be collapsed. */
+	minorEditCutoff DATETIME NOT NULL,
+	/* Id of the last page that was processed. Used to resume the compaction. */
+	lastPageId VARCHAR(32) NOT NULL,
+	/* Number of pages processed so far. */
+	pagesProcessed INT NOT NULL,
+	/* Number of stale autosaves deleted. */
+	autosavesDeleted INT NOT NULL,
+	/* Number of minor edits collapsed into a later edit. */
+	editsCollapsed INT NOT NULL,
+	/* Number of bytes taken by the deleted edits. */
+	bytesReclaimed BIGINT NOT NULL,
+	/* Set to true when all pages have been processed. */
+	isFinished BOOLEAN NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains a row for each page a domain uses as a template for new pages. */
+CREATE TABLE pageTemplates (
+	/* Id of the domain. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* Id of the template page. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Id of the user who marked the page as a template. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When the page was marked as a template. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(domainId, pageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains a row for each batch revert of a user's edits in a domain.
+	All the change logs of the batch are listed in authorRevertPages. */
+CREATE TABLE authorReverts (
+	/* Id of this revert. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the domain whose pages were reverted. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* Id of the user whose edits were reverted. FK into users. */
+	authorId VARCHAR(32) NOT NULL,
+	/* Only live edits created after this time were reverted. */
+	since DATETIME NOT NULL,
+	/* Set to true if the author was also banned from the domain. */
+	isAuthorBanned BOOLEAN NOT NULL,
+	/* Number of pages that were reverted. */
+	pagesReverted INT NOT NULL,
+	/* Id of the user who did the revert. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When the revert was done. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains a row for each page reverted by an author revert. */
+CREATE TABLE authorRevertPages (
+	/* Id of the author revert. FK into authorReverts. */
+	authorRevertId BIGINT NOT NULL,
+	/* Id of the reverted page. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* The author's edit that was live before the revert. FK into pages. */
+	revertedEdit INT NOT NULL,
+	/* The edit the page was reverted to. FK into pages. */
+	toEdit INT NOT NULL,
+	/* Id of the revertEdit change log. FK into changeLogs. */
+	changeLogId BIGINT NOT NULL,
+
+	PRIMARY KEY(authorRevertId, pageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains a row for each run of the requisite graph integrity check. */
+CREATE TABLE requisiteGraphChecks (
+	/* Id of this check. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* When this check was run. */
+	createdAt DATETIME NOT NULL,
+	/* Number of requirement and subject relationships checked. */
+	pairsChecked INT NOT NULL,
+	/* Number of cycles between subjects. */
+	cycleCount INT NOT NULL,
+	/* Number of required pages that no page teaches. */
+	orphanCount INT NOT NULL,
+	/* Number of requirements that point at deleted pages. */
+	deletedCount INT NOT NULL,
+	/* Number of requirements already implied by other requirements. */
+	redundantCount INT NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains a row for each problem found by a requisite graph check. */
+CREATE TABLE requisiteGraphCheckFindings (
+	/* Id of the check that found this problem. FK into requisiteGraphChecks. */
+	checkId BIGINT NOT NULL,
+	/* Type of the problem: "cycle", "orphan", "deleted", or "redundant". */
+	type VARCHAR(32) NOT NULL,
+	/* Id of the page with the problem. For deleted and redundant requirements
+		it's the requiring page, for orphans it's the required page, and for
+		cycles it's the subject with the smallest id. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* For deleted and redundant requirements, id of the required page.
+		FK into pageInfos. */
+	auxPageId VARCHAR(32) NOT NULL,
+	/* Edit domain of the page with the problem. FK into domains. */
+	domainId VARCHAR(32) NOT NULL,
+	/* Description of the problem. */
+	details VARCHAR(1024) NOT NULL,
+	/* When this problem was found. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(checkId,type,pageId,auxPageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains the correct answer for each quiz question in a page.
+	Answer keys are written for a specific edit of the page, and apply to all
+	later edits until a new answer key is written. */
+CREATE TABLE quizAnswerKeys (
+	/* Id of the page with the question. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Edit of the page this answer key is for. */
+	edit INT NOT NULL,
+	/* Alias of the question object. Matches userPageObjectPairs.object. */
+	object VARCHAR(64) NOT NULL,
+	/* The correct value. Compared with userPageObjectPairs.value. */
+	answer VARCHAR(512) NOT NULL,
+	/* Id of the user who wrote this answer key. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When this answer key was written. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(pageId,edit,object)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains the masteries a user gets for passing the quiz in a page. */
+CREATE TABLE quizMasteryGrants (
+	/* Id of the page with the quiz. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Edit of the page this grant is for. Matches quizAnswerKeys.edit. */
+	edit INT NOT NULL,
+	/* Id of the mastery granted. FK into pages. */
+	masteryId VARCHAR(32) NOT NULL,
+	/* Level at which the mastery is granted. */
+	level INT NOT NULL,
+	/* How many questions the user has to answer correctly. 0 means all of them. */
+	passingScore INT NOT NULL,
+
+	PRIMARY KEY(pageId,edit,masteryId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains an entry for every mastery a user is reviewing with
+	spaced repetition. The schedule follows the SM-2 algorithm. */
+CREATE TABLE masteryReviews (
+	/* Id of the user. FK into users. */
+	userId VARCHAR(32) NOT NULL,
+	/* Id of the mastery. FK into pages. */
+	masteryId VARCHAR(32) NOT NULL,
+	/* How easy the mastery is for the user to remember. Starts at 2.5 and
+		never goes below 1.3. */
+	easiness DOUBLE NOT NULL,
+	/* Number of days until the next review. */
+	intervalDays INT NOT NULL,
+	/* Number of reviews in a row the user passed. */
+	repetitions INT NOT NULL,
+	/* When the next review is due. */
+	dueAt DATETIME NOT NULL,
+	/* When the user last reviewed the mastery (or learned it). */
+	lastReviewedAt DATETIME NOT NULL,
+	/* Grade the user got on the last review, from 0 to 5. */
+	lastGrade INT NOT NULL,
+	/* When we last reminded the user about this review. If it's before dueAt,
+		the user hasn't been reminded about the current review yet. */
+	remindedAt DATETIME NOT NULL,
+	/* When this entry was created. */
+	createdAt DATETIME NOT NULL,
+	/* When this entry was updated. */
+	updatedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(userId,masteryId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+alter table pathPages add column branchOf bigint not null;
+alter table pathPages add column conditionType varchar(32) not null;
+alter table pathPages add column quizPageId varchar(32) not null;
+alter table pathPages add column quizObject varchar(64) not null;
+alter table pathPages add column quizValue varchar(255) not null;
+alter table pathPages add column skipIfMasteryIds text not null;
+alter table pathPages add column insertRequisites boolean not null;
+alter table pathInstances add column pathPageIds text not null;
+alter table pathInstances add column speed int not null;
+
+/* This table contains the precomputed analytics for each guide's path. */
+CREATE TABLE pathAnalytics (
+	/* Id of the page guide that starts the path. FK into pageInfos. */
+	guideId VARCHAR(32) NOT NULL,
+	/* Number of path instances readers started. */
+	starts INT NOT NULL,
+	/* Number of path instances readers finished. */
+	finishes INT NOT NULL,
+	/* Fraction of the started path instances that were finished. */
+	completionRate DOUBLE NOT NULL,
+	/* When the analytics were computed. */
+	computedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(guideId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+/* This table contains the precomputed analytics for each page readers read
+	on a guide's path. */
+CREATE TABLE pathStepAnalytics (
+	/* Id of the page guide that starts the path. FK into pageInfos. */
+	guideId VARCHAR(32) NOT NULL,
+	/* Id of the page on the path. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* True if the page was inserted for a requisite. */
+	isRequisite BOOLEAN NOT NULL,
+	/* Order in which to show the pages: path order for the path's pages, most
+		common first for the requisites. */
+	orderIndex INT NOT NULL,
+	/* Number of path instances that had this page. */
+	inserted INT NOT NULL,
+	/* Number of path instances that got to this page. */
+	reached INT NOT NULL,
+	/* Number of unfinished path instances where the reader stopped on this page. */
+	droppedOff INT NOT NULL,
+	/* Median time readers spent on this page, in seconds. 0 if unknown. */
+	medianSeconds INT NOT NULL,
+
+	PRIMARY KEY(guideId,pageId,isRequisite)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+alter table pathInstances add column isGroup boolean not null;
+
+alter table pageInfos add column readingSeconds int not null;
+alter table pageInfos add column difficulty int not null;
+
+alter table userPageObjectPairs add column attempts int not null;
+alter table pathInstances add column shareKey varchar(64) not null;
@@ -0,0 +1,30 @@
+/* This table contains an entry for every mastery a user is reviewing with
+	spaced repetition. The schedule follows the SM-2 algorithm. */
+CREATE TABLE masteryReviews (
+	/* Id of the user. FK into users. */
+	userId VARCHAR(32) NOT NULL,
+	/* Id of the mastery. FK into pages. */
+	masteryId VARCHAR(32) NOT NULL,
+	/* How easy the mastery is for the user to remember. Starts at 2.5 and
+		never goes below 1.3. */
+	easiness DOUBLE NOT NULL,
+	/* Number of days until the next review. */
+	intervalDays INT NOT NULL,
+	/* Number of reviews in a row the user passed. */
+	repetitions INT NOT NULL,
+	/* When the next review is due. */
+	dueAt DATETIME NOT NULL,
+	/* When the user last reviewed the mastery (or learned it). */
+	lastReviewedAt DATETIME NOT NULL,
+	/* Grade the user got on the last review, from 0 to 5. */
+	lastGrade INT NOT NULL,
+	/* When we last reminded the user about this review. If it's before dueAt,
+		the user hasn't been reminded about the current review yet. */
+	remindedAt DATETIME NOT NULL,
+	/* When this entry was created. */
+	createdAt DATETIME NOT NULL,
+	/* When this entry was updated. */
+	updatedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(userId,masteryId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -70,5 +70,12 @@ CREATE TABLE pageInfos (
 	/* If set, this page is a link to an external resource with this url. */
 	externalUrl VARCHAR(2048) NOT NULL,
 
+	/* == Estimates, computed when the page is published. == */
+	/* Estimated time it takes to read the page, in seconds. */
+	readingSeconds INT NOT NULL,
+	/* Sum of the requirement levels along the longest chain of requirements
+		leading to this page. */
+	difficulty INT NOT NULL,
+
 	PRIMARY KEY(pageId)
 ) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,13 @@
+/* This table contains a row for each page a domain uses as a template for new pages. */
+CREATE TABLE pageTemplates (
+	/* Id of the domain. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* Id of the template page. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Id of the user who marked the page as a template. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When the page was marked as a template. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(domainId, pageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,15 @@
+/* This table contains the precomputed analytics for each guide's path. */
+CREATE TABLE pathAnalytics (
+	/* Id of the page guide that starts the path. FK into pageInfos. */
+	guideId VARCHAR(32) NOT NULL,
+	/* Number of path instances readers started. */
+	starts INT NOT NULL,
+	/* Number of path instances readers finished. */
+	finishes INT NOT NULL,
+	/* Fraction of the started path instances that were finished. */
+	completionRate DOUBLE NOT NULL,
+	/* When the analytics were computed. */
+	computedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(guideId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -10,6 +10,11 @@ CREATE TABLE pathInstances (
 	pageIds TEXT NOT NULL,
 	/* Comma separated list of which page added the corresponding page to pageIds. FK into pageInfos. */
 	sourcePageIds TEXT NOT NULL,
+	/* Comma separated list of the pathPages entries that added the corresponding
+		page to pageIds. 0 if the page wasn't added by a path page. FK into pathPages. */
+	pathPageIds TEXT NOT NULL,
+	/* Speed the user prefers: -1 for slower pages, 1 for faster ones, 0 if no preference. */
+	speed INT NOT NULL,
 	/* Index of the page the user is on. */
 	progress INT NOT NULL,
 	/* When this instance was created. */
@@ -20,6 +25,12 @@ CREATE TABLE pathInstances (
 	originalInstanceId BIGINT NOT NULL,
 	/* Set to true when the user finished the path. */
 	isFinished BOOLEAN NOT NULL,
+	/* True if a teacher created this instance for a group of students. Students
+		start copies of it, and the teacher can see the copies' progress. */
+	isGroup BOOLEAN NOT NULL,
+	/* Random key that has to be passed along with the id to view or copy this
+		instance, when it's not the user's own. */
+	shareKey VARCHAR(64) NOT NULL,
 
 	PRIMARY KEY(id)
 ) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
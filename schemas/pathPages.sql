@@ -8,6 +8,24 @@ CREATE TABLE pathPages (
 	pathPageId VARCHAR(32) NOT NULL,
 	/* Ordering index when ordering the pages in a path. */
 	pathIndex INT NOT NULL,
+	/* If set, this page is an alternate for the path page with this id, and
+		replaces it when the condition holds. FK into pathPages. */
+	branchOf BIGINT NOT NULL,
+	/* Condition for picking this alternate: "speed" (the page's speed tag matches
+		the speed the user prefers) or "quizAnswer". */
+	conditionType VARCHAR(32) NOT NULL,
+	/* For quizAnswer conditions: the page with the question. FK into pageInfos. */
+	quizPageId VARCHAR(32) NOT NULL,
+	/* For quizAnswer conditions: alias of the question's object. */
+	quizObject VARCHAR(64) NOT NULL,
+	/* For quizAnswer conditions: the answer that picks this alternate. */
+	quizValue VARCHAR(255) NOT NULL,
+	/* Comma separated list of mastery ids. The page is skipped if the user has
+		all of them. FK into pageInfos. */
+	skipIfMasteryIds TEXT NOT NULL,
+	/* If true, pages teaching the requisites the user doesn't have are inserted
+		before this page. */
+	insertRequisites BOOLEAN NOT NULL,
 	/* Id of the user who created the relationship. FK into users. */
 	createdBy VARCHAR(32) NOT NULL,
 	/* When this lens relationship was originally created. */
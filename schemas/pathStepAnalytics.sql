@@ -0,0 +1,23 @@
+/* This table contains the precomputed analytics for each page readers read
+	on a guide's path. */
+CREATE TABLE pathStepAnalytics (
+	/* Id of the page guide that starts the path. FK into pageInfos. */
+	guideId VARCHAR(32) NOT NULL,
+	/* Id of the page on the path. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* True if the page was inserted for a requisite. */
+	isRequisite BOOLEAN NOT NULL,
+	/* Order in which to show the pages: path order for the path's pages, most
+		common first for the requisites. */
+	orderIndex INT NOT NULL,
+	/* Number of path instances that had this page. */
+	inserted INT NOT NULL,
+	/* Number of path instances that got to this page. */
+	reached INT NOT NULL,
+	/* Number of unfinished path instances where the reader stopped on this page. */
+	droppedOff INT NOT NULL,
+	/* Median time readers spent on this page, in seconds. 0 if unknown. */
+	medianSeconds INT NOT NULL,
+
+	PRIMARY KEY(guideId,pageId,isRequisite)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
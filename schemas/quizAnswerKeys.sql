@@ -0,0 +1,19 @@
+/* This table contains the correct answer for each quiz question in a page.
+	Answer keys are written for a specific edit of the page, and apply to all
+	later edits until a new answer key is written. */
+CREATE TABLE quizAnswerKeys (
+	/* Id of the page with the question. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Edit of the page this answer key is for. */
+	edit INT NOT NULL,
+	/* Alias of the question object. Matches userPageObjectPairs.object. */
+	object VARCHAR(64) NOT NULL,
+	/* The correct value. Compared with userPageObjectPairs.value. */
+	answer VARCHAR(512) NOT NULL,
+	/* Id of the user who wrote this answer key. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When this answer key was written. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(pageId,edit,object)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,15 @@
+/* This table contains the masteries a user gets for passing the quiz in a page. */
+CREATE TABLE quizMasteryGrants (
+	/* Id of the page with the quiz. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Edit of the page this grant is for. Matches quizAnswerKeys.edit. */
+	edit INT NOT NULL,
+	/* Id of the mastery granted. FK into pages. */
+	masteryId VARCHAR(32) NOT NULL,
+	/* Level at which the mastery is granted. */
+	level INT NOT NULL,
+	/* How many questions the user has to answer correctly. 0 means all of them. */
+	passingScore INT NOT NULL,
+
+	PRIMARY KEY(pageId,edit,masteryId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,22 @@
+/* This table contains a row for each problem found by a requisite graph check. */
+CREATE TABLE requisiteGraphCheckFindings (
+	/* Id of the check that found this problem. FK into requisiteGraphChecks. */
+	checkId BIGINT NOT NULL,
+	/* Type of the problem: "cycle", "orphan", "deleted", or "redundant". */
+	type VARCHAR(32) NOT NULL,
+	/* Id of the page with the problem. For deleted and redundant requirements
+		it's the requiring page, for orphans it's the required page, and for
+		cycles it's the subject with the smallest id. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* For deleted and redundant requirements, id of the required page.
+		FK into pageInfos. */
+	auxPageId VARCHAR(32) NOT NULL,
+	/* Edit domain of the page with the problem. FK into domains. */
+	domainId VARCHAR(32) NOT NULL,
+	/* Description of the problem. */
+	details VARCHAR(1024) NOT NULL,
+	/* When this problem was found. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(checkId,type,pageId,auxPageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
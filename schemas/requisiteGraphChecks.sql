@@ -0,0 +1,19 @@
+/* This table contains a row for each run of the requisite graph integrity check. */
+CREATE TABLE requisiteGraphChecks (
+	/* Id of this check. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* When this check was run. */
+	createdAt DATETIME NOT NULL,
+	/* Number of requirement and subject relationships checked. */
+	pairsChecked INT NOT NULL,
+	/* Number of cycles between subjects. */
+	cycleCount INT NOT NULL,
+	/* Number of required pages that no page teaches. */
+	orphanCount INT NOT NULL,
+	/* Number of requirements that point at deleted pages. */
+	deletedCount INT NOT NULL,
+	/* Number of requirements already implied by other requirements. */
+	redundantCount INT NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
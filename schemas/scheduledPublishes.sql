@@ -0,0 +1,25 @@
+/* This table contains edits which are scheduled to become live at a given time. */
+CREATE TABLE scheduledPublishes (
+	/* Id of this scheduled publish. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the page. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Edit to publish. It's saved as a snapshot until then. FK into pages. */
+	edit INT NOT NULL,
+	/* Id of the user who scheduled the publish. FK into users. */
+	userId VARCHAR(32) NOT NULL,
+	/* Live edit at the time the publish was scheduled. */
+	baseEdit INT NOT NULL,
+	/* When the edit should go live. */
+	publishAt DATETIME NOT NULL,
+	/* One of: "pending", "published", "cancelled", "failed". */
+	status VARCHAR(32) NOT NULL,
+	/* If the publish failed, this is why. */
+	failureReason VARCHAR(1024) NOT NULL,
+	/* When the publish was scheduled. */
+	createdAt DATETIME NOT NULL,
+	/* When the status last changed. */
+	updatedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
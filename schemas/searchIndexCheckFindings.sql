@@ -0,0 +1,17 @@
+/* This table contains a row for each problem found by a search index check. */
+CREATE TABLE searchIndexCheckFindings (
+	/* Id of the check that found this problem. FK into searchIndexChecks. */
+	checkId BIGINT NOT NULL,
+	/* Id of the page with the problem. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Type of the problem: "missing", "mismatch", or "extra". */
+	type VARCHAR(32) NOT NULL,
+	/* Description of what doesn't match. */
+	details VARCHAR(1024) NOT NULL,
+	/* True iff the problem was fixed. */
+	repaired BOOLEAN NOT NULL,
+	/* When this problem was found. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(checkId,pageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
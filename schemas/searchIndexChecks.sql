@@ -0,0 +1,29 @@
+/* This table contains a row for each run of the search index consistency check. */
+CREATE TABLE searchIndexChecks (
+	/* Id of this check. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the admin who started this check. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When this check was started. */
+	createdAt DATETIME NOT NULL,
+	/* When this check was last updated. */
+	updatedAt DATETIME NOT NULL,
+	/* If true, the problems found will be fixed, not just reported. */
+	repair BOOLEAN NOT NULL,
+	/* Id of the last page that was checked. Used to resume the check. */
+	lastPageId VARCHAR(32) NOT NULL,
+	/* Number of pages checked so far. */
+	pagesChecked INT NOT NULL,
+	/* Number of published pages that are missing from the index. */
+	missingCount INT NOT NULL,
+	/* Number of pages whose indexed data doesn't match the db. */
+	mismatchCount INT NOT NULL,
+	/* Number of indexed pages that shouldn't be in the index. */
+	extraCount INT NOT NULL,
+	/* Number of problems that were fixed. */
+	repairedCount INT NOT NULL,
+	/* Set to true when all pages have been checked. */
+	isFinished BOOLEAN NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,18 @@
+/* This table contains the tag and page type rules used to rank search results
+ in a domain. */
+CREATE TABLE searchRankingRules (
+	/* Id of the domain these rules apply to. 0 for site-wide rules. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* Type of the rule: "tag" or "pageType". */
+	type VARCHAR(32) NOT NULL,
+	/* Tag page id for "tag" rules. Page type for "pageType" rules. */
+	ruleKey VARCHAR(32) NOT NULL,
+	/* Search score of a matching page is multiplied by this. */
+	multiplier DOUBLE NOT NULL,
+	/* Id of the user who last updated this rule. FK into users. */
+	updatedBy VARCHAR(32) NOT NULL,
+	/* When this rule was last updated. */
+	updatedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(domainId,type,ruleKey)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,21 @@
+/* This table contains the settings used to rank search results in a domain. */
+CREATE TABLE searchRankingSettings (
+	/* Id of the domain these settings apply to. 0 for site-wide settings. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* Score is multiplied by log(likeScore) / likeScoreDivisor + 1. 0 to ignore likes. */
+	likeScoreDivisor DOUBLE NOT NULL,
+	/* Score multiplier if the current user liked the page. */
+	myLikeMultiplier DOUBLE NOT NULL,
+	/* Score multiplier if the current user is one of the page's creators. */
+	creatorMultiplier DOUBLE NOT NULL,
+	/* Score is halved every this many days since the last edit. 0 for no decay. */
+	recencyHalfLifeDays INT NOT NULL,
+	/* Recency decay never makes the multiplier go below this value. */
+	recencyMinMultiplier DOUBLE NOT NULL,
+	/* Id of the user who last updated these settings. FK into users. */
+	updatedBy VARCHAR(32) NOT NULL,
+	/* When these settings were last updated. */
+	updatedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(domainId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,15 @@
+/* This table contains groups of terms that should be treated as equivalent when searching. */
+CREATE TABLE searchSynonyms (
+	/* Id of this synonym group. */
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	/* Id of the domain this group applies to. 0 for site-wide synonyms. FK into domains. */
+	domainId BIGINT NOT NULL,
+	/* Comma separated list of equivalent terms, e.g. "maximiser,maximizer". */
+	terms VARCHAR(1024) NOT NULL,
+	/* Id of the user who added this group. FK into users. */
+	createdBy VARCHAR(32) NOT NULL,
+	/* When this group was added. */
+	createdAt DATETIME NOT NULL,
+
+	PRIMARY KEY(id)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -0,0 +1,14 @@
+/* This table contains, for each page, the pages with the most similar text.
+ It's recomputed periodically by ComputeSimilarPagesTask. */
+CREATE TABLE similarPages (
+	/* Id of the page. FK into pageInfos. */
+	pageId VARCHAR(32) NOT NULL,
+	/* Id of a page similar to it. FK into pageInfos. */
+	similarPageId VARCHAR(32) NOT NULL,
+	/* Cosine similarity of the pages' TF-IDF vectors, between 0 and 1. */
+	score DOUBLE NOT NULL,
+	/* When this similarity was computed. */
+	computedAt DATETIME NOT NULL,
+
+	PRIMARY KEY(pageId,similarPageId)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -24,5 +24,9 @@ CREATE TABLE userPageObjectPairs (
 	/* Whatever value the object decides to set here. */
 	value VARCHAR(512) NOT NULL,
 
+	/* How many times the user changed the value. Only counted for quiz
+		questions, which can only be answered a few times. */
+	attempts INT NOT NULL,
+
 	PRIMARY KEY(userId,pageId,object)
 ) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -118,6 +118,8 @@ type Config struct {
 		Session struct {
 			Auth, Crypt string
 		}
+		// Id of the system account that bulk edits are attributed to
+		BulkEditUserID string `yaml:"bulkEditUserId"`
 	}
 	Elastic struct {
 		Live struct {
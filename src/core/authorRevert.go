@@ -0,0 +1,63 @@
+// authorRevert.go contains the functions for reverting all of a user's recent
+// edits in a domain at once.
+package core
+
+import (
+	"fmt"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// By default, we revert the author's live edits from the last week
+	DefaultAuthorRevertHours = 7 * 24
+)
+
+// AuthorRevertPage is a page whose live edit was made by the author we are reverting.
+type AuthorRevertPage struct {
+	PageID string `json:"pageId"`
+	// The author's live edit
+	RevertedEdit int    `json:"revertedEdit"`
+	RevertedAt   string `json:"revertedAt"`
+	// Last edit made live by someone else. 0 if there isn't one, in which case
+	// the page can't be reverted.
+	ToEdit int `json:"toEdit"`
+
+	// Only set once the page is reverted
+	ChangeLogID int64 `json:"changeLogId,string"`
+}
+
+// LoadAuthorRevertPages loads the pages in the domain whose live edit was created
+// by the author after the given time (in database.TimeLayout).
+func LoadAuthorRevertPages(db *database.DB, authorID string, domainID string, since string) ([]*AuthorRevertPage, error) {
+	revertPages := make([]*AuthorRevertPage, 0)
+	rows := database.NewQuery(`
+		SELECT pi.pageId,pi.currentEdit,p.createdAt,IFNULL((
+				SELECT cl.edit
+				FROM changeLogs AS cl
+				WHERE cl.pageId=pi.pageId AND cl.userId!=?`, authorID).Add(`
+					AND cl.type IN (?,?,?)`, NewEditChangeLog, RevertEditChangeLog, UndeletePageChangeLog).Add(`
+					AND cl.edit!=pi.currentEdit
+				ORDER BY cl.id DESC
+				LIMIT 1
+			),0)
+		FROM pageInfos AS pi
+		JOIN pages AS p
+		ON (p.pageId=pi.pageId AND p.edit=pi.currentEdit)
+		WHERE p.creatorId=? AND p.createdAt>=? AND pi.editDomainId=?`, authorID, since, domainID).Add(`
+			AND`).AddPart(PageInfosFilter(nil)).Add(`
+		ORDER BY p.createdAt DESC`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var page AuthorRevertPage
+		err := rows.Scan(&page.PageID, &page.RevertedEdit, &page.RevertedAt, &page.ToEdit)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		revertPages = append(revertPages, &page)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load pages to revert: %v", err)
+	}
+	return revertPages, nil
+}
@@ -0,0 +1,185 @@
+// bulkEdit.go contains the functions for admin find-and-replace across many pages.
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"zanaduu3/src/config"
+	"zanaduu3/src/database"
+)
+
+const (
+	// Statuses of a bulk edit
+	RunningBulkEditStatus  = "running"
+	FinishedBulkEditStatus = "finished"
+	UndoingBulkEditStatus  = "undoing"
+	UndoneBulkEditStatus   = "undone"
+)
+
+// BulkEdit is a find-and-replace applied to many pages at once.
+type BulkEdit struct {
+	ID          string   `json:"id"`
+	CreatedBy   string   `json:"createdBy"`
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
+	FindText    string   `json:"findText"`
+	ReplaceText string   `json:"replaceText"`
+	IsRegexp    bool     `json:"isRegexp"`
+	DomainID    string   `json:"domainId"`
+	PageIDs     []string `json:"pageIds"`
+	EditSummary string   `json:"editSummary"`
+
+	Status       string `json:"status"`
+	LastPageID   string `json:"lastPageId"`
+	PagesScanned int    `json:"pagesScanned"`
+	PagesChanged int    `json:"pagesChanged"`
+	PagesUndone  int    `json:"pagesUndone"`
+}
+
+// BulkEditPage is a page that was changed by a bulk edit.
+type BulkEditPage struct {
+	BulkEditID   string `json:"bulkEditId"`
+	PageID       string `json:"pageId"`
+	PrevEdit     int    `json:"prevEdit"`
+	NewEdit      int    `json:"newEdit"`
+	UndoEdit     int    `json:"undoEdit"`
+	UndoFailure  string `json:"undoFailure"`
+	Replacements int    `json:"replacements"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+// LoadBulkEditUserID returns the id of the system account that bulk edits are
+// attributed to. Returns an error if the account isn't configured or doesn't exist.
+func LoadBulkEditUserID(db *database.DB) (string, error) {
+	userID := config.XC.Site.BulkEditUserID
+	if !IsIDValid(userID) {
+		return "", fmt.Errorf("site.bulkEditUserId isn't set in config.yaml")
+	}
+	exists, err := database.NewQuery(`
+		SELECT 1
+		FROM users
+		WHERE id=?`, userID).ToStatement(db).QueryRow().Scan(new(int))
+	if err != nil {
+		return "", fmt.Errorf("Couldn't load the bulk edit account: %v", err)
+	} else if !exists {
+		return "", fmt.Errorf("The bulk edit account %s doesn't exist", userID)
+	}
+	return userID, nil
+}
+
+// BulkEditReplacer does the find-and-replace for a bulk edit.
+type BulkEditReplacer struct {
+	findText    string
+	replaceText string
+	exp         *regexp.Regexp
+}
+
+// NewBulkEditReplacer returns a replacer for the given text or regexp.
+func NewBulkEditReplacer(findText string, replaceText string, isRegexp bool) (*BulkEditReplacer, error) {
+	if findText == "" {
+		return nil, fmt.Errorf("Find text can't be empty")
+	}
+	r := &BulkEditReplacer{findText: findText, replaceText: replaceText}
+	if isRegexp {
+		exp, err := regexp.Compile(findText)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't compile regexp: %v", err)
+		}
+		r.exp = exp
+	}
+	return r, nil
+}
+
+// Replace returns the text with all the matches replaced, and the number of replacements made.
+func (r *BulkEditReplacer) Replace(text string) (string, int) {
+	if r.exp == nil {
+		count := strings.Count(text, r.findText)
+		if count <= 0 {
+			return text, 0
+		}
+		return strings.Replace(text, r.findText, r.replaceText, -1), count
+	}
+
+	matches := r.exp.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) <= 0 {
+		return text, 0
+	}
+	result := make([]byte, 0, len(text))
+	lastIndex := 0
+	for _, match := range matches {
+		result = append(result, text[lastIndex:match[0]]...)
+		result = r.exp.ExpandString(result, r.replaceText, text, match)
+		lastIndex = match[1]
+	}
+	result = append(result, text[lastIndex:]...)
+	return string(result), len(matches)
+}
+
+// NewBulkEditFilter returns the condition on pageInfos (aliased as pi) for the
+// pages the bulk edit should look at.
+func NewBulkEditFilter(domainID string, pageIDs []string) *database.QueryPart {
+	q := database.NewQuery(`(pi.type!=?`, CommentPageType)
+	if IsIntIDValid(domainID) {
+		q.Add(`AND pi.editDomainId=?`, domainID)
+	}
+	if len(pageIDs) > 0 {
+		q.Add(`AND pi.pageId IN`).AddArgsGroupStr(pageIDs)
+	}
+	return q.Add(`)`)
+}
+
+// LoadBulkEdit loads the bulk edit with the given id. Returns nil if there is no such bulk edit.
+func LoadBulkEdit(db *database.DB, id string) (*BulkEdit, error) {
+	var bulkEdit *BulkEdit
+	rows := database.NewQuery(`
+		SELECT id,createdBy,createdAt,updatedAt,findText,replaceText,isRegexp,domainId,pageIds,
+			editSummary,status,lastPageId,pagesScanned,pagesChanged,pagesUndone
+		FROM bulkEdits
+		WHERE id=?`, id).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var pageIDs string
+		bulkEdit = &BulkEdit{}
+		err := rows.Scan(&bulkEdit.ID, &bulkEdit.CreatedBy, &bulkEdit.CreatedAt, &bulkEdit.UpdatedAt,
+			&bulkEdit.FindText, &bulkEdit.ReplaceText, &bulkEdit.IsRegexp, &bulkEdit.DomainID, &pageIDs,
+			&bulkEdit.EditSummary, &bulkEdit.Status, &bulkEdit.LastPageID, &bulkEdit.PagesScanned,
+			&bulkEdit.PagesChanged, &bulkEdit.PagesUndone)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		bulkEdit.PageIDs = make([]string, 0)
+		if pageIDs != "" {
+			bulkEdit.PageIDs = strings.Split(pageIDs, ",")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load bulk edit: %v", err)
+	}
+	return bulkEdit, nil
+}
+
+// LoadBulkEditPages loads the pages changed by the given bulk edit.
+func LoadBulkEditPages(db *database.DB, bulkEditID string, queryPart *database.QueryPart) ([]*BulkEditPage, error) {
+	bulkEditPages := make([]*BulkEditPage, 0)
+	rows := database.NewQuery(`
+		SELECT bulkEditId,pageId,prevEdit,newEdit,undoEdit,undoFailure,replacements,createdAt
+		FROM bulkEditPages
+		WHERE bulkEditId=?`, bulkEditID).AddPart(queryPart).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var bulkEditPage BulkEditPage
+		err := rows.Scan(&bulkEditPage.BulkEditID, &bulkEditPage.PageID, &bulkEditPage.PrevEdit,
+			&bulkEditPage.NewEdit, &bulkEditPage.UndoEdit, &bulkEditPage.UndoFailure,
+			&bulkEditPage.Replacements, &bulkEditPage.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		bulkEditPages = append(bulkEditPages, &bulkEditPage)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load bulk edit pages: %v", err)
+	}
+	return bulkEditPages, nil
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"testing"
+)
+
+// Make sure literal and regexp replacements work and are counted.
+func TestBulkEditReplacer(t *testing.T) {
+	r, err := NewBulkEditReplacer("a.b", "X", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if text, count := r.Replace("a.b aab a.b"); text != "X aab X" || count != 2 {
+		t.Errorf("Unexpected literal replace: %q, %d", text, count)
+	}
+	if text, count := r.Replace("nothing here"); text != "nothing here" || count != 0 {
+		t.Errorf("Unexpected literal replace: %q, %d", text, count)
+	}
+
+	r, err = NewBulkEditReplacer(`\[(\w+)\]\(http://old\.com/(\w+)\)`, "[$1](https://new.com/${2}x)", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	text, count := r.Replace("See [one](http://old.com/a) and [two](http://old.com/b).")
+	if text != "See [one](https://new.com/ax) and [two](https://new.com/bx)." || count != 2 {
+		t.Errorf("Unexpected regexp replace: %q, %d", text, count)
+	}
+
+	if _, err := NewBulkEditReplacer("(", "", true); err == nil {
+		t.Errorf("Expected an error for an invalid regexp")
+	}
+	if _, err := NewBulkEditReplacer("", "x", false); err == nil {
+		t.Errorf("Expected an error for empty find text")
+	}
+}
@@ -66,6 +66,18 @@ func PageInfosTableWithOptions(u *CurrentUser, options *PageInfosOptions) *datab
 	return q
 }
 
+// GetVisibleDomainIDs returns the ids of all the domains whose pages the user can see,
+// including the public domain "0".
+func GetVisibleDomainIDs(u *CurrentUser) []string {
+	allowedDomainIDs := []string{"0"}
+	for domainID := range u.DomainMembershipMap {
+		if CanUserSeeDomain(u, domainID) {
+			allowedDomainIDs = append(allowedDomainIDs, domainID)
+		}
+	}
+	return allowedDomainIDs
+}
+
 func PageInfosFilter(u *CurrentUser) *database.QueryPart {
 	return PageInfosFilterWithOptions(u, &PageInfosOptions{})
 }
@@ -82,13 +94,7 @@ func PageInfosFilterWithOptions(u *CurrentUser, options *PageInfosOptions) *data
 	}
 	q := database.NewQuery(`(TRUE`)
 	if u != nil {
-		allowedDomainIDs := []string{"0"}
-		for domainID := range u.DomainMembershipMap {
-			if CanUserSeeDomain(u, domainID) {
-				allowedDomainIDs = append(allowedDomainIDs, domainID)
-			}
-		}
-		q.Add(`AND ` + prefix + `seeDomainId IN`).AddArgsGroupStr(allowedDomainIDs)
+		q.Add(`AND ` + prefix + `seeDomainId IN`).AddArgsGroupStr(GetVisibleDomainIDs(u))
 	}
 	if !options.Unpublished {
 		q.Add(`AND ` + prefix + `currentEdit > 0`)
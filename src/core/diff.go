@@ -0,0 +1,268 @@
+// diff.go computes line and word level differences between two texts.
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// Types of diff operations
+	EqualDiffType  = "equal"
+	InsertDiffType = "insert"
+	DeleteDiffType = "delete"
+	// Line that was deleted and replaced with another one
+	ChangeDiffType = "change"
+
+	// If the part of the texts that differs is bigger than this (old tokens * new
+	// tokens), we don't bother computing a minimal diff
+	maxDiffMatrixSize = 4000000
+)
+
+var (
+	// Splits text into words, runs of whitespace, and punctuation
+	diffWordRegexp = regexp.MustCompile(`[\pL\pN_]+|\s+|[^\pL\pN_\s]`)
+)
+
+// DiffOp is a run of text which was kept, inserted, or deleted.
+type DiffOp struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// DiffLine describes what happened to one line of text.
+type DiffLine struct {
+	Type string `json:"type"`
+	// 1-based line numbers in the old and new text; 0 if the line doesn't exist there
+	OldNum  int    `json:"oldNum"`
+	NewNum  int    `json:"newNum"`
+	OldText string `json:"oldText"`
+	NewText string `json:"newText"`
+	// For changed lines, word level diff between the old and the new line
+	Words []*DiffOp `json:"words,omitempty"`
+}
+
+// TextDiff is a line level diff between two texts.
+type TextDiff struct {
+	Lines []*DiffLine `json:"lines"`
+	// Number of lines added and removed (changed lines count as both)
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+// diffToken is one element of the shortest edit script.
+type diffToken struct {
+	diffType string
+	// Index into the old and new token lists
+	oldIndex int
+	newIndex int
+}
+
+// diffTokens computes the edit script which turns a into b, using the longest
+// common subsequence.
+func diffTokens(a, b []string) []*diffToken {
+	result := make([]*diffToken, 0, len(a)+len(b))
+
+	// Common prefix and suffix don't need to go through the expensive part
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for n := 0; n < prefix; n++ {
+		result = append(result, &diffToken{diffType: EqualDiffType, oldIndex: n, newIndex: n})
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffMatrixSize {
+		// Too big, so just replace everything
+		for n := range midA {
+			result = append(result, &diffToken{diffType: DeleteDiffType, oldIndex: prefix + n, newIndex: -1})
+		}
+		for n := range midB {
+			result = append(result, &diffToken{diffType: InsertDiffType, oldIndex: -1, newIndex: prefix + n})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of midA[i:] and midB[j:]
+		lcs := make([][]int, len(midA)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(midA) || j < len(midB) {
+			if i < len(midA) && j < len(midB) && midA[i] == midB[j] {
+				result = append(result, &diffToken{diffType: EqualDiffType, oldIndex: prefix + i, newIndex: prefix + j})
+				i++
+				j++
+			} else if j >= len(midB) || (i < len(midA) && lcs[i+1][j] >= lcs[i][j+1]) {
+				result = append(result, &diffToken{diffType: DeleteDiffType, oldIndex: prefix + i, newIndex: -1})
+				i++
+			} else {
+				result = append(result, &diffToken{diffType: InsertDiffType, oldIndex: -1, newIndex: prefix + j})
+				j++
+			}
+		}
+	}
+
+	for n := suffix; n > 0; n-- {
+		result = append(result, &diffToken{diffType: EqualDiffType, oldIndex: len(a) - n, newIndex: len(b) - n})
+	}
+	return result
+}
+
+// DiffWords returns the word level diff between the two texts. Consecutive
+// operations of the same type are merged.
+func DiffWords(oldText, newText string) []*DiffOp {
+	a := diffWordRegexp.FindAllString(oldText, -1)
+	b := diffWordRegexp.FindAllString(newText, -1)
+	ops := make([]*DiffOp, 0)
+	for _, token := range diffTokens(a, b) {
+		text := ""
+		if token.diffType == InsertDiffType {
+			text = b[token.newIndex]
+		} else {
+			text = a[token.oldIndex]
+		}
+		if len(ops) > 0 && ops[len(ops)-1].Type == token.diffType {
+			ops[len(ops)-1].Text += text
+		} else {
+			ops = append(ops, &DiffOp{Type: token.diffType, Text: text})
+		}
+	}
+	return ops
+}
+
+// splitDiffLines splits the text into lines. Empty text has no lines.
+func splitDiffLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+}
+
+// DiffText returns the line level diff between the two texts. Deleted lines
+// which were immediately replaced by inserted lines are paired up into changed
+// lines, which also have a word level diff.
+func DiffText(oldText, newText string) *TextDiff {
+	a := splitDiffLines(oldText)
+	b := splitDiffLines(newText)
+	diff := &TextDiff{Lines: make([]*DiffLine, 0)}
+
+	deleted := make([]int, 0)
+	inserted := make([]int, 0)
+	// flush converts the pending deleted and inserted lines into DiffLines
+	flush := func() {
+		for n := 0; n < len(deleted) || n < len(inserted); n++ {
+			line := &DiffLine{}
+			if n < len(deleted) && n < len(inserted) {
+				line.Type = ChangeDiffType
+				line.OldNum, line.OldText = deleted[n]+1, a[deleted[n]]
+				line.NewNum, line.NewText = inserted[n]+1, b[inserted[n]]
+				line.Words = DiffWords(line.OldText, line.NewText)
+				diff.Deletions++
+				diff.Additions++
+			} else if n < len(deleted) {
+				line.Type = DeleteDiffType
+				line.OldNum, line.OldText = deleted[n]+1, a[deleted[n]]
+				diff.Deletions++
+			} else {
+				line.Type = InsertDiffType
+				line.NewNum, line.NewText = inserted[n]+1, b[inserted[n]]
+				diff.Additions++
+			}
+			diff.Lines = append(diff.Lines, line)
+		}
+		deleted = deleted[:0]
+		inserted = inserted[:0]
+	}
+
+	for _, token := range diffTokens(a, b) {
+		switch token.diffType {
+		case DeleteDiffType:
+			deleted = append(deleted, token.oldIndex)
+		case InsertDiffType:
+			inserted = append(inserted, token.newIndex)
+		default:
+			flush()
+			diff.Lines = append(diff.Lines, &DiffLine{
+				Type:    EqualDiffType,
+				OldNum:  token.oldIndex + 1,
+				NewNum:  token.newIndex + 1,
+				OldText: a[token.oldIndex],
+				NewText: b[token.newIndex],
+			})
+		}
+	}
+	flush()
+	return diff
+}
+
+// DiffHunk is a run of consecutive changed lines.
+type DiffHunk struct {
+	Index int         `json:"index"`
+	Lines []*DiffLine `json:"lines"`
+}
+
+// GetHunks groups the changed lines of the diff into hunks.
+func (diff *TextDiff) GetHunks() []*DiffHunk {
+	hunks := make([]*DiffHunk, 0)
+	var hunk *DiffHunk
+	for _, line := range diff.Lines {
+		if line.Type == EqualDiffType {
+			hunk = nil
+			continue
+		}
+		if hunk == nil {
+			hunk = &DiffHunk{Index: len(hunks), Lines: make([]*DiffLine, 0)}
+			hunks = append(hunks, hunk)
+		}
+		hunk.Lines = append(hunk.Lines, line)
+	}
+	return hunks
+}
+
+// ApplyDiffHunks returns the old text with only the given hunks of the diff
+// between the old and the new text applied.
+func ApplyDiffHunks(oldText, newText string, hunkIndexes []int) string {
+	accepted := make(map[int]bool)
+	for _, index := range hunkIndexes {
+		accepted[index] = true
+	}
+	lines := make([]string, 0)
+	hunkIndex := -1
+	inHunk := false
+	for _, line := range DiffText(oldText, newText).Lines {
+		if line.Type == EqualDiffType {
+			inHunk = false
+			lines = append(lines, line.OldText)
+			continue
+		}
+		if !inHunk {
+			inHunk = true
+			hunkIndex++
+		}
+		if accepted[hunkIndex] {
+			if line.Type != DeleteDiffType {
+				lines = append(lines, line.NewText)
+			}
+		} else if line.Type != InsertDiffType {
+			lines = append(lines, line.OldText)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
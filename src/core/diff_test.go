@@ -0,0 +1,72 @@
+package core
+
+import (
+	"testing"
+)
+
+// Make sure unchanged, changed, added and removed lines are detected.
+func TestDiffText(t *testing.T) {
+	diff := DiffText("one\ntwo\nthree\nfour", "one\n2\nthree\nfour\nfive")
+	expectedTypes := []string{EqualDiffType, ChangeDiffType, EqualDiffType, EqualDiffType, InsertDiffType}
+	if len(diff.Lines) != len(expectedTypes) {
+		t.Fatalf("Unexpected number of lines: %d", len(diff.Lines))
+	}
+	for n, line := range diff.Lines {
+		if line.Type != expectedTypes[n] {
+			t.Errorf("Line %d has type %s, expected %s", n, line.Type, expectedTypes[n])
+		}
+	}
+	if diff.Additions != 2 || diff.Deletions != 1 {
+		t.Errorf("Unexpected counts: +%d -%d", diff.Additions, diff.Deletions)
+	}
+	if last := diff.Lines[4]; last.OldNum != 0 || last.NewNum != 5 || last.NewText != "five" {
+		t.Errorf("Unexpected inserted line: %+v", last)
+	}
+
+	diff = DiffText("", "")
+	if len(diff.Lines) != 0 {
+		t.Errorf("Expected no lines for empty texts")
+	}
+}
+
+// Make sure the word diff reconstructs both texts.
+func TestDiffWords(t *testing.T) {
+	oldText := "Bayes' rule is useful."
+	newText := "Bayes' theorem is very useful!"
+	var rebuiltOld, rebuiltNew string
+	for _, op := range DiffWords(oldText, newText) {
+		if op.Type != InsertDiffType {
+			rebuiltOld += op.Text
+		}
+		if op.Type != DeleteDiffType {
+			rebuiltNew += op.Text
+		}
+	}
+	if rebuiltOld != oldText || rebuiltNew != newText {
+		t.Errorf("Couldn't rebuild texts: %q, %q", rebuiltOld, rebuiltNew)
+	}
+}
+
+// Make sure only the accepted hunks are applied.
+func TestApplyDiffHunks(t *testing.T) {
+	oldText := "one\ntwo\nthree\nfour\nfive"
+	newText := "one\n2\nthree\nfive\nsix"
+	hunks := DiffText(oldText, newText).GetHunks()
+	if len(hunks) != 3 {
+		t.Fatalf("Expected 3 hunks, got %d", len(hunks))
+	}
+	tests := []struct {
+		hunks    []int
+		expected string
+	}{
+		{hunks: []int{}, expected: oldText},
+		{hunks: []int{0, 1, 2}, expected: newText},
+		{hunks: []int{0}, expected: "one\n2\nthree\nfour\nfive"},
+		{hunks: []int{1, 2}, expected: "one\ntwo\nthree\nfive\nsix"},
+	}
+	for _, test := range tests {
+		if actual := ApplyDiffHunks(oldText, newText, test.hunks); actual != test.expected {
+			t.Errorf("Hunks %v: got %q, expected %q", test.hunks, actual, test.expected)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// editCompaction.go contains the retention policy for a page's edit history.
+package core
+
+import (
+	"fmt"
+	"sort"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// By default, minor edits older than this many days can be collapsed
+	DefaultMinorEditRetentionDays = 30
+)
+
+// CompactableEdit is the part of an edit that matters for deciding whether it
+// can be removed.
+type CompactableEdit struct {
+	Edit        int
+	PrevEdit    int
+	CreatorID   string
+	CreatedAt   string
+	IsLiveEdit  bool
+	IsMinorEdit bool
+	IsAutosave  bool
+	IsSnapshot  bool
+	// Number of bytes taken by the edit's text fields
+	Size int
+}
+
+// compactableEditList implements sort.Interface, ordering edits by edit number.
+type compactableEditList []*CompactableEdit
+
+func (l compactableEditList) Len() int           { return len(l) }
+func (l compactableEditList) Less(i, j int) bool { return l[i].Edit < l[j].Edit }
+func (l compactableEditList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// EditCompactionPlan describes which edits of a page can be removed.
+type EditCompactionPlan struct {
+	// Autosaves whose author has since saved a newer edit
+	StaleAutosaves []int
+	// Old minor edits, mapped to the later edit of the same run that replaces them
+	CollapsedEdits map[int]int
+	// Remaining edits whose prevEdit is being removed, mapped to their new prevEdit
+	NewPrevEdits map[int]int
+	// Number of bytes taken by the removed edits
+	BytesReclaimed int
+}
+
+// RemovedEdits returns all the edits the plan removes.
+func (plan *EditCompactionPlan) RemovedEdits() []int {
+	edits := append([]int{}, plan.StaleAutosaves...)
+	for edit := range plan.CollapsedEdits {
+		edits = append(edits, edit)
+	}
+	sort.Ints(edits)
+	return edits
+}
+
+// PlanEditCompaction decides which of the page's edits can be removed. Edits in
+// referencedEdits, the live edit and the highest numbered edit are never removed.
+// Minor edits created before minorEditCutoff (in database.TimeLayout) by the same
+// user in a row are collapsed into the last edit of that run.
+func PlanEditCompaction(edits []*CompactableEdit, referencedEdits map[int]bool, minorEditCutoff string) *EditCompactionPlan {
+	plan := &EditCompactionPlan{
+		StaleAutosaves: make([]int, 0),
+		CollapsedEdits: make(map[int]int),
+		NewPrevEdits:   make(map[int]int),
+	}
+	if len(edits) <= 1 {
+		return plan
+	}
+	sorted := make(compactableEditList, len(edits))
+	copy(sorted, edits)
+	sort.Sort(sorted)
+	maxEdit := sorted[len(sorted)-1].Edit
+	isProtected := func(e *CompactableEdit) bool {
+		return referencedEdits[e.Edit] || e.IsLiveEdit || e.Edit == maxEdit
+	}
+
+	// Autosaves are stale once their author saved something newer
+	removed := make(map[int]*CompactableEdit)
+	for _, autosave := range sorted {
+		if !autosave.IsAutosave || isProtected(autosave) {
+			continue
+		}
+		for _, e := range sorted {
+			if !e.IsAutosave && e.CreatorID == autosave.CreatorID && e.CreatedAt > autosave.CreatedAt {
+				plan.StaleAutosaves = append(plan.StaleAutosaves, autosave.Edit)
+				removed[autosave.Edit] = autosave
+				break
+			}
+		}
+	}
+
+	// Collapse runs of old minor edits by the same user
+	run := make([]*CompactableEdit, 0)
+	flushRun := func() {
+		for n := 0; n < len(run)-1; n++ {
+			plan.CollapsedEdits[run[n].Edit] = run[len(run)-1].Edit
+			removed[run[n].Edit] = run[n]
+		}
+		run = run[:0]
+	}
+	for _, e := range sorted {
+		if e.IsAutosave || e.IsSnapshot {
+			continue
+		}
+		isCollapsible := e.IsMinorEdit && e.CreatedAt < minorEditCutoff
+		if !isCollapsible || (len(run) > 0 && run[0].CreatorID != e.CreatorID) {
+			flushRun()
+		}
+		if isCollapsible {
+			run = append(run, e)
+			// A protected edit can only be the last edit of a run
+			if isProtected(e) {
+				flushRun()
+			}
+		}
+	}
+	flushRun()
+
+	// Fix up the prevEdit chain of the remaining edits
+	for _, e := range sorted {
+		if _, ok := removed[e.Edit]; ok {
+			continue
+		}
+		prevEdit := e.PrevEdit
+		for steps := 0; steps < len(sorted); steps++ {
+			removedEdit, ok := removed[prevEdit]
+			if !ok {
+				break
+			}
+			prevEdit = removedEdit.PrevEdit
+		}
+		if prevEdit != e.PrevEdit {
+			plan.NewPrevEdits[e.Edit] = prevEdit
+		}
+	}
+	for _, e := range removed {
+		plan.BytesReclaimed += e.Size
+	}
+	return plan
+}
+
+// EditCompaction is a run of the edit history compaction.
+type EditCompaction struct {
+	ID        string `json:"id"`
+	CreatedBy string `json:"createdBy"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+	// If true, nothing is deleted, we only report what would be
+	IsDryRun         bool   `json:"isDryRun"`
+	MinorEditCutoff  string `json:"minorEditCutoff"`
+	LastPageID       string `json:"lastPageId"`
+	PagesProcessed   int    `json:"pagesProcessed"`
+	AutosavesDeleted int    `json:"autosavesDeleted"`
+	EditsCollapsed   int    `json:"editsCollapsed"`
+	BytesReclaimed   int64  `json:"bytesReclaimed"`
+	IsFinished       bool   `json:"isFinished"`
+}
+
+// LoadEditCompaction loads the compaction with the given id. Returns nil if there is no such compaction.
+func LoadEditCompaction(db *database.DB, id string) (*EditCompaction, error) {
+	var compaction *EditCompaction
+	rows := database.NewQuery(`
+		SELECT id,createdBy,createdAt,updatedAt,isDryRun,minorEditCutoff,lastPageId,
+			pagesProcessed,autosavesDeleted,editsCollapsed,bytesReclaimed,isFinished
+		FROM editCompactions
+		WHERE id=?`, id).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		compaction = &EditCompaction{}
+		err := rows.Scan(&compaction.ID, &compaction.CreatedBy, &compaction.CreatedAt, &compaction.UpdatedAt,
+			&compaction.IsDryRun, &compaction.MinorEditCutoff, &compaction.LastPageID, &compaction.PagesProcessed,
+			&compaction.AutosavesDeleted, &compaction.EditsCollapsed, &compaction.BytesReclaimed, &compaction.IsFinished)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load edit compaction: %v", err)
+	}
+	return compaction, nil
+}
+
+// LoadLatestEditCompactionID returns the id of the most recently started compaction, or "" if there are none.
+func LoadLatestEditCompactionID(db *database.DB) (string, error) {
+	var id string
+	_, err := database.NewQuery(`
+		SELECT id
+		FROM editCompactions
+		ORDER BY createdAt DESC, id DESC
+		LIMIT 1`).ToStatement(db).QueryRow().Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't load latest edit compaction: %v", err)
+	}
+	return id, nil
+}
+
+// LoadCompactableEdits loads all the edits of the given page.
+func LoadCompactableEdits(tx *database.Tx, pageID string) ([]*CompactableEdit, error) {
+	edits := make([]*CompactableEdit, 0)
+	rows := database.NewQuery(`
+		SELECT edit,prevEdit,creatorId,createdAt,isLiveEdit,isMinorEdit,isAutosave,isSnapshot,
+			LENGTH(title)+LENGTH(clickbait)+LENGTH(text)+LENGTH(metaText)+LENGTH(anchorContext)+
+			LENGTH(anchorText)+LENGTH(editSummary)+LENGTH(snapshotText)
+		FROM pages
+		WHERE pageId=?`, pageID).ToTxStatement(tx).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var e CompactableEdit
+		err := rows.Scan(&e.Edit, &e.PrevEdit, &e.CreatorID, &e.CreatedAt, &e.IsLiveEdit,
+			&e.IsMinorEdit, &e.IsAutosave, &e.IsSnapshot, &e.Size)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		edits = append(edits, &e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load edits: %v", err)
+	}
+	return edits, nil
+}
+
+// GetChangeLogReferencedEdits returns the edits the given change logs point to,
+// and which therefore have to be kept. A newEdit change log doesn't count, since
+// it's moved to the edit that replaces the one it points to.
+func GetChangeLogReferencedEdits(changeLogs []*ChangeLog) map[int]bool {
+	referencedEdits := make(map[int]bool)
+	for _, changeLog := range changeLogs {
+		if changeLog.Type != NewEditChangeLog {
+			referencedEdits[changeLog.Edit] = true
+		}
+	}
+	return referencedEdits
+}
+
+// LoadReferencedEdits returns the edits of the given page that other tables point
+// to, and which therefore have to be kept.
+func LoadReferencedEdits(tx *database.Tx, pageID string) (map[int]bool, error) {
+	changeLogs := make([]*ChangeLog, 0)
+	rows := database.NewQuery(`
+		SELECT edit,type
+		FROM changeLogs
+		WHERE pageId=?`, pageID).ToTxStatement(tx).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var changeLog ChangeLog
+		if err := rows.Scan(&changeLog.Edit, &changeLog.Type); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		changeLogs = append(changeLogs, &changeLog)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load change logs: %v", err)
+	}
+	referencedEdits := GetChangeLogReferencedEdits(changeLogs)
+
+	rows = database.NewQuery(`
+		SELECT edit FROM marks WHERE pageId=?`, pageID).Add(`
+		UNION SELECT edit FROM userPageObjectPairs WHERE pageId=?`, pageID).Add(`
+		UNION SELECT currentEdit FROM pageInfos WHERE pageId=?`, pageID).Add(`
+		UNION SELECT baseEdit FROM editSessions WHERE pageId=?`, pageID).Add(`
+		UNION SELECT resultEdit FROM editProposalReviews WHERE pageId=?`, pageID).Add(`
+		UNION SELECT edit FROM scheduledPublishes WHERE pageId=?`, pageID).Add(`
+		UNION SELECT baseEdit FROM scheduledPublishes WHERE pageId=?`, pageID).Add(`
+		UNION SELECT prevEdit FROM bulkEditPages WHERE pageId=?`, pageID).Add(`
+		UNION SELECT newEdit FROM bulkEditPages WHERE pageId=?`, pageID).Add(`
+		UNION SELECT undoEdit FROM bulkEditPages WHERE pageId=?`, pageID).ToTxStatement(tx).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var edit int
+		if err := rows.Scan(&edit); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		referencedEdits[edit] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load referenced edits: %v", err)
+	}
+	return referencedEdits, nil
+}
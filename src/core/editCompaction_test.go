@@ -0,0 +1,94 @@
+package core
+
+import (
+	"testing"
+)
+
+// Make sure stale autosaves are removed, but referenced and live edits are kept.
+func TestPlanEditCompactionAutosaves(t *testing.T) {
+	edits := []*CompactableEdit{
+		{Edit: 1, CreatorID: "a", CreatedAt: "2016-01-01 00:00:00", Size: 10},
+		{Edit: 2, PrevEdit: 1, CreatorID: "b", CreatedAt: "2016-01-02 00:00:00", IsAutosave: true, Size: 20},
+		{Edit: 3, PrevEdit: 1, CreatorID: "c", CreatedAt: "2016-01-03 00:00:00", IsAutosave: true, Size: 30},
+		{Edit: 4, PrevEdit: 1, CreatorID: "b", CreatedAt: "2016-01-04 00:00:00", IsLiveEdit: true, Size: 40},
+		{Edit: 5, PrevEdit: 4, CreatorID: "a", CreatedAt: "2016-01-05 00:00:00", IsAutosave: true, Size: 50},
+	}
+	plan := PlanEditCompaction(edits, map[int]bool{}, "2000-01-01 00:00:00")
+	// 3 is by a user who never saved anything else, 5 is the max edit
+	if len(plan.StaleAutosaves) != 1 || plan.StaleAutosaves[0] != 2 {
+		t.Errorf("Unexpected stale autosaves: %v", plan.StaleAutosaves)
+	}
+	if plan.BytesReclaimed != 20 {
+		t.Errorf("Unexpected bytes reclaimed: %d", plan.BytesReclaimed)
+	}
+
+	plan = PlanEditCompaction(edits, map[int]bool{2: true}, "2000-01-01 00:00:00")
+	if len(plan.StaleAutosaves) != 0 {
+		t.Errorf("Referenced autosave was removed: %v", plan.StaleAutosaves)
+	}
+}
+
+// Make sure runs of old minor edits by the same user are collapsed into their last edit.
+func TestPlanEditCompactionMinorEdits(t *testing.T) {
+	edits := []*CompactableEdit{
+		{Edit: 1, CreatorID: "a", CreatedAt: "2016-01-01 00:00:00"},
+		{Edit: 2, PrevEdit: 1, CreatorID: "a", CreatedAt: "2016-01-02 00:00:00", IsMinorEdit: true, Size: 1},
+		{Edit: 3, PrevEdit: 2, CreatorID: "a", CreatedAt: "2016-01-03 00:00:00", IsMinorEdit: true, Size: 2},
+		{Edit: 4, PrevEdit: 3, CreatorID: "a", CreatedAt: "2016-01-04 00:00:00", IsMinorEdit: true, Size: 4},
+		{Edit: 5, PrevEdit: 4, CreatorID: "b", CreatedAt: "2016-01-05 00:00:00", IsMinorEdit: true, Size: 8},
+		{Edit: 6, PrevEdit: 5, CreatorID: "b", CreatedAt: "2016-01-06 00:00:00", IsMinorEdit: true, Size: 16},
+		{Edit: 7, PrevEdit: 6, CreatorID: "b", CreatedAt: "2016-01-07 00:00:00", IsMinorEdit: true, Size: 32},
+		{Edit: 8, PrevEdit: 7, CreatorID: "b", CreatedAt: "2016-03-01 00:00:00", IsMinorEdit: true, IsLiveEdit: true},
+	}
+	// Edit 6 is referenced, so it has to end its run
+	plan := PlanEditCompaction(edits, map[int]bool{6: true}, "2016-02-01 00:00:00")
+	expected := map[int]int{2: 4, 3: 4, 5: 6}
+	if len(plan.CollapsedEdits) != len(expected) {
+		t.Fatalf("Unexpected collapsed edits: %v", plan.CollapsedEdits)
+	}
+	for edit, into := range expected {
+		if plan.CollapsedEdits[edit] != into {
+			t.Errorf("Edit %d collapsed into %d, expected %d", edit, plan.CollapsedEdits[edit], into)
+		}
+	}
+	if plan.NewPrevEdits[4] != 1 || plan.NewPrevEdits[6] != 4 || len(plan.NewPrevEdits) != 2 {
+		t.Errorf("Unexpected new prev edits: %v", plan.NewPrevEdits)
+	}
+	if plan.BytesReclaimed != 11 {
+		t.Errorf("Unexpected bytes reclaimed: %d", plan.BytesReclaimed)
+	}
+	if removed := plan.RemovedEdits(); len(removed) != 3 || removed[0] != 2 || removed[2] != 5 {
+		t.Errorf("Unexpected removed edits: %v", removed)
+	}
+}
+
+// Make sure edits with only a newEdit change log can still be collapsed, but
+// edits that other change logs point to are kept.
+func TestPlanEditCompactionChangeLogReferences(t *testing.T) {
+	edits := []*CompactableEdit{
+		{Edit: 1, CreatorID: "a", CreatedAt: "2016-01-01 00:00:00"},
+		{Edit: 2, PrevEdit: 1, CreatorID: "a", CreatedAt: "2016-01-02 00:00:00", IsMinorEdit: true},
+		{Edit: 3, PrevEdit: 2, CreatorID: "a", CreatedAt: "2016-01-03 00:00:00", IsMinorEdit: true},
+		{Edit: 4, PrevEdit: 3, CreatorID: "a", CreatedAt: "2016-01-04 00:00:00", IsMinorEdit: true},
+		{Edit: 5, PrevEdit: 4, CreatorID: "a", CreatedAt: "2016-01-05 00:00:00", IsMinorEdit: true, IsLiveEdit: true},
+	}
+	// Every published edit has a newEdit change log
+	changeLogs := make([]*ChangeLog, 0)
+	for _, e := range edits {
+		changeLogs = append(changeLogs, &ChangeLog{Edit: e.Edit, Type: NewEditChangeLog})
+	}
+	// A tag was added while edit 3 was live
+	changeLogs = append(changeLogs, &ChangeLog{Edit: 3, Type: NewTagChangeLog})
+
+	referencedEdits := GetChangeLogReferencedEdits(changeLogs)
+	plan := PlanEditCompaction(edits, referencedEdits, "2016-02-01 00:00:00")
+	expected := map[int]int{2: 3, 4: 5}
+	if len(plan.CollapsedEdits) != len(expected) {
+		t.Fatalf("Unexpected collapsed edits: %v", plan.CollapsedEdits)
+	}
+	for edit, into := range expected {
+		if plan.CollapsedEdits[edit] != into {
+			t.Errorf("Edit %d collapsed into %d, expected %d", edit, plan.CollapsedEdits[edit], into)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// editProposalReview.go contains helpers for reviewing edit proposals.
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// Actions reviewers can take on an edit proposal
+	ApproveReviewAction        = "approve"
+	DismissReviewAction        = "dismiss"
+	RequestChangesReviewAction = "requestChanges"
+	RejectReviewAction         = "reject"
+	PartialAcceptReviewAction  = "partialAccept"
+)
+
+// EditProposalReview is an action a reviewer took on an edit proposal.
+type EditProposalReview struct {
+	ID          string `json:"id"`
+	ChangeLogID string `json:"changeLogId"`
+	PageID      string `json:"pageId"`
+	ReviewerID  string `json:"reviewerId"`
+	Action      string `json:"action"`
+	Reason      string `json:"reason"`
+	// For partial accepts, indexes of the accepted diff hunks
+	AcceptedHunks []int  `json:"acceptedHunks"`
+	ResultEdit    int    `json:"resultEdit"`
+	CreatedAt     string `json:"createdAt"`
+}
+
+// EditProposalComment is a comment on a line of the edit proposal's diff.
+type EditProposalComment struct {
+	ID          string `json:"id"`
+	ChangeLogID string `json:"changeLogId"`
+	PageID      string `json:"pageId"`
+	UserID      string `json:"userId"`
+	OldLineNum  int    `json:"oldLineNum"`
+	NewLineNum  int    `json:"newLineNum"`
+	Text        string `json:"text"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// PendingEditProposal is an edit proposal which hasn't been accepted or rejected yet.
+type PendingEditProposal struct {
+	ChangeLog *ChangeLog `json:"changeLog"`
+	// Most recent review, if any (e.g. if changes were requested)
+	LastReview *EditProposalReview `json:"lastReview"`
+	// Number of line comments on the proposal
+	CommentCount int `json:"commentCount"`
+}
+
+// IsValidReviewAction returns true iff reviewers can submit this action directly.
+func IsValidReviewAction(action string) bool {
+	return action == RequestChangesReviewAction || action == RejectReviewAction || action == PartialAcceptReviewAction
+}
+
+// EncodeHunkIndexes converts the list of hunk indexes into a string for the database.
+func EncodeHunkIndexes(indexes []int) string {
+	strs := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		strs = append(strs, fmt.Sprintf("%d", index))
+	}
+	return strings.Join(strs, ",")
+}
+
+// decodeHunkIndexes converts the database string back into a list of hunk indexes.
+func decodeHunkIndexes(str string) []int {
+	indexes := make([]int, 0)
+	for _, s := range strings.Split(str, ",") {
+		if index, err := strconv.Atoi(s); err == nil {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// LoadEditProposal loads the change log of the given pending edit proposal.
+// Returns nil if there is no such pending proposal.
+func LoadEditProposal(db *database.DB, changeLogID string) (*ChangeLog, error) {
+	changeLogs, err := LoadChangeLogsByIDs(db, []string{changeLogID}, NewEditProposalChangeLog)
+	if err != nil {
+		return nil, err
+	}
+	return changeLogs[changeLogID], nil
+}
+
+// AddEditProposalReview records the reviewer's action.
+func AddEditProposalReview(tx *database.Tx, review *EditProposalReview) error {
+	hashmap := make(database.InsertMap)
+	hashmap["changeLogId"] = review.ChangeLogID
+	hashmap["pageId"] = review.PageID
+	hashmap["reviewerId"] = review.ReviewerID
+	hashmap["action"] = review.Action
+	hashmap["reason"] = review.Reason
+	hashmap["acceptedHunks"] = EncodeHunkIndexes(review.AcceptedHunks)
+	hashmap["resultEdit"] = review.ResultEdit
+	hashmap["createdAt"] = database.Now()
+	statement := tx.DB.NewInsertStatement("editProposalReviews", hashmap).WithTx(tx)
+	if _, err := statement.Exec(); err != nil {
+		return fmt.Errorf("Couldn't insert review: %v", err)
+	}
+	return nil
+}
+
+// LoadEditProposalReviews loads all the reviews for the given proposals.
+// Returns a map: change log id -> reviews ordered by creation time.
+func LoadEditProposalReviews(db *database.DB, changeLogIDs []string) (map[string][]*EditProposalReview, error) {
+	reviewMap := make(map[string][]*EditProposalReview)
+	if len(changeLogIDs) <= 0 {
+		return reviewMap, nil
+	}
+	rows := database.NewQuery(`
+		SELECT id,changeLogId,pageId,reviewerId,action,reason,acceptedHunks,resultEdit,createdAt
+		FROM editProposalReviews
+		WHERE changeLogId IN`).AddArgsGroupStr(changeLogIDs).Add(`
+		ORDER BY createdAt,id`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var review EditProposalReview
+		var acceptedHunks string
+		err := rows.Scan(&review.ID, &review.ChangeLogID, &review.PageID, &review.ReviewerID, &review.Action,
+			&review.Reason, &acceptedHunks, &review.ResultEdit, &review.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		review.AcceptedHunks = decodeHunkIndexes(acceptedHunks)
+		reviewMap[review.ChangeLogID] = append(reviewMap[review.ChangeLogID], &review)
+		return nil
+	})
+	return reviewMap, err
+}
+
+// LoadEditProposalComments loads all the line comments for the given proposal.
+func LoadEditProposalComments(db *database.DB, changeLogID string) ([]*EditProposalComment, error) {
+	comments := make([]*EditProposalComment, 0)
+	rows := database.NewQuery(`
+		SELECT id,changeLogId,pageId,userId,oldLineNum,newLineNum,text,createdAt
+		FROM editProposalComments
+		WHERE changeLogId=?`, changeLogID).Add(`
+		ORDER BY createdAt,id`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var comment EditProposalComment
+		err := rows.Scan(&comment.ID, &comment.ChangeLogID, &comment.PageID, &comment.UserID,
+			&comment.OldLineNum, &comment.NewLineNum, &comment.Text, &comment.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		comments = append(comments, &comment)
+		return nil
+	})
+	return comments, err
+}
+
+// LoadPendingEditProposals loads the pending edit proposals for pages in the
+// given domain, oldest first.
+func LoadPendingEditProposals(db *database.DB, u *CurrentUser, resultData *CommonHandlerData, domainID string, limit int) ([]*PendingEditProposal, error) {
+	proposals := make([]*PendingEditProposal, 0)
+	proposalMap := make(map[string]*PendingEditProposal)
+	changeLogIDs := make([]string, 0)
+	queryPart := database.NewQuery(`
+		WHERE cl.type=?`, NewEditProposalChangeLog).Add(`
+			AND cl.pageId IN (
+				SELECT pi.pageId
+				FROM pageInfos AS pi
+				WHERE pi.editDomainId=?`, domainID).Add(`
+					AND`).AddPart(PageInfosFilter(u)).Add(`
+			)
+		ORDER BY cl.createdAt
+		LIMIT ?`, limit)
+	err := LoadChangeLogs(db, queryPart, resultData, func(db *database.DB, changeLog *ChangeLog) error {
+		proposal := &PendingEditProposal{ChangeLog: changeLog}
+		proposals = append(proposals, proposal)
+		proposalMap[changeLog.ID] = proposal
+		changeLogIDs = append(changeLogIDs, changeLog.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reviewMap, err := LoadEditProposalReviews(db, changeLogIDs)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load reviews: %v", err)
+	}
+	for changeLogID, reviews := range reviewMap {
+		proposalMap[changeLogID].LastReview = reviews[len(reviews)-1]
+	}
+
+	if len(changeLogIDs) > 0 {
+		rows := database.NewQuery(`
+			SELECT changeLogId,COUNT(*)
+			FROM editProposalComments
+			WHERE changeLogId IN`).AddArgsGroupStr(changeLogIDs).Add(`
+			GROUP BY 1`).ToStatement(db).Query()
+		err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+			var changeLogID string
+			var count int
+			if err := rows.Scan(&changeLogID, &count); err != nil {
+				return fmt.Errorf("failed to scan: %v", err)
+			}
+			proposalMap[changeLogID].CommentCount = count
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't load comment counts: %v", err)
+		}
+	}
+	return proposals, nil
+}
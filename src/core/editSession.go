@@ -0,0 +1,211 @@
+// editSession.go contains helpers for collaborative editing sessions.
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// Users who haven't polled the session for this long are no longer present (in seconds)
+	EditSessionPresenceTimeout = 30
+	// Sessions without any present users or changes for this long are restarted
+	// from the page's text next time someone joins (in seconds)
+	EditSessionTimeout = 10 * 60
+	// How often the session text is saved as an autosave (in seconds)
+	EditSessionAutosavePeriod = 30
+)
+
+// EditSession is the shared document of a collaborative editing session.
+type EditSession struct {
+	PageID         string `json:"pageId"`
+	Revision       int    `json:"revision"`
+	Text           string `json:"text"`
+	BaseEdit       int    `json:"baseEdit"`
+	CreatedBy      string `json:"createdBy"`
+	CreatedAt      string `json:"createdAt"`
+	UpdatedAt      string `json:"updatedAt"`
+	LastAutosaveAt string `json:"lastAutosaveAt"`
+}
+
+// EditSessionOp is an operation that was applied to the session text.
+type EditSessionOp struct {
+	Revision  int           `json:"revision"`
+	UserID    string        `json:"userId"`
+	Ops       TextOperation `json:"ops"`
+	CreatedAt string        `json:"createdAt"`
+}
+
+// EditSessionPresence describes a user taking part in the session.
+type EditSessionPresence struct {
+	UserID         string `json:"userId"`
+	SelectionStart int    `json:"selectionStart"`
+	SelectionEnd   int    `json:"selectionEnd"`
+	UpdatedAt      string `json:"updatedAt"`
+}
+
+// getTimeAgo returns the database time the given number of seconds ago.
+func getTimeAgo(seconds int) string {
+	return time.Now().UTC().Add(-time.Duration(seconds) * time.Second).Format(database.TimeLayout)
+}
+
+// IsStale returns true if the session hasn't been changed for a while.
+func (s *EditSession) IsStale() bool {
+	return s.UpdatedAt < getTimeAgo(EditSessionTimeout)
+}
+
+// NeedsAutosave returns true if it's time to save the session text again.
+func (s *EditSession) NeedsAutosave() bool {
+	return s.LastAutosaveAt < getTimeAgo(EditSessionAutosavePeriod)
+}
+
+// LoadEditSession loads the editing session for the given page. Returns nil if
+// there is no session.
+func LoadEditSession(db *database.DB, pageID string) (*EditSession, error) {
+	session := &EditSession{}
+	row := database.NewQuery(`
+		SELECT pageId,revision,text,baseEdit,createdBy,createdAt,updatedAt,lastAutosaveAt
+		FROM editSessions
+		WHERE pageId=?`, pageID).ToStatement(db).QueryRow()
+	exists, err := row.Scan(&session.PageID, &session.Revision, &session.Text, &session.BaseEdit,
+		&session.CreatedBy, &session.CreatedAt, &session.UpdatedAt, &session.LastAutosaveAt)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load edit session: %v", err)
+	} else if !exists {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// LoadEditSessionForUpdate loads the editing session for the given page and
+// locks its row until the transaction is done. Returns nil if there is no session.
+func LoadEditSessionForUpdate(tx *database.Tx, pageID string) (*EditSession, error) {
+	session := &EditSession{}
+	row := database.NewQuery(`
+		SELECT pageId,revision,text,baseEdit,createdBy,createdAt,updatedAt,lastAutosaveAt
+		FROM editSessions
+		WHERE pageId=?
+		FOR UPDATE`, pageID).ToTxStatement(tx).QueryRow()
+	exists, err := row.Scan(&session.PageID, &session.Revision, &session.Text, &session.BaseEdit,
+		&session.CreatedBy, &session.CreatedAt, &session.UpdatedAt, &session.LastAutosaveAt)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load edit session: %v", err)
+	} else if !exists {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// LoadEditSessionOps loads all the operations applied after the given revision,
+// ordered by revision.
+func LoadEditSessionOps(db *database.DB, pageID string, afterRevision int) ([]*EditSessionOp, error) {
+	ops := make([]*EditSessionOp, 0)
+	rows := database.NewQuery(`
+		SELECT revision,userId,ops,createdAt
+		FROM editSessionOps
+		WHERE pageId=? AND revision>?`, pageID, afterRevision).Add(`
+		ORDER BY revision`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var op EditSessionOp
+		var opsJSON string
+		err := rows.Scan(&op.Revision, &op.UserID, &opsJSON, &op.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		if err := json.Unmarshal([]byte(opsJSON), &op.Ops); err != nil {
+			return fmt.Errorf("Couldn't decode ops: %v", err)
+		}
+		ops = append(ops, &op)
+		return nil
+	})
+	return ops, err
+}
+
+// LoadEditSessionPresences loads the users who are currently taking part in the
+// editing session for the given page.
+func LoadEditSessionPresences(db *database.DB, pageID string) ([]*EditSessionPresence, error) {
+	presences := make([]*EditSessionPresence, 0)
+	rows := database.NewQuery(`
+		SELECT userId,selectionStart,selectionEnd,updatedAt
+		FROM editSessionPresences
+		WHERE pageId=? AND updatedAt>=?`, pageID, getTimeAgo(EditSessionPresenceTimeout)).Add(`
+		ORDER BY userId`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var presence EditSessionPresence
+		err := rows.Scan(&presence.UserID, &presence.SelectionStart, &presence.SelectionEnd, &presence.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		presences = append(presences, &presence)
+		return nil
+	})
+	return presences, err
+}
+
+// IsEditSessionActive returns true if anyone is currently taking part in the
+// editing session for the given page.
+func IsEditSessionActive(db *database.DB, pageID string) (bool, error) {
+	presences, err := LoadEditSessionPresences(db, pageID)
+	if err != nil {
+		return false, err
+	}
+	return len(presences) > 0, nil
+}
+
+// IsInEditSession returns true if the user joined the current editing session
+// for the given page and hasn't timed out since. Should be called after the
+// session row has been locked, so the session can't restart in the meantime.
+func IsInEditSession(tx *database.Tx, u *CurrentUser, pageID string) (bool, error) {
+	var userID string
+	row := database.NewQuery(`
+		SELECT userId
+		FROM editSessionPresences
+		WHERE pageId=? AND userId=? AND updatedAt>=?`, pageID, u.ID, getTimeAgo(EditSessionPresenceTimeout)).Add(`
+		FOR UPDATE`).ToTxStatement(tx).QueryRow()
+	return row.Scan(&userID)
+}
+
+// UpdateEditSessionPresence marks the user as present in the editing session.
+func UpdateEditSessionPresence(tx *database.Tx, u *CurrentUser, pageID string, selectionStart, selectionEnd int) error {
+	hashmap := make(database.InsertMap)
+	hashmap["pageId"] = pageID
+	hashmap["userId"] = u.ID
+	hashmap["selectionStart"] = selectionStart
+	hashmap["selectionEnd"] = selectionEnd
+	hashmap["updatedAt"] = database.Now()
+	statement := tx.DB.NewInsertStatement("editSessionPresences", hashmap, "selectionStart", "selectionEnd", "updatedAt").WithTx(tx)
+	if _, err := statement.Exec(); err != nil {
+		return fmt.Errorf("Couldn't update presence: %v", err)
+	}
+	return nil
+}
+
+// DeleteEditSessionPresence removes the user from the editing session.
+func DeleteEditSessionPresence(tx *database.Tx, u *CurrentUser, pageID string) error {
+	statement := database.NewQuery(`
+		DELETE FROM editSessionPresences
+		WHERE pageId=? AND userId=?`, pageID, u.ID).ToTxStatement(tx)
+	if _, err := statement.Exec(); err != nil {
+		return fmt.Errorf("Couldn't delete presence: %v", err)
+	}
+	return nil
+}
+
+// DeleteStaleEditSessionPresences removes the users who timed out of the
+// editing session. If all is true, everyone is removed (used when the session
+// restarts and the old revisions no longer apply).
+func DeleteStaleEditSessionPresences(tx *database.Tx, pageID string, all bool) error {
+	query := database.NewQuery(`
+		DELETE FROM editSessionPresences
+		WHERE pageId=?`, pageID)
+	if !all {
+		query.Add(`AND updatedAt<?`, getTimeAgo(EditSessionPresenceTimeout))
+	}
+	if _, err := query.ToTxStatement(tx).Exec(); err != nil {
+		return fmt.Errorf("Couldn't delete presences: %v", err)
+	}
+	return nil
+}
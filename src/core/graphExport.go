@@ -0,0 +1,340 @@
+// graphExport.go contains the functions for exporting and importing the graph
+// of relationships between the pages of a domain.
+package core
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// Formats we can export the graph in
+	JSONGraphFormat    = "json"
+	GraphMLGraphFormat = "graphml"
+
+	graphMLNamespace = "http://graphml.graphdrawing.org/xmlns"
+)
+
+// Relationships we export and import
+var exportedPagePairTypes = []string{ParentPagePairType, TagPagePairType, RequirementPagePairType, SubjectPagePairType}
+
+// ExportedGraph is the graph of relationships between pages.
+type ExportedGraph struct {
+	DomainID string               `json:"domainId"`
+	Nodes    []*ExportedGraphNode `json:"nodes"`
+	Edges    []*ExportedGraphEdge `json:"edges"`
+}
+
+// ExportedGraphNode is a page in the graph.
+type ExportedGraphNode struct {
+	PageID string `json:"pageId"`
+	Alias  string `json:"alias"`
+	Title  string `json:"title"`
+	Type   string `json:"type"`
+}
+
+// ExportedGraphEdge is a relationship between two pages in the graph.
+type ExportedGraphEdge struct {
+	ParentID string `json:"parentId"`
+	ChildID  string `json:"childId"`
+	Type     string `json:"type"`
+	Level    int    `json:"level"`
+	IsStrong bool   `json:"isStrong"`
+}
+
+// GraphML document structure
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr,omitempty"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr,omitempty"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// FormatGraphML returns the graph as a GraphML document. Edges go from the
+// parent to the child.
+func FormatGraphML(graph *ExportedGraph) ([]byte, error) {
+	doc := graphMLDocument{
+		Xmlns: graphMLNamespace,
+		Keys: []graphMLKey{
+			{ID: "alias", For: "node", AttrName: "alias", AttrType: "string"},
+			{ID: "title", For: "node", AttrName: "title", AttrType: "string"},
+			{ID: "pageType", For: "node", AttrName: "pageType", AttrType: "string"},
+			{ID: "type", For: "edge", AttrName: "type", AttrType: "string"},
+			{ID: "level", For: "edge", AttrName: "level", AttrType: "int"},
+			{ID: "isStrong", For: "edge", AttrName: "isStrong", AttrType: "boolean"},
+		},
+		Graph: graphMLGraph{ID: "domain" + graph.DomainID, EdgeDefault: "directed"},
+	}
+	for _, node := range graph.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: node.PageID,
+			Data: []graphMLData{
+				{Key: "alias", Value: node.Alias},
+				{Key: "title", Value: node.Title},
+				{Key: "pageType", Value: node.Type},
+			},
+		})
+	}
+	for _, edge := range graph.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: edge.ParentID,
+			Target: edge.ChildID,
+			Data: []graphMLData{
+				{Key: "type", Value: edge.Type},
+				{Key: "level", Value: strconv.Itoa(edge.Level)},
+				{Key: "isStrong", Value: strconv.FormatBool(edge.IsStrong)},
+			},
+		})
+	}
+	output, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't marshal GraphML: %v", err)
+	}
+	return append([]byte(xml.Header), output...), nil
+}
+
+// ParseGraphML reads a graph from a GraphML document. Data is matched by the
+// keys' attribute names, so documents edited in other tools can be read too.
+func ParseGraphML(input []byte) (*ExportedGraph, error) {
+	var doc graphMLDocument
+	if err := xml.Unmarshal(input, &doc); err != nil {
+		return nil, fmt.Errorf("Couldn't parse GraphML: %v", err)
+	}
+	keyNames := make(map[string]string)
+	for _, key := range doc.Keys {
+		keyNames[key.ID] = key.AttrName
+	}
+
+	graph := &ExportedGraph{Nodes: make([]*ExportedGraphNode, 0), Edges: make([]*ExportedGraphEdge, 0)}
+	for _, n := range doc.Graph.Nodes {
+		node := &ExportedGraphNode{PageID: n.ID}
+		for _, data := range n.Data {
+			switch keyNames[data.Key] {
+			case "alias":
+				node.Alias = data.Value
+			case "title":
+				node.Title = data.Value
+			case "pageType":
+				node.Type = data.Value
+			}
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	for _, e := range doc.Graph.Edges {
+		edge := &ExportedGraphEdge{ParentID: e.Source, ChildID: e.Target}
+		for _, data := range e.Data {
+			var err error
+			switch keyNames[data.Key] {
+			case "type":
+				edge.Type = data.Value
+			case "level":
+				edge.Level, err = strconv.Atoi(data.Value)
+			case "isStrong":
+				edge.IsStrong, err = strconv.ParseBool(data.Value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s for edge %s->%s: %v", keyNames[data.Key], e.Source, e.Target, err)
+			}
+		}
+		graph.Edges = append(graph.Edges, edge)
+	}
+	return graph, nil
+}
+
+// ParseGraph reads a graph in the given format.
+func ParseGraph(format string, input []byte) (*ExportedGraph, error) {
+	switch format {
+	case GraphMLGraphFormat:
+		return ParseGraphML(input)
+	case JSONGraphFormat:
+		var graph ExportedGraph
+		if err := json.Unmarshal(input, &graph); err != nil {
+			return nil, fmt.Errorf("Couldn't parse JSON: %v", err)
+		}
+		return &graph, nil
+	}
+	return nil, fmt.Errorf("Unknown format: %s", format)
+}
+
+// LoadAncestorParentEdges returns the existing parent edges between the given
+// pages and all of their ancestors.
+func LoadAncestorParentEdges(db *database.DB, pageIDs []string) ([]*ExportedGraphEdge, error) {
+	edges := make([]*ExportedGraphEdge, 0)
+	visited := make(map[string]bool)
+	toVisit := append([]string{}, pageIDs...)
+	for len(toVisit) > 0 {
+		childID := toVisit[0]
+		toVisit = toVisit[1:]
+		if visited[childID] {
+			continue
+		}
+		visited[childID] = true
+
+		parentIDs, err := _getParents(db, childID)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't load parents: %v", err)
+		}
+		for _, parentID := range parentIDs {
+			edges = append(edges, &ExportedGraphEdge{ParentID: parentID, ChildID: childID, Type: ParentPagePairType})
+			if !visited[parentID] {
+				toVisit = append(toVisit, parentID)
+			}
+		}
+	}
+	return edges, nil
+}
+
+// FindParentCycle returns the ids of pages that form a cycle through the
+// parent edges, or nil if there isn't one.
+func FindParentCycle(edges []*ExportedGraphEdge) []string {
+	childrenMap := make(map[string][]string)
+	for _, edge := range edges {
+		if edge.Type == ParentPagePairType {
+			childrenMap[edge.ParentID] = append(childrenMap[edge.ParentID], edge.ChildID)
+		}
+	}
+	parentIDs := make([]string, 0)
+	for parentID := range childrenMap {
+		parentIDs = append(parentIDs, parentID)
+	}
+	sort.Strings(parentIDs)
+
+	// 1 while we are visiting the page's descendants, 2 once we are done
+	state := make(map[string]int)
+	stack := make([]string, 0)
+	var visit func(pageID string) []string
+	visit = func(pageID string) []string {
+		state[pageID] = 1
+		stack = append(stack, pageID)
+		for _, childID := range childrenMap[pageID] {
+			if state[childID] == 1 {
+				for n, stackID := range stack {
+					if stackID == childID {
+						return append([]string{}, stack[n:]...)
+					}
+				}
+			} else if state[childID] == 0 {
+				if cycle := visit(childID); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[pageID] = 2
+		return nil
+	}
+	for _, parentID := range parentIDs {
+		if state[parentID] == 0 {
+			if cycle := visit(parentID); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// LoadDomainGraph loads the domain's pages the user can see, and their
+// relationships. Pages from other domains the relationships point to are
+// included as nodes too.
+func LoadDomainGraph(db *database.DB, u *CurrentUser, domainID string) (*ExportedGraph, error) {
+	graph := &ExportedGraph{
+		DomainID: domainID,
+		Nodes:    make([]*ExportedGraphNode, 0),
+		Edges:    make([]*ExportedGraphEdge, 0),
+	}
+
+	// Load the relationships
+	pageIDs := make(map[string]bool)
+	rows := database.NewQuery(`
+		SELECT pp.parentId,pp.childId,pp.type,pp.level,pp.isStrong
+		FROM pagePairs AS pp
+		JOIN pageInfos AS pi
+		ON (pp.childId=pi.pageId)
+		JOIN pageInfos AS parent
+		ON (pp.parentId=parent.pageId)
+		WHERE pi.editDomainId=?`, domainID).Add(`
+			AND pp.type IN`).AddArgsGroupStr(exportedPagePairTypes).Add(`
+			AND pp.everPublished
+			AND NOT parent.isDeleted
+			AND parent.seeDomainId IN`).AddArgsGroupStr(GetVisibleDomainIDs(u)).Add(`
+			AND`).AddPart(PageInfosFilter(u)).Add(`
+		ORDER BY pp.childId,pp.type,pp.parentId`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var edge ExportedGraphEdge
+		if err := rows.Scan(&edge.ParentID, &edge.ChildID, &edge.Type, &edge.Level, &edge.IsStrong); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		graph.Edges = append(graph.Edges, &edge)
+		pageIDs[edge.ParentID] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load page pairs: %v", err)
+	}
+
+	// Load the pages
+	parentIDs := make([]string, 0)
+	for pageID := range pageIDs {
+		parentIDs = append(parentIDs, pageID)
+	}
+	queryPart := database.NewQuery(`pi.editDomainId=?`, domainID)
+	if len(parentIDs) > 0 {
+		queryPart = database.NewQuery(`(pi.editDomainId=?`, domainID).Add(`
+			OR pi.pageId IN`).AddArgsGroupStr(parentIDs).Add(`)`)
+	}
+	rows = database.NewQuery(`
+		SELECT pi.pageId,pi.alias,pi.type,p.title
+		FROM pageInfos AS pi
+		JOIN pages AS p
+		ON (p.pageId=pi.pageId AND p.isLiveEdit)
+		WHERE`).AddPart(queryPart).Add(`
+			AND`).AddPart(PageInfosFilter(u)).Add(`
+		ORDER BY pi.pageId`).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var node ExportedGraphNode
+		if err := rows.Scan(&node.PageID, &node.Alias, &node.Type, &node.Title); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		graph.Nodes = append(graph.Nodes, &node)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load pages: %v", err)
+	}
+	return graph, nil
+}
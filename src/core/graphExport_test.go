@@ -0,0 +1,81 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// Make sure a graph survives being written to GraphML and read back.
+func TestGraphMLRoundTrip(t *testing.T) {
+	graph := &ExportedGraph{
+		DomainID: "1",
+		Nodes: []*ExportedGraphNode{
+			&ExportedGraphNode{PageID: "10", Alias: "bayes", Title: "Bayes & friends", Type: WikiPageType},
+			&ExportedGraphNode{PageID: "20", Alias: "odds", Title: "Odds", Type: WikiPageType},
+		},
+		Edges: []*ExportedGraphEdge{
+			&ExportedGraphEdge{ParentID: "20", ChildID: "10", Type: RequirementPagePairType, Level: 2, IsStrong: true},
+			&ExportedGraphEdge{ParentID: "10", ChildID: "20", Type: ParentPagePairType},
+		},
+	}
+	output, err := FormatGraphML(graph)
+	if err != nil {
+		t.Fatalf("Couldn't format: %v", err)
+	}
+	parsed, err := ParseGraph(GraphMLGraphFormat, output)
+	if err != nil {
+		t.Fatalf("Couldn't parse: %v", err)
+	}
+	if len(parsed.Nodes) != len(graph.Nodes) || len(parsed.Edges) != len(graph.Edges) {
+		t.Fatalf("Unexpected graph: %+v", parsed)
+	}
+	for n, node := range graph.Nodes {
+		if *parsed.Nodes[n] != *node {
+			t.Errorf("Unexpected node %d: %+v, expected %+v", n, parsed.Nodes[n], node)
+		}
+	}
+	for n, edge := range graph.Edges {
+		if *parsed.Edges[n] != *edge {
+			t.Errorf("Unexpected edge %d: %+v, expected %+v", n, parsed.Edges[n], edge)
+		}
+	}
+
+	if _, err := ParseGraph("csv", output); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
+
+// Make sure cycles are only found through parent edges.
+func TestFindParentCycle(t *testing.T) {
+	edges := []*ExportedGraphEdge{
+		&ExportedGraphEdge{ParentID: "1", ChildID: "2", Type: ParentPagePairType},
+		&ExportedGraphEdge{ParentID: "2", ChildID: "3", Type: ParentPagePairType},
+		&ExportedGraphEdge{ParentID: "3", ChildID: "1", Type: RequirementPagePairType},
+	}
+	if cycle := FindParentCycle(edges); cycle != nil {
+		t.Errorf("Unexpected cycle: %v", cycle)
+	}
+
+	edges = append(edges, &ExportedGraphEdge{ParentID: "3", ChildID: "2", Type: ParentPagePairType})
+	if cycle := FindParentCycle(edges); strings.Join(cycle, ",") != "2,3" {
+		t.Errorf("Unexpected cycle: %v", cycle)
+	}
+}
+
+// Make sure a cycle is found when it mixes new and existing parent edges.
+func TestFindParentCycleWithExistingEdges(t *testing.T) {
+	newEdges := []*ExportedGraphEdge{
+		&ExportedGraphEdge{ParentID: "a", ChildID: "b", Type: ParentPagePairType},
+		&ExportedGraphEdge{ParentID: "c", ChildID: "a", Type: ParentPagePairType},
+	}
+	if cycle := FindParentCycle(newEdges); cycle != nil {
+		t.Errorf("Unexpected cycle: %v", cycle)
+	}
+
+	existingEdges := []*ExportedGraphEdge{
+		&ExportedGraphEdge{ParentID: "b", ChildID: "c", Type: ParentPagePairType},
+	}
+	if cycle := FindParentCycle(append(existingEdges, newEdges...)); strings.Join(cycle, ",") != "a,b,c" {
+		t.Errorf("Unexpected cycle: %v", cycle)
+	}
+}
@@ -0,0 +1,311 @@
+// masteryReview.go contains the functions for spaced-repetition reviews of masteries.
+package core
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// Grades a user can give themselves when reviewing a mastery (SM-2 scale).
+	// 0 means they didn't remember anything, 5 means they remembered perfectly.
+	MinMasteryReviewGrade     = 0
+	PassingMasteryReviewGrade = 3
+	MaxMasteryReviewGrade     = 5
+
+	// How easy a new mastery is to remember, and the lowest it can get
+	DefaultMasteryReviewEasiness = 2.5
+	MinMasteryReviewEasiness     = 1.3
+)
+
+// MasteryReview tracks how well a user remembers one of their masteries.
+type MasteryReview struct {
+	UserID    string `json:"userId"`
+	MasteryID string `json:"masteryId"`
+	// Level the user has the mastery at
+	Level int `json:"level"`
+	// Page where the user learned the mastery
+	TaughtBy string `json:"taughtBy"`
+
+	Easiness       float64 `json:"easiness"`
+	IntervalDays   int     `json:"intervalDays"`
+	Repetitions    int     `json:"repetitions"`
+	DueAt          string  `json:"dueAt"`
+	LastReviewedAt string  `json:"lastReviewedAt"`
+	LastGrade      int     `json:"lastGrade"`
+
+	// Page to reread to refresh the mastery
+	ReviewPageID string `json:"reviewPageId"`
+	// Page with a quiz that grants the mastery
+	QuizPageID string `json:"quizPageId"`
+}
+
+// ReviewCandidate is a page that could be used to review a mastery.
+type ReviewCandidate struct {
+	PageID string
+	// Level at which the page teaches (or quizzes) the mastery
+	Level int
+}
+
+// NewMasteryReview returns the review state for a mastery the user just learned.
+func NewMasteryReview(userID, masteryID string, level int, now time.Time) *MasteryReview {
+	return &MasteryReview{
+		UserID:         userID,
+		MasteryID:      masteryID,
+		Level:          level,
+		Easiness:       DefaultMasteryReviewEasiness,
+		IntervalDays:   1,
+		DueAt:          now.AddDate(0, 0, 1).Format(database.TimeLayout),
+		LastReviewedAt: now.Format(database.TimeLayout),
+	}
+}
+
+// ApplyMasteryReviewGrade updates the review schedule after the user graded how
+// well they remembered the mastery, using the SM-2 algorithm. A failed review
+// starts the schedule over and lowers the mastery's level by one.
+func ApplyMasteryReviewGrade(review *MasteryReview, grade int, now time.Time) {
+	if grade < MinMasteryReviewGrade {
+		grade = MinMasteryReviewGrade
+	} else if grade > MaxMasteryReviewGrade {
+		grade = MaxMasteryReviewGrade
+	}
+
+	if grade >= PassingMasteryReviewGrade {
+		switch review.Repetitions {
+		case 0:
+			review.IntervalDays = 1
+		case 1:
+			review.IntervalDays = 6
+		default:
+			review.IntervalDays = int(math.Ceil(float64(review.IntervalDays) * review.Easiness))
+		}
+		review.Repetitions++
+	} else {
+		review.Repetitions = 0
+		review.IntervalDays = 1
+		if review.Level > LooseMasteryLevel {
+			review.Level--
+		}
+	}
+
+	miss := float64(MaxMasteryReviewGrade - grade)
+	review.Easiness += 0.1 - miss*(0.08+miss*0.02)
+	if review.Easiness < MinMasteryReviewEasiness {
+		review.Easiness = MinMasteryReviewEasiness
+	}
+
+	review.LastGrade = grade
+	review.LastReviewedAt = now.Format(database.TimeLayout)
+	review.DueAt = now.AddDate(0, 0, review.IntervalDays).Format(database.TimeLayout)
+}
+
+// PickReviewPage returns the best page to review a mastery the user has at the
+// given level: the page they learned it from if possible, otherwise the page
+// with the highest level that doesn't go beyond what the user knows. Returns ""
+// if there are no candidates.
+func PickReviewPage(candidates []*ReviewCandidate, taughtBy string, level int) string {
+	var best *ReviewCandidate
+	for _, candidate := range candidates {
+		if candidate.PageID == taughtBy {
+			return taughtBy
+		}
+		if best == nil {
+			best = candidate
+			continue
+		}
+		bestFits, fits := best.Level <= level, candidate.Level <= level
+		if fits != bestFits {
+			if fits {
+				best = candidate
+			}
+			continue
+		}
+		// Among pages that fit, prefer the highest level; otherwise the lowest
+		if candidate.Level != best.Level {
+			if (fits && candidate.Level > best.Level) || (!fits && candidate.Level < best.Level) {
+				best = candidate
+			}
+			continue
+		}
+		if candidate.PageID < best.PageID {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.PageID
+}
+
+// LoadMasteryReview loads the review state of the given mastery. If the user
+// has the mastery, but it's not being reviewed yet, a new review is returned.
+// Returns nil if the user doesn't have the mastery.
+func LoadMasteryReview(db *database.DB, userID, masteryID string) (*MasteryReview, error) {
+	review := &MasteryReview{UserID: userID, MasteryID: masteryID}
+	var hasReview bool
+	exists, err := database.NewQuery(`
+		SELECT ump.level,ump.taughtBy,mr.userId IS NOT NULL,IFNULL(mr.easiness,0),
+			IFNULL(mr.intervalDays,0),IFNULL(mr.repetitions,0),IFNULL(mr.dueAt,""),
+			IFNULL(mr.lastReviewedAt,""),IFNULL(mr.lastGrade,0)
+		FROM userMasteryPairs AS ump
+		LEFT JOIN masteryReviews AS mr
+		ON (ump.userId=mr.userId AND ump.masteryId=mr.masteryId)
+		WHERE ump.userId=?`, userID).Add(`
+			AND ump.masteryId=?`, masteryID).Add(`
+			AND ump.has`).ToStatement(db).QueryRow().Scan(&review.Level, &review.TaughtBy, &hasReview,
+		&review.Easiness, &review.IntervalDays, &review.Repetitions, &review.DueAt,
+		&review.LastReviewedAt, &review.LastGrade)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load mastery review: %v", err)
+	} else if !exists {
+		return nil, nil
+	} else if !hasReview {
+		newReview := NewMasteryReview(userID, masteryID, review.Level, time.Now())
+		newReview.TaughtBy = review.TaughtBy
+		return newReview, nil
+	}
+	return review, nil
+}
+
+// LoadDueMasteryReviews loads the user's masteries that are due for a review, oldest first.
+func LoadDueMasteryReviews(db *database.DB, userID string, limit int) ([]*MasteryReview, error) {
+	reviews := make([]*MasteryReview, 0)
+	rows := database.NewQuery(`
+		SELECT mr.masteryId,ump.level,ump.taughtBy,mr.easiness,mr.intervalDays,
+			mr.repetitions,mr.dueAt,mr.lastReviewedAt,mr.lastGrade
+		FROM masteryReviews AS mr
+		JOIN userMasteryPairs AS ump
+		ON (ump.userId=mr.userId AND ump.masteryId=mr.masteryId)
+		WHERE mr.userId=?`, userID).Add(`
+			AND ump.has
+			AND mr.dueAt<=?`, database.Now()).Add(`
+		ORDER BY mr.dueAt
+		LIMIT ?`, limit).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		review := &MasteryReview{UserID: userID}
+		err := rows.Scan(&review.MasteryID, &review.Level, &review.TaughtBy, &review.Easiness,
+			&review.IntervalDays, &review.Repetitions, &review.DueAt, &review.LastReviewedAt, &review.LastGrade)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		reviews = append(reviews, review)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load mastery reviews: %v", err)
+	}
+	return reviews, nil
+}
+
+// LoadMasteryReviewPages picks the page to reread and the quiz to take for
+// each of the given reviews.
+func LoadMasteryReviewPages(db *database.DB, u *CurrentUser, reviews []*MasteryReview) error {
+	if len(reviews) <= 0 {
+		return nil
+	}
+	masteryIDs := make([]string, 0)
+	for _, review := range reviews {
+		masteryIDs = append(masteryIDs, review.MasteryID)
+	}
+
+	// Load the pages that teach the masteries
+	tutorMap, err := loadTutorCandidates(db, u, masteryIDs)
+	if err != nil {
+		return err
+	}
+
+	// Load the quizzes that grant the masteries
+	quizMap := make(map[string][]*ReviewCandidate)
+	rows := database.NewQuery(`
+		SELECT qmg.masteryId,qmg.pageId,MAX(qmg.level)
+		FROM quizMasteryGrants AS qmg
+		JOIN pageInfos AS pi
+		ON (qmg.pageId=pi.pageId)
+		WHERE qmg.masteryId IN`).AddArgsGroupStr(masteryIDs).Add(`
+			AND`).AddPart(PageInfosFilter(u)).Add(`
+		GROUP BY 1,2`).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var masteryID string
+		var candidate ReviewCandidate
+		if err := rows.Scan(&masteryID, &candidate.PageID, &candidate.Level); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		quizMap[masteryID] = append(quizMap[masteryID], &candidate)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Couldn't load quizzes: %v", err)
+	}
+
+	for _, review := range reviews {
+		review.ReviewPageID = PickReviewPage(tutorMap[review.MasteryID], review.TaughtBy, review.Level)
+		review.QuizPageID = PickReviewPage(quizMap[review.MasteryID], review.TaughtBy, review.Level)
+	}
+	return nil
+}
+
+// loadTutorCandidates loads the pages the user can see that teach the given masteries.
+func loadTutorCandidates(db *database.DB, u *CurrentUser, masteryIDs []string) (map[string][]*ReviewCandidate, error) {
+	tutorMap := make(map[string][]*ReviewCandidate)
+	if len(masteryIDs) <= 0 {
+		return tutorMap, nil
+	}
+	rows := database.NewQuery(`
+		SELECT pp.parentId,pp.childId,pp.level
+		FROM pagePairs AS pp
+		JOIN pageInfos AS pi
+		ON (pp.childId=pi.pageId)
+		WHERE pp.parentId IN`).AddArgsGroupStr(masteryIDs).Add(`
+			AND pp.type=?`, SubjectPagePairType).Add(`
+			AND`).AddPart(PageInfosFilter(u)).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var masteryID string
+		var candidate ReviewCandidate
+		if err := rows.Scan(&masteryID, &candidate.PageID, &candidate.Level); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		tutorMap[masteryID] = append(tutorMap[masteryID], &candidate)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load tutors: %v", err)
+	}
+	return tutorMap, nil
+}
+
+// SaveMasteryReview saves the review state, and the mastery's level if the
+// review changed it.
+func SaveMasteryReview(tx *database.Tx, review *MasteryReview) error {
+	now := database.Now()
+	hashmap := make(database.InsertMap)
+	hashmap["userId"] = review.UserID
+	hashmap["masteryId"] = review.MasteryID
+	hashmap["easiness"] = review.Easiness
+	hashmap["intervalDays"] = review.IntervalDays
+	hashmap["repetitions"] = review.Repetitions
+	hashmap["dueAt"] = review.DueAt
+	hashmap["lastReviewedAt"] = review.LastReviewedAt
+	hashmap["lastGrade"] = review.LastGrade
+	hashmap["remindedAt"] = now
+	hashmap["createdAt"] = now
+	hashmap["updatedAt"] = now
+	statement := tx.DB.NewInsertStatement("masteryReviews", hashmap, "easiness", "intervalDays",
+		"repetitions", "dueAt", "lastReviewedAt", "lastGrade", "updatedAt").WithTx(tx)
+	if _, err := statement.Exec(); err != nil {
+		return fmt.Errorf("Couldn't save mastery review: %v", err)
+	}
+
+	statement = database.NewQuery(`
+		UPDATE userMasteryPairs
+		SET level=?,updatedAt=?`, review.Level, now).Add(`
+		WHERE userId=?`, review.UserID).Add(`
+			AND masteryId=?`, review.MasteryID).Add(`
+			AND level>?`, review.Level).ToTxStatement(tx)
+	if _, err := statement.Exec(); err != nil {
+		return fmt.Errorf("Couldn't update mastery level: %v", err)
+	}
+	return nil
+}
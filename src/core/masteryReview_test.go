@@ -0,0 +1,79 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+// Make sure passing reviews follow the SM-2 intervals.
+func TestApplyMasteryReviewGradePass(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	review := NewMasteryReview("1", "2", BasicMasteryLevel, now)
+	expectedIntervals := []int{1, 6, 15, 38}
+	for n, expected := range expectedIntervals {
+		ApplyMasteryReviewGrade(review, 4, now)
+		if review.IntervalDays != expected {
+			t.Errorf("Unexpected interval after review %d: %d, expected %d", n+1, review.IntervalDays, expected)
+		}
+	}
+	if review.Easiness != DefaultMasteryReviewEasiness || review.Repetitions != 4 {
+		t.Errorf("Unexpected state: %+v", review)
+	}
+	if review.DueAt != "2016-02-08 00:00:00" {
+		t.Errorf("Unexpected due date: %s", review.DueAt)
+	}
+
+	ApplyMasteryReviewGrade(review, 5, now)
+	if review.Easiness <= DefaultMasteryReviewEasiness {
+		t.Errorf("Perfect recall should make the mastery easier: %f", review.Easiness)
+	}
+	if review.Level != BasicMasteryLevel {
+		t.Errorf("Passing shouldn't change the level: %d", review.Level)
+	}
+}
+
+// Make sure failing a review starts over and downgrades the mastery.
+func TestApplyMasteryReviewGradeFail(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	review := NewMasteryReview("1", "2", BasicMasteryLevel, now)
+	ApplyMasteryReviewGrade(review, 5, now)
+	ApplyMasteryReviewGrade(review, 5, now)
+	ApplyMasteryReviewGrade(review, 1, now)
+	if review.IntervalDays != 1 || review.Repetitions != 0 {
+		t.Errorf("Failed review should start over: %+v", review)
+	}
+	if review.Level != LooseMasteryLevel {
+		t.Errorf("Failed review should downgrade the level: %d", review.Level)
+	}
+	for n := 0; n < 10; n++ {
+		ApplyMasteryReviewGrade(review, 0, now)
+	}
+	if review.Level != LooseMasteryLevel {
+		t.Errorf("Level shouldn't go below loose: %d", review.Level)
+	}
+	if review.Easiness != MinMasteryReviewEasiness {
+		t.Errorf("Unexpected easiness: %f", review.Easiness)
+	}
+}
+
+// Make sure we pick the page the user learned from, or the best fitting level.
+func TestPickReviewPage(t *testing.T) {
+	candidates := []*ReviewCandidate{
+		{PageID: "5", Level: ResearchMasteryLevel},
+		{PageID: "4", Level: BasicMasteryLevel},
+		{PageID: "3", Level: LooseMasteryLevel},
+		{PageID: "2", Level: BasicMasteryLevel},
+	}
+	if pageID := PickReviewPage(candidates, "5", BasicMasteryLevel); pageID != "5" {
+		t.Errorf("Should pick the page the user learned from: %s", pageID)
+	}
+	if pageID := PickReviewPage(candidates, "", TechnicalMasteryLevel); pageID != "2" {
+		t.Errorf("Should pick the highest fitting level: %s", pageID)
+	}
+	if pageID := PickReviewPage(candidates[:1], "", LooseMasteryLevel); pageID != "5" {
+		t.Errorf("Should pick something even if nothing fits: %s", pageID)
+	}
+	if pageID := PickReviewPage(nil, "", LooseMasteryLevel); pageID != "" {
+		t.Errorf("Unexpected page: %s", pageID)
+	}
+}
@@ -0,0 +1,123 @@
+// merge.go does three-way merges of page texts.
+package core
+
+import (
+	"strings"
+)
+
+// MergeConflict is a part of the text which was changed differently in both versions.
+type MergeConflict struct {
+	// 1-based line number in the merged text where the conflict starts
+	Line   int    `json:"line"`
+	Base   string `json:"base"`
+	Ours   string `json:"ours"`
+	Theirs string `json:"theirs"`
+}
+
+// MergeResult is the result of a three-way merge.
+type MergeResult struct {
+	// Merged text. Conflicts are marked with "<<<<<<<", "=======", ">>>>>>>" lines.
+	Text      string           `json:"text"`
+	Conflicts []*MergeConflict `json:"conflicts"`
+}
+
+// IsClean returns true iff the merge didn't have conflicts.
+func (r *MergeResult) IsClean() bool {
+	return len(r.Conflicts) <= 0
+}
+
+// equalLines returns true iff the two lists of lines are the same.
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if a[n] != b[n] {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge3 merges the changes made to the base text in "ours" and "theirs" at the
+// line level. Parts changed only in one version are taken from that version.
+// Parts changed in both versions in the same way are merged cleanly too.
+func Merge3(base, ours, theirs string) *MergeResult {
+	result := &MergeResult{Conflicts: make([]*MergeConflict, 0)}
+	if ours == theirs {
+		result.Text = ours
+		return result
+	} else if base == ours {
+		result.Text = theirs
+		return result
+	} else if base == theirs {
+		result.Text = ours
+		return result
+	}
+
+	baseLines := splitDiffLines(base)
+	ourLines := splitDiffLines(ours)
+	theirLines := splitDiffLines(theirs)
+
+	// For each base line, the index of the matching line in ours/theirs, or -1
+	ourMatches := make([]int, len(baseLines))
+	theirMatches := make([]int, len(baseLines))
+	for n := range baseLines {
+		ourMatches[n] = -1
+		theirMatches[n] = -1
+	}
+	for _, token := range diffTokens(baseLines, ourLines) {
+		if token.diffType == EqualDiffType {
+			ourMatches[token.oldIndex] = token.newIndex
+		}
+	}
+	for _, token := range diffTokens(baseLines, theirLines) {
+		if token.diffType == EqualDiffType {
+			theirMatches[token.oldIndex] = token.newIndex
+		}
+	}
+
+	merged := make([]string, 0)
+	i, j, k := 0, 0, 0
+	for {
+		// Find the next base line that is unchanged in both versions
+		syncIndex := i
+		for syncIndex < len(baseLines) && (ourMatches[syncIndex] < 0 || theirMatches[syncIndex] < 0) {
+			syncIndex++
+		}
+		ourEnd, theirEnd := len(ourLines), len(theirLines)
+		if syncIndex < len(baseLines) {
+			ourEnd, theirEnd = ourMatches[syncIndex], theirMatches[syncIndex]
+		}
+
+		// Merge the chunk before the sync line
+		baseChunk := baseLines[i:syncIndex]
+		ourChunk := ourLines[j:ourEnd]
+		theirChunk := theirLines[k:theirEnd]
+		if equalLines(baseChunk, ourChunk) {
+			merged = append(merged, theirChunk...)
+		} else if equalLines(baseChunk, theirChunk) || equalLines(ourChunk, theirChunk) {
+			merged = append(merged, ourChunk...)
+		} else {
+			result.Conflicts = append(result.Conflicts, &MergeConflict{
+				Line:   len(merged) + 1,
+				Base:   strings.Join(baseChunk, "\n"),
+				Ours:   strings.Join(ourChunk, "\n"),
+				Theirs: strings.Join(theirChunk, "\n"),
+			})
+			merged = append(merged, "<<<<<<< your edit")
+			merged = append(merged, ourChunk...)
+			merged = append(merged, "=======")
+			merged = append(merged, theirChunk...)
+			merged = append(merged, ">>>>>>> live edit")
+		}
+
+		if syncIndex >= len(baseLines) {
+			break
+		}
+		merged = append(merged, baseLines[syncIndex])
+		i, j, k = syncIndex+1, ourEnd+1, theirEnd+1
+	}
+	result.Text = strings.Join(merged, "\n")
+	return result
+}
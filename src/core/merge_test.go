@@ -0,0 +1,44 @@
+package core
+
+import (
+	"testing"
+)
+
+// Make sure changes to different lines are merged cleanly.
+func TestMerge3Clean(t *testing.T) {
+	base := "one\ntwo\nthree\nfour"
+	ours := "one\n2\nthree\nfour"
+	theirs := "one\ntwo\nthree\nfour\nfive"
+	result := Merge3(base, ours, theirs)
+	if !result.IsClean() {
+		t.Fatalf("Unexpected conflicts: %+v", result.Conflicts)
+	}
+	if expected := "one\n2\nthree\nfour\nfive"; result.Text != expected {
+		t.Errorf("Unexpected merge: %q, expected %q", result.Text, expected)
+	}
+
+	// Same change on both sides isn't a conflict
+	result = Merge3(base, ours, ours)
+	if !result.IsClean() || result.Text != ours {
+		t.Errorf("Unexpected merge for identical changes: %+v", result)
+	}
+}
+
+// Make sure changes to the same line are reported as conflicts.
+func TestMerge3Conflict(t *testing.T) {
+	base := "one\ntwo\nthree"
+	ours := "one\nmine\nthree"
+	theirs := "one\nyours\nthree"
+	result := Merge3(base, ours, theirs)
+	if len(result.Conflicts) != 1 {
+		t.Fatalf("Expected one conflict: %+v", result.Conflicts)
+	}
+	conflict := result.Conflicts[0]
+	if conflict.Line != 2 || conflict.Base != "two" || conflict.Ours != "mine" || conflict.Theirs != "yours" {
+		t.Errorf("Unexpected conflict: %+v", conflict)
+	}
+	expected := "one\n<<<<<<< your edit\nmine\n=======\nyours\n>>>>>>> live edit\nthree"
+	if result.Text != expected {
+		t.Errorf("Unexpected merge: %q, expected %q", result.Text, expected)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"math"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"zanaduu3/src/database"
@@ -47,10 +48,21 @@ const (
 	DeleteTeacherChangeLog      = "deleteTeacher"
 	DeletePageChangeLog         = "deletePage"
 	UndeletePageChangeLog       = "undeletePage"
+	ForkPageChangeLog           = "forkPage"
+	ForkedFromChangeLog         = "forkedFrom"
+	SplitPageChangeLog          = "splitPage"
+	SplitFromChangeLog          = "splitFrom"
+	MergePageChangeLog          = "mergePage"
+	MergedIntoChangeLog         = "mergedInto"
+	MovedLensChangeLog          = "movedLens"
 	NewEditChangeLog            = "newEdit"
 	NewEditProposalChangeLog    = "newEditProposal"
+	RejectEditProposalChangeLog = "rejectEditProposal"
+	AcceptedHunksChangeLog      = "acceptedProposalHunks"
 	RevertEditChangeLog         = "revertEdit"
 	NewSnapshotChangeLog        = "newSnapshot"
+	MergeEditsChangeLog         = "mergeEdits"
+	EditConflictChangeLog       = "editConflict"
 	NewAliasChangeLog           = "newAlias"
 	NewExternalUrlChangeLog     = "newExternalUrl"
 	NewSortChildrenByChangeLog  = "newSortChildrenBy"
@@ -223,6 +235,9 @@ type Page struct {
 	RelatedIDs  []string `json:"relatedIds"`
 	MarkIDs     []string `json:"markIds"`
 
+	// Pages with similar text, most similar first
+	SimilarPageIDs []string `json:"similarPageIds"`
+
 	// Page pairs for Concept pages
 	Explanations []*PagePair `json:"explanations"`
 	LearnMore    []*PagePair `json:"learnMore"`
@@ -303,6 +318,7 @@ func NewPage(pageID string) *Page {
 	p.QuestionIDs = make([]string, 0)
 	p.TagIDs = make([]string, 0)
 	p.RelatedIDs = make([]string, 0)
+	p.SimilarPageIDs = make([]string, 0)
 	p.Requirements = make([]*PagePair, 0)
 	p.Subjects = make([]*PagePair, 0)
 	p.ChangeLogs = make([]*ChangeLog, 0)
@@ -369,6 +385,17 @@ type PathPage struct {
 	CreatedAt  string `json:"createdAt"`
 	UpdatedBy  string `json:"updatedBy"`
 	UpdatedAt  string `json:"updatedAt"`
+
+	// If set, this page is an alternate for the path page with this id
+	BranchOf      int64  `json:"branchOf,string"`
+	ConditionType string `json:"conditionType"`
+	QuizPageID    string `json:"quizPageId"`
+	QuizObject    string `json:"quizObject"`
+	QuizValue     string `json:"quizValue"`
+	// Skip this page if the user has all of these masteries
+	SkipIfMasteryIDs []string `json:"skipIfMasteryIds"`
+	// Insert pages for the requisites the user doesn't have before this page
+	InsertRequisites bool `json:"insertRequisites"`
 }
 
 type Path []*PathPage
@@ -384,12 +411,22 @@ type PathInstance struct {
 	GuideID    string              `json:"guideId"`
 	Pages      []*PathInstancePage `json:"pages"`
 	Progress   int                 `json:"progress"`
+	Speed      int                 `json:"speed"` // -1 for slower pages, 1 for faster
 	CreatedAt  string              `json:"createdAt"`
 	UpdatedAt  string              `json:"updatedAt"`
 	IsFinished bool                `json:"isFinished"`
+	// If set, the user started this path as a copy of that instance
+	OriginalInstanceID int64 `json:"originalInstanceId,string"`
+	// True if a teacher created this instance for a group of students
+	IsGroup bool `json:"isGroup"`
+	// Key other readers need to view or copy this instance. Only sent to the owner.
+	ShareKey string `json:"shareKey,omitempty"`
 
 	// True if the path was created by current user
 	IsByCurrentUser bool `json:"isByCurrentUser"`
+	// Estimates for reading the whole path, and the pages the user hasn't reached yet
+	Estimate          *PageEstimate `json:"estimate"`
+	RemainingEstimate *PageEstimate `json:"remainingEstimate"`
 
 	// FE data
 	// Insert these page ids when continuing the path
@@ -407,6 +444,8 @@ func NewPathInstance() *PathInstance {
 type PathInstancePage struct {
 	PageID   string `json:"pageId"`
 	SourceID string `json:"sourceId"`
+	// Id of the path page that added this page, or 0 if it was added otherwise
+	PathPageID int64 `json:"pathPageId,string"`
 }
 
 // User's probability vote
@@ -677,6 +716,15 @@ func ExecuteLoadPipeline(db *database.DB, data *CommonHandlerData) error {
 		return fmt.Errorf("LoadChildIds for related failed: %v", err)
 	}
 
+	// Load similar pages
+	filteredPageMap = filterPageMap(pageMap, func(p *Page) bool { return p.LoadOptions.SimilarPages })
+	err = LoadSimilarPages(db, data, &LoadDataOptions{
+		ForPages: filteredPageMap,
+	})
+	if err != nil {
+		return fmt.Errorf("LoadSimilarPages failed: %v", err)
+	}
+
 	// Load available lenses
 	filteredPageMap = filterPageMap(pageMap, func(p *Page) bool { return p.LoadOptions.Lenses })
 	err = LoadLensesForPages(db, data, &LoadDataOptions{
@@ -2495,6 +2543,39 @@ func LoadLensesForPages(db *database.DB, resultData *CommonHandlerData, options
 	return nil
 }
 
+// LoadSimilarPages loads the ids of the pages with text similar to the given pages.
+// Only pages the current user can see are loaded.
+func LoadSimilarPages(db *database.DB, resultData *CommonHandlerData, options *LoadDataOptions) error {
+	sourcePageMap := options.ForPages
+	if len(sourcePageMap) <= 0 {
+		return nil
+	}
+
+	pageIDs := PageIDsListFromMap(sourcePageMap)
+	rows := database.NewQuery(`
+		SELECT sp.pageId,sp.similarPageId
+		FROM similarPages AS sp
+		JOIN pageInfos AS pi
+		ON (sp.similarPageId=pi.pageId)
+		WHERE sp.pageId IN`).AddArgsGroup(pageIDs).Add(`
+			AND`).AddPart(PageInfosFilter(resultData.User)).Add(`
+		ORDER BY sp.score DESC`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var pageID, similarPageID string
+		err := rows.Scan(&pageID, &similarPageID)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		sourcePageMap[pageID].SimilarPageIDs = append(sourcePageMap[pageID].SimilarPageIDs, similarPageID)
+		AddPageToMap(similarPageID, resultData.PageMap, TitlePlusLoadOptions)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Couldn't load similar pages: %v", err)
+	}
+	return nil
+}
+
 // Load the given lens
 func LoadLens(db *database.DB, id string) (*Lens, error) {
 	var lens *Lens
@@ -2551,15 +2632,24 @@ type ProcessPathPageCallback func(db *database.DB, pathPage *PathPage) error
 func LoadPathPages(db *database.DB, queryPart *database.QueryPart, resultData *CommonHandlerData, callback ProcessPathPageCallback) error {
 	rows := database.NewQuery(`
 		SELECT pathp.id,pathp.guideId,pathp.pathPageId,pathp.pathIndex,
-			pathp.createdBy,pathp.createdAt,pathp.updatedBy,pathp.updatedAt
+			pathp.createdBy,pathp.createdAt,pathp.updatedBy,pathp.updatedAt,
+			pathp.branchOf,pathp.conditionType,pathp.quizPageId,pathp.quizObject,
+			pathp.quizValue,pathp.skipIfMasteryIds,pathp.insertRequisites
 		FROM pathPages AS pathp`).AddPart(queryPart).ToStatement(db).Query()
 	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
 		var pathPage PathPage
+		var skipIfMasteryIDs string
 		err := rows.Scan(&pathPage.ID, &pathPage.GuideID, &pathPage.PathPageID, &pathPage.PathIndex,
-			&pathPage.CreatedBy, &pathPage.CreatedAt, &pathPage.UpdatedBy, &pathPage.UpdatedAt)
+			&pathPage.CreatedBy, &pathPage.CreatedAt, &pathPage.UpdatedBy, &pathPage.UpdatedAt,
+			&pathPage.BranchOf, &pathPage.ConditionType, &pathPage.QuizPageID, &pathPage.QuizObject,
+			&pathPage.QuizValue, &skipIfMasteryIDs, &pathPage.InsertRequisites)
 		if err != nil {
 			return fmt.Errorf("failed to scan: %v", err)
 		}
+		pathPage.SkipIfMasteryIDs = make([]string, 0)
+		if skipIfMasteryIDs != "" {
+			pathPage.SkipIfMasteryIDs = strings.Split(skipIfMasteryIDs, ",")
+		}
 		return callback(db, &pathPage)
 	})
 	if err != nil {
@@ -2612,22 +2702,33 @@ type ProcessPathInstanceCallback func(db *database.DB, pathInstance *PathInstanc
 // Load path instances matching the giving query condition
 func LoadPathInstances(db *database.DB, queryPart *database.QueryPart, u *CurrentUser, callback ProcessPathInstanceCallback) error {
 	rows := database.NewQuery(`
-		SELECT pathi.id,pathi.userId,pathi.guideId,pathi.pageIds,pathi.sourcePageIds,pathi.progress,
-			pathi.createdAt,pathi.updatedAt,pathi.isFinished
+		SELECT pathi.id,pathi.userId,pathi.guideId,pathi.pageIds,pathi.sourcePageIds,pathi.pathPageIds,
+			pathi.progress,pathi.speed,pathi.createdAt,pathi.updatedAt,pathi.isFinished,
+			pathi.originalInstanceId,pathi.isGroup,pathi.shareKey
 		FROM pathInstances AS pathi`).AddPart(queryPart).ToStatement(db).Query()
 	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
 		instance := NewPathInstance()
-		var pageIDs, sourcePageIDs, userID string
-		err := rows.Scan(&instance.ID, &userID, &instance.GuideID, &pageIDs, &sourcePageIDs,
-			&instance.Progress, &instance.CreatedAt, &instance.UpdatedAt, &instance.IsFinished)
+		var pageIDs, sourcePageIDs, pathPageIDs, userID, shareKey string
+		err := rows.Scan(&instance.ID, &userID, &instance.GuideID, &pageIDs, &sourcePageIDs, &pathPageIDs,
+			&instance.Progress, &instance.Speed, &instance.CreatedAt, &instance.UpdatedAt, &instance.IsFinished,
+			&instance.OriginalInstanceID, &instance.IsGroup, &shareKey)
 		if err != nil {
 			return fmt.Errorf("failed to scan: %v", err)
 		}
-		instance.IsByCurrentUser = userID == u.ID
+		instance.IsByCurrentUser = userID == u.GetSomeID()
+		if instance.IsByCurrentUser {
+			instance.ShareKey = shareKey
+		}
 		pageIdsList := strings.Split(pageIDs, ",")
 		sourceIdsList := strings.Split(sourcePageIDs, ",")
+		// Instances started before paths could branch don't have path page ids
+		pathPageIdsList := strings.Split(pathPageIDs, ",")
 		for n, pageID := range pageIdsList {
-			instance.Pages = append(instance.Pages, &PathInstancePage{pageID, sourceIdsList[n]})
+			page := &PathInstancePage{PageID: pageID, SourceID: sourceIdsList[n]}
+			if len(pathPageIdsList) == len(pageIdsList) {
+				page.PathPageID, _ = strconv.ParseInt(pathPageIdsList[n], 10, 64)
+			}
+			instance.Pages = append(instance.Pages, page)
 		}
 		return callback(db, instance)
 	})
@@ -2636,14 +2737,32 @@ func LoadPathInstances(db *database.DB, queryPart *database.QueryPart, u *Curren
 
 // Load path instance with the given id
 func LoadPathInstance(db *database.DB, id string, u *CurrentUser) (*PathInstance, error) {
+	return loadPathInstance(db, database.NewQuery(`WHERE pathi.id=?`, id), u)
+}
+
+// Load path instance with the given id, if it belongs to the current user or
+// the share key matches
+func LoadSharedPathInstance(db *database.DB, id string, shareKey string, u *CurrentUser) (*PathInstance, error) {
+	queryPart := database.NewQuery(`
+		WHERE pathi.id=?`, id).Add(`
+			AND (pathi.userId=? OR (pathi.shareKey!="" AND pathi.shareKey=?))`, u.GetSomeID(), shareKey)
+	return loadPathInstance(db, queryPart, u)
+}
+
+// Load the path instance matching the given query condition, along with its estimates
+func loadPathInstance(db *database.DB, queryPart *database.QueryPart, u *CurrentUser) (*PathInstance, error) {
 	var instance *PathInstance
-	queryPart := database.NewQuery(`WHERE pathi.id=?`, id)
 	err := LoadPathInstances(db, queryPart, u, func(db *database.DB, pathInstance *PathInstance) error {
 		instance = pathInstance
 		return nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't load path instance: %v", err)
+	} else if instance == nil {
+		return nil, nil
+	}
+	if err := LoadPathInstanceEstimate(db, instance); err != nil {
+		return nil, fmt.Errorf("Couldn't load path instance estimate: %v", err)
 	}
 	return instance, nil
 }
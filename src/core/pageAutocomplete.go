@@ -0,0 +1,242 @@
+// pageAutocomplete.go keeps an in-memory index of page aliases, titles and ids
+// for fast autocompletion.
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// How often we check changeLogs for pages that need to be updated in the index
+	pageAutocompleteRefreshPeriod = 10 * time.Second
+	// If more than this many pages changed since the last refresh, we rebuild the whole index
+	pageAutocompleteMaxIncrementalPages = 1000
+)
+
+// pageAutocompleteIndex has a trie for each domain, which contains all the
+// published pages visible in that domain.
+type pageAutocompleteIndex struct {
+	// Guards the fields below. It's only held while the index is read or
+	// changed in memory, never while we query the db.
+	sync.RWMutex
+	// Map: seeDomainId -> trie with all the pages in that domain
+	domainTries map[string]*PageTrie
+	// Map: page id -> seeDomainId of the page
+	pageDomains map[string]string
+	// Id of the last changeLog processed by the index
+	lastChangeLogID int64
+	isLoaded        bool
+	lastRefreshedAt time.Time
+	// Set while a request is loading the changes from the db
+	isRefreshing bool
+
+	// Held while loading the changes from the db, so only one request does it at a time
+	refreshMutex sync.Mutex
+}
+
+// pageAutocompleteRow is a page loaded from the db for the index.
+type pageAutocompleteRow struct {
+	pageID      string
+	alias       string
+	seeDomainID string
+	title       string
+}
+
+var pageAutocomplete = &pageAutocompleteIndex{}
+
+// SearchPageAutocomplete returns up to limit ids of pages visible to the user
+// which have an alias, title or id starting with the given prefix.
+func SearchPageAutocomplete(db *database.DB, u *CurrentUser, prefix string, limit int) ([]string, error) {
+	index := pageAutocomplete
+	if err := index.refresh(db); err != nil {
+		return nil, err
+	}
+
+	index.RLock()
+	defer index.RUnlock()
+
+	matches := make([]*PageTrieMatch, 0)
+	for _, domainID := range GetVisibleDomainIDs(u) {
+		if trie, ok := index.domainTries[domainID]; ok {
+			matches = append(matches, trie.Search(prefix, limit)...)
+		}
+	}
+	sort.Sort(pageTrieMatchList(matches))
+
+	pageIDs := make([]string, 0)
+	for _, match := range matches {
+		if len(pageIDs) >= limit {
+			break
+		}
+		pageIDs = append(pageIDs, match.PageID)
+	}
+	return pageIDs, nil
+}
+
+// Sort matches from different domains so that shorter keys come first
+type pageTrieMatchList []*PageTrieMatch
+
+func (a pageTrieMatchList) Len() int      { return len(a) }
+func (a pageTrieMatchList) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a pageTrieMatchList) Less(i, j int) bool {
+	if len(a[i].Key) != len(a[j].Key) {
+		return len(a[i].Key) < len(a[j].Key)
+	}
+	if a[i].Key != a[j].Key {
+		return a[i].Key < a[j].Key
+	}
+	return a[i].PageID < a[j].PageID
+}
+
+// refresh updates the index with all the pages that changed since the last
+// refresh. Every change to a page creates a changeLog, so we use them to find
+// which pages we need to reload from pageInfos. The changes are loaded without
+// holding the index lock, so other requests keep searching the current index in
+// the meantime. Only the very first load makes them wait.
+func (index *pageAutocompleteIndex) refresh(db *database.DB) error {
+	index.Lock()
+	if index.isLoaded && (index.isRefreshing || time.Since(index.lastRefreshedAt) < pageAutocompleteRefreshPeriod) {
+		index.Unlock()
+		return nil
+	}
+	index.isRefreshing = true
+	index.Unlock()
+
+	index.refreshMutex.Lock()
+	defer index.refreshMutex.Unlock()
+	defer func() {
+		index.Lock()
+		index.isRefreshing = false
+		index.Unlock()
+	}()
+
+	// Only this request changes these fields now, so it's safe to read them
+	// without the lock. Another request might have loaded the index while we
+	// were waiting.
+	if index.isLoaded && time.Since(index.lastRefreshedAt) < pageAutocompleteRefreshPeriod {
+		return nil
+	}
+
+	// Find the pages that changed
+	var lastChangeLogID int64
+	_, err := database.NewQuery(`
+		SELECT ifnull(max(id),0)
+		FROM changeLogs`).ToStatement(db).QueryRow().Scan(&lastChangeLogID)
+	if err != nil {
+		return fmt.Errorf("Couldn't load last changeLog id: %v", err)
+	}
+	changedPageIDs := make([]string, 0)
+	if index.isLoaded && lastChangeLogID > index.lastChangeLogID {
+		rows := database.NewQuery(`
+			SELECT DISTINCT pageId
+			FROM changeLogs
+			WHERE id>?`, index.lastChangeLogID).Add(`AND id<=?`, lastChangeLogID).Add(`
+			LIMIT ?`, pageAutocompleteMaxIncrementalPages+1).ToStatement(db).Query()
+		err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+			var pageID string
+			if err := rows.Scan(&pageID); err != nil {
+				return fmt.Errorf("failed to scan: %v", err)
+			}
+			changedPageIDs = append(changedPageIDs, pageID)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("Couldn't load changed pages: %v", err)
+		}
+	}
+
+	if !index.isLoaded || len(changedPageIDs) > pageAutocompleteMaxIncrementalPages {
+		// Rebuild the whole index, then swap it in
+		pageRows, err := loadPageAutocompleteRows(db, nil)
+		if err != nil {
+			return err
+		}
+		newIndex := &pageAutocompleteIndex{
+			domainTries: make(map[string]*PageTrie),
+			pageDomains: make(map[string]string),
+		}
+		for _, row := range pageRows {
+			newIndex.addPage(row)
+		}
+		index.Lock()
+		index.domainTries = newIndex.domainTries
+		index.pageDomains = newIndex.pageDomains
+	} else if len(changedPageIDs) > 0 {
+		pageRows, err := loadPageAutocompleteRows(db, changedPageIDs)
+		if err != nil {
+			return err
+		}
+		index.Lock()
+		for _, pageID := range changedPageIDs {
+			index.removePage(pageID)
+		}
+		for _, row := range pageRows {
+			index.addPage(row)
+		}
+	} else {
+		index.Lock()
+	}
+
+	index.lastChangeLogID = lastChangeLogID
+	index.lastRefreshedAt = time.Now()
+	index.isLoaded = true
+	index.Unlock()
+	return nil
+}
+
+// loadPageAutocompleteRows loads the given pages (or all pages if pageIDs is nil)
+// from the db.
+func loadPageAutocompleteRows(db *database.DB, pageIDs []string) ([]*pageAutocompleteRow, error) {
+	pageRows := make([]*pageAutocompleteRow, 0)
+	q := database.NewQuery(`
+		SELECT pi.pageId,pi.alias,pi.seeDomainId,p.title
+		FROM pageInfos AS pi
+		JOIN pages AS p
+		ON (p.pageId=pi.pageId AND p.isLiveEdit)
+		WHERE pi.type!=?`, CommentPageType).Add(`
+			AND`).AddPart(PageInfosFilter(nil))
+	if pageIDs != nil {
+		q.Add(`AND pi.pageId IN`).AddArgsGroupStr(pageIDs)
+	}
+	err := q.ToStatement(db).Query().Process(func(db *database.DB, rows *database.Rows) error {
+		var row pageAutocompleteRow
+		if err := rows.Scan(&row.pageID, &row.alias, &row.seeDomainID, &row.title); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		pageRows = append(pageRows, &row)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load pages for autocomplete: %v", err)
+	}
+	return pageRows, nil
+}
+
+func (index *pageAutocompleteIndex) addPage(row *pageAutocompleteRow) {
+	trie, ok := index.domainTries[row.seeDomainID]
+	if !ok {
+		trie = NewPageTrie()
+		index.domainTries[row.seeDomainID] = trie
+	}
+	keys := []string{row.pageID, row.alias}
+	// Allow matching any word in the title, not just the first one
+	words := strings.Fields(row.title)
+	for n := range words {
+		keys = append(keys, strings.Join(words[n:], " "))
+	}
+	trie.Add(row.pageID, keys...)
+	index.pageDomains[row.pageID] = row.seeDomainID
+}
+
+func (index *pageAutocompleteIndex) removePage(pageID string) {
+	if seeDomainID, ok := index.pageDomains[pageID]; ok {
+		index.domainTries[seeDomainID].Remove(pageID)
+		delete(index.pageDomains, pageID)
+	}
+}
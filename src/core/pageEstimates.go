@@ -0,0 +1,300 @@
+// pageEstimates.go contains the functions for estimating how long it takes to
+// read a page, and how difficult it is.
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// Average reading speed for prose
+	ReadingWordsPerMinute = 200
+	// Extra time it takes to read a $$math$$ block
+	DisplayMathSeconds = 15
+	// Extra time it takes to read $inline math$
+	InlineMathSeconds = 3
+	// Fraction of readers we expect to open a %hidden()% block, in percent
+	HiddenTextReadPercent = 50
+
+	// How many levels of requirements we follow when computing difficulty
+	maxDifficultyDepth = 20
+)
+
+var (
+	displayMathRegexp      = regexp.MustCompile("\\$\\$[\\s\\S]+?\\$\\$")
+	inlineMathRegexp       = regexp.MustCompile("\\$[^$\\n]+?\\$")
+	linkUrlRegexp          = regexp.MustCompile("\\]\\([^)\\s]*\\)")
+	hiddenBlockStartRegexp = regexp.MustCompile("(?m)^(%+)hidden\\([^\\n]*?\\): ?")
+)
+
+// PageEstimate is how long it takes to read one or more pages, and how difficult they are.
+type PageEstimate struct {
+	// Estimated reading time
+	ReadingSeconds int `json:"readingSeconds"`
+	// Sum of the levels along the longest chain of requirements
+	Difficulty int `json:"difficulty"`
+}
+
+// splitHiddenText separates the text in %hidden(button): text% blocks from
+// the rest of the text.
+func splitHiddenText(text string) (visible string, hidden string) {
+	visibleParts := make([]string, 0)
+	hiddenParts := make([]string, 0)
+	for {
+		start := hiddenBlockStartRegexp.FindStringSubmatchIndex(text)
+		if start == nil {
+			break
+		}
+		bars := text[start[2]:start[3]]
+		endRegexp := regexp.MustCompile(regexp.QuoteMeta(bars) + " *(?:\\n|$)")
+		end := endRegexp.FindStringIndex(text[start[1]:])
+		if end == nil {
+			break
+		}
+		visibleParts = append(visibleParts, text[:start[0]])
+		hiddenParts = append(hiddenParts, text[start[1]:start[1]+end[0]])
+		text = text[start[1]+end[1]:]
+	}
+	visibleParts = append(visibleParts, text)
+	return strings.Join(visibleParts, "\n"), strings.Join(hiddenParts, "\n")
+}
+
+// estimateTextSeconds returns how long it takes to read the given text, not
+// counting hidden blocks differently.
+func estimateTextSeconds(text string) int {
+	seconds := DisplayMathSeconds * len(displayMathRegexp.FindAllStringIndex(text, -1))
+	text = displayMathRegexp.ReplaceAllString(text, " ")
+	seconds += InlineMathSeconds * len(inlineMathRegexp.FindAllStringIndex(text, -1))
+	text = inlineMathRegexp.ReplaceAllString(text, " ")
+	text = linkUrlRegexp.ReplaceAllString(text, "]")
+	return seconds + len(strings.Fields(text))*60/ReadingWordsPerMinute
+}
+
+// EstimateReadingSeconds returns how long it takes to read the given page text.
+func EstimateReadingSeconds(text string) int {
+	visible, hidden := splitHiddenText(text)
+	return estimateTextSeconds(visible) + estimateTextSeconds(hidden)*HiddenTextReadPercent/100
+}
+
+// ComputeDifficulty returns the sum of the requirement levels along the
+// longest chain of requirements leading to the given page. requirementMap
+// maps page ids to the requirement pairs where they are the child.
+func ComputeDifficulty(pageID string, requirementMap map[string][]*PagePair) int {
+	difficultyMap := make(map[string]int)
+	visiting := make(map[string]bool)
+	var compute func(pageID string, depth int) int
+	compute = func(pageID string, depth int) int {
+		if difficulty, ok := difficultyMap[pageID]; ok {
+			return difficulty
+		}
+		// Don't go around cycles
+		if visiting[pageID] || depth >= maxDifficultyDepth {
+			return 0
+		}
+		visiting[pageID] = true
+		difficulty := 0
+		for _, pair := range requirementMap[pageID] {
+			if pair.ParentID == pageID {
+				continue
+			}
+			level := pair.Level
+			if level <= NoMasteryLevel {
+				level = LooseMasteryLevel
+			}
+			if chain := level + compute(pair.ParentID, depth+1); chain > difficulty {
+				difficulty = chain
+			}
+		}
+		visiting[pageID] = false
+		difficultyMap[pageID] = difficulty
+		return difficulty
+	}
+	return compute(pageID, 0)
+}
+
+// TotalPageEstimates returns the estimate for reading all the given pages:
+// the reading times add up, and the difficulty is the highest one.
+func TotalPageEstimates(pageIDs []string, estimateMap map[string]*PageEstimate) *PageEstimate {
+	total := &PageEstimate{}
+	for _, pageID := range pageIDs {
+		estimate, ok := estimateMap[pageID]
+		if !ok {
+			continue
+		}
+		total.ReadingSeconds += estimate.ReadingSeconds
+		if estimate.Difficulty > total.Difficulty {
+			total.Difficulty = estimate.Difficulty
+		}
+	}
+	return total
+}
+
+// LoadRequirementChains loads the published requirement pairs leading to the
+// given page, following them up to maxDifficultyDepth levels.
+func LoadRequirementChains(db *database.DB, pageID string) (map[string][]*PagePair, error) {
+	requirementMap := make(map[string][]*PagePair)
+	loadedIDs := map[string]bool{pageID: true}
+	childIDs := []string{pageID}
+	for depth := 0; depth < maxDifficultyDepth && len(childIDs) > 0; depth++ {
+		parentIDs := make([]string, 0)
+		queryPart := database.NewQuery(`
+			JOIN pageInfos AS pi
+			ON (pp.parentId=pi.pageId)
+			WHERE pp.type=?`, RequirementPagePairType).Add(`
+				AND pp.everPublished
+				AND pi.currentEdit>0 AND NOT pi.isDeleted
+				AND pp.childId IN`).AddArgsGroupStr(childIDs)
+		err := LoadPagePairs(db, queryPart, func(db *database.DB, pp *PagePair) error {
+			requirementMap[pp.ChildID] = append(requirementMap[pp.ChildID], pp)
+			if !loadedIDs[pp.ParentID] {
+				loadedIDs[pp.ParentID] = true
+				parentIDs = append(parentIDs, pp.ParentID)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't load requirements: %v", err)
+		}
+		childIDs = parentIDs
+	}
+	return requirementMap, nil
+}
+
+// UpdatePageEstimates recomputes the reading time and difficulty of the given
+// page from its newly published text.
+func UpdatePageEstimates(tx *database.Tx, pageID string, text string) error {
+	query, err := newPageEstimatesQuery(tx.DB, pageID, text)
+	if err != nil {
+		return err
+	}
+	if _, err := query.ToTxStatement(tx).Exec(); err != nil {
+		return fmt.Errorf("Couldn't update page estimates: %v", err)
+	}
+	return nil
+}
+
+// UpdatePageEstimatesWithoutTx is the same as UpdatePageEstimates, for callers
+// that aren't publishing an edit and don't need a transaction.
+func UpdatePageEstimatesWithoutTx(db *database.DB, pageID string, text string) error {
+	query, err := newPageEstimatesQuery(db, pageID, text)
+	if err != nil {
+		return err
+	}
+	if _, err := query.ToStatement(db).Exec(); err != nil {
+		return fmt.Errorf("Couldn't update page estimates: %v", err)
+	}
+	return nil
+}
+
+// newPageEstimatesQuery returns the query that saves the reading time and
+// difficulty of the given page.
+func newPageEstimatesQuery(db *database.DB, pageID string, text string) (*database.QueryPart, error) {
+	requirementMap, err := LoadRequirementChains(db, pageID)
+	if err != nil {
+		return nil, err
+	}
+	return database.NewQuery(`
+		UPDATE pageInfos
+		SET readingSeconds=?,difficulty=?`, EstimateReadingSeconds(text), ComputeDifficulty(pageID, requirementMap)).Add(`
+		WHERE pageId=?`, pageID), nil
+}
+
+// UpdatePageDifficulty recomputes the difficulty of the given page after its
+// requirements changed. Returns true if the difficulty changed, in which case
+// the pages that require this one have to be updated as well.
+func UpdatePageDifficulty(db *database.DB, pageID string) (bool, error) {
+	requirementMap, err := LoadRequirementChains(db, pageID)
+	if err != nil {
+		return false, err
+	}
+	var oldDifficulty int
+	row := database.NewQuery(`
+		SELECT difficulty
+		FROM pageInfos
+		WHERE pageId=?`, pageID).ToStatement(db).QueryRow()
+	if exists, err := row.Scan(&oldDifficulty); err != nil {
+		return false, fmt.Errorf("Couldn't load page difficulty: %v", err)
+	} else if !exists {
+		return false, nil
+	}
+	difficulty := ComputeDifficulty(pageID, requirementMap)
+	if difficulty == oldDifficulty {
+		return false, nil
+	}
+	statement := database.NewQuery(`
+		UPDATE pageInfos
+		SET difficulty=?`, difficulty).Add(`
+		WHERE pageId=?`, pageID).ToStatement(db)
+	if _, err := statement.Exec(); err != nil {
+		return false, fmt.Errorf("Couldn't update page difficulty: %v", err)
+	}
+	return true, nil
+}
+
+// LoadRequiringPageIDs loads the ids of the pages which have the given page as
+// a published requirement.
+func LoadRequiringPageIDs(db *database.DB, pageID string) ([]string, error) {
+	pageIDs := make([]string, 0)
+	queryPart := database.NewQuery(`
+		WHERE pp.type=?`, RequirementPagePairType).Add(`
+			AND pp.everPublished
+			AND pp.parentId=?`, pageID).Add(`
+			AND pp.childId!=?`, pageID)
+	err := LoadPagePairs(db, queryPart, func(db *database.DB, pp *PagePair) error {
+		pageIDs = append(pageIDs, pp.ChildID)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load requiring pages: %v", err)
+	}
+	return pageIDs, nil
+}
+
+// LoadPageEstimates loads the stored estimates for the given pages.
+func LoadPageEstimates(db *database.DB, pageIDs []string) (map[string]*PageEstimate, error) {
+	estimateMap := make(map[string]*PageEstimate)
+	if len(pageIDs) <= 0 {
+		return estimateMap, nil
+	}
+	rows := database.NewQuery(`
+		SELECT pageId,readingSeconds,difficulty
+		FROM pageInfos
+		WHERE pageId IN`).AddArgsGroupStr(pageIDs).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var pageID string
+		var estimate PageEstimate
+		if err := rows.Scan(&pageID, &estimate.ReadingSeconds, &estimate.Difficulty); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		estimateMap[pageID] = &estimate
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load page estimates: %v", err)
+	}
+	return estimateMap, nil
+}
+
+// LoadPathInstanceEstimate sets the estimates for reading the whole path, and
+// for the pages the user hasn't reached yet.
+func LoadPathInstanceEstimate(db *database.DB, instance *PathInstance) error {
+	pageIDs := make([]string, 0)
+	for _, page := range instance.Pages {
+		pageIDs = append(pageIDs, page.PageID)
+	}
+	estimateMap, err := LoadPageEstimates(db, pageIDs)
+	if err != nil {
+		return err
+	}
+	instance.Estimate = TotalPageEstimates(pageIDs, estimateMap)
+	remainingIDs := make([]string, 0)
+	if instance.Progress >= 0 && instance.Progress < len(pageIDs) {
+		remainingIDs = pageIDs[instance.Progress:]
+	}
+	instance.RemainingEstimate = TotalPageEstimates(remainingIDs, estimateMap)
+	return nil
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// Make sure math blocks add time and hidden text counts for less.
+func TestEstimateReadingSeconds(t *testing.T) {
+	words := strings.Repeat("word ", 400)
+	if seconds := EstimateReadingSeconds(words); seconds != 120 {
+		t.Errorf("Unexpected estimate for plain text: %d", seconds)
+	}
+
+	text := words + "\n$$x^2 + y^2 = z^2$$\nwhere $x$ and $y$ are [legs](http://example.com/legs)\n"
+	if seconds := EstimateReadingSeconds(text); seconds != 120+DisplayMathSeconds+2*InlineMathSeconds+1 {
+		t.Errorf("Unexpected estimate for text with math: %d", seconds)
+	}
+
+	text = words + "\n%%hidden(Show solution):\n" + words + "\n%%\n"
+	if seconds := EstimateReadingSeconds(text); seconds != 120+120*HiddenTextReadPercent/100 {
+		t.Errorf("Unexpected estimate for text with hidden blocks: %d", seconds)
+	}
+}
+
+// Make sure difficulty follows the longest chain of requirements and doesn't
+// get stuck on cycles.
+func TestComputeDifficulty(t *testing.T) {
+	requirementMap := map[string][]*PagePair{
+		"1": {
+			&PagePair{ParentID: "2", ChildID: "1", Level: BasicMasteryLevel},
+			&PagePair{ParentID: "3", ChildID: "1", Level: TechnicalMasteryLevel},
+			&PagePair{ParentID: "1", ChildID: "1", Level: ResearchMasteryLevel},
+		},
+		"2": {&PagePair{ParentID: "4", ChildID: "2", Level: TechnicalMasteryLevel}},
+		"4": {&PagePair{ParentID: "2", ChildID: "4"}},
+	}
+	// 1 <- 2 (basic) <- 4 (technical) <- 2 (loose, but it's a cycle)
+	if difficulty := ComputeDifficulty("1", requirementMap); difficulty != BasicMasteryLevel+TechnicalMasteryLevel+LooseMasteryLevel {
+		t.Errorf("Unexpected difficulty: %d", difficulty)
+	}
+	if difficulty := ComputeDifficulty("3", requirementMap); difficulty != 0 {
+		t.Errorf("Unexpected difficulty without requirements: %d", difficulty)
+	}
+
+	total := TotalPageEstimates([]string{"1", "2", "5"}, map[string]*PageEstimate{
+		"1": &PageEstimate{ReadingSeconds: 60, Difficulty: 3},
+		"2": &PageEstimate{ReadingSeconds: 30, Difficulty: 5},
+	})
+	if total.ReadingSeconds != 90 || total.Difficulty != 5 {
+		t.Errorf("Unexpected total: %+v", total)
+	}
+}
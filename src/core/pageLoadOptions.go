@@ -25,6 +25,7 @@ type PageLoadOptions struct {
 	Parents                 bool
 	Tags                    bool
 	Related                 bool
+	SimilarPages            bool
 	Lenses                  bool
 	Path                    bool
 	Requisites              bool
@@ -0,0 +1,269 @@
+// pageOperations.go contains helpers for changing pages outside of the normal
+// edit flow: saving and reverting edits directly, and splitting pages.
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"zanaduu3/src/database"
+	"zanaduu3/src/sessions"
+)
+
+var (
+	// Matches a markdown heading line, e.g. "## Examples"
+	headingRegexp = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+)
+
+// NewLiveEditOptions describe an edit that becomes live right away.
+type NewLiveEditOptions struct {
+	PageID    string
+	CreatorID string
+	// The edit is only saved if this is still the page's live edit
+	ExpectedEdit int
+
+	Title         string
+	Clickbait     string
+	Text          string
+	MetaText      string
+	AnchorContext string
+	AnchorText    string
+	AnchorOffset  int
+
+	IsMinorEdit   bool
+	EditSummary   string
+	ChangeLogType string
+}
+
+// SaveNewLiveEdit creates a new live edit for the page, as long as the page's
+// live edit is still options.ExpectedEdit. Returns the number of the new edit
+// and whether it was created.
+func SaveNewLiveEdit(tx *database.Tx, options *NewLiveEditOptions) (int, bool, error) {
+	var currentEdit, maxEdit int
+	row := database.NewQuery(`
+		SELECT currentEdit,maxEdit
+		FROM pageInfos
+		WHERE pageId=?
+		FOR UPDATE`, options.PageID).ToTxStatement(tx).QueryRow()
+	if _, err := row.Scan(&currentEdit, &maxEdit); err != nil {
+		return 0, false, fmt.Errorf("Couldn't load pageInfo: %v", err)
+	} else if currentEdit != options.ExpectedEdit {
+		return 0, false, nil
+	}
+	newEdit := maxEdit + 1
+
+	statement := database.NewQuery(`
+		UPDATE pages
+		SET isLiveEdit=FALSE
+		WHERE pageId=?`, options.PageID).ToTxStatement(tx)
+	if _, err := statement.Exec(); err != nil {
+		return 0, false, fmt.Errorf("Couldn't update isLiveEdit: %v", err)
+	}
+
+	hashmap := make(database.InsertMap)
+	hashmap["pageId"] = options.PageID
+	hashmap["edit"] = newEdit
+	hashmap["prevEdit"] = currentEdit
+	hashmap["creatorId"] = options.CreatorID
+	hashmap["title"] = options.Title
+	hashmap["clickbait"] = options.Clickbait
+	hashmap["text"] = options.Text
+	hashmap["metaText"] = options.MetaText
+	hashmap["todoCount"] = ExtractTodoCount(options.Text)
+	hashmap["isLiveEdit"] = true
+	hashmap["isMinorEdit"] = options.IsMinorEdit
+	hashmap["editSummary"] = options.EditSummary
+	hashmap["createdAt"] = database.Now()
+	hashmap["anchorContext"] = options.AnchorContext
+	hashmap["anchorText"] = options.AnchorText
+	hashmap["anchorOffset"] = options.AnchorOffset
+	statement = tx.DB.NewInsertStatement("pages", hashmap).WithTx(tx)
+	if _, err := statement.Exec(); err != nil {
+		return 0, false, fmt.Errorf("Couldn't insert a new page: %v", err)
+	}
+
+	hashmap = make(database.InsertMap)
+	hashmap["pageId"] = options.PageID
+	hashmap["currentEdit"] = newEdit
+	hashmap["maxEdit"] = newEdit
+	statement = tx.DB.NewInsertStatement("pageInfos", hashmap, hashmap.GetKeys()...).WithTx(tx)
+	if _, err := statement.Exec(); err != nil {
+		return 0, false, fmt.Errorf("Couldn't update pageInfos: %v", err)
+	}
+
+	_, err := InsertChangeLog(tx, &ChangeLog{
+		PageID:           options.PageID,
+		UserID:           options.CreatorID,
+		Edit:             newEdit,
+		Type:             options.ChangeLogType,
+		NewSettingsValue: options.EditSummary,
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	if err := UpdatePageSummaries(tx, options.PageID, options.Text); err != nil {
+		return 0, false, err
+	}
+	if err := UpdatePageEstimates(tx, options.PageID, options.Text); err != nil {
+		return 0, false, err
+	}
+	if err := UpdatePageLinks(tx, options.PageID, options.Text, sessions.GetDomain()); err != nil {
+		return 0, false, err
+	}
+	return newEdit, true, nil
+}
+
+// InsertChangeLog adds the given change log and returns its id.
+func InsertChangeLog(tx *database.Tx, changeLog *ChangeLog) (int64, error) {
+	hashmap := make(database.InsertMap)
+	hashmap["pageId"] = changeLog.PageID
+	hashmap["userId"] = changeLog.UserID
+	hashmap["edit"] = changeLog.Edit
+	hashmap["type"] = changeLog.Type
+	hashmap["createdAt"] = database.Now()
+	hashmap["auxPageId"] = changeLog.AuxPageID
+	hashmap["oldSettingsValue"] = changeLog.OldSettingsValue
+	hashmap["newSettingsValue"] = changeLog.NewSettingsValue
+	statement := tx.DB.NewInsertStatement("changeLogs", hashmap).WithTx(tx)
+	result, err := statement.Exec()
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't insert change log: %v", err)
+	}
+	changeLogID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't get changeLog id: %v", err)
+	}
+	return changeLogID, nil
+}
+
+// TextSection is a part of a page's text that starts with a heading.
+type TextSection struct {
+	// Heading text, without the #s. Empty for the text before the first heading.
+	Heading string `json:"heading"`
+	// The full text of the section, including the heading line
+	Text string `json:"text"`
+}
+
+// Body returns the section's text without the heading line.
+func (s *TextSection) Body() string {
+	if s.Heading == "" {
+		return s.Text
+	}
+	if index := strings.Index(s.Text, "\n"); index >= 0 {
+		return strings.TrimLeft(s.Text[index+1:], "\n")
+	}
+	return ""
+}
+
+// SplitTextIntoSections splits the markdown text at its top-level headings (the
+// highest level of heading used in the text). Subheadings stay in their section.
+// Joining the sections' texts gives back the original text.
+func SplitTextIntoSections(text string) []*TextSection {
+	lines := strings.SplitAfter(text, "\n")
+
+	// Find the headings outside of code blocks
+	headingLevels := make([]int, len(lines))
+	headings := make([]string, len(lines))
+	topLevel := 7
+	inCodeBlock := false
+	for n, line := range lines {
+		trimmedLine := strings.TrimRight(line, "\n")
+		if strings.HasPrefix(trimmedLine, "```") || strings.HasPrefix(trimmedLine, "~~~") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock {
+			continue
+		}
+		if submatch := headingRegexp.FindStringSubmatch(trimmedLine); submatch != nil {
+			headingLevels[n] = len(submatch[1])
+			headings[n] = submatch[2]
+			if headingLevels[n] < topLevel {
+				topLevel = headingLevels[n]
+			}
+		}
+	}
+
+	sections := make([]*TextSection, 0)
+	current := &TextSection{}
+	for n, line := range lines {
+		if headingLevels[n] == topLevel {
+			if current.Text != "" || current.Heading != "" {
+				sections = append(sections, current)
+			}
+			current = &TextSection{Heading: headings[n]}
+		}
+		current.Text += line
+	}
+	if current.Text != "" || current.Heading != "" {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+// RevertToEdit makes the given edit of the page live again, the same way
+// editPageHandler reverts a page, as long as the page's live edit is still
+// expectedEdit. Returns the id of the revert change log and whether the page was
+// reverted.
+func RevertToEdit(tx *database.Tx, pageID, userID string, expectedEdit, toEdit int) (int64, bool, error) {
+	var currentEdit int
+	row := database.NewQuery(`
+		SELECT currentEdit
+		FROM pageInfos
+		WHERE pageId=?
+		FOR UPDATE`, pageID).ToTxStatement(tx).QueryRow()
+	if _, err := row.Scan(&currentEdit); err != nil {
+		return 0, false, fmt.Errorf("Couldn't load pageInfo: %v", err)
+	} else if currentEdit != expectedEdit {
+		return 0, false, nil
+	}
+
+	var text string
+	row = database.NewQuery(`
+		SELECT text
+		FROM pages
+		WHERE pageId=? AND edit=?`, pageID, toEdit).ToTxStatement(tx).QueryRow()
+	if exists, err := row.Scan(&text); err != nil {
+		return 0, false, fmt.Errorf("Couldn't load the edit: %v", err)
+	} else if !exists {
+		return 0, false, fmt.Errorf("Edit %d of page %s doesn't exist", toEdit, pageID)
+	}
+
+	statement := database.NewQuery(`
+		UPDATE pages
+		SET isLiveEdit=(edit=?)`, toEdit).Add(`
+		WHERE pageId=?`, pageID).ToTxStatement(tx)
+	if _, err := statement.Exec(); err != nil {
+		return 0, false, fmt.Errorf("Couldn't update isLiveEdit: %v", err)
+	}
+	statement = database.NewQuery(`
+		UPDATE pageInfos
+		SET currentEdit=?`, toEdit).Add(`
+		WHERE pageId=?`, pageID).ToTxStatement(tx)
+	if _, err := statement.Exec(); err != nil {
+		return 0, false, fmt.Errorf("Couldn't update pageInfos: %v", err)
+	}
+
+	changeLogID, err := InsertChangeLog(tx, &ChangeLog{
+		PageID: pageID,
+		UserID: userID,
+		Edit:   toEdit,
+		Type:   RevertEditChangeLog,
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	if err := UpdatePageSummaries(tx, pageID, text); err != nil {
+		return 0, false, err
+	}
+	if err := UpdatePageEstimates(tx, pageID, text); err != nil {
+		return 0, false, err
+	}
+	if err := UpdatePageLinks(tx, pageID, text, sessions.GetDomain()); err != nil {
+		return 0, false, err
+	}
+	return changeLogID, true, nil
+}
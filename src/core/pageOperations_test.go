@@ -0,0 +1,39 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// Make sure the text is split at its top-level headings, ignoring code blocks.
+func TestSplitTextIntoSections(t *testing.T) {
+	text := "Intro text.\n\n## First\nOne.\n### Sub\nStill one.\n```\n## Not a heading\n```\n## Second ##\nTwo.\n"
+	sections := SplitTextIntoSections(text)
+	expectedHeadings := []string{"", "First", "Second"}
+	if len(sections) != len(expectedHeadings) {
+		t.Fatalf("Unexpected number of sections: %d", len(sections))
+	}
+	rebuilt := ""
+	for n, section := range sections {
+		if section.Heading != expectedHeadings[n] {
+			t.Errorf("Section %d has heading %q, expected %q", n, section.Heading, expectedHeadings[n])
+		}
+		rebuilt += section.Text
+	}
+	if rebuilt != text {
+		t.Errorf("Sections don't add up to the original text: %q", rebuilt)
+	}
+	if !strings.Contains(sections[1].Text, "## Not a heading") {
+		t.Errorf("Code block was split: %q", sections[1].Text)
+	}
+	if body := sections[2].Body(); body != "Two.\n" {
+		t.Errorf("Unexpected body: %q", body)
+	}
+	if body := sections[0].Body(); body != "Intro text.\n\n" {
+		t.Errorf("Unexpected intro body: %q", body)
+	}
+
+	if sections := SplitTextIntoSections("No headings"); len(sections) != 1 || sections[0].Heading != "" {
+		t.Errorf("Unexpected sections for text without headings: %v", sections)
+	}
+}
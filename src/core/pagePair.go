@@ -416,3 +416,23 @@ func CreateNewPagePair(db *database.DB, u *CurrentUser, options *CreateNewPagePa
 	}
 	return fmt.Sprintf("%d", pagePairID), nil
 }
+
+// LoadParentAndTagIDs returns the ids of the parents and tags of the given page.
+func LoadParentAndTagIDs(db *database.DB, pageID string) ([]string, []string, error) {
+	parentIDs := make([]string, 0)
+	tagIDs := make([]string, 0)
+	queryPart := database.NewQuery(`
+		WHERE pp.childId=? AND pp.type IN (?,?)`, pageID, ParentPagePairType, TagPagePairType)
+	err := LoadPagePairs(db, queryPart, func(db *database.DB, pp *PagePair) error {
+		if pp.Type == ParentPagePairType {
+			parentIDs = append(parentIDs, pp.ParentID)
+		} else {
+			tagIDs = append(tagIDs, pp.ParentID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Couldn't load parents and tags: %v", err)
+	}
+	return parentIDs, tagIDs, nil
+}
@@ -0,0 +1,91 @@
+// pageTemplate.go contains the functions for creating new pages from domain templates.
+package core
+
+import (
+	"fmt"
+	"regexp"
+
+	"zanaduu3/src/database"
+)
+
+var (
+	// Matches a placeholder variable, e.g. {{title}}
+	templatePlaceholderRegexp = regexp.MustCompile(`\{\{\s*([a-zA-Z]+)\s*\}\}`)
+)
+
+// PageTemplate is a page a domain offers as a starting point for new pages.
+type PageTemplate struct {
+	DomainID  string `json:"domainId"`
+	PageID    string `json:"pageId"`
+	CreatedBy string `json:"createdBy"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// TemplateValues are the values substituted for the placeholders in a template.
+type TemplateValues struct {
+	Title  string
+	Author string
+	Date   string
+	Parent string
+}
+
+// ExpandTemplatePlaceholders replaces {{title}}, {{author}}, {{date}} and
+// {{parent}} in the text. Unknown placeholders are left alone.
+func ExpandTemplatePlaceholders(text string, values *TemplateValues) string {
+	return templatePlaceholderRegexp.ReplaceAllStringFunc(text, func(match string) string {
+		name := templatePlaceholderRegexp.FindStringSubmatch(match)[1]
+		switch name {
+		case "title":
+			return values.Title
+		case "author":
+			return values.Author
+		case "date":
+			return values.Date
+		case "parent":
+			return values.Parent
+		}
+		return match
+	})
+}
+
+// LoadPageTemplates loads the templates of the given domains.
+func LoadPageTemplates(db *database.DB, domainIDs []string) ([]*PageTemplate, error) {
+	templates := make([]*PageTemplate, 0)
+	if len(domainIDs) <= 0 {
+		return templates, nil
+	}
+	rows := database.NewQuery(`
+		SELECT pt.domainId,pt.pageId,pt.createdBy,pt.createdAt
+		FROM pageTemplates AS pt
+		JOIN pageInfos AS pi
+		ON (pt.pageId=pi.pageId)
+		WHERE pt.domainId IN`).AddArgsGroupStr(domainIDs).Add(`
+			AND`).AddPart(PageInfosFilter(nil)).Add(`
+		ORDER BY pt.domainId,pt.createdAt`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var template PageTemplate
+		err := rows.Scan(&template.DomainID, &template.PageID, &template.CreatedBy, &template.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		templates = append(templates, &template)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load page templates: %v", err)
+	}
+	return templates, nil
+}
+
+// IsPageTemplateInDomains returns true iff the page is a template in one of the given domains.
+func IsPageTemplateInDomains(db *database.DB, pageID string, domainIDs []string) (bool, error) {
+	var count int
+	row := database.NewQuery(`
+		SELECT COUNT(*)
+		FROM pageTemplates
+		WHERE pageId=?`, pageID).Add(`AND domainId IN`).AddArgsGroupStr(domainIDs).ToStatement(db).QueryRow()
+	if _, err := row.Scan(&count); err != nil {
+		return false, fmt.Errorf("Couldn't check page template: %v", err)
+	}
+	return count > 0, nil
+}
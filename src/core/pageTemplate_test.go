@@ -0,0 +1,15 @@
+package core
+
+import (
+	"testing"
+)
+
+// Make sure known placeholders are expanded and unknown ones are left alone.
+func TestExpandTemplatePlaceholders(t *testing.T) {
+	values := &TemplateValues{Title: "Bayes' rule", Author: "Alice Smith", Date: "2016-05-04", Parent: "Probability"}
+	text := "# {{title}}\nBy {{ author }} on {{date}}, part of {{parent}}. {{unknown}} {{title"
+	expected := "# Bayes' rule\nBy Alice Smith on 2016-05-04, part of Probability. {{unknown}} {{title"
+	if result := ExpandTemplatePlaceholders(text, values); result != expected {
+		t.Errorf("Unexpected expansion: %q", result)
+	}
+}
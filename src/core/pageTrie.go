@@ -0,0 +1,163 @@
+// pageTrie.go contains a prefix tree for looking up pages by aliases, titles and ids.
+package core
+
+import (
+	"sort"
+	"strings"
+)
+
+// PageTrie maps string keys to page ids and allows looking pages up by key prefix.
+// It's not safe for concurrent use.
+type PageTrie struct {
+	root *pageTrieNode
+	// Map: page id -> keys added for that page
+	pageKeys map[string][]string
+}
+
+type pageTrieNode struct {
+	children map[rune]*pageTrieNode
+	// Ids of the pages whose key ends at this node
+	pageIDs []string
+}
+
+// PageTrieMatch is a page found in the trie along with the key that matched.
+type PageTrieMatch struct {
+	PageID string
+	Key    string
+}
+
+type runeList []rune
+
+func (a runeList) Len() int           { return len(a) }
+func (a runeList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a runeList) Less(i, j int) bool { return a[i] < a[j] }
+
+// NewPageTrie returns an empty trie.
+func NewPageTrie() *PageTrie {
+	return &PageTrie{
+		root:     newPageTrieNode(),
+		pageKeys: make(map[string][]string),
+	}
+}
+
+func newPageTrieNode() *pageTrieNode {
+	return &pageTrieNode{children: make(map[rune]*pageTrieNode)}
+}
+
+// NormalizePageTrieKey converts the given string into the form in which it's stored in the trie.
+func NormalizePageTrieKey(key string) string {
+	return strings.Join(strings.Fields(strings.ToLower(key)), " ")
+}
+
+// Add adds the given keys for the page. Keys are normalized first.
+func (t *PageTrie) Add(pageID string, keys ...string) {
+	for _, key := range keys {
+		key = NormalizePageTrieKey(key)
+		if key == "" || IsStringInList(key, t.pageKeys[pageID]) {
+			continue
+		}
+		node := t.root
+		for _, r := range key {
+			child, ok := node.children[r]
+			if !ok {
+				child = newPageTrieNode()
+				node.children[r] = child
+			}
+			node = child
+		}
+		node.pageIDs = append(node.pageIDs, pageID)
+		t.pageKeys[pageID] = append(t.pageKeys[pageID], key)
+	}
+}
+
+// Remove removes all the keys for the given page.
+func (t *PageTrie) Remove(pageID string) {
+	for _, key := range t.pageKeys[pageID] {
+		node := t.root
+		path := []*pageTrieNode{node}
+		runes := []rune(key)
+		for _, r := range runes {
+			node = node.children[r]
+			if node == nil {
+				break
+			}
+			path = append(path, node)
+		}
+		if node == nil {
+			continue
+		}
+		for n, id := range node.pageIDs {
+			if id == pageID {
+				node.pageIDs = append(node.pageIDs[:n], node.pageIDs[n+1:]...)
+				break
+			}
+		}
+		// Prune the nodes which no longer lead anywhere
+		for n := len(runes) - 1; n >= 0; n-- {
+			child := path[n+1]
+			if len(child.pageIDs) > 0 || len(child.children) > 0 {
+				break
+			}
+			delete(path[n].children, runes[n])
+		}
+	}
+	delete(t.pageKeys, pageID)
+}
+
+// Has returns true iff the page has been added to the trie.
+func (t *PageTrie) Has(pageID string) bool {
+	_, ok := t.pageKeys[pageID]
+	return ok
+}
+
+// Search returns up to limit pages which have a key starting with the given
+// prefix. Shorter keys come first, so an exact match is always the first result.
+// Each page is returned at most once.
+func (t *PageTrie) Search(prefix string, limit int) []*PageTrieMatch {
+	matches := make([]*PageTrieMatch, 0)
+	prefix = NormalizePageTrieKey(prefix)
+	if prefix == "" || limit <= 0 {
+		return matches
+	}
+
+	node := t.root
+	for _, r := range prefix {
+		node = node.children[r]
+		if node == nil {
+			return matches
+		}
+	}
+
+	// Breadth-first search, so that we find shorter keys first
+	type queueItem struct {
+		node *pageTrieNode
+		key  string
+	}
+	seenPageIDs := make(map[string]bool)
+	queue := []*queueItem{&queueItem{node: node, key: prefix}}
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+		for _, pageID := range item.node.pageIDs {
+			if seenPageIDs[pageID] {
+				continue
+			}
+			seenPageIDs[pageID] = true
+			matches = append(matches, &PageTrieMatch{PageID: pageID, Key: item.key})
+			if len(matches) >= limit {
+				return matches
+			}
+		}
+
+		// Go through the children in a deterministic order
+		runes := make([]rune, 0, len(item.node.children))
+		for r := range item.node.children {
+			runes = append(runes, r)
+		}
+		sort.Sort(runeList(runes))
+		for _, r := range runes {
+			queue = append(queue, &queueItem{node: item.node.children[r], key: item.key + string(r)})
+		}
+	}
+	return matches
+}
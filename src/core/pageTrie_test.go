@@ -0,0 +1,53 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func matchedPageIDs(matches []*PageTrieMatch) []string {
+	pageIDs := make([]string, 0)
+	for _, match := range matches {
+		pageIDs = append(pageIDs, match.PageID)
+	}
+	return pageIDs
+}
+
+// Make sure shorter keys come first and each page is returned once.
+func TestPageTrieSearch(t *testing.T) {
+	trie := NewPageTrie()
+	trie.Add("1", "bayes_rule", "Bayes' rule", "rule")
+	trie.Add("2", "bayes", "Bayes")
+	trie.Add("3", "bayesian_update", "Bayesian update")
+
+	if ids := matchedPageIDs(trie.Search("BAYES", 10)); !reflect.DeepEqual(ids, []string{"2", "1", "3"}) {
+		t.Errorf("Unexpected results: %v", ids)
+	}
+	if ids := matchedPageIDs(trie.Search("bayes", 2)); !reflect.DeepEqual(ids, []string{"2", "1"}) {
+		t.Errorf("Limit not respected: %v", ids)
+	}
+	if ids := matchedPageIDs(trie.Search("ru", 10)); !reflect.DeepEqual(ids, []string{"1"}) {
+		t.Errorf("Unexpected results: %v", ids)
+	}
+	if ids := matchedPageIDs(trie.Search("xyz", 10)); len(ids) != 0 {
+		t.Errorf("Unexpected results: %v", ids)
+	}
+}
+
+// Make sure removed pages are no longer found, and other pages are unaffected.
+func TestPageTrieRemove(t *testing.T) {
+	trie := NewPageTrie()
+	trie.Add("1", "bayes_rule")
+	trie.Add("2", "bayes")
+	trie.Remove("1")
+
+	if trie.Has("1") {
+		t.Errorf("Page is still in the trie")
+	}
+	if ids := matchedPageIDs(trie.Search("bayes", 10)); !reflect.DeepEqual(ids, []string{"2"}) {
+		t.Errorf("Unexpected results: %v", ids)
+	}
+	if ids := matchedPageIDs(trie.Search("bayes_", 10)); len(ids) != 0 {
+		t.Errorf("Unexpected results: %v", ids)
+	}
+}
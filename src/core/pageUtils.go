@@ -35,6 +35,11 @@ const (
 	FeaturedClassPageID           = "4yl"
 	HubPageID                     = "5ls"
 	ConceptPageID                 = "6cc"
+	JustARequisitePageID          = "22t"
+	OutOfDatePageID               = "15r"
+	SlowDownPageID                = "6b4"
+	SpeedUpPageID                 = "6b5"
+	WorkInProgressPageID          = "4v"
 
 	MathDomainID = 1
 )
@@ -213,6 +218,25 @@ func ExtractPageLinks(text string, configAddress string) []string {
 	return aliasesAndIDs
 }
 
+// UpdatePageSummaries replaces the summaries of the given page with the ones
+// extracted from the text.
+func UpdatePageSummaries(tx *database.Tx, pageID string, text string) error {
+	statement := database.NewQuery(`
+		DELETE FROM pageSummaries WHERE pageId=?`, pageID).ToTxStatement(tx)
+	if _, err := statement.Exec(); err != nil {
+		return fmt.Errorf("Couldn't delete existing page summaries: %v", err)
+	}
+
+	_, summaryValues := ExtractSummaries(pageID, text)
+	statement = tx.DB.NewStatement(`
+		INSERT INTO pageSummaries (pageId,name,text)
+		VALUES ` + database.ArgsPlaceholder(len(summaryValues), 3)).WithTx(tx)
+	if _, err := statement.Exec(summaryValues...); err != nil {
+		return fmt.Errorf("Couldn't insert page summaries: %v", err)
+	}
+	return nil
+}
+
 // UpdatePageLinks updates the links table for the given page by parsing the text.
 func UpdatePageLinks(tx *database.Tx, pageID string, text string, configAddress string) error {
 	// Delete old links.
@@ -436,6 +460,7 @@ type CreateNewPageOptions struct {
 
 	// Additional options
 	ParentIDs []string
+	TagIDs    []string
 	// If creating a new comment, this is the id of the page to which the comment belongs...
 	CommentPrimaryPageID string
 	Tx                   *database.Tx
@@ -583,6 +608,20 @@ func CreateNewPage(db *database.DB, u *CurrentUser, options *CreateNewPageOption
 			ParentID: parentIDStr,
 			ChildID:  options.PageID,
 			Type:     ParentPagePairType,
+			Tx:       options.Tx,
+		})
+		if err != nil {
+			return "", fmt.Errorf("Couldn't create a new page pair: %v", err)
+		}
+	}
+
+	// Add tags
+	for _, tagID := range options.TagIDs {
+		_, err := CreateNewPagePair(db, u, &CreateNewPagePairOptions{
+			ParentID: tagID,
+			ChildID:  options.PageID,
+			Type:     TagPagePairType,
+			Tx:       options.Tx,
 		})
 		if err != nil {
 			return "", fmt.Errorf("Couldn't create a new page pair: %v", err)
@@ -0,0 +1,322 @@
+// pathAnalytics.go contains the functions for computing how readers move through guided paths.
+package core
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"zanaduu3/src/database"
+)
+
+// PathAnalytics summarizes how readers went through a guide's path.
+// Corresponds to one row from the pathAnalytics table.
+type PathAnalytics struct {
+	GuideID string `json:"guideId"`
+	// Number of path instances started
+	Starts int `json:"starts"`
+	// Number of path instances finished
+	Finishes       int     `json:"finishes"`
+	CompletionRate float64 `json:"completionRate"`
+	ComputedAt     string  `json:"computedAt"`
+
+	// Pages readers read on the path, in path order
+	Steps []*PathStepAnalytics `json:"steps"`
+	// Pages that were inserted for requisites, most common first
+	Requisites []*PathStepAnalytics `json:"requisites"`
+}
+
+// PathStepAnalytics summarizes how readers went through one page on a path.
+// Corresponds to one row from the pathStepAnalytics table.
+type PathStepAnalytics struct {
+	PageID string `json:"pageId"`
+	// True if the page was inserted for a requisite, rather than being one of the path's pages
+	IsRequisite bool `json:"isRequisite"`
+	// Number of path instances that had this page
+	Inserted int `json:"inserted"`
+	// Number of path instances that got to this page
+	Reached int `json:"reached"`
+	// Number of unfinished path instances where the reader stopped on this page
+	DroppedOff int `json:"droppedOff"`
+	// Median time readers spent on this page, in seconds. 0 if unknown.
+	MedianSeconds int `json:"medianSeconds"`
+}
+
+// Return true iff the given page on the instance was inserted for a requisite.
+func isRequisitePathPage(instance *PathInstance, page *PathInstancePage, stepMap map[int64]*PathPage) bool {
+	if page.SourceID != instance.GuideID {
+		return true
+	}
+	step, ok := stepMap[page.PathPageID]
+	return ok && step.PathPageID != page.PageID
+}
+
+// Return the median of the given durations, or 0 if there are none.
+func medianSeconds(durations []int) int {
+	if len(durations) <= 0 {
+		return 0
+	}
+	sort.Ints(durations)
+	middle := len(durations) / 2
+	if len(durations)%2 == 0 {
+		return (durations[middle-1] + durations[middle]) / 2
+	}
+	return durations[middle]
+}
+
+// ComputePathAnalytics computes the analytics for a guide from all the path
+// instances readers started. firstVisits maps instance id -> page id -> when
+// the reader first visited the page after starting the path. The time spent
+// on a page is measured until the visit of the next page, or until the path
+// was last updated if the reader finished on that page.
+func ComputePathAnalytics(guideID string, steps Path, instances []*PathInstance, firstVisits map[int64]map[string]string) *PathAnalytics {
+	analytics := &PathAnalytics{
+		GuideID:    guideID,
+		Steps:      make([]*PathStepAnalytics, 0),
+		Requisites: make([]*PathStepAnalytics, 0),
+	}
+	stepMap := make(map[int64]*PathPage)
+	stepOrder := make(map[string]int)
+	for n, step := range steps {
+		stepMap[step.ID] = step
+		if _, ok := stepOrder[step.PathPageID]; !ok {
+			stepOrder[step.PathPageID] = n
+		}
+	}
+
+	// Page id -> analytics, separately for path pages and requisites
+	stepAnalyticsMap := make(map[string]*PathStepAnalytics)
+	requisiteAnalyticsMap := make(map[string]*PathStepAnalytics)
+	durationsMap := make(map[*PathStepAnalytics][]int)
+	for _, instance := range instances {
+		analytics.Starts++
+		if instance.IsFinished {
+			analytics.Finishes++
+		}
+
+		// Parse when the reader got to each page
+		visitTimes := make([]*time.Time, len(instance.Pages))
+		for n, page := range instance.Pages {
+			if visitedAt, ok := firstVisits[instance.ID][page.PageID]; ok {
+				if t, err := time.Parse(database.TimeLayout, visitedAt); err == nil {
+					visitTimes[n] = &t
+				}
+			}
+		}
+		updatedAt, updatedAtErr := time.Parse(database.TimeLayout, instance.UpdatedAt)
+
+		lastIndex := len(instance.Pages) - 1
+		progress := instance.Progress
+		if progress > lastIndex {
+			progress = lastIndex
+		}
+		// The first page is the guide itself
+		for n := 1; n <= lastIndex; n++ {
+			page := instance.Pages[n]
+			isRequisite := isRequisitePathPage(instance, page, stepMap)
+			analyticsMap := stepAnalyticsMap
+			if isRequisite {
+				analyticsMap = requisiteAnalyticsMap
+			}
+			pageAnalytics, ok := analyticsMap[page.PageID]
+			if !ok {
+				pageAnalytics = &PathStepAnalytics{PageID: page.PageID, IsRequisite: isRequisite}
+				analyticsMap[page.PageID] = pageAnalytics
+			}
+			pageAnalytics.Inserted++
+			if n > progress {
+				continue
+			}
+			pageAnalytics.Reached++
+			if n == progress && !instance.IsFinished {
+				pageAnalytics.DroppedOff++
+			}
+
+			// Compute how long the reader spent on the page
+			if visitTimes[n] == nil {
+				continue
+			}
+			var end *time.Time
+			if n < progress {
+				end = visitTimes[n+1]
+			} else if instance.IsFinished && n == lastIndex && updatedAtErr == nil {
+				end = &updatedAt
+			}
+			if end != nil && !end.Before(*visitTimes[n]) {
+				durationsMap[pageAnalytics] = append(durationsMap[pageAnalytics], int(end.Sub(*visitTimes[n]).Seconds()))
+			}
+		}
+	}
+	if analytics.Starts > 0 {
+		analytics.CompletionRate = float64(analytics.Finishes) / float64(analytics.Starts)
+	}
+
+	for _, pageAnalytics := range stepAnalyticsMap {
+		pageAnalytics.MedianSeconds = medianSeconds(durationsMap[pageAnalytics])
+		analytics.Steps = append(analytics.Steps, pageAnalytics)
+	}
+	for _, pageAnalytics := range requisiteAnalyticsMap {
+		pageAnalytics.MedianSeconds = medianSeconds(durationsMap[pageAnalytics])
+		analytics.Requisites = append(analytics.Requisites, pageAnalytics)
+	}
+
+	// Order the path's pages like the path, and the pages that are no longer
+	// on it at the end
+	sort.Sort(pathStepAnalyticsList{analytics.Steps, func(a, b *PathStepAnalytics) bool {
+		aOrder, aOk := stepOrder[a.PageID]
+		bOrder, bOk := stepOrder[b.PageID]
+		if aOk != bOk {
+			return aOk
+		} else if aOrder != bOrder {
+			return aOrder < bOrder
+		}
+		return a.PageID < b.PageID
+	}})
+	sort.Sort(pathStepAnalyticsList{analytics.Requisites, func(a, b *PathStepAnalytics) bool {
+		if a.Inserted != b.Inserted {
+			return a.Inserted > b.Inserted
+		}
+		return a.PageID < b.PageID
+	}})
+	return analytics
+}
+
+// pathStepAnalyticsList sorts the analytics with the given function.
+type pathStepAnalyticsList struct {
+	list []*PathStepAnalytics
+	less func(a, b *PathStepAnalytics) bool
+}
+
+func (a pathStepAnalyticsList) Len() int           { return len(a.list) }
+func (a pathStepAnalyticsList) Swap(i, j int)      { a.list[i], a.list[j] = a.list[j], a.list[i] }
+func (a pathStepAnalyticsList) Less(i, j int) bool { return a.less(a.list[i], a.list[j]) }
+
+// LoadPathAnalyticsInput loads the steps, instances and first visits needed
+// to compute the analytics for the given guide.
+func LoadPathAnalyticsInput(db *database.DB, guideID string) (Path, []*PathInstance, map[int64]map[string]string, error) {
+	steps, err := LoadPathSteps(db, guideID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	instances := make([]*PathInstance, 0)
+	queryPart := database.NewQuery(`
+		WHERE pathi.guideId=?`, guideID)
+	err = LoadPathInstances(db, queryPart, NewCurrentUser(), func(db *database.DB, instance *PathInstance) error {
+		instances = append(instances, instance)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Couldn't load path instances: %v", err)
+	}
+
+	firstVisits := make(map[int64]map[string]string)
+	rows := database.NewQuery(`
+		SELECT pathi.id,v.pageId,MIN(v.createdAt)
+		FROM pathInstances AS pathi
+		JOIN visits AS v
+		ON (v.userId=pathi.userId AND v.createdAt>=pathi.createdAt AND FIND_IN_SET(v.pageId,pathi.pageIds))
+		WHERE pathi.guideId=?`, guideID).Add(`
+		GROUP BY 1,2`).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var instanceID int64
+		var pageID, visitedAt string
+		if err := rows.Scan(&instanceID, &pageID, &visitedAt); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		if _, ok := firstVisits[instanceID]; !ok {
+			firstVisits[instanceID] = make(map[string]string)
+		}
+		firstVisits[instanceID][pageID] = visitedAt
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Couldn't load visits: %v", err)
+	}
+	return steps, instances, firstVisits, nil
+}
+
+// SavePathAnalytics replaces the stored analytics for the guide.
+func SavePathAnalytics(tx *database.Tx, analytics *PathAnalytics) error {
+	hashmap := make(database.InsertMap)
+	hashmap["guideId"] = analytics.GuideID
+	hashmap["starts"] = analytics.Starts
+	hashmap["finishes"] = analytics.Finishes
+	hashmap["completionRate"] = analytics.CompletionRate
+	hashmap["computedAt"] = analytics.ComputedAt
+	statement := tx.DB.NewInsertStatement("pathAnalytics", hashmap, "starts", "finishes", "completionRate", "computedAt").WithTx(tx)
+	if _, err := statement.Exec(); err != nil {
+		return fmt.Errorf("Couldn't save path analytics: %v", err)
+	}
+
+	statement = database.NewQuery(`
+		DELETE FROM pathStepAnalytics
+		WHERE guideId=?`, analytics.GuideID).ToTxStatement(tx)
+	if _, err := statement.Exec(); err != nil {
+		return fmt.Errorf("Couldn't delete old path step analytics: %v", err)
+	}
+	hashmaps := make(database.InsertMaps, 0)
+	for n, stepAnalytics := range append(analytics.Steps, analytics.Requisites...) {
+		hashmap := make(database.InsertMap)
+		hashmap["guideId"] = analytics.GuideID
+		hashmap["pageId"] = stepAnalytics.PageID
+		hashmap["isRequisite"] = stepAnalytics.IsRequisite
+		hashmap["orderIndex"] = n
+		hashmap["inserted"] = stepAnalytics.Inserted
+		hashmap["reached"] = stepAnalytics.Reached
+		hashmap["droppedOff"] = stepAnalytics.DroppedOff
+		hashmap["medianSeconds"] = stepAnalytics.MedianSeconds
+		hashmaps = append(hashmaps, hashmap)
+	}
+	if len(hashmaps) > 0 {
+		statement = tx.DB.NewMultipleInsertStatement("pathStepAnalytics", hashmaps).WithTx(tx)
+		if _, err := statement.Exec(); err != nil {
+			return fmt.Errorf("Couldn't insert path step analytics: %v", err)
+		}
+	}
+	return nil
+}
+
+// LoadPathAnalytics loads the precomputed analytics for the guide. Returns nil
+// if they haven't been computed yet.
+func LoadPathAnalytics(db *database.DB, guideID string) (*PathAnalytics, error) {
+	analytics := &PathAnalytics{
+		GuideID:    guideID,
+		Steps:      make([]*PathStepAnalytics, 0),
+		Requisites: make([]*PathStepAnalytics, 0),
+	}
+	exists, err := database.NewQuery(`
+		SELECT starts,finishes,completionRate,computedAt
+		FROM pathAnalytics
+		WHERE guideId=?`, guideID).ToStatement(db).QueryRow().Scan(&analytics.Starts,
+		&analytics.Finishes, &analytics.CompletionRate, &analytics.ComputedAt)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load path analytics: %v", err)
+	} else if !exists {
+		return nil, nil
+	}
+
+	rows := database.NewQuery(`
+		SELECT pageId,isRequisite,inserted,reached,droppedOff,medianSeconds
+		FROM pathStepAnalytics
+		WHERE guideId=?`, guideID).Add(`
+		ORDER BY orderIndex`).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var stepAnalytics PathStepAnalytics
+		err := rows.Scan(&stepAnalytics.PageID, &stepAnalytics.IsRequisite, &stepAnalytics.Inserted,
+			&stepAnalytics.Reached, &stepAnalytics.DroppedOff, &stepAnalytics.MedianSeconds)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		if stepAnalytics.IsRequisite {
+			analytics.Requisites = append(analytics.Requisites, &stepAnalytics)
+		} else {
+			analytics.Steps = append(analytics.Steps, &stepAnalytics)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load path step analytics: %v", err)
+	}
+	return analytics, nil
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"testing"
+)
+
+// Make sure starts, drop-offs, median times and requisites are computed.
+func TestComputePathAnalytics(t *testing.T) {
+	steps := Path{
+		&PathPage{ID: 1, GuideID: "1", PathPageID: "10"},
+		&PathPage{ID: 2, GuideID: "1", PathPageID: "20"},
+	}
+	newPages := func() []*PathInstancePage {
+		return []*PathInstancePage{
+			&PathInstancePage{PageID: "1", SourceID: "1"},
+			&PathInstancePage{PageID: "10", SourceID: "1", PathPageID: 1},
+			&PathInstancePage{PageID: "40", SourceID: "1", PathPageID: 2},
+			&PathInstancePage{PageID: "20", SourceID: "1", PathPageID: 2},
+		}
+	}
+
+	// Finished the path
+	finished := NewPathInstance()
+	finished.ID, finished.GuideID, finished.Progress, finished.IsFinished = 1, "1", 3, true
+	finished.Pages = newPages()
+	finished.UpdatedAt = "2016-01-01 00:10:00"
+	// Stopped on the requisite
+	stopped := NewPathInstance()
+	stopped.ID, stopped.GuideID, stopped.Progress = 2, "1", 2
+	stopped.Pages = newPages()
+	// Stopped on the first page, and added a page of their own
+	started := NewPathInstance()
+	started.ID, started.GuideID, started.Progress = 3, "1", 1
+	started.Pages = append(newPages(), &PathInstancePage{PageID: "50", SourceID: "20"})
+
+	firstVisits := map[int64]map[string]string{
+		1: {"10": "2016-01-01 00:00:00", "40": "2016-01-01 00:02:00", "20": "2016-01-01 00:05:00"},
+		2: {"10": "2016-01-01 00:00:00", "40": "2016-01-01 00:04:00"},
+	}
+	analytics := ComputePathAnalytics("1", steps, []*PathInstance{finished, stopped, started}, firstVisits)
+	if analytics.Starts != 3 || analytics.Finishes != 1 {
+		t.Errorf("Unexpected totals: %+v", analytics)
+	}
+	if len(analytics.Steps) != 2 || len(analytics.Requisites) != 2 {
+		t.Fatalf("Unexpected number of pages: %+v", analytics)
+	}
+
+	expectedSteps := []PathStepAnalytics{
+		{PageID: "10", Inserted: 3, Reached: 3, DroppedOff: 1, MedianSeconds: 180},
+		{PageID: "20", Inserted: 3, Reached: 1, DroppedOff: 0, MedianSeconds: 300},
+	}
+	for n, expected := range expectedSteps {
+		if *analytics.Steps[n] != expected {
+			t.Errorf("Unexpected step %d: %+v, expected %+v", n, analytics.Steps[n], expected)
+		}
+	}
+	expectedRequisites := []PathStepAnalytics{
+		{PageID: "40", IsRequisite: true, Inserted: 3, Reached: 2, DroppedOff: 1, MedianSeconds: 180},
+		{PageID: "50", IsRequisite: true, Inserted: 1},
+	}
+	for n, expected := range expectedRequisites {
+		if *analytics.Requisites[n] != expected {
+			t.Errorf("Unexpected requisite %d: %+v, expected %+v", n, analytics.Requisites[n], expected)
+		}
+	}
+}
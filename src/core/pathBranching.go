@@ -0,0 +1,364 @@
+// pathBranching.go resolves which pages a user reads when they follow a guide's path.
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// Conditions for picking an alternate path page instead of the page it's an alternate for.
+	// The page's speed tag matches the speed the user prefers.
+	SpeedPathCondition = "speed"
+	// The user gave the listed answer to a quiz question.
+	QuizAnswerPathCondition = "quizAnswer"
+
+	// How many of the user's unfinished paths we recompute when their masteries change
+	recomputedPathInstancesLimit = 10
+)
+
+// PathStepContext contains everything about the user we need to resolve a path.
+type PathStepContext struct {
+	// User's masteries
+	Masteries map[string]*Mastery
+	// Speed the user prefers: -1 for slower, 1 for faster, 0 for either
+	Speed int
+	// Page id -> speed of the page, based on its speed tags
+	PageSpeeds map[string]int
+	// Page id -> object alias -> the user's answer
+	Answers map[string]map[string]string
+	// Page id -> requirements of the page
+	Requirements map[string][]*PagePair
+	// Mastery id -> pages that teach it
+	Tutors map[string][]*ReviewCandidate
+}
+
+// NewPathStepContext returns an empty context.
+func NewPathStepContext(speed int) *PathStepContext {
+	return &PathStepContext{
+		Masteries:    make(map[string]*Mastery),
+		Speed:        speed,
+		PageSpeeds:   make(map[string]int),
+		Answers:      make(map[string]map[string]string),
+		Requirements: make(map[string][]*PagePair),
+		Tutors:       make(map[string][]*ReviewCandidate),
+	}
+}
+
+// Return true iff the user has the mastery at the given level or higher.
+func (context *PathStepContext) hasMastery(masteryID string, level int) bool {
+	mastery, ok := context.Masteries[masteryID]
+	return ok && mastery.Has && mastery.Level >= level
+}
+
+// Return true iff the user has all of the given masteries. False if there are none.
+func (context *PathStepContext) hasAllMasteries(masteryIDs []string) bool {
+	if len(masteryIDs) <= 0 {
+		return false
+	}
+	for _, masteryID := range masteryIDs {
+		if !context.hasMastery(masteryID, NoMasteryLevel) {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the first alternate whose condition holds, or the step itself.
+func (context *PathStepContext) pickPathStep(step *PathPage, alternates []*PathPage) *PathPage {
+	for _, alternate := range alternates {
+		switch alternate.ConditionType {
+		case SpeedPathCondition:
+			if context.Speed != 0 && context.PageSpeeds[alternate.PathPageID] == context.Speed {
+				return alternate
+			}
+		case QuizAnswerPathCondition:
+			value, ok := context.Answers[alternate.QuizPageID][alternate.QuizObject]
+			if ok && IsQuizAnswerCorrect(alternate.QuizValue, value) {
+				return alternate
+			}
+		}
+	}
+	return step
+}
+
+// Return the id of the path page the given step is, or is an alternate for.
+func getMainPathStepID(step *PathPage) int64 {
+	if step.BranchOf != 0 {
+		return step.BranchOf
+	}
+	return step.ID
+}
+
+// ResolvePathSteps returns the pages the user should read for the given path
+// steps, which have to be in path order. For each step we pick an alternate if
+// its condition holds, skip it if the user knows what it teaches, and insert
+// pages for the requisites the user is missing if the step asks for it. Pages
+// in the seen map aren't added again; the map is updated with the new pages.
+func ResolvePathSteps(guideID string, steps Path, context *PathStepContext, seen map[string]bool) []*PathInstancePage {
+	alternates := make(map[int64][]*PathPage)
+	for _, step := range steps {
+		if step.BranchOf != 0 {
+			alternates[step.BranchOf] = append(alternates[step.BranchOf], step)
+		}
+	}
+
+	pages := make([]*PathInstancePage, 0)
+	for _, step := range steps {
+		if step.BranchOf != 0 {
+			continue
+		}
+		chosen := context.pickPathStep(step, alternates[step.ID])
+		if seen[chosen.PathPageID] || context.hasAllMasteries(chosen.SkipIfMasteryIDs) {
+			continue
+		}
+		if chosen.InsertRequisites {
+			for _, requirement := range context.Requirements[chosen.PathPageID] {
+				if context.hasMastery(requirement.ParentID, requirement.Level) {
+					continue
+				}
+				tutorID := PickReviewPage(context.Tutors[requirement.ParentID], "", requirement.Level)
+				if tutorID == "" || tutorID == chosen.PathPageID || seen[tutorID] {
+					continue
+				}
+				pages = append(pages, &PathInstancePage{PageID: tutorID, SourceID: guideID, PathPageID: chosen.ID})
+				seen[tutorID] = true
+			}
+		}
+		pages = append(pages, &PathInstancePage{PageID: chosen.PathPageID, SourceID: guideID, PathPageID: chosen.ID})
+		seen[chosen.PathPageID] = true
+	}
+	return pages
+}
+
+// RecomputePathInstancePages keeps the pages the user has already reached and
+// resolves the rest of the path again. Pages that weren't added by the path
+// (e.g. the user asked to learn a requisite) are kept too. Instances started
+// before paths could branch don't know where their pages came from, so they
+// are left alone.
+func RecomputePathInstancePages(instance *PathInstance, steps Path, context *PathStepContext) {
+	isResolved := false
+	for _, page := range instance.Pages {
+		if page.PathPageID != 0 {
+			isResolved = true
+			break
+		}
+	}
+	if !isResolved {
+		return
+	}
+
+	stepMap := make(map[int64]*PathPage)
+	for _, step := range steps {
+		stepMap[step.ID] = step
+	}
+
+	pages := make([]*PathInstancePage, 0)
+	seen := make(map[string]bool)
+	readStepIDs := make(map[int64]bool)
+	for n, page := range instance.Pages {
+		if n <= instance.Progress {
+			if step, ok := stepMap[page.PathPageID]; ok && step.PathPageID == page.PageID {
+				readStepIDs[getMainPathStepID(step)] = true
+			}
+		} else if page.PathPageID != 0 {
+			continue
+		}
+		pages = append(pages, page)
+		seen[page.PageID] = true
+	}
+
+	remainingSteps := make(Path, 0)
+	for _, step := range steps {
+		if !readStepIDs[getMainPathStepID(step)] {
+			remainingSteps = append(remainingSteps, step)
+		}
+	}
+	instance.Pages = append(pages, ResolvePathSteps(instance.GuideID, remainingSteps, context, seen)...)
+}
+
+// LoadPathSteps loads all the path pages for the given guide in path order.
+func LoadPathSteps(db *database.DB, guideID string) (Path, error) {
+	steps := make(Path, 0)
+	queryPart := database.NewQuery(`
+		WHERE pathp.guideId=?`, guideID).Add(`
+		ORDER BY pathp.pathIndex,pathp.id`)
+	err := LoadPathPages(db, queryPart, nil, func(db *database.DB, pathPage *PathPage) error {
+		steps = append(steps, pathPage)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// LoadPathStepContext loads the data about the current user needed to resolve the given steps.
+func LoadPathStepContext(db *database.DB, u *CurrentUser, steps Path, speed int) (*PathStepContext, error) {
+	context := NewPathStepContext(speed)
+	if len(steps) <= 0 {
+		return context, nil
+	}
+	if err := LoadMasteries(db, u, context.Masteries); err != nil {
+		return nil, fmt.Errorf("Couldn't load masteries: %v", err)
+	}
+
+	pageIDs := make([]string, 0)
+	quizPageIDs := make([]string, 0)
+	requisitePageIDs := make([]string, 0)
+	for _, step := range steps {
+		pageIDs = append(pageIDs, step.PathPageID)
+		if step.ConditionType == QuizAnswerPathCondition {
+			quizPageIDs = append(quizPageIDs, step.QuizPageID)
+		}
+		if step.InsertRequisites {
+			requisitePageIDs = append(requisitePageIDs, step.PathPageID)
+		}
+	}
+
+	// Load page speeds
+	rows := database.NewQuery(`
+		SELECT childId,IF(SUM(parentId=?),-1,IF(SUM(parentId=?),1,0))`, SlowDownPageID, SpeedUpPageID).Add(`
+		FROM pagePairs
+		WHERE type=?`, TagPagePairType).Add(`
+			AND childId IN`).AddArgsGroupStr(pageIDs).Add(`
+		GROUP BY childId`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var pageID string
+		var speed int
+		if err := rows.Scan(&pageID, &speed); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		context.PageSpeeds[pageID] = speed
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load page speeds: %v", err)
+	}
+
+	// Load the user's quiz answers
+	userID := u.GetSomeID()
+	if len(quizPageIDs) > 0 && userID != "" {
+		rows = database.NewQuery(`
+			SELECT pageId,object,value
+			FROM userPageObjectPairs
+			WHERE userId=?`, userID).Add(`
+				AND pageId IN`).AddArgsGroupStr(quizPageIDs).ToStatement(db).Query()
+		err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+			var pageID, object, value string
+			if err := rows.Scan(&pageID, &object, &value); err != nil {
+				return fmt.Errorf("failed to scan: %v", err)
+			}
+			if _, ok := context.Answers[pageID]; !ok {
+				context.Answers[pageID] = make(map[string]string)
+			}
+			context.Answers[pageID][object] = value
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't load quiz answers: %v", err)
+		}
+	}
+
+	// Load the requisites and the pages that teach them
+	if len(requisitePageIDs) > 0 {
+		masteryIDs := make([]string, 0)
+		rows = database.NewQuery(`
+			SELECT childId,parentId,level
+			FROM pagePairs
+			WHERE type=?`, RequirementPagePairType).Add(`
+				AND childId IN`).AddArgsGroupStr(requisitePageIDs).Add(`
+			ORDER BY childId,parentId`).ToStatement(db).Query()
+		err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+			pagePair := &PagePair{Type: RequirementPagePairType}
+			if err := rows.Scan(&pagePair.ChildID, &pagePair.ParentID, &pagePair.Level); err != nil {
+				return fmt.Errorf("failed to scan: %v", err)
+			}
+			context.Requirements[pagePair.ChildID] = append(context.Requirements[pagePair.ChildID], pagePair)
+			masteryIDs = append(masteryIDs, pagePair.ParentID)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't load requirements: %v", err)
+		}
+		context.Tutors, err = loadTutorCandidates(db, u, masteryIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return context, nil
+}
+
+// UpdatePathInstancePages resolves the parts of the path the user hasn't reached yet again.
+func UpdatePathInstancePages(db *database.DB, u *CurrentUser, instance *PathInstance) error {
+	steps, err := LoadPathSteps(db, instance.GuideID)
+	if err != nil {
+		return err
+	}
+	context, err := LoadPathStepContext(db, u, steps, instance.Speed)
+	if err != nil {
+		return err
+	}
+	RecomputePathInstancePages(instance, steps, context)
+	return nil
+}
+
+// GetPathInstanceColumns returns the pageIds, sourcePageIds and pathPageIds
+// values to store for the instance's pages.
+func GetPathInstanceColumns(instance *PathInstance) (string, string, string) {
+	pageIDs := make([]string, 0)
+	sourceIDs := make([]string, 0)
+	pathPageIDs := make([]string, 0)
+	for _, page := range instance.Pages {
+		pageIDs = append(pageIDs, page.PageID)
+		sourceIDs = append(sourceIDs, page.SourceID)
+		pathPageIDs = append(pathPageIDs, strconv.FormatInt(page.PathPageID, 10))
+	}
+	return strings.Join(pageIDs, ","), strings.Join(sourceIDs, ","), strings.Join(pathPageIDs, ",")
+}
+
+// RecomputeUserPathInstances resolves the remaining pages of the current
+// user's most recent unfinished paths again. Called when the user's masteries
+// or answers change.
+func RecomputeUserPathInstances(db *database.DB, u *CurrentUser) error {
+	userID := u.GetSomeID()
+	if userID == "" {
+		return nil
+	}
+
+	instances := make([]*PathInstance, 0)
+	queryPart := database.NewQuery(`
+		WHERE pathi.userId=?`, userID).Add(`
+			AND NOT pathi.isFinished
+		ORDER BY pathi.updatedAt DESC
+		LIMIT ?`, recomputedPathInstancesLimit)
+	err := LoadPathInstances(db, queryPart, u, func(db *database.DB, instance *PathInstance) error {
+		instances = append(instances, instance)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Couldn't load path instances: %v", err)
+	}
+
+	for _, instance := range instances {
+		oldPageIDs, _, oldPathPageIDs := GetPathInstanceColumns(instance)
+		if err := UpdatePathInstancePages(db, u, instance); err != nil {
+			return fmt.Errorf("Couldn't recompute path instance: %v", err)
+		}
+		pageIDs, sourceIDs, pathPageIDs := GetPathInstanceColumns(instance)
+		if pageIDs == oldPageIDs && pathPageIDs == oldPathPageIDs {
+			continue
+		}
+		statement := database.NewQuery(`
+			UPDATE pathInstances
+			SET pageIds=?,sourcePageIds=?,pathPageIds=?`, pageIDs, sourceIDs, pathPageIDs).Add(`
+			WHERE id=?`, instance.ID).ToStatement(db)
+		if _, err := statement.Exec(); err != nil {
+			return fmt.Errorf("Couldn't update path instance: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// Return the page ids of the given path pages, comma separated.
+func joinPathPageIDs(pages []*PathInstancePage) string {
+	pageIDs := make([]string, 0)
+	for _, page := range pages {
+		pageIDs = append(pageIDs, page.PageID)
+	}
+	return strings.Join(pageIDs, ",")
+}
+
+// Return a guide with steps 10, 20 (with a slower alternate 21 and a quiz
+// alternate 22) and 30 (which is skipped if the user knows 3 and needs 4).
+func newBranchingPathSteps() Path {
+	return Path{
+		&PathPage{ID: 1, GuideID: "1", PathPageID: "10"},
+		&PathPage{ID: 2, GuideID: "1", PathPageID: "20"},
+		&PathPage{ID: 3, GuideID: "1", PathPageID: "21", BranchOf: 2, ConditionType: SpeedPathCondition},
+		&PathPage{ID: 4, GuideID: "1", PathPageID: "22", BranchOf: 2, ConditionType: QuizAnswerPathCondition,
+			QuizPageID: "10", QuizObject: "q1", QuizValue: "yes"},
+		&PathPage{ID: 5, GuideID: "1", PathPageID: "30", SkipIfMasteryIDs: []string{"3"}, InsertRequisites: true},
+	}
+}
+
+// Make sure alternates are picked by speed and quiz answers, and steps are
+// skipped or get their requisites inserted depending on the user's masteries.
+func TestResolvePathSteps(t *testing.T) {
+	steps := newBranchingPathSteps()
+
+	context := NewPathStepContext(0)
+	context.PageSpeeds["21"] = -1
+	context.Requirements["30"] = []*PagePair{&PagePair{ParentID: "4", ChildID: "30", Level: BasicMasteryLevel}}
+	context.Tutors["4"] = []*ReviewCandidate{&ReviewCandidate{PageID: "40", Level: BasicMasteryLevel}}
+	pages := ResolvePathSteps("1", steps, context, make(map[string]bool))
+	if joined := joinPathPageIDs(pages); joined != "10,20,40,30" {
+		t.Errorf("Unexpected pages: %s", joined)
+	}
+	if pages[2].PathPageID != 5 || pages[2].SourceID != "1" {
+		t.Errorf("Unexpected requisite page: %+v", pages[2])
+	}
+
+	// Slower alternate, and the requisite is known
+	context.Speed = -1
+	context.Masteries["4"] = &Mastery{PageID: "4", Has: true, Level: TechnicalMasteryLevel}
+	pages = ResolvePathSteps("1", steps, context, make(map[string]bool))
+	if joined := joinPathPageIDs(pages); joined != "10,21,30" {
+		t.Errorf("Unexpected pages: %s", joined)
+	}
+
+	// Quiz alternate comes after the speed one, and the last step is skipped
+	context.Speed = 1
+	context.Answers["10"] = map[string]string{"q1": " Yes"}
+	context.Masteries["3"] = &Mastery{PageID: "3", Has: true, Level: LooseMasteryLevel}
+	pages = ResolvePathSteps("1", steps, context, map[string]bool{"10": true})
+	if joined := joinPathPageIDs(pages); joined != "22" {
+		t.Errorf("Unexpected pages: %s", joined)
+	}
+}
+
+// Make sure recomputing keeps the pages the user reached and the ones they
+// added, and resolves the rest again.
+func TestRecomputePathInstancePages(t *testing.T) {
+	steps := newBranchingPathSteps()
+	instance := NewPathInstance()
+	instance.GuideID = "1"
+	instance.Progress = 1
+	instance.Pages = []*PathInstancePage{
+		&PathInstancePage{PageID: "1", SourceID: "1"},
+		&PathInstancePage{PageID: "10", SourceID: "1", PathPageID: 1},
+		&PathInstancePage{PageID: "50", SourceID: "10"},
+		&PathInstancePage{PageID: "20", SourceID: "1", PathPageID: 2},
+		&PathInstancePage{PageID: "30", SourceID: "1", PathPageID: 5},
+	}
+
+	context := NewPathStepContext(-1)
+	context.PageSpeeds["21"] = -1
+	context.Masteries["3"] = &Mastery{PageID: "3", Has: true}
+	RecomputePathInstancePages(instance, steps, context)
+	if joined := joinPathPageIDs(instance.Pages); joined != "1,10,50,21" {
+		t.Errorf("Unexpected pages: %s", joined)
+	}
+
+	// Instances from before paths could branch are left alone
+	instance.Pages = []*PathInstancePage{
+		&PathInstancePage{PageID: "1", SourceID: "1"},
+		&PathInstancePage{PageID: "10", SourceID: "1"},
+		&PathInstancePage{PageID: "30", SourceID: "1"},
+	}
+	RecomputePathInstancePages(instance, steps, context)
+	if joined := joinPathPageIDs(instance.Pages); joined != "1,10,30" {
+		t.Errorf("Unexpected pages: %s", joined)
+	}
+}
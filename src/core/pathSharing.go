@@ -0,0 +1,130 @@
+// pathSharing.go contains the functions for sharing path instances with other readers.
+package core
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"zanaduu3/src/database"
+)
+
+// PathGroupMember is the progress of a student who started a copy of a
+// teacher's group path instance.
+type PathGroupMember struct {
+	// Id of the student, or "" if they aren't logged in
+	UserID     string `json:"userId"`
+	InstanceID int64  `json:"instanceId,string"`
+	Progress   int    `json:"progress"`
+	// Number of pages on the student's path
+	PageCount  int    `json:"pageCount"`
+	IsFinished bool   `json:"isFinished"`
+	CreatedAt  string `json:"createdAt"`
+	UpdatedAt  string `json:"updatedAt"`
+}
+
+// NewPathInstanceCopy returns a copy of the original instance for another
+// reader. The copy keeps the pages the original reader added themselves, but
+// starts from the beginning.
+func NewPathInstanceCopy(original *PathInstance) *PathInstance {
+	instance := NewPathInstance()
+	instance.GuideID = original.GuideID
+	instance.Speed = original.Speed
+	instance.OriginalInstanceID = original.ID
+	instance.Progress = 1
+	for _, page := range original.Pages {
+		pageCopy := *page
+		instance.Pages = append(instance.Pages, &pageCopy)
+	}
+	return instance
+}
+
+// NewPathShareKey returns a random key for sharing a path instance.
+func NewPathShareKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("Failed to read random device: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// InsertPathInstance creates a new path instance for the given user and returns its id.
+func InsertPathInstance(tx *database.Tx, userID string, instance *PathInstance) (int64, error) {
+	shareKey, err := NewPathShareKey()
+	if err != nil {
+		return 0, err
+	}
+	pageIDs, sourcePageIDs, pathPageIDs := GetPathInstanceColumns(instance)
+	hashmap := make(database.InsertMap)
+	hashmap["userId"] = userID
+	hashmap["guideId"] = instance.GuideID
+	hashmap["pageIds"] = pageIDs
+	hashmap["sourcePageIds"] = sourcePageIDs
+	hashmap["pathPageIds"] = pathPageIDs
+	hashmap["speed"] = instance.Speed
+	hashmap["progress"] = instance.Progress
+	hashmap["originalInstanceId"] = instance.OriginalInstanceID
+	hashmap["isGroup"] = instance.IsGroup
+	hashmap["shareKey"] = shareKey
+	hashmap["createdAt"] = database.Now()
+	hashmap["updatedAt"] = database.Now()
+	statement := tx.DB.NewInsertStatement("pathInstances", hashmap).WithTx(tx)
+	result, err := statement.Exec()
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't insert pathInstance: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't get pathInstance id: %v", err)
+	}
+	return id, nil
+}
+
+// LoadPathInstanceCopyID returns the id of the unfinished copy of the given
+// instance the user already started, or "" if there isn't one.
+func LoadPathInstanceCopyID(db *database.DB, userID string, originalInstanceID int64) (string, error) {
+	var id string
+	_, err := database.NewQuery(`
+		SELECT id
+		FROM pathInstances
+		WHERE userId=?`, userID).Add(`
+			AND originalInstanceId=?`, originalInstanceID).Add(`
+			AND NOT isFinished
+		ORDER BY updatedAt DESC
+		LIMIT 1`).ToStatement(db).QueryRow().Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't load path instance copy: %v", err)
+	}
+	return id, nil
+}
+
+// LoadPathGroupMembers loads the progress of everyone who started a copy of the given instance.
+func LoadPathGroupMembers(db *database.DB, instanceID int64) ([]*PathGroupMember, error) {
+	members := make([]*PathGroupMember, 0)
+	rows := database.NewQuery(`
+		SELECT id,userId,progress,pageIds,isFinished,createdAt,updatedAt
+		FROM pathInstances
+		WHERE originalInstanceId=?`, instanceID).Add(`
+		ORDER BY createdAt`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var member PathGroupMember
+		var pageIDs string
+		err := rows.Scan(&member.InstanceID, &member.UserID, &member.Progress, &pageIDs,
+			&member.IsFinished, &member.CreatedAt, &member.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		// Don't expose session ids
+		if strings.HasPrefix(member.UserID, "sid:") {
+			member.UserID = ""
+		}
+		member.PageCount = len(strings.Split(pageIDs, ","))
+		members = append(members, &member)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load path group members: %v", err)
+	}
+	return members, nil
+}
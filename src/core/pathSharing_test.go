@@ -0,0 +1,28 @@
+package core
+
+import (
+	"testing"
+)
+
+// Make sure a copy starts over, remembers the original and doesn't share pages with it.
+func TestNewPathInstanceCopy(t *testing.T) {
+	original := NewPathInstance()
+	original.ID, original.GuideID, original.Progress, original.Speed, original.IsFinished = 7, "1", 2, -1, true
+	original.Pages = []*PathInstancePage{
+		&PathInstancePage{PageID: "1", SourceID: "1"},
+		&PathInstancePage{PageID: "10", SourceID: "1", PathPageID: 1},
+		&PathInstancePage{PageID: "50", SourceID: "10"},
+	}
+
+	instance := NewPathInstanceCopy(original)
+	if instance.OriginalInstanceID != 7 || instance.Progress != 1 || instance.IsFinished || instance.Speed != -1 {
+		t.Errorf("Unexpected copy: %+v", instance)
+	}
+	if joined := joinPathPageIDs(instance.Pages); joined != "1,10,50" {
+		t.Errorf("Unexpected pages: %s", joined)
+	}
+	instance.Pages[2].PageID = "60"
+	if original.Pages[2].PageID != "50" {
+		t.Errorf("Changing the copy changed the original")
+	}
+}
@@ -0,0 +1,338 @@
+// quiz.go contains the functions for grading checkpoint quizzes embedded in pages.
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// How many times a user can answer each quiz question
+	MaxQuizAnswerAttempts = 2
+)
+
+// QuizGrant is a mastery a user gets when they answer enough quiz questions
+// on a page correctly.
+type QuizGrant struct {
+	MasteryID string `json:"masteryId"`
+	Level     int    `json:"level"`
+	// How many questions the user needs to answer correctly. 0 means all of them.
+	PassingScore int `json:"passingScore"`
+}
+
+// Quiz is the answer key for the questions in one edit of a page.
+type Quiz struct {
+	PageID string `json:"pageId"`
+	// Edit the answer key was written for. It's used for all later edits
+	// until the author writes a new one.
+	Edit int `json:"edit"`
+	// Object alias -> correct value
+	AnswerKeys map[string]string `json:"answerKeys"`
+	Grants     []*QuizGrant      `json:"grants"`
+}
+
+// QuizGrade is the result of grading a user's answers.
+type QuizGrade struct {
+	Correct int `json:"correct"`
+	Total   int `json:"total"`
+	// Aliases of the objects the user answered incorrectly or didn't answer
+	WrongObjects []string `json:"wrongObjects"`
+}
+
+// QuizAnswer is one user's answer to a quiz question.
+type QuizAnswer struct {
+	Edit   int
+	Object string
+	Value  string
+}
+
+// QuizItemStats has the aggregate results for one question of a quiz.
+type QuizItemStats struct {
+	// Edit of the answer key the question is from
+	Edit      int    `json:"edit"`
+	Object    string `json:"object"`
+	AnswerKey string `json:"answerKey"`
+	Answered  int    `json:"answered"`
+	Correct   int    `json:"correct"`
+	// Wrong value -> how many users gave it
+	WrongAnswers map[string]int `json:"wrongAnswers"`
+}
+
+// IsQuizAnswerCorrect returns true iff the value matches the answer key.
+func IsQuizAnswerCorrect(answerKey, value string) bool {
+	return strings.EqualFold(strings.TrimSpace(answerKey), strings.TrimSpace(value))
+}
+
+// IsQuizAnswered returns true iff the user answered every question in the quiz.
+func IsQuizAnswered(quiz *Quiz, answers map[string]string) bool {
+	for object := range quiz.AnswerKeys {
+		if _, ok := answers[object]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// GradeQuiz compares the user's answers (object alias -> value) with the answer key.
+func GradeQuiz(quiz *Quiz, answers map[string]string) *QuizGrade {
+	grade := &QuizGrade{Total: len(quiz.AnswerKeys), WrongObjects: make([]string, 0)}
+	for object, answerKey := range quiz.AnswerKeys {
+		if value, ok := answers[object]; ok && IsQuizAnswerCorrect(answerKey, value) {
+			grade.Correct++
+		} else {
+			grade.WrongObjects = append(grade.WrongObjects, object)
+		}
+	}
+	return grade
+}
+
+// GetPassedQuizGrants returns the grants the user earned with the given grade.
+func GetPassedQuizGrants(quiz *Quiz, grade *QuizGrade) []*QuizGrant {
+	passed := make([]*QuizGrant, 0)
+	if grade.Total <= 0 {
+		return passed
+	}
+	for _, grant := range quiz.Grants {
+		passingScore := grant.PassingScore
+		if passingScore <= 0 || passingScore > grade.Total {
+			passingScore = grade.Total
+		}
+		if grade.Correct >= passingScore {
+			passed = append(passed, grant)
+		}
+	}
+	return passed
+}
+
+// ComputeQuizStats aggregates the given answers to the quizzes of a page. Each
+// answer counts towards the latest quiz no newer than the edit it was given for.
+// Quizzes have to be sorted by edit. The result is sorted by edit and object.
+func ComputeQuizStats(quizzes []*Quiz, answers []*QuizAnswer) []*QuizItemStats {
+	statsMap := make(map[string]*QuizItemStats)
+	statsList := make([]*QuizItemStats, 0)
+	for _, quiz := range quizzes {
+		objects := make([]string, 0)
+		for object := range quiz.AnswerKeys {
+			objects = append(objects, object)
+		}
+		sort.Strings(objects)
+		for _, object := range objects {
+			stats := &QuizItemStats{
+				Edit:         quiz.Edit,
+				Object:       object,
+				AnswerKey:    quiz.AnswerKeys[object],
+				WrongAnswers: make(map[string]int),
+			}
+			statsMap[fmt.Sprintf("%d:%s", quiz.Edit, object)] = stats
+			statsList = append(statsList, stats)
+		}
+	}
+	for _, answer := range answers {
+		var quiz *Quiz
+		for _, q := range quizzes {
+			if q.Edit <= answer.Edit {
+				quiz = q
+			}
+		}
+		if quiz == nil {
+			continue
+		}
+		stats, ok := statsMap[fmt.Sprintf("%d:%s", quiz.Edit, answer.Object)]
+		if !ok {
+			continue
+		}
+		stats.Answered++
+		if IsQuizAnswerCorrect(stats.AnswerKey, answer.Value) {
+			stats.Correct++
+		} else {
+			stats.WrongAnswers[strings.TrimSpace(answer.Value)]++
+		}
+	}
+	return statsList
+}
+
+// LoadQuiz loads the answer key that applies to the given edit of the page, i.e.
+// the one written for the latest edit no newer than it. Returns nil if there is none.
+func LoadQuiz(db *database.DB, pageID string, edit int) (*Quiz, error) {
+	quiz := &Quiz{PageID: pageID, AnswerKeys: make(map[string]string), Grants: make([]*QuizGrant, 0)}
+	exists, err := database.NewQuery(`
+		SELECT edit
+		FROM quizAnswerKeys
+		WHERE pageId=?`, pageID).Add(`
+			AND edit<=?`, edit).Add(`
+		ORDER BY edit DESC
+		LIMIT 1`).ToStatement(db).QueryRow().Scan(&quiz.Edit)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load quiz edit: %v", err)
+	} else if !exists {
+		return nil, nil
+	}
+
+	rows := database.NewQuery(`
+		SELECT object,answer
+		FROM quizAnswerKeys
+		WHERE pageId=?`, pageID).Add(`
+			AND edit=?`, quiz.Edit).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var object, answer string
+		if err := rows.Scan(&object, &answer); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		quiz.AnswerKeys[object] = answer
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load answer keys: %v", err)
+	}
+
+	rows = database.NewQuery(`
+		SELECT masteryId,level,passingScore
+		FROM quizMasteryGrants
+		WHERE pageId=?`, pageID).Add(`
+			AND edit=?`, quiz.Edit).Add(`
+		ORDER BY masteryId`).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var grant QuizGrant
+		if err := rows.Scan(&grant.MasteryID, &grant.Level, &grant.PassingScore); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		quiz.Grants = append(quiz.Grants, &grant)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load mastery grants: %v", err)
+	}
+	return quiz, nil
+}
+
+// LoadQuizAnswers loads the given user's answers to the objects on the page:
+// object alias -> value. Only answers given for the quiz's edit or later count.
+func LoadQuizAnswers(db *database.DB, userID string, quiz *Quiz) (map[string]string, error) {
+	answers := make(map[string]string)
+	rows := database.NewQuery(`
+		SELECT object,value
+		FROM userPageObjectPairs
+		WHERE userId=?`, userID).Add(`
+			AND pageId=?`, quiz.PageID).Add(`
+			AND edit>=?`, quiz.Edit).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var object, value string
+		if err := rows.Scan(&object, &value); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		answers[object] = value
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load answers: %v", err)
+	}
+	return answers, nil
+}
+
+// GrantQuizMasteries gives the user the masteries they earned on the page. A
+// mastery the user already has at the same or a higher level is left alone.
+// Returns the grants that changed the user's masteries.
+func GrantQuizMasteries(tx *database.Tx, userID, pageID string, grants []*QuizGrant) ([]*QuizGrant, error) {
+	granted := make([]*QuizGrant, 0)
+	if len(grants) <= 0 {
+		return granted, nil
+	}
+	masteryIDs := make([]string, 0)
+	for _, grant := range grants {
+		masteryIDs = append(masteryIDs, grant.MasteryID)
+	}
+	currentLevels := make(map[string]int)
+	rows := database.NewQuery(`
+		SELECT masteryId,level
+		FROM userMasteryPairs
+		WHERE userId=?`, userID).Add(`
+			AND has
+			AND masteryId IN`).AddArgsGroupStr(masteryIDs).ToTxStatement(tx).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var masteryID string
+		var level int
+		if err := rows.Scan(&masteryID, &level); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		currentLevels[masteryID] = level
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load masteries: %v", err)
+	}
+
+	hashmaps := make(database.InsertMaps, 0)
+	for _, grant := range grants {
+		if level, ok := currentLevels[grant.MasteryID]; ok && level >= grant.Level {
+			continue
+		}
+		currentLevels[grant.MasteryID] = grant.Level
+		hashmap := make(database.InsertMap)
+		hashmap["userId"] = userID
+		hashmap["masteryId"] = grant.MasteryID
+		hashmap["has"] = true
+		hashmap["level"] = grant.Level
+		hashmap["taughtBy"] = pageID
+		hashmap["createdAt"] = database.Now()
+		hashmap["updatedAt"] = database.Now()
+		hashmaps = append(hashmaps, hashmap)
+		granted = append(granted, grant)
+	}
+	if len(hashmaps) > 0 {
+		statement := tx.DB.NewMultipleInsertStatement("userMasteryPairs", hashmaps, "has", "level", "taughtBy", "updatedAt").WithTx(tx)
+		if _, err := statement.Exec(); err != nil {
+			return nil, fmt.Errorf("Couldn't insert masteries: %v", err)
+		}
+	}
+	return granted, nil
+}
+
+// LoadQuizStats loads the aggregate results for all the quizzes on the page.
+func LoadQuizStats(db *database.DB, pageID string) ([]*QuizItemStats, error) {
+	quizzes := make([]*Quiz, 0)
+	rows := database.NewQuery(`
+		SELECT edit,object,answer
+		FROM quizAnswerKeys
+		WHERE pageId=?`, pageID).Add(`
+		ORDER BY edit`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var edit int
+		var object, answer string
+		if err := rows.Scan(&edit, &object, &answer); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		if len(quizzes) <= 0 || quizzes[len(quizzes)-1].Edit != edit {
+			quizzes = append(quizzes, &Quiz{PageID: pageID, Edit: edit, AnswerKeys: make(map[string]string)})
+		}
+		quizzes[len(quizzes)-1].AnswerKeys[object] = answer
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load answer keys: %v", err)
+	}
+	if len(quizzes) <= 0 {
+		return make([]*QuizItemStats, 0), nil
+	}
+
+	answers := make([]*QuizAnswer, 0)
+	rows = database.NewQuery(`
+		SELECT edit,object,value
+		FROM userPageObjectPairs
+		WHERE pageId=?`, pageID).Add(`
+			AND edit>=?`, quizzes[0].Edit).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var answer QuizAnswer
+		if err := rows.Scan(&answer.Edit, &answer.Object, &answer.Value); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		answers = append(answers, &answer)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load answers: %v", err)
+	}
+	return ComputeQuizStats(quizzes, answers), nil
+}
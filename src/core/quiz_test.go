@@ -0,0 +1,86 @@
+package core
+
+import (
+	"testing"
+)
+
+// Make sure answers are graded leniently, and missing answers count as wrong.
+func TestGradeQuiz(t *testing.T) {
+	quiz := &Quiz{AnswerKeys: map[string]string{"q1": "a", "q2": "Paris", "q3": "c"}}
+	grade := GradeQuiz(quiz, map[string]string{"q1": "a", "q2": " paris ", "q4": "c"})
+	if grade.Correct != 2 || grade.Total != 3 {
+		t.Errorf("Unexpected grade: %d/%d", grade.Correct, grade.Total)
+	}
+	if len(grade.WrongObjects) != 1 || grade.WrongObjects[0] != "q3" {
+		t.Errorf("Unexpected wrong objects: %v", grade.WrongObjects)
+	}
+}
+
+// Make sure a quiz is only graded once every question has an answer.
+func TestIsQuizAnswered(t *testing.T) {
+	quiz := &Quiz{AnswerKeys: map[string]string{"q1": "a", "q2": "b"}}
+	if IsQuizAnswered(quiz, map[string]string{"q1": "a", "other": "x"}) {
+		t.Errorf("Quiz with an unanswered question counted as answered")
+	}
+	if !IsQuizAnswered(quiz, map[string]string{"q1": "wrong", "q2": "b"}) {
+		t.Errorf("Quiz with all questions answered counted as unanswered")
+	}
+}
+
+// Make sure each grant has its own passing score.
+func TestGetPassedQuizGrants(t *testing.T) {
+	quiz := &Quiz{
+		AnswerKeys: map[string]string{"q1": "a", "q2": "b", "q3": "c"},
+		Grants: []*QuizGrant{
+			{MasteryID: "1", Level: BasicMasteryLevel, PassingScore: 2},
+			{MasteryID: "2", Level: TechnicalMasteryLevel},
+		},
+	}
+	passed := GetPassedQuizGrants(quiz, &QuizGrade{Correct: 2, Total: 3})
+	if len(passed) != 1 || passed[0].MasteryID != "1" {
+		t.Errorf("Unexpected grants for 2/3: %v", passed)
+	}
+	passed = GetPassedQuizGrants(quiz, &QuizGrade{Correct: 3, Total: 3})
+	if len(passed) != 2 {
+		t.Errorf("Unexpected grants for 3/3: %v", passed)
+	}
+	passed = GetPassedQuizGrants(&Quiz{Grants: quiz.Grants}, &QuizGrade{})
+	if len(passed) != 0 {
+		t.Errorf("Quiz without questions shouldn't grant anything: %v", passed)
+	}
+}
+
+// Make sure answers count towards the answer key of their edit.
+func TestComputeQuizStats(t *testing.T) {
+	quizzes := []*Quiz{
+		{Edit: 2, AnswerKeys: map[string]string{"q1": "a"}},
+		{Edit: 5, AnswerKeys: map[string]string{"q1": "b", "q2": "c"}},
+	}
+	answers := []*QuizAnswer{
+		{Edit: 1, Object: "q1", Value: "a"},
+		{Edit: 2, Object: "q1", Value: "a"},
+		{Edit: 4, Object: "q1", Value: "b"},
+		{Edit: 5, Object: "q1", Value: "b"},
+		{Edit: 6, Object: "q1", Value: "a"},
+		{Edit: 6, Object: "q2", Value: "d"},
+		{Edit: 6, Object: "q3", Value: "d"},
+	}
+	stats := ComputeQuizStats(quizzes, answers)
+	if len(stats) != 3 {
+		t.Fatalf("Unexpected number of items: %d", len(stats))
+	}
+	expected := []struct {
+		edit              int
+		object            string
+		answered, correct int
+	}{{2, "q1", 2, 1}, {5, "q1", 2, 1}, {5, "q2", 1, 0}}
+	for n, e := range expected {
+		s := stats[n]
+		if s.Edit != e.edit || s.Object != e.object || s.Answered != e.answered || s.Correct != e.correct {
+			t.Errorf("Unexpected stats for item %d: %+v", n, s)
+		}
+	}
+	if stats[1].WrongAnswers["a"] != 1 || stats[2].WrongAnswers["d"] != 1 {
+		t.Errorf("Unexpected wrong answers: %v %v", stats[1].WrongAnswers, stats[2].WrongAnswers)
+	}
+}
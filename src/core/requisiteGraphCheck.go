@@ -0,0 +1,92 @@
+// requisiteGraphCheck.go contains the functions for tracking requisite graph integrity checks.
+package core
+
+import (
+	"fmt"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// Types of problems a requisite graph check can find
+	CycleRequisiteFinding     = "cycle"
+	OrphanRequisiteFinding    = "orphan"
+	DeletedRequisiteFinding   = "deleted"
+	RedundantRequisiteFinding = "redundant"
+)
+
+// RequisiteGraphCheck is a run of the integrity check of the requirement/subject graph.
+type RequisiteGraphCheck struct {
+	ID             string `json:"id"`
+	CreatedAt      string `json:"createdAt"`
+	PairsChecked   int    `json:"pairsChecked"`
+	CycleCount     int    `json:"cycleCount"`
+	OrphanCount    int    `json:"orphanCount"`
+	DeletedCount   int    `json:"deletedCount"`
+	RedundantCount int    `json:"redundantCount"`
+}
+
+// RequisiteGraphCheckFinding is one problem found by a requisite graph check.
+type RequisiteGraphCheckFinding struct {
+	CheckID string `json:"checkId"`
+	Type    string `json:"type"`
+	// Page with the problem: the requiring page for deleted and redundant
+	// requirements, the subject for orphans, and the first subject of a cycle
+	PageID string `json:"pageId"`
+	// The required page, for deleted and redundant requirements
+	AuxPageID string `json:"auxPageId"`
+	// Domain of the page with the problem
+	DomainID  string `json:"domainId"`
+	Details   string `json:"details"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// LoadLatestRequisiteGraphCheck loads the most recent check, or nil if there are none.
+func LoadLatestRequisiteGraphCheck(db *database.DB) (*RequisiteGraphCheck, error) {
+	check := &RequisiteGraphCheck{}
+	exists, err := database.NewQuery(`
+		SELECT id,createdAt,pairsChecked,cycleCount,orphanCount,deletedCount,redundantCount
+		FROM requisiteGraphChecks
+		ORDER BY createdAt DESC, id DESC
+		LIMIT 1`).ToStatement(db).QueryRow().Scan(&check.ID, &check.CreatedAt, &check.PairsChecked,
+		&check.CycleCount, &check.OrphanCount, &check.DeletedCount, &check.RedundantCount)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load latest requisite graph check: %v", err)
+	} else if !exists {
+		return nil, nil
+	}
+	return check, nil
+}
+
+// LoadRequisiteGraphCheckFindings loads the problems found by the given check
+// in the given domains. If domainIDs is nil, problems in all domains are loaded.
+func LoadRequisiteGraphCheckFindings(db *database.DB, checkID string, domainIDs []string, limit int) ([]*RequisiteGraphCheckFinding, error) {
+	findings := make([]*RequisiteGraphCheckFinding, 0)
+	query := database.NewQuery(`
+		SELECT checkId,type,pageId,auxPageId,domainId,details,createdAt
+		FROM requisiteGraphCheckFindings
+		WHERE checkId=?`, checkID)
+	if domainIDs != nil {
+		if len(domainIDs) <= 0 {
+			return findings, nil
+		}
+		query.Add(`AND domainId IN`).AddArgsGroupStr(domainIDs)
+	}
+	rows := query.Add(`
+		ORDER BY type,pageId,auxPageId
+		LIMIT ?`, limit).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var finding RequisiteGraphCheckFinding
+		err := rows.Scan(&finding.CheckID, &finding.Type, &finding.PageID, &finding.AuxPageID,
+			&finding.DomainID, &finding.Details, &finding.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		findings = append(findings, &finding)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load requisite graph check findings: %v", err)
+	}
+	return findings, nil
+}
@@ -0,0 +1,96 @@
+// scheduledPublish.go contains helpers for edits scheduled to be published later.
+package core
+
+import (
+	"fmt"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// Statuses of a scheduled publish
+	PendingScheduledPublish   = "pending"
+	PublishedScheduledPublish = "published"
+	CancelledScheduledPublish = "cancelled"
+	FailedScheduledPublish    = "failed"
+)
+
+// ScheduledPublish is an edit which will become live at the given time.
+type ScheduledPublish struct {
+	ID     string `json:"id"`
+	PageID string `json:"pageId"`
+	Edit   int    `json:"edit"`
+	UserID string `json:"userId"`
+	// Live edit at the time the publish was scheduled
+	BaseEdit      int    `json:"baseEdit"`
+	PublishAt     string `json:"publishAt"`
+	Status        string `json:"status"`
+	FailureReason string `json:"failureReason"`
+	CreatedAt     string `json:"createdAt"`
+	UpdatedAt     string `json:"updatedAt"`
+}
+
+// LoadScheduledPublishes loads the scheduled publishes matching the given condition.
+func LoadScheduledPublishes(db *database.DB, queryPart *database.QueryPart) ([]*ScheduledPublish, error) {
+	scheduledPublishes := make([]*ScheduledPublish, 0)
+	rows := database.NewQuery(`
+		SELECT id,pageId,edit,userId,baseEdit,publishAt,status,failureReason,createdAt,updatedAt
+		FROM scheduledPublishes`).AddPart(queryPart).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var sp ScheduledPublish
+		err := rows.Scan(&sp.ID, &sp.PageID, &sp.Edit, &sp.UserID, &sp.BaseEdit, &sp.PublishAt,
+			&sp.Status, &sp.FailureReason, &sp.CreatedAt, &sp.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		scheduledPublishes = append(scheduledPublishes, &sp)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load scheduled publishes: %v", err)
+	}
+	return scheduledPublishes, nil
+}
+
+// LoadScheduledPublish loads the scheduled publish with the given id. Returns
+// nil if it doesn't exist.
+func LoadScheduledPublish(db *database.DB, id string) (*ScheduledPublish, error) {
+	scheduledPublishes, err := LoadScheduledPublishes(db, database.NewQuery(`WHERE id=?`, id))
+	if err != nil || len(scheduledPublishes) <= 0 {
+		return nil, err
+	}
+	return scheduledPublishes[0], nil
+}
+
+// LockScheduledPublishStatus loads the current status of the scheduled publish
+// and locks its row until the transaction ends. Returns "" if it doesn't exist.
+func LockScheduledPublishStatus(tx *database.Tx, id string) (string, error) {
+	var status string
+	row := database.NewQuery(`
+		SELECT status
+		FROM scheduledPublishes
+		WHERE id=?
+		FOR UPDATE`, id).ToTxStatement(tx).QueryRow()
+	if _, err := row.Scan(&status); err != nil {
+		return "", fmt.Errorf("Couldn't load scheduled publish status: %v", err)
+	}
+	return status, nil
+}
+
+// SetScheduledPublishStatus updates the status of the scheduled publish, as long
+// as it's still pending. Returns true if the status was updated.
+func SetScheduledPublishStatus(tx *database.Tx, id string, status string, failureReason string) (bool, error) {
+	statement := database.NewQuery(`
+		UPDATE scheduledPublishes
+		SET status=?,failureReason=?,updatedAt=?`, status, failureReason, database.Now()).Add(`
+		WHERE id=? AND status=?`, id, PendingScheduledPublish).ToTxStatement(tx)
+	result, err := statement.Exec()
+	if err != nil {
+		return false, fmt.Errorf("Couldn't update scheduled publish: %v", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("Couldn't get the number of updated rows: %v", err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,104 @@
+// searchIndexCheck.go contains the functions for tracking search index consistency checks.
+package core
+
+import (
+	"fmt"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// Types of problems a search index check can find
+	MissingSearchIndexFinding  = "missing"
+	MismatchSearchIndexFinding = "mismatch"
+	ExtraSearchIndexFinding    = "extra"
+)
+
+// SearchIndexCheck is a run of the consistency check between the db and the search index.
+type SearchIndexCheck struct {
+	ID            string `json:"id"`
+	CreatedBy     string `json:"createdBy"`
+	CreatedAt     string `json:"createdAt"`
+	UpdatedAt     string `json:"updatedAt"`
+	Repair        bool   `json:"repair"`
+	LastPageID    string `json:"lastPageId"`
+	PagesChecked  int    `json:"pagesChecked"`
+	MissingCount  int    `json:"missingCount"`
+	MismatchCount int    `json:"mismatchCount"`
+	ExtraCount    int    `json:"extraCount"`
+	RepairedCount int    `json:"repairedCount"`
+	IsFinished    bool   `json:"isFinished"`
+}
+
+// SearchIndexCheckFinding is one problem found by a search index check.
+type SearchIndexCheckFinding struct {
+	CheckID   string `json:"checkId"`
+	PageID    string `json:"pageId"`
+	Type      string `json:"type"`
+	Details   string `json:"details"`
+	Repaired  bool   `json:"repaired"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// LoadSearchIndexCheck loads the check with the given id. Returns nil if there is no such check.
+func LoadSearchIndexCheck(db *database.DB, id string) (*SearchIndexCheck, error) {
+	var check *SearchIndexCheck
+	rows := database.NewQuery(`
+		SELECT id,createdBy,createdAt,updatedAt,repair,lastPageId,pagesChecked,
+			missingCount,mismatchCount,extraCount,repairedCount,isFinished
+		FROM searchIndexChecks
+		WHERE id=?`, id).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		check = &SearchIndexCheck{}
+		err := rows.Scan(&check.ID, &check.CreatedBy, &check.CreatedAt, &check.UpdatedAt,
+			&check.Repair, &check.LastPageID, &check.PagesChecked, &check.MissingCount,
+			&check.MismatchCount, &check.ExtraCount, &check.RepairedCount, &check.IsFinished)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load search index check: %v", err)
+	}
+	return check, nil
+}
+
+// LoadLatestSearchIndexCheckID returns the id of the most recently started check, or "" if there are none.
+func LoadLatestSearchIndexCheckID(db *database.DB) (string, error) {
+	var id string
+	_, err := database.NewQuery(`
+		SELECT id
+		FROM searchIndexChecks
+		ORDER BY createdAt DESC, id DESC
+		LIMIT 1`).ToStatement(db).QueryRow().Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't load latest search index check: %v", err)
+	}
+	return id, nil
+}
+
+// LoadSearchIndexCheckFindings loads the problems found by the given check.
+func LoadSearchIndexCheckFindings(db *database.DB, checkID string, limit int) ([]*SearchIndexCheckFinding, error) {
+	findings := make([]*SearchIndexCheckFinding, 0)
+	rows := database.NewQuery(`
+		SELECT checkId,pageId,type,details,repaired,createdAt
+		FROM searchIndexCheckFindings
+		WHERE checkId=?`, checkID).Add(`
+		ORDER BY pageId
+		LIMIT ?`, limit).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var finding SearchIndexCheckFinding
+		err := rows.Scan(&finding.CheckID, &finding.PageID, &finding.Type,
+			&finding.Details, &finding.Repaired, &finding.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		findings = append(findings, &finding)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load search index check findings: %v", err)
+	}
+	return findings, nil
+}
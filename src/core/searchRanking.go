@@ -0,0 +1,266 @@
+// searchRanking.go contains the rules for adjusting search result scores.
+package core
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// Types of rules stored in searchRankingRules table
+	TagSearchRankingRule      = "tag"
+	PageTypeSearchRankingRule = "pageType"
+
+	// Domain id for the site-wide search ranking rules
+	DefaultSearchRankingDomainID = "0"
+)
+
+// SearchRanking describes how the scores returned by Elastic are adjusted
+// before the results are sorted and returned to the FE.
+type SearchRanking struct {
+	DomainID string `json:"domainId"`
+
+	// Map: tag page id -> factor by which to multiply the score of a page with that tag
+	TagMultipliers map[string]float64 `json:"tagMultipliers"`
+	// Map: page type -> factor by which to multiply the score of a page with that type
+	TypeMultipliers map[string]float64 `json:"typeMultipliers"`
+
+	// Like score curve: score *= log(likeScore) / LikeScoreDivisor + 1
+	// If 0, likes don't affect the score.
+	LikeScoreDivisor float64 `json:"likeScoreDivisor"`
+	// Multiplier if the current user liked the page
+	MyLikeMultiplier float64 `json:"myLikeMultiplier"`
+	// Multiplier if the current user is one of the page's creators
+	CreatorMultiplier float64 `json:"creatorMultiplier"`
+
+	// Recency decay: the score is halved every RecencyHalfLifeDays since the
+	// page's last edit, but never goes below RecencyMinMultiplier.
+	// If RecencyHalfLifeDays is 0, there is no decay.
+	RecencyHalfLifeDays  int     `json:"recencyHalfLifeDays"`
+	RecencyMinMultiplier float64 `json:"recencyMinMultiplier"`
+
+	UpdatedBy string `json:"updatedBy"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// NewDefaultSearchRanking returns the ranking we use when a domain hasn't
+// configured anything.
+func NewDefaultSearchRanking() *SearchRanking {
+	return &SearchRanking{
+		DomainID: DefaultSearchRankingDomainID,
+		TagMultipliers: map[string]float64{
+			JustARequisitePageID: 0.75,
+			OutOfDatePageID:      0.85,
+			WorkInProgressPageID: 0.75,
+			StubPageID:           0.65,
+			ConceptPageID:        2.0,
+		},
+		TypeMultipliers: map[string]float64{
+			GroupPageType: 0.2,
+		},
+		LikeScoreDivisor:     10,
+		MyLikeMultiplier:     1.2,
+		CreatorMultiplier:    1.2,
+		RecencyHalfLifeDays:  0,
+		RecencyMinMultiplier: 1,
+	}
+}
+
+// ComputeMultiplier returns the factor by which the search score of the given
+// page should be multiplied.
+func (r *SearchRanking) ComputeMultiplier(p *Page, u *CurrentUser, now time.Time) float64 {
+	multiplier := 1.0
+
+	// Adjust the score based on tags
+	for _, tagID := range p.TagIDs {
+		if m, ok := r.TagMultipliers[tagID]; ok {
+			multiplier *= m
+		}
+	}
+
+	// Adjust the score based on page type
+	if m, ok := r.TypeMultipliers[p.Type]; ok {
+		multiplier *= m
+	}
+
+	// Adjust the score based on likes
+	if p.LikeScore > 0 && r.LikeScoreDivisor > 0 {
+		multiplier *= math.Log(float64(p.LikeScore))/r.LikeScoreDivisor + 1
+	}
+	if p.MyLikeValue > 0 && r.MyLikeMultiplier > 0 {
+		multiplier *= r.MyLikeMultiplier
+	}
+
+	// Adjust the score if the user created the page
+	if u != nil && u.ID != "" && r.CreatorMultiplier > 0 {
+		for _, creatorID := range p.CreatorIDs {
+			if creatorID == u.ID {
+				multiplier *= r.CreatorMultiplier
+				break
+			}
+		}
+	}
+
+	// Adjust the score based on how long ago the page was edited
+	if r.RecencyHalfLifeDays > 0 {
+		if editedAt, err := time.Parse(database.TimeLayout, p.EditCreatedAt); err == nil {
+			ageDays := now.Sub(editedAt).Hours() / 24
+			decay := math.Pow(0.5, ageDays/float64(r.RecencyHalfLifeDays))
+			multiplier *= math.Max(decay, r.RecencyMinMultiplier)
+		}
+	}
+	return multiplier
+}
+
+// Validate checks that all the values in the ranking make sense.
+func (r *SearchRanking) Validate() error {
+	for tagID, m := range r.TagMultipliers {
+		if !IsIDValid(tagID) {
+			return fmt.Errorf("Invalid tag id: %s", tagID)
+		}
+		if m < 0 {
+			return fmt.Errorf("Negative multiplier for tag %s", tagID)
+		}
+	}
+	for pageType, m := range r.TypeMultipliers {
+		if _, err := CorrectPageType(pageType); err != nil {
+			return err
+		}
+		if m < 0 {
+			return fmt.Errorf("Negative multiplier for type %s", pageType)
+		}
+	}
+	if r.LikeScoreDivisor < 0 || r.MyLikeMultiplier < 0 || r.CreatorMultiplier < 0 {
+		return fmt.Errorf("Like and creator multipliers can't be negative")
+	}
+	if r.RecencyHalfLifeDays < 0 {
+		return fmt.Errorf("Recency half-life can't be negative")
+	}
+	if r.RecencyMinMultiplier < 0 || r.RecencyMinMultiplier > 1 {
+		return fmt.Errorf("Recency min multiplier has to be between 0 and 1")
+	}
+	return nil
+}
+
+// CanUserEditSearchRanking returns true iff the user can change search ranking
+// rules for the given domain.
+func CanUserEditSearchRanking(u *CurrentUser, domainID string) bool {
+	if u.IsAdmin {
+		return true
+	}
+	if domainID == DefaultSearchRankingDomainID {
+		return false
+	}
+	return RoleAtLeast(u.GetDomainMembershipRole(domainID), ArbiterDomainRole)
+}
+
+// LoadSearchRanking loads the search ranking for the given domain. Rules set for
+// the domain override the site-wide rules, which override the built-in defaults.
+func LoadSearchRanking(db *database.DB, domainID string) (*SearchRanking, error) {
+	ranking := NewDefaultSearchRanking()
+	if !IsIntIDValid(domainID) {
+		domainID = DefaultSearchRankingDomainID
+	}
+	domainIDs := []string{DefaultSearchRankingDomainID}
+	if domainID != DefaultSearchRankingDomainID {
+		domainIDs = append(domainIDs, domainID)
+	}
+	ranking.DomainID = domainID
+
+	// Load settings; the domain's row (if any) is processed last
+	rows := database.NewQuery(`
+		SELECT domainId,likeScoreDivisor,myLikeMultiplier,creatorMultiplier,
+			recencyHalfLifeDays,recencyMinMultiplier,updatedBy,updatedAt
+		FROM searchRankingSettings
+		WHERE domainId IN`).AddArgsGroupStr(domainIDs).Add(`
+		ORDER BY domainId=?`, domainID).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var rowDomainID string
+		err := rows.Scan(&rowDomainID, &ranking.LikeScoreDivisor, &ranking.MyLikeMultiplier,
+			&ranking.CreatorMultiplier, &ranking.RecencyHalfLifeDays, &ranking.RecencyMinMultiplier,
+			&ranking.UpdatedBy, &ranking.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("Failed to scan: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load search ranking settings: %v", err)
+	}
+
+	// Load tag and type rules
+	rows = database.NewQuery(`
+		SELECT type,ruleKey,multiplier
+		FROM searchRankingRules
+		WHERE domainId IN`).AddArgsGroupStr(domainIDs).Add(`
+		ORDER BY domainId=?`, domainID).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var ruleType, ruleKey string
+		var multiplier float64
+		err := rows.Scan(&ruleType, &ruleKey, &multiplier)
+		if err != nil {
+			return fmt.Errorf("Failed to scan: %v", err)
+		}
+		if ruleType == TagSearchRankingRule {
+			ranking.TagMultipliers[ruleKey] = multiplier
+		} else if ruleType == PageTypeSearchRankingRule {
+			ranking.TypeMultipliers[ruleKey] = multiplier
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load search ranking rules: %v", err)
+	}
+	return ranking, nil
+}
+
+// SaveSearchRanking replaces all the search ranking rules for the ranking's domain.
+func SaveSearchRanking(tx *database.Tx, ranking *SearchRanking) error {
+	hashmap := make(database.InsertMap)
+	hashmap["domainId"] = ranking.DomainID
+	hashmap["likeScoreDivisor"] = ranking.LikeScoreDivisor
+	hashmap["myLikeMultiplier"] = ranking.MyLikeMultiplier
+	hashmap["creatorMultiplier"] = ranking.CreatorMultiplier
+	hashmap["recencyHalfLifeDays"] = ranking.RecencyHalfLifeDays
+	hashmap["recencyMinMultiplier"] = ranking.RecencyMinMultiplier
+	hashmap["updatedBy"] = ranking.UpdatedBy
+	hashmap["updatedAt"] = ranking.UpdatedAt
+	statement := tx.DB.NewInsertStatement("searchRankingSettings", hashmap, hashmap.GetKeys()...).WithTx(tx)
+	if _, err := statement.Exec(); err != nil {
+		return fmt.Errorf("Couldn't update search ranking settings: %v", err)
+	}
+
+	statement = database.NewQuery(`
+		DELETE FROM searchRankingRules WHERE domainId=?`, ranking.DomainID).ToTxStatement(tx)
+	if _, err := statement.Exec(); err != nil {
+		return fmt.Errorf("Couldn't delete old search ranking rules: %v", err)
+	}
+
+	hashmaps := make(database.InsertMaps, 0)
+	addRule := func(ruleType, ruleKey string, multiplier float64) {
+		hashmap := make(database.InsertMap)
+		hashmap["domainId"] = ranking.DomainID
+		hashmap["type"] = ruleType
+		hashmap["ruleKey"] = ruleKey
+		hashmap["multiplier"] = multiplier
+		hashmap["updatedBy"] = ranking.UpdatedBy
+		hashmap["updatedAt"] = ranking.UpdatedAt
+		hashmaps = append(hashmaps, hashmap)
+	}
+	for tagID, multiplier := range ranking.TagMultipliers {
+		addRule(TagSearchRankingRule, tagID, multiplier)
+	}
+	for pageType, multiplier := range ranking.TypeMultipliers {
+		addRule(PageTypeSearchRankingRule, pageType, multiplier)
+	}
+	if len(hashmaps) > 0 {
+		statement = tx.DB.NewMultipleInsertStatement("searchRankingRules", hashmaps).WithTx(tx)
+		if _, err := statement.Exec(); err != nil {
+			return fmt.Errorf("Couldn't insert search ranking rules: %v", err)
+		}
+	}
+	return nil
+}
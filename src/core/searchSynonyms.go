@@ -0,0 +1,118 @@
+// searchSynonyms.go contains the functions for working with search synonyms.
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"zanaduu3/src/database"
+)
+
+const (
+	// Max number of alternative search terms we generate from synonyms
+	maxSearchTermExpansions = 10
+)
+
+// SearchSynonymGroup is a list of terms which are considered equivalent when searching.
+type SearchSynonymGroup struct {
+	ID        string   `json:"id"`
+	DomainID  string   `json:"domainId"`
+	Terms     []string `json:"terms"`
+	CreatedBy string   `json:"createdBy"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+// NormalizeSearchSynonymTerms lowercases and trims the given terms, and removes
+// empty and duplicate ones.
+func NormalizeSearchSynonymTerms(terms []string) []string {
+	normalized := make([]string, 0)
+	for _, term := range terms {
+		term = strings.Join(strings.Fields(strings.ToLower(term)), " ")
+		term = strings.Replace(term, ",", "", -1)
+		if term != "" && !IsStringInList(term, normalized) {
+			normalized = append(normalized, term)
+		}
+	}
+	return normalized
+}
+
+// CanUserEditSearchSynonyms returns true iff the user can change search synonyms
+// for the given domain.
+func CanUserEditSearchSynonyms(u *CurrentUser, domainID string) bool {
+	return CanUserEditSearchRanking(u, domainID)
+}
+
+// LoadSearchSynonyms loads all synonym groups for the given domains.
+func LoadSearchSynonyms(db *database.DB, domainIDs []string) ([]*SearchSynonymGroup, error) {
+	groups := make([]*SearchSynonymGroup, 0)
+	if len(domainIDs) <= 0 {
+		return groups, nil
+	}
+	rows := database.NewQuery(`
+		SELECT id,domainId,terms,createdBy,createdAt
+		FROM searchSynonyms
+		WHERE domainId IN`).AddArgsGroupStr(domainIDs).Add(`
+		ORDER BY id`).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var group SearchSynonymGroup
+		var terms string
+		err := rows.Scan(&group.ID, &group.DomainID, &terms, &group.CreatedBy, &group.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		group.Terms = strings.Split(terms, ",")
+		groups = append(groups, &group)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load search synonyms: %v", err)
+	}
+	return groups, nil
+}
+
+// ExpandSearchTerm returns the alternative versions of the given search term
+// where a term from a synonym group is replaced with the other terms in the group.
+// The original term isn't included.
+func ExpandSearchTerm(term string, groups []*SearchSynonymGroup) []string {
+	expansions := make([]string, 0)
+	paddedTerm := " " + strings.Join(strings.Fields(strings.ToLower(term)), " ") + " "
+	for _, group := range groups {
+		for _, synonym := range group.Terms {
+			if synonym == "" || !strings.Contains(paddedTerm, " "+synonym+" ") {
+				continue
+			}
+			for _, replacement := range group.Terms {
+				if replacement == synonym || replacement == "" {
+					continue
+				}
+				expansion := strings.Replace(paddedTerm, " "+synonym+" ", " "+replacement+" ", -1)
+				expansion = strings.TrimSpace(expansion)
+				if !IsStringInList(expansion, expansions) {
+					expansions = append(expansions, expansion)
+				}
+				if len(expansions) >= maxSearchTermExpansions {
+					return expansions
+				}
+			}
+		}
+	}
+	return expansions
+}
+
+// LoadSearchSynonymGroup loads the synonym group with the given id. Returns nil if there is no such group.
+func LoadSearchSynonymGroup(db *database.DB, id string) (*SearchSynonymGroup, error) {
+	group := &SearchSynonymGroup{}
+	var terms string
+	row := database.NewQuery(`
+		SELECT id,domainId,terms,createdBy,createdAt
+		FROM searchSynonyms
+		WHERE id=?`, id).ToStatement(db).QueryRow()
+	exists, err := row.Scan(&group.ID, &group.DomainID, &terms, &group.CreatedBy, &group.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load search synonym group: %v", err)
+	} else if !exists {
+		return nil, nil
+	}
+	group.Terms = strings.Split(terms, ",")
+	return group, nil
+}
@@ -0,0 +1,223 @@
+// sessionMerge.go moves what a logged-out reader did in their session into their account.
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"zanaduu3/src/database"
+)
+
+// SessionMergeResult counts the rows that were moved into the account.
+type SessionMergeResult struct {
+	Masteries     int
+	PathInstances int
+	PageObjects   int
+	Visits        int
+	LastVisits    int
+}
+
+// userMasteryPair is a row from the userMasteryPairs table.
+type userMasteryPair struct {
+	MasteryID       string
+	CreatedAt       string
+	UpdatedAt       string
+	Has             bool
+	Wants           bool
+	Level           int
+	TaughtBy        string
+	TrustSnapshotID string
+}
+
+// mergeUserMasteryPairs combines the account's and the session's entries for
+// the same mastery. Having the mastery beats not having it, then the higher
+// level wins, then the more recent update.
+func mergeUserMasteryPairs(userPair, sessionPair *userMasteryPair) *userMasteryPair {
+	winner := userPair
+	if sessionPair.Has != userPair.Has {
+		if sessionPair.Has {
+			winner = sessionPair
+		}
+	} else if sessionPair.Has && sessionPair.Level != userPair.Level {
+		if sessionPair.Level > userPair.Level {
+			winner = sessionPair
+		}
+	} else if sessionPair.UpdatedAt > userPair.UpdatedAt {
+		winner = sessionPair
+	}
+
+	merged := *winner
+	if sessionPair.CreatedAt < userPair.CreatedAt {
+		merged.CreatedAt = sessionPair.CreatedAt
+	} else {
+		merged.CreatedAt = userPair.CreatedAt
+	}
+	if sessionPair.UpdatedAt > userPair.UpdatedAt {
+		merged.UpdatedAt = sessionPair.UpdatedAt
+	} else {
+		merged.UpdatedAt = userPair.UpdatedAt
+	}
+	return &merged
+}
+
+// loadUserMasteryPairs loads all the mastery entries for the given user or session id.
+func loadUserMasteryPairs(tx *database.Tx, userID string) (map[string]*userMasteryPair, error) {
+	pairs := make(map[string]*userMasteryPair)
+	rows := database.NewQuery(`
+		SELECT masteryId,createdAt,updatedAt,has,wants,level,taughtBy,trustSnapshotId
+		FROM userMasteryPairs
+		WHERE userId=?`, userID).ToTxStatement(tx).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var pair userMasteryPair
+		err := rows.Scan(&pair.MasteryID, &pair.CreatedAt, &pair.UpdatedAt, &pair.Has,
+			&pair.Wants, &pair.Level, &pair.TaughtBy, &pair.TrustSnapshotID)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		pairs[pair.MasteryID] = &pair
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// MergeSessionProgress reassigns the masteries, path instances, page objects and
+// visits recorded under the session id to the user. When both have an entry for
+// the same thing, the higher mastery level and the more recent update win.
+func MergeSessionProgress(tx *database.Tx, sessionID, userID string) (*SessionMergeResult, error) {
+	result := &SessionMergeResult{}
+	if !strings.HasPrefix(sessionID, "sid:") {
+		return nil, fmt.Errorf("Invalid session id")
+	}
+	if !IsIDValid(userID) {
+		return nil, fmt.Errorf("Invalid user id: %s", userID)
+	}
+
+	// Masteries
+	sessionPairs, err := loadUserMasteryPairs(tx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load session masteries: %v", err)
+	}
+	if len(sessionPairs) > 0 {
+		userPairs, err := loadUserMasteryPairs(tx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't load user masteries: %v", err)
+		}
+		hashmaps := make(database.InsertMaps, 0)
+		for masteryID, sessionPair := range sessionPairs {
+			merged := sessionPair
+			if userPair, ok := userPairs[masteryID]; ok {
+				merged = mergeUserMasteryPairs(userPair, sessionPair)
+			}
+			hashmap := make(database.InsertMap)
+			hashmap["userId"] = userID
+			hashmap["masteryId"] = masteryID
+			hashmap["createdAt"] = merged.CreatedAt
+			hashmap["updatedAt"] = merged.UpdatedAt
+			hashmap["has"] = merged.Has
+			hashmap["wants"] = merged.Wants
+			hashmap["level"] = merged.Level
+			hashmap["taughtBy"] = merged.TaughtBy
+			hashmap["trustSnapshotId"] = merged.TrustSnapshotID
+			hashmaps = append(hashmaps, hashmap)
+		}
+		statement := tx.DB.NewMultipleInsertStatement("userMasteryPairs", hashmaps, "createdAt", "updatedAt",
+			"has", "wants", "level", "taughtBy", "trustSnapshotId").WithTx(tx)
+		if _, err := statement.Exec(); err != nil {
+			return nil, fmt.Errorf("Couldn't merge masteries: %v", err)
+		}
+		statement = database.NewQuery(`
+			DELETE FROM userMasteryPairs
+			WHERE userId=?`, sessionID).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return nil, fmt.Errorf("Couldn't delete session masteries: %v", err)
+		}
+		result.Masteries = len(hashmaps)
+
+		// Keep the account's review schedule if it has one
+		statement = database.NewQuery(`
+			UPDATE IGNORE masteryReviews
+			SET userId=?`, userID).Add(`
+			WHERE userId=?`, sessionID).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return nil, fmt.Errorf("Couldn't move mastery reviews: %v", err)
+		}
+		statement = database.NewQuery(`
+			DELETE FROM masteryReviews
+			WHERE userId=?`, sessionID).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return nil, fmt.Errorf("Couldn't delete session mastery reviews: %v", err)
+		}
+	}
+
+	// Path instances
+	statement := database.NewQuery(`
+		UPDATE pathInstances
+		SET userId=?`, userID).Add(`
+		WHERE userId=?`, sessionID).ToTxStatement(tx)
+	if result.PathInstances, err = execAndCount(statement); err != nil {
+		return nil, fmt.Errorf("Couldn't move path instances: %v", err)
+	}
+
+	// Page objects. NOTE: updatedAt has to be assigned last, since MySQL uses
+	// the new values in the assignments that follow.
+	statement = database.NewQuery(`
+		INSERT INTO userPageObjectPairs (userId,pageId,edit,object,createdAt,updatedAt,value,attempts)
+		SELECT ?,pageId,edit,object,createdAt,updatedAt,value,attempts`, userID).Add(`
+		FROM userPageObjectPairs AS s
+		WHERE s.userId=?`, sessionID).Add(`
+		ON DUPLICATE KEY UPDATE
+			edit=IF(VALUES(updatedAt)>userPageObjectPairs.updatedAt,VALUES(edit),userPageObjectPairs.edit),
+			value=IF(VALUES(updatedAt)>userPageObjectPairs.updatedAt,VALUES(value),userPageObjectPairs.value),
+			createdAt=LEAST(userPageObjectPairs.createdAt,VALUES(createdAt)),
+			attempts=userPageObjectPairs.attempts+VALUES(attempts),
+			updatedAt=GREATEST(userPageObjectPairs.updatedAt,VALUES(updatedAt))`).ToTxStatement(tx)
+	if _, err := statement.Exec(); err != nil {
+		return nil, fmt.Errorf("Couldn't merge page objects: %v", err)
+	}
+	statement = database.NewQuery(`
+		DELETE FROM userPageObjectPairs
+		WHERE userId=?`, sessionID).ToTxStatement(tx)
+	if result.PageObjects, err = execAndCount(statement); err != nil {
+		return nil, fmt.Errorf("Couldn't delete session page objects: %v", err)
+	}
+
+	// Visits
+	statement = database.NewQuery(`
+		UPDATE visits
+		SET userId=?`, userID).Add(`
+		WHERE userId=?`, sessionID).ToTxStatement(tx)
+	if result.Visits, err = execAndCount(statement); err != nil {
+		return nil, fmt.Errorf("Couldn't move visits: %v", err)
+	}
+	statement = database.NewQuery(`
+		INSERT INTO lastVisits (userId,pageId,createdAt,updatedAt)
+		SELECT ?,pageId,createdAt,updatedAt`, userID).Add(`
+		FROM lastVisits AS s
+		WHERE s.userId=?`, sessionID).Add(`
+		ON DUPLICATE KEY UPDATE
+			createdAt=LEAST(lastVisits.createdAt,VALUES(createdAt)),
+			updatedAt=GREATEST(lastVisits.updatedAt,VALUES(updatedAt))`).ToTxStatement(tx)
+	if _, err := statement.Exec(); err != nil {
+		return nil, fmt.Errorf("Couldn't merge last visits: %v", err)
+	}
+	statement = database.NewQuery(`
+		DELETE FROM lastVisits
+		WHERE userId=?`, sessionID).ToTxStatement(tx)
+	if result.LastVisits, err = execAndCount(statement); err != nil {
+		return nil, fmt.Errorf("Couldn't delete session last visits: %v", err)
+	}
+	return result, nil
+}
+
+// execAndCount executes the statement and returns the number of affected rows.
+func execAndCount(statement *database.Stmt) (int, error) {
+	result, err := statement.Exec()
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"testing"
+)
+
+// Make sure conflicting masteries keep the higher level, then the newer update.
+func TestMergeUserMasteryPairs(t *testing.T) {
+	older := &userMasteryPair{MasteryID: "1", CreatedAt: "2016-01-01 00:00:00", UpdatedAt: "2016-01-02 00:00:00",
+		Has: true, Level: TechnicalMasteryLevel, TaughtBy: "10"}
+	newer := &userMasteryPair{MasteryID: "1", CreatedAt: "2016-01-03 00:00:00", UpdatedAt: "2016-01-04 00:00:00",
+		Has: true, Level: BasicMasteryLevel, TaughtBy: "20"}
+
+	merged := mergeUserMasteryPairs(older, newer)
+	if merged.Level != TechnicalMasteryLevel || merged.TaughtBy != "10" {
+		t.Errorf("Higher level should win: %+v", merged)
+	}
+	if merged.CreatedAt != older.CreatedAt || merged.UpdatedAt != newer.UpdatedAt {
+		t.Errorf("Unexpected dates: %+v", merged)
+	}
+	if merged = mergeUserMasteryPairs(newer, older); merged.Level != TechnicalMasteryLevel {
+		t.Errorf("Higher level should win regardless of order: %+v", merged)
+	}
+
+	newer.Level = TechnicalMasteryLevel
+	if merged = mergeUserMasteryPairs(older, newer); merged.TaughtBy != "20" {
+		t.Errorf("Newer update should win on the same level: %+v", merged)
+	}
+
+	// Having the mastery beats just wanting it, even if wanting it is more recent
+	wants := &userMasteryPair{MasteryID: "1", CreatedAt: "2016-01-05 00:00:00", UpdatedAt: "2016-01-06 00:00:00",
+		Wants: true}
+	if merged = mergeUserMasteryPairs(wants, older); !merged.Has || merged.Wants {
+		t.Errorf("Having the mastery should win: %+v", merged)
+	}
+	notWants := &userMasteryPair{MasteryID: "1", CreatedAt: "2016-01-01 00:00:00", UpdatedAt: "2016-01-01 00:00:00"}
+	if merged = mergeUserMasteryPairs(notWants, wants); !merged.Wants {
+		t.Errorf("Newer wants should win: %+v", merged)
+	}
+}
@@ -0,0 +1,229 @@
+// textOperation.go implements operational transformation for plain text, which
+// is used for collaborative editing.
+package core
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// TextOpComponent is one step of a text operation. Exactly one of the fields
+// should be set. Lengths are measured in characters (runes).
+type TextOpComponent struct {
+	// Keep this many characters
+	Retain int `json:"retain,omitempty"`
+	// Insert this string
+	Insert string `json:"insert,omitempty"`
+	// Delete this many characters
+	Delete int `json:"delete,omitempty"`
+}
+
+// TextOperation is a list of components which together walk through the whole
+// text, transforming it into the new text.
+type TextOperation []*TextOpComponent
+
+// length returns the number of characters this component spans in the text
+// it's applied to (or inserts, for insert components).
+func (c *TextOpComponent) length() int {
+	if c.Insert != "" {
+		return utf8.RuneCountInString(c.Insert)
+	} else if c.Delete > 0 {
+		return c.Delete
+	}
+	return c.Retain
+}
+
+// isValid returns true iff exactly one of the fields is set.
+func (c *TextOpComponent) isValid() bool {
+	count := 0
+	if c.Retain > 0 {
+		count++
+	}
+	if c.Insert != "" {
+		count++
+	}
+	if c.Delete > 0 {
+		count++
+	}
+	return count == 1 && c.Retain >= 0 && c.Delete >= 0
+}
+
+// add appends the component to the operation, merging it with the last
+// component if they have the same type.
+func (op TextOperation) add(c *TextOpComponent) TextOperation {
+	if c.length() <= 0 {
+		return op
+	}
+	if len(op) > 0 {
+		last := op[len(op)-1]
+		if c.Retain > 0 && last.Retain > 0 {
+			return append(op[:len(op)-1], &TextOpComponent{Retain: last.Retain + c.Retain})
+		} else if c.Insert != "" && last.Insert != "" {
+			return append(op[:len(op)-1], &TextOpComponent{Insert: last.Insert + c.Insert})
+		} else if c.Delete > 0 && last.Delete > 0 {
+			return append(op[:len(op)-1], &TextOpComponent{Delete: last.Delete + c.Delete})
+		}
+	}
+	return append(op, c)
+}
+
+// Validate checks that all the components are well formed.
+func (op TextOperation) Validate() error {
+	for _, c := range op {
+		if c == nil || !c.isValid() {
+			return fmt.Errorf("Invalid operation component")
+		}
+	}
+	return nil
+}
+
+// BaseLength returns the length of the text this operation can be applied to.
+func (op TextOperation) BaseLength() int {
+	length := 0
+	for _, c := range op {
+		if c.Insert == "" {
+			length += c.length()
+		}
+	}
+	return length
+}
+
+// Apply applies the operation to the given text.
+func (op TextOperation) Apply(text string) (string, error) {
+	if err := op.Validate(); err != nil {
+		return "", err
+	}
+	runes := []rune(text)
+	if op.BaseLength() != len(runes) {
+		return "", fmt.Errorf("Operation length %d doesn't match text length %d", op.BaseLength(), len(runes))
+	}
+	result := make([]rune, 0, len(runes))
+	index := 0
+	for _, c := range op {
+		if c.Retain > 0 {
+			result = append(result, runes[index:index+c.Retain]...)
+			index += c.Retain
+		} else if c.Insert != "" {
+			result = append(result, []rune(c.Insert)...)
+		} else {
+			index += c.Delete
+		}
+	}
+	return string(result), nil
+}
+
+// textOpIterator walks through an operation, allowing to consume components
+// partially.
+type textOpIterator struct {
+	op    TextOperation
+	index int
+	// How much of the current component was already consumed
+	offset int
+}
+
+// peek returns the remaining part of the current component, or nil at the end.
+func (it *textOpIterator) peek() *TextOpComponent {
+	if it.index >= len(it.op) {
+		return nil
+	}
+	c := it.op[it.index]
+	if c.Insert != "" {
+		return &TextOpComponent{Insert: string([]rune(c.Insert)[it.offset:])}
+	} else if c.Delete > 0 {
+		return &TextOpComponent{Delete: c.Delete - it.offset}
+	}
+	return &TextOpComponent{Retain: c.Retain - it.offset}
+}
+
+// next consumes up to the given number of characters of the current component.
+func (it *textOpIterator) next(length int) {
+	it.offset += length
+	if it.offset >= it.op[it.index].length() {
+		it.index++
+		it.offset = 0
+	}
+}
+
+// TransformTextOperations transforms two concurrent operations a and b, which
+// were both applied to the same text, into a' and b' such that applying a then
+// b' gives the same result as applying b then a'. When both operations insert
+// at the same position, a's insert goes first.
+func TransformTextOperations(a, b TextOperation) (aPrime, bPrime TextOperation, err error) {
+	if err = a.Validate(); err != nil {
+		return nil, nil, err
+	} else if err = b.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if a.BaseLength() != b.BaseLength() {
+		return nil, nil, fmt.Errorf("Concurrent operations have different base lengths")
+	}
+	aPrime = make(TextOperation, 0)
+	bPrime = make(TextOperation, 0)
+	aIt := &textOpIterator{op: a}
+	bIt := &textOpIterator{op: b}
+	for {
+		aComp, bComp := aIt.peek(), bIt.peek()
+		if aComp == nil && bComp == nil {
+			break
+		}
+
+		// Inserts don't depend on the other operation
+		if aComp != nil && aComp.Insert != "" {
+			aPrime = aPrime.add(aComp)
+			bPrime = bPrime.add(&TextOpComponent{Retain: aComp.length()})
+			aIt.next(aComp.length())
+			continue
+		}
+		if bComp != nil && bComp.Insert != "" {
+			aPrime = aPrime.add(&TextOpComponent{Retain: bComp.length()})
+			bPrime = bPrime.add(bComp)
+			bIt.next(bComp.length())
+			continue
+		}
+		if aComp == nil || bComp == nil {
+			return nil, nil, fmt.Errorf("Operations are too short")
+		}
+
+		length := aComp.length()
+		if bComp.length() < length {
+			length = bComp.length()
+		}
+		if aComp.Retain > 0 && bComp.Retain > 0 {
+			aPrime = aPrime.add(&TextOpComponent{Retain: length})
+			bPrime = bPrime.add(&TextOpComponent{Retain: length})
+		} else if aComp.Delete > 0 && bComp.Retain > 0 {
+			aPrime = aPrime.add(&TextOpComponent{Delete: length})
+		} else if aComp.Retain > 0 && bComp.Delete > 0 {
+			bPrime = bPrime.add(&TextOpComponent{Delete: length})
+		}
+		// If both delete the same text, there is nothing left to do
+		aIt.next(length)
+		bIt.next(length)
+	}
+	return aPrime, bPrime, nil
+}
+
+// TransformTextPosition returns where the given position in the text ends up
+// after the operation is applied.
+func TransformTextPosition(position int, op TextOperation) int {
+	index := 0
+	newPosition := position
+	for _, c := range op {
+		if index > position {
+			break
+		}
+		if c.Retain > 0 {
+			index += c.Retain
+		} else if c.Insert != "" {
+			newPosition += c.length()
+		} else {
+			if c.Delete > position-index {
+				newPosition -= position - index
+			} else {
+				newPosition -= c.Delete
+			}
+			index += c.Delete
+		}
+	}
+	return newPosition
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"testing"
+)
+
+// Make sure operations are applied correctly.
+func TestTextOperationApply(t *testing.T) {
+	op := TextOperation{{Retain: 2}, {Insert: "é!"}, {Delete: 1}, {Retain: 2}}
+	result, err := op.Apply("abcde")
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if result != "abé!de" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+	if _, err := op.Apply("abcdef"); err == nil {
+		t.Errorf("Expected an error for mismatched length")
+	}
+	if _, err := (TextOperation{{Retain: 1, Delete: 1}}).Apply("ab"); err == nil {
+		t.Errorf("Expected an error for an invalid component")
+	}
+}
+
+// Make sure concurrent operations converge after being transformed.
+func TestTransformTextOperations(t *testing.T) {
+	text := "hello world"
+	tests := []struct {
+		a, b     TextOperation
+		expected string
+	}{
+		{
+			a:        TextOperation{{Retain: 5}, {Insert: ","}, {Retain: 6}},
+			b:        TextOperation{{Retain: 11}, {Insert: "!"}},
+			expected: "hello, world!",
+		},
+		{
+			a:        TextOperation{{Insert: "A "}, {Retain: 11}},
+			b:        TextOperation{{Insert: "B "}, {Retain: 11}},
+			expected: "A B hello world",
+		},
+		{
+			a:        TextOperation{{Delete: 6}, {Retain: 5}},
+			b:        TextOperation{{Retain: 3}, {Delete: 5}, {Retain: 3}},
+			expected: "rld",
+		},
+		{
+			a:        TextOperation{{Retain: 6}, {Delete: 5}, {Insert: "there"}},
+			b:        TextOperation{{Retain: 8}, {Insert: "X"}, {Retain: 3}},
+			expected: "hello Xthere",
+		},
+	}
+	for n, test := range tests {
+		aPrime, bPrime, err := TransformTextOperations(test.a, test.b)
+		if err != nil {
+			t.Fatalf("Test %d: transform failed: %v", n, err)
+		}
+		afterA, _ := test.a.Apply(text)
+		resultAB, err := bPrime.Apply(afterA)
+		if err != nil {
+			t.Fatalf("Test %d: applying b' failed: %v", n, err)
+		}
+		afterB, _ := test.b.Apply(text)
+		resultBA, err := aPrime.Apply(afterB)
+		if err != nil {
+			t.Fatalf("Test %d: applying a' failed: %v", n, err)
+		}
+		if resultAB != resultBA {
+			t.Errorf("Test %d: results diverged: %q vs %q", n, resultAB, resultBA)
+		}
+		if resultAB != test.expected {
+			t.Errorf("Test %d: unexpected result %q, expected %q", n, resultAB, test.expected)
+		}
+	}
+}
+
+// Make sure cursor positions move with the text.
+func TestTransformTextPosition(t *testing.T) {
+	op := TextOperation{{Retain: 2}, {Insert: "xyz"}, {Retain: 2}, {Delete: 3}, {Retain: 1}}
+	tests := map[int]int{0: 0, 2: 5, 3: 6, 5: 7, 6: 7, 7: 7, 8: 8}
+	for position, expected := range tests {
+		if actual := TransformTextPosition(position, op); actual != expected {
+			t.Errorf("Position %d moved to %d, expected %d", position, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// textSimilarity.go computes TF-IDF based similarity between pages.
+package core
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// Only this many highest weighted terms of each document are used
+	maxSimilarityTermsPerDoc = 100
+	// Terms which occur in more than this fraction of documents are ignored
+	maxSimilarityTermDocFraction = 0.5
+	// Terms shorter than this are ignored
+	minSimilarityTermLength = 3
+)
+
+var (
+	// Matches markdown links, so we can drop the urls
+	similarityLinkRegexp = regexp.MustCompile(`\]\([^)]*\)`)
+	// Matches words
+	similarityWordRegexp = regexp.MustCompile(`[\pL][\pL\pN']*`)
+	// Common words that carry no meaning
+	similarityStopWords = map[string]bool{
+		"the": true, "and": true, "for": true, "are": true, "but": true, "not": true,
+		"you": true, "all": true, "any": true, "can": true, "had": true, "her": true,
+		"was": true, "one": true, "our": true, "out": true, "has": true, "him": true,
+		"his": true, "how": true, "its": true, "may": true, "new": true, "now": true,
+		"see": true, "two": true, "who": true, "did": true, "get": true, "let": true,
+		"she": true, "too": true, "use": true, "that": true, "with": true, "have": true,
+		"this": true, "will": true, "your": true, "from": true, "they": true, "been": true,
+		"more": true, "when": true, "what": true, "which": true, "their": true, "there": true,
+		"than": true, "then": true, "them": true, "these": true, "some": true, "would": true,
+		"could": true, "should": true, "about": true, "into": true, "also": true, "only": true,
+		"other": true, "such": true, "were": true, "where": true, "while": true, "each": true,
+		"it's": true, "don't": true, "because": true, "does": true, "just": true, "like": true,
+	}
+)
+
+// SimilarPage is a page similar to some other page.
+type SimilarPage struct {
+	PageID string  `json:"pageId"`
+	Score  float64 `json:"score"`
+}
+
+type similarPageList []*SimilarPage
+
+func (a similarPageList) Len() int      { return len(a) }
+func (a similarPageList) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a similarPageList) Less(i, j int) bool {
+	if a[i].Score != a[j].Score {
+		return a[i].Score > a[j].Score
+	}
+	return a[i].PageID < a[j].PageID
+}
+
+// termWeight is one component of a TF-IDF vector.
+type termWeight struct {
+	term   string
+	weight float64
+}
+
+type termWeightList []*termWeight
+
+func (a termWeightList) Len() int      { return len(a) }
+func (a termWeightList) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a termWeightList) Less(i, j int) bool {
+	if a[i].weight != a[j].weight {
+		return a[i].weight > a[j].weight
+	}
+	return a[i].term < a[j].term
+}
+
+// tokenizeForSimilarity splits the text into lowercase terms, dropping stop words.
+func tokenizeForSimilarity(text string) []string {
+	text = similarityLinkRegexp.ReplaceAllString(strings.ToLower(text), "]")
+	terms := make([]string, 0)
+	for _, word := range similarityWordRegexp.FindAllString(text, -1) {
+		word = strings.Trim(word, "'")
+		if len(word) < minSimilarityTermLength || similarityStopWords[word] {
+			continue
+		}
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// ComputeSimilarPages computes the TF-IDF vector for each given document and
+// returns, for each document, up to topN most similar other documents with
+// cosine similarity at least minScore.
+// docs is a map: page id -> text of the page.
+func ComputeSimilarPages(docs map[string]string, topN int, minScore float64) map[string][]*SimilarPage {
+	// Go through pages in a deterministic order
+	pageIDs := make([]string, 0, len(docs))
+	for pageID := range docs {
+		pageIDs = append(pageIDs, pageID)
+	}
+	sort.Strings(pageIDs)
+
+	// Compute term frequencies and document frequencies
+	termCounts := make(map[string]map[string]int)
+	docFrequency := make(map[string]int)
+	for _, pageID := range pageIDs {
+		counts := make(map[string]int)
+		for _, term := range tokenizeForSimilarity(docs[pageID]) {
+			counts[term]++
+		}
+		for term := range counts {
+			docFrequency[term]++
+		}
+		termCounts[pageID] = counts
+	}
+
+	// Compute normalized TF-IDF vectors, keeping only the highest weighted terms
+	type posting struct {
+		pageID string
+		weight float64
+	}
+	docCount := float64(len(pageIDs))
+	postings := make(map[string][]*posting)
+	vectors := make(map[string]termWeightList)
+	for _, pageID := range pageIDs {
+		weights := make(termWeightList, 0)
+		for term, count := range termCounts[pageID] {
+			df := float64(docFrequency[term])
+			if df <= 1 || df > docCount*maxSimilarityTermDocFraction {
+				// Terms unique to one page can't make it similar to anything else
+				continue
+			}
+			weight := (1 + math.Log(float64(count))) * math.Log(docCount/df)
+			weights = append(weights, &termWeight{term: term, weight: weight})
+		}
+		sort.Sort(weights)
+		if len(weights) > maxSimilarityTermsPerDoc {
+			weights = weights[:maxSimilarityTermsPerDoc]
+		}
+		var norm float64
+		for _, w := range weights {
+			norm += w.weight * w.weight
+		}
+		norm = math.Sqrt(norm)
+		for _, w := range weights {
+			w.weight /= norm
+			postings[w.term] = append(postings[w.term], &posting{pageID: pageID, weight: w.weight})
+		}
+		vectors[pageID] = weights
+	}
+
+	// Compute cosine similarity with every page that shares a term
+	similarMap := make(map[string][]*SimilarPage)
+	for _, pageID := range pageIDs {
+		scores := make(map[string]float64)
+		for _, w := range vectors[pageID] {
+			for _, p := range postings[w.term] {
+				if p.pageID != pageID {
+					scores[p.pageID] += w.weight * p.weight
+				}
+			}
+		}
+		similarPages := make(similarPageList, 0)
+		for otherID, score := range scores {
+			if score >= minScore {
+				similarPages = append(similarPages, &SimilarPage{PageID: otherID, Score: score})
+			}
+		}
+		sort.Sort(similarPages)
+		if len(similarPages) > topN {
+			similarPages = similarPages[:topN]
+		}
+		similarMap[pageID] = similarPages
+	}
+	return similarMap
+}
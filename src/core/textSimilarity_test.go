@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+)
+
+// Make sure pages about the same topic are more similar than unrelated pages.
+func TestComputeSimilarPages(t *testing.T) {
+	docs := map[string]string{
+		"1": "Bayes theorem relates conditional probability of evidence and hypothesis.",
+		"2": "Using Bayes theorem we update the probability of a hypothesis given evidence.",
+		"3": "Sorting algorithms like quicksort and mergesort order a list of numbers.",
+		"4": "Quicksort is a sorting algorithm that picks a pivot to order numbers.",
+		"5": "Cooking pasta requires boiling water and salt.",
+	}
+	similarMap := ComputeSimilarPages(docs, 2, 0.01)
+
+	if len(similarMap["1"]) <= 0 || similarMap["1"][0].PageID != "2" {
+		t.Errorf("Expected page 2 to be most similar to page 1: %+v", similarMap["1"])
+	}
+	if len(similarMap["4"]) <= 0 || similarMap["4"][0].PageID != "3" {
+		t.Errorf("Expected page 3 to be most similar to page 4: %+v", similarMap["4"])
+	}
+	if len(similarMap["5"]) != 0 {
+		t.Errorf("Expected no similar pages for page 5: %+v", similarMap["5"])
+	}
+	for pageID, similarPages := range similarMap {
+		if len(similarPages) > 2 {
+			t.Errorf("Too many similar pages for %s", pageID)
+		}
+		for _, similarPage := range similarPages {
+			if similarPage.PageID == pageID {
+				t.Errorf("Page %s is similar to itself", pageID)
+			}
+		}
+	}
+}
+
+// Make sure stop words, short words and link urls are dropped.
+func TestTokenizeForSimilarity(t *testing.T) {
+	terms := tokenizeForSimilarity("The [Bayes rule](http://example.com/xyz) is OK")
+	expected := []string{"bayes", "rule"}
+	if len(terms) != len(expected) {
+		t.Fatalf("Unexpected terms: %v", terms)
+	}
+	for n, term := range terms {
+		if term != expected[n] {
+			t.Errorf("Unexpected terms: %v", terms)
+		}
+	}
+}
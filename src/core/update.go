@@ -22,6 +22,11 @@ const (
 	ChangeLogUpdateType              = "changeLog"
 	PageEditUpdateType               = "pageEdit"
 	EditProposalAcceptedUpdateType   = "editProposalAccepted"
+	EditProposalPartialUpdateType    = "editProposalPartiallyAccepted"
+	EditProposalCommentUpdateType    = "editProposalComment"
+	ChangesRequestedUpdateType       = "editProposalChangesRequested"
+	EditProposalRejectedUpdateType   = "editProposalRejected"
+	ScheduledPublishFailedUpdateType = "scheduledPublishFailed"
 	NewPageByUserUpdateType          = "newPageByUser"
 	PageToDomainSubmissionUpdateType = "pageToDomainSubmission"
 	PageToDomainAcceptedUpdateType   = "pageToDomainAccepted"
@@ -36,6 +41,7 @@ const (
 	AnsweredMarkUpdateType           = "answeredMark"
 	QuestionMergedUpdateType         = "questionMerged"
 	QuestionMergedReverseUpdateType  = "questionMergedReverse"
+	MasteryReviewUpdateType          = "masteryReview"
 )
 
 // UpdateRow is a row from updates table
@@ -299,6 +305,7 @@ func GetAchievementUpdateTypes() []string {
 		InviteReceivedUpdateType,
 		PageToDomainAcceptedUpdateType,
 		EditProposalAcceptedUpdateType,
+		EditProposalPartialUpdateType,
 		UserTrustUpdateType,
 	}
 }
@@ -311,6 +318,11 @@ func GetNotificationUpdateTypes() []string {
 		ResolvedThreadUpdateType,
 		ResolvedMarkUpdateType,
 		AnsweredMarkUpdateType,
+		EditProposalCommentUpdateType,
+		ChangesRequestedUpdateType,
+		EditProposalRejectedUpdateType,
+		ScheduledPublishFailedUpdateType,
+		MasteryReviewUpdateType,
 	}
 }
 
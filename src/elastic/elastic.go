@@ -43,7 +43,8 @@ type Document struct {
 
 // All the elasticsearch result structs
 type SearchResult struct {
-	Hits *Hits `json:"hits"`
+	Hits    *Hits                    `json:"hits"`
+	Suggest map[string][]*Suggestion `json:"suggest"`
 }
 
 type Hits struct {
@@ -58,6 +59,38 @@ type Hit struct {
 	Source *Document `json:"_source"`
 }
 
+// Suggestion is the result of a suggester for one part of the suggest text.
+type Suggestion struct {
+	Text    string              `json:"text"`
+	Offset  int                 `json:"offset"`
+	Length  int                 `json:"length"`
+	Options []*SuggestionOption `json:"options"`
+}
+
+type SuggestionOption struct {
+	Text  string  `json:"text"`
+	Score float32 `json:"score"`
+}
+
+// BestSuggestion returns the top option returned by the given suggester, or ""
+// if there are none. Options which are the same as the original text are ignored.
+func (result *SearchResult) BestSuggestion(name string) string {
+	best := ""
+	var bestScore float32
+	for _, suggestion := range result.Suggest[name] {
+		for _, option := range suggestion.Options {
+			if strings.EqualFold(option.Text, suggestion.Text) {
+				continue
+			}
+			if best == "" || option.Score > bestScore {
+				best = option.Text
+				bestScore = option.Score
+			}
+		}
+	}
+	return best
+}
+
 // EscapeMatchTerm escapes various characters in the given text so it's safe to
 // pass to elastic.
 func EscapeMatchTerm(text string) string {
@@ -150,10 +183,11 @@ type Mapping struct {
 }
 
 type Property struct {
-	Type      string `json:"type,omitempty"`
-	Index     string `json:"index,omitempty"`
-	Analyzer  string `json:"analyzer,omitempty"`
-	IndexName string `json:"index_name,omitempty"`
+	Type      string               `json:"type,omitempty"`
+	Index     string               `json:"index,omitempty"`
+	Analyzer  string               `json:"analyzer,omitempty"`
+	IndexName string               `json:"index_name,omitempty"`
+	Fields    map[string]*Property `json:"fields,omitempty"`
 }
 
 // CreatePageIndex creates the pages index.
@@ -164,7 +198,10 @@ func CreatePageIndex(c sessions.Context) error {
 	mapping.Properties = make(map[string]*Property)
 	mapping.Properties["pageId"] = &Property{Type: "string", Index: "not_analyzed"}
 	mapping.Properties["type"] = &Property{Type: "string", Index: "not_analyzed"}
-	mapping.Properties["title"] = &Property{Type: "string", Analyzer: "english"}
+	mapping.Properties["title"] = &Property{Type: "string", Analyzer: "english",
+		// Unstemmed version of the title, used for "did you mean" suggestions
+		Fields: map[string]*Property{"suggest": &Property{Type: "string", Analyzer: "simple"}},
+	}
 	mapping.Properties["clickbait"] = &Property{Type: "string", Analyzer: "english"}
 	mapping.Properties["text"] = &Property{Type: "string", Analyzer: "english"}
 	mapping.Properties["alias"] = &Property{Type: "string"}
@@ -202,6 +239,38 @@ func CreatePageIndex(c sessions.Context) error {
 	return nil
 }
 
+// HasPageIndexField returns true iff the mapping of the pages index has the
+// given field. Fields added to CreatePageIndex only exist after a reindex.
+func HasPageIndexField(c sessions.Context, field string) (bool, error) {
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s/_mapping/page/field/%s", ElasticDomain, field), nil)
+	if err != nil {
+		return false, fmt.Errorf("Couldn't create request: %v", err)
+	}
+	if sessions.Live {
+		request.SetBasicAuth(config.XC.Elastic.Live.User, config.XC.Elastic.Live.Password)
+	}
+
+	// Execute request
+	resp, err := sendRequest(c, request)
+	if err != nil {
+		return false, fmt.Errorf("Couldn't execute request: %v", err)
+	}
+
+	// The result is keyed by index name, then by type, then by field
+	var result map[string]struct {
+		Mappings map[string]map[string]interface{} `json:"mappings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("Couldn't decode json: %v", err)
+	}
+	for _, index := range result {
+		if _, ok := index.Mappings["page"][field]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // DeletePageIndex deletes the pages index.
 func DeletePageIndex(c sessions.Context) error {
 	request, err := http.NewRequest("DELETE", ElasticDomain, nil)
@@ -248,3 +317,57 @@ func SearchPageIndex(c sessions.Context, jsonStr string) (*SearchResult, error)
 	}
 	return &searchResult, nil
 }
+
+// Result structs for the multi-get request
+type multiGetResult struct {
+	Docs []*multiGetDoc `json:"docs"`
+}
+
+type multiGetDoc struct {
+	ID     string    `json:"_id"`
+	Found  bool      `json:"found"`
+	Source *Document `json:"_source"`
+}
+
+// LoadPagesFromIndex loads the documents for the given page ids from the pages
+// index. Pages that aren't in the index won't be in the returned map.
+func LoadPagesFromIndex(c sessions.Context, pageIDs []string) (map[string]*Document, error) {
+	docMap := make(map[string]*Document)
+	if len(pageIDs) <= 0 {
+		return docMap, nil
+	}
+
+	// Construct request body
+	jsonData, err := json.Marshal(map[string][]string{"ids": pageIDs})
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling data into json: %v", err)
+	}
+	request, err := http.NewRequest("POST", fmt.Sprintf("%s/page/_mget", ElasticDomain), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't create request: %v", err)
+	}
+	if sessions.Live {
+		request.SetBasicAuth(config.XC.Elastic.Live.User, config.XC.Elastic.Live.Password)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	// Execute request
+	resp, err := sendRequest(c, request)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't execute request: %v", err)
+	}
+
+	// Process results
+	decoder := json.NewDecoder(resp.Body)
+	var result multiGetResult
+	err = decoder.Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't decode json: %v", err)
+	}
+	for _, doc := range result.Docs {
+		if doc.Found && doc.Source != nil {
+			docMap[doc.ID] = doc.Source
+		}
+	}
+	return docMap, nil
+}
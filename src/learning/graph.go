@@ -0,0 +1,66 @@
+// graph.go contains the part of the requirement/subject graph the planner works with.
+package learning
+
+import (
+	"zanaduu3/src/core"
+)
+
+// Edge connects a page to a subject at the given mastery level.
+type Edge struct {
+	PageID string
+	Level  int
+}
+
+// Graph is an in-memory requirement/subject graph.
+type Graph struct {
+	// Subject id -> pages that teach it, with the level they teach it at
+	Tutors map[string][]*Edge
+	// Page id -> subjects the page requires, with the level they are required at
+	Requirements map[string][]*Edge
+	// Page id -> index of the page among the lenses of its parent page
+	LensIndexes map[string]int
+}
+
+// NewGraph returns an empty graph.
+func NewGraph() *Graph {
+	return &Graph{
+		Tutors:       make(map[string][]*Edge),
+		Requirements: make(map[string][]*Edge),
+		LensIndexes:  make(map[string]int),
+	}
+}
+
+// AddTutor records that the page teaches the subject at the given level.
+func (g *Graph) AddTutor(subjectID, pageID string, level int) {
+	g.Tutors[subjectID] = addEdge(g.Tutors[subjectID], pageID, level)
+}
+
+// AddRequirement records that the page requires the subject at the given level.
+func (g *Graph) AddRequirement(pageID, subjectID string, level int) {
+	g.Requirements[pageID] = addEdge(g.Requirements[pageID], subjectID, level)
+}
+
+// addEdge adds the edge to the list, keeping the higher level if it's already there.
+func addEdge(edges []*Edge, pageID string, level int) []*Edge {
+	level = normalizeLevel(level)
+	for _, edge := range edges {
+		if edge.PageID == pageID {
+			if level > edge.Level {
+				edge.Level = level
+			}
+			return edges
+		}
+	}
+	return append(edges, &Edge{PageID: pageID, Level: level})
+}
+
+// normalizeLevel treats an unset level as the lowest mastery level.
+func normalizeLevel(level int) int {
+	if level < core.LooseMasteryLevel {
+		return core.LooseMasteryLevel
+	}
+	if level > core.ResearchMasteryLevel {
+		return core.ResearchMasteryLevel
+	}
+	return level
+}
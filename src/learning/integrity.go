@@ -0,0 +1,229 @@
+// integrity.go finds problems in the requirement/subject graph.
+package learning
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"zanaduu3/src/core"
+)
+
+// FindIntegrityProblems returns the problems in the given graph:
+// cycles between subjects, requirements no page teaches, requirements that
+// point at deleted pages, and requirements already implied by other ones.
+// The graph should only contain edges of live pages; deletedIDs are the
+// deleted pages they might still require. Findings of each type are sorted by
+// page id, so the result doesn't depend on map order.
+func FindIntegrityProblems(graph *Graph, deletedIDs map[string]bool) []*core.RequisiteGraphCheckFinding {
+	findings := make([]*core.RequisiteGraphCheckFinding, 0)
+	pageIDs := sortedKeys(graph.Requirements)
+
+	// Requirements that point at deleted pages. We drop them from the graph
+	// for the other checks.
+	requirements := make(map[string][]*Edge)
+	for _, pageID := range pageIDs {
+		for _, edge := range sortedEdges(graph.Requirements[pageID]) {
+			if deletedIDs[edge.PageID] {
+				findings = append(findings, &core.RequisiteGraphCheckFinding{
+					Type:      core.DeletedRequisiteFinding,
+					PageID:    pageID,
+					AuxPageID: edge.PageID,
+					Details:   fmt.Sprintf("Requires deleted page %s", edge.PageID),
+				})
+				continue
+			}
+			requirements[pageID] = append(requirements[pageID], edge)
+		}
+	}
+
+	// Requirements no page teaches
+	requiredBy := make(map[string][]string)
+	requiredIDs := make([]string, 0)
+	for _, pageID := range pageIDs {
+		for _, edge := range requirements[pageID] {
+			if _, ok := requiredBy[edge.PageID]; !ok {
+				requiredIDs = append(requiredIDs, edge.PageID)
+			}
+			requiredBy[edge.PageID] = append(requiredBy[edge.PageID], pageID)
+		}
+	}
+	sort.Strings(requiredIDs)
+	for _, subjectID := range requiredIDs {
+		if len(graph.Tutors[subjectID]) > 0 {
+			continue
+		}
+		findings = append(findings, &core.RequisiteGraphCheckFinding{
+			Type:    core.OrphanRequisiteFinding,
+			PageID:  subjectID,
+			Details: fmt.Sprintf("No page teaches it, but it's required by %s", strings.Join(requiredBy[subjectID], ", ")),
+		})
+	}
+
+	// Cycles: subject A depends on subject B if a page that teaches A requires B
+	subjectEdges := make(map[string][]string)
+	for subjectID, tutors := range graph.Tutors {
+		for _, tutor := range tutors {
+			for _, edge := range requirements[tutor.PageID] {
+				if !core.IsStringInList(edge.PageID, subjectEdges[subjectID]) {
+					subjectEdges[subjectID] = append(subjectEdges[subjectID], edge.PageID)
+				}
+			}
+		}
+	}
+	onCycle := make(map[string]bool)
+	for _, component := range findStronglyConnectedComponents(subjectEdges) {
+		if len(component) == 1 && !core.IsStringInList(component[0], subjectEdges[component[0]]) {
+			continue
+		}
+		for _, subjectID := range component {
+			onCycle[subjectID] = true
+		}
+		findings = append(findings, &core.RequisiteGraphCheckFinding{
+			Type:    core.CycleRequisiteFinding,
+			PageID:  component[0],
+			Details: fmt.Sprintf("Subjects on the cycle: %s", strings.Join(component, ", ")),
+		})
+	}
+
+	// Redundant requirements: the page requires B, but it also requires A, and
+	// every page that teaches A requires B at least at the same level.
+	implied := make(map[string]map[string]int)
+	var computeImplied func(subjectID string) map[string]int
+	computeImplied = func(subjectID string) map[string]int {
+		if levels, ok := implied[subjectID]; ok {
+			return levels
+		}
+		// Subjects without tutors don't imply anything, and neither do
+		// subjects on a cycle, since we can't tell which way to follow it.
+		var levels map[string]int
+		if onCycle[subjectID] || len(graph.Tutors[subjectID]) <= 0 {
+			levels = make(map[string]int)
+		}
+		for _, tutor := range graph.Tutors[subjectID] {
+			if levels != nil && len(levels) <= 0 {
+				break
+			}
+			tutorLevels := make(map[string]int)
+			for _, edge := range requirements[tutor.PageID] {
+				addImpliedLevel(tutorLevels, edge.PageID, edge.Level)
+				for impliedID, level := range computeImplied(edge.PageID) {
+					addImpliedLevel(tutorLevels, impliedID, level)
+				}
+			}
+			if levels == nil {
+				levels = tutorLevels
+				continue
+			}
+			for impliedID, level := range levels {
+				if tutorLevel, ok := tutorLevels[impliedID]; !ok {
+					delete(levels, impliedID)
+				} else if tutorLevel < level {
+					levels[impliedID] = tutorLevel
+				}
+			}
+		}
+		implied[subjectID] = levels
+		return levels
+	}
+	for _, pageID := range pageIDs {
+		edges := requirements[pageID]
+		for _, edge := range edges {
+			for _, otherEdge := range edges {
+				if otherEdge.PageID == edge.PageID {
+					continue
+				}
+				if level, ok := computeImplied(otherEdge.PageID)[edge.PageID]; ok && level >= edge.Level {
+					findings = append(findings, &core.RequisiteGraphCheckFinding{
+						Type:      core.RedundantRequisiteFinding,
+						PageID:    pageID,
+						AuxPageID: edge.PageID,
+						Details:   fmt.Sprintf("Already required through %s", otherEdge.PageID),
+					})
+					break
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// addImpliedLevel records that the subject is needed at the given level,
+// keeping the higher level if it's already there.
+func addImpliedLevel(levels map[string]int, subjectID string, level int) {
+	if oldLevel, ok := levels[subjectID]; !ok || level > oldLevel {
+		levels[subjectID] = level
+	}
+}
+
+// findStronglyConnectedComponents returns the strongly connected components of
+// the given graph, using Tarjan's algorithm. Each component is sorted by id,
+// and the components are sorted by their first id.
+func findStronglyConnectedComponents(edges map[string][]string) [][]string {
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	stack := make([]string, 0)
+	components := make([][]string, 0)
+
+	var visit func(nodeID string)
+	visit = func(nodeID string) {
+		index[nodeID] = len(index)
+		lowLink[nodeID] = index[nodeID]
+		stack = append(stack, nodeID)
+		onStack[nodeID] = true
+		for _, nextID := range edges[nodeID] {
+			if _, ok := index[nextID]; !ok {
+				visit(nextID)
+				if lowLink[nextID] < lowLink[nodeID] {
+					lowLink[nodeID] = lowLink[nextID]
+				}
+			} else if onStack[nextID] && index[nextID] < lowLink[nodeID] {
+				lowLink[nodeID] = index[nextID]
+			}
+		}
+		if lowLink[nodeID] != index[nodeID] {
+			return
+		}
+		component := make([]string, 0)
+		for {
+			topID := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[topID] = false
+			component = append(component, topID)
+			if topID == nodeID {
+				break
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	nodeIDs := make([]string, 0)
+	for nodeID := range edges {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+	for _, nodeID := range nodeIDs {
+		if _, ok := index[nodeID]; !ok {
+			visit(nodeID)
+		}
+	}
+	sort.Sort(componentList(components))
+	return components
+}
+
+type componentList [][]string
+
+func (a componentList) Len() int           { return len(a) }
+func (a componentList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a componentList) Less(i, j int) bool { return a[i][0] < a[j][0] }
+
+// sortedKeys returns the keys of the given map in sorted order.
+func sortedKeys(edgeMap map[string][]*Edge) []string {
+	keys := make([]string, 0)
+	for key := range edgeMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
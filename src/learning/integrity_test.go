@@ -0,0 +1,95 @@
+package learning
+
+import (
+	"reflect"
+	"testing"
+
+	"zanaduu3/src/core"
+)
+
+// findingsOfType returns "pageId>auxPageId" for each finding of the given type.
+func findingsOfType(findings []*core.RequisiteGraphCheckFinding, findingType string) []string {
+	result := make([]string, 0)
+	for _, finding := range findings {
+		if finding.Type == findingType {
+			result = append(result, finding.PageID+">"+finding.AuxPageID)
+		}
+	}
+	return result
+}
+
+// Make sure a healthy graph has no problems.
+func TestIntegrityNoProblems(t *testing.T) {
+	graph := newTestGraph(
+		map[string][]string{"1": {"2"}, "3": {"4"}},
+		map[string][]string{"2": {"3"}})
+	findings := FindIntegrityProblems(graph, nil)
+	if len(findings) != 0 {
+		t.Errorf("Unexpected findings: %d", len(findings))
+	}
+}
+
+// Make sure we find requirements that nothing teaches, or that were deleted.
+func TestIntegrityOrphanAndDeleted(t *testing.T) {
+	graph := newTestGraph(
+		map[string][]string{"1": {"2"}},
+		map[string][]string{"2": {"3", "5"}, "4": {"3"}})
+	findings := FindIntegrityProblems(graph, map[string]bool{"5": true})
+	if orphans := findingsOfType(findings, core.OrphanRequisiteFinding); !reflect.DeepEqual(orphans, []string{"3>"}) {
+		t.Errorf("Unexpected orphans: %v", orphans)
+	}
+	if deleted := findingsOfType(findings, core.DeletedRequisiteFinding); !reflect.DeepEqual(deleted, []string{"2>5"}) {
+		t.Errorf("Unexpected deleted requirements: %v", deleted)
+	}
+}
+
+// Make sure we find cycles, including a page that requires what it teaches.
+func TestIntegrityCycles(t *testing.T) {
+	graph := newTestGraph(
+		map[string][]string{"1": {"2"}, "3": {"4"}, "5": {"6"}, "7": {"8"}},
+		map[string][]string{"2": {"3"}, "4": {"5"}, "6": {"3"}, "8": {"7"}})
+	findings := FindIntegrityProblems(graph, nil)
+	if cycles := findingsOfType(findings, core.CycleRequisiteFinding); !reflect.DeepEqual(cycles, []string{"3>", "7>"}) {
+		t.Fatalf("Unexpected cycles: %v", cycles)
+	}
+	for _, finding := range findings {
+		if finding.PageID == "3" && finding.Details != "Subjects on the cycle: 3, 5" {
+			t.Errorf("Unexpected details: %s", finding.Details)
+		}
+	}
+}
+
+// Make sure a requirement is redundant only if every way to learn another
+// requirement already needs it at the same level.
+func TestIntegrityRedundant(t *testing.T) {
+	graph := NewGraph()
+	// Page 10 requires 1 and 2, and the only tutor of 1 requires 2
+	graph.AddRequirement("10", "1", core.LooseMasteryLevel)
+	graph.AddRequirement("10", "2", core.LooseMasteryLevel)
+	graph.AddTutor("1", "11", core.LooseMasteryLevel)
+	graph.AddRequirement("11", "2", core.BasicMasteryLevel)
+	graph.AddTutor("2", "12", core.BasicMasteryLevel)
+	// Page 20 requires 3 and 2, but only one of the tutors of 3 requires 2
+	graph.AddRequirement("20", "3", core.LooseMasteryLevel)
+	graph.AddRequirement("20", "2", core.LooseMasteryLevel)
+	graph.AddTutor("3", "21", core.LooseMasteryLevel)
+	graph.AddTutor("3", "22", core.LooseMasteryLevel)
+	graph.AddRequirement("21", "2", core.LooseMasteryLevel)
+	// Page 30 requires 1 and 2, but 2 at a higher level than 1 needs it
+	graph.AddRequirement("30", "1", core.LooseMasteryLevel)
+	graph.AddRequirement("30", "2", core.TechnicalMasteryLevel)
+	// Page 40 requires 4 and 2, and 4 needs 2 through 1
+	graph.AddRequirement("40", "4", core.LooseMasteryLevel)
+	graph.AddRequirement("40", "2", core.LooseMasteryLevel)
+	graph.AddTutor("4", "41", core.LooseMasteryLevel)
+	graph.AddRequirement("41", "1", core.LooseMasteryLevel)
+
+	findings := FindIntegrityProblems(graph, nil)
+	redundant := findingsOfType(findings, core.RedundantRequisiteFinding)
+	if !reflect.DeepEqual(redundant, []string{"10>2", "40>2"}) {
+		t.Errorf("Unexpected redundant requirements: %v", redundant)
+	}
+	if len(findings) != len(redundant) {
+		t.Errorf("Unexpected findings: %d, expected %d", len(findings), len(redundant))
+	}
+}
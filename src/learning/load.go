@@ -0,0 +1,120 @@
+// load.go loads the planner's input from the database.
+package learning
+
+import (
+	"fmt"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+)
+
+const (
+	// Stop exploring the graph once it has this many subjects
+	MaxGraphSubjects = 2000
+)
+
+// LoadMasteryLevels returns the level at which the user knows each of the
+// subjects they have: subject id -> level.
+func LoadMasteryLevels(db *database.DB, userID string) (map[string]int, error) {
+	masteryLevels := make(map[string]int)
+	if userID == "" {
+		return masteryLevels, nil
+	}
+	rows := database.NewQuery(`
+		SELECT masteryId,level
+		FROM userMasteryPairs
+		WHERE userId=? AND has`, userID).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var masteryID string
+		var level int
+		if err := rows.Scan(&masteryID, &level); err != nil {
+			return fmt.Errorf("Failed to scan: %v", err)
+		}
+		masteryLevels[masteryID] = normalizeLevel(level)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load masteries: %v", err)
+	}
+	return masteryLevels, nil
+}
+
+// LoadGraph loads the part of the requirement/subject graph reachable from the
+// given subjects. Requirements the user already knows well enough aren't
+// explored.
+func LoadGraph(db *database.DB, u *core.CurrentUser, subjectIDs []string, masteryLevels map[string]int) (*Graph, error) {
+	graph := NewGraph()
+	seenSubjects := make(map[string]bool)
+	seenTutors := make(map[string]bool)
+	for _, subjectID := range subjectIDs {
+		seenSubjects[subjectID] = true
+	}
+
+	for len(subjectIDs) > 0 {
+		if len(seenSubjects) > MaxGraphSubjects {
+			db.C.Warningf("Learning graph is too large, stopping at %d subjects", len(seenSubjects))
+			break
+		}
+
+		// Load which pages teach the subjects
+		tutorIDs := make([]string, 0)
+		rows := database.NewQuery(`
+			SELECT pp.parentId,pp.childId,pp.level,IFNULL(l.lensIndex,0)
+			FROM pagePairs AS pp
+			JOIN pageInfos AS pi
+			ON (pp.childId=pi.pageId)
+			LEFT JOIN lenses AS l
+			ON (pi.pageId=l.lensId)
+			WHERE pp.parentId IN`).AddArgsGroupStr(subjectIDs).Add(`
+				AND pp.type=?`, core.SubjectPagePairType).Add(`
+				AND`).AddPart(core.PageInfosFilter(u)).ToStatement(db).Query()
+		err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+			var subjectID, tutorID string
+			var level, lensIndex int
+			if err := rows.Scan(&subjectID, &tutorID, &level, &lensIndex); err != nil {
+				return fmt.Errorf("Failed to scan: %v", err)
+			}
+			graph.AddTutor(subjectID, tutorID, level)
+			graph.LensIndexes[tutorID] = lensIndex
+			if !seenTutors[tutorID] {
+				seenTutors[tutorID] = true
+				tutorIDs = append(tutorIDs, tutorID)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error while loading tutors: %v", err)
+		}
+		if len(tutorIDs) <= 0 {
+			break
+		}
+
+		// Load the requirements of the tutors
+		subjectIDs = make([]string, 0)
+		rows = database.NewQuery(`
+			SELECT pp.childId,pp.parentId,pp.level
+			FROM pagePairs AS pp
+			JOIN pageInfos AS pi
+			ON (pp.parentId=pi.pageId)
+			WHERE pp.childId IN`).AddArgsGroupStr(tutorIDs).Add(`
+				AND pp.type=?`, core.RequirementPagePairType).Add(`
+				AND`).AddPart(core.PageInfosFilter(u)).ToStatement(db).Query()
+		err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+			var tutorID, subjectID string
+			var level int
+			if err := rows.Scan(&tutorID, &subjectID, &level); err != nil {
+				return fmt.Errorf("Failed to scan: %v", err)
+			}
+			graph.AddRequirement(tutorID, subjectID, level)
+			if masteryLevels[subjectID] < normalizeLevel(level) && !seenSubjects[subjectID] {
+				seenSubjects[subjectID] = true
+				subjectIDs = append(subjectIDs, subjectID)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error while loading requirements: %v", err)
+		}
+	}
+	return graph, nil
+}
@@ -0,0 +1,449 @@
+// planner.go computes the order in which a user should read pages to learn
+// the subjects they want to learn.
+package learning
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// Cost of learning a subject no page teaches
+	PenaltyCost = 10000
+	// Extra cost of reading a page for each lens that comes before it
+	LensCost = 10
+	// Extra cost for each level by which a page falls short of the level at which
+	// the subject is needed
+	LevelShortfallCost = 100
+)
+
+// Target is a subject the user wants to learn, and the level they want to learn it at.
+type Target struct {
+	PageID string
+	Level  int
+}
+
+// RequirementNode is a subject the user needs to learn.
+type RequirementNode struct {
+	PageID string `json:"pageId"`
+	// Highest level at which the subject is needed
+	Level int `json:"level"`
+	// Which pages can teach this subject
+	TutorIDs []string `json:"tutorIds"`
+	// Best tutor
+	BestTutorID string `json:"bestTutorId"`
+	// Cost assigned to learning this subject
+	Cost int `json:"cost"`
+}
+
+// TutorNode is a page that teaches one or more of the subjects.
+type TutorNode struct {
+	PageID    string `json:"pageId"`
+	LensIndex int    `json:"-"`
+	// To read this page, the user needs these subjects, cheapest first
+	RequirementIDs []string `json:"requirementIds"`
+	// Cost assigned to reading this page
+	Cost int `json:"cost"`
+	// Set when a subject doesn't have a tutor, so we pretend it teaches itself.
+	MadeUp bool `json:"madeUp"`
+}
+
+// Reason explains why a page is in the plan.
+type Reason struct {
+	// The page is read to learn this subject at this level
+	SubjectID string `json:"subjectId"`
+	Level     int    `json:"level"`
+	// Page that requires the subject. Empty if the subject is one of the targets.
+	NeededByID string `json:"neededById"`
+	// Level at which the page teaches the subject. Lower than Level if no page
+	// teaches the subject well enough.
+	TaughtLevel int `json:"taughtLevel"`
+	// Set if we couldn't find a page that teaches the subject
+	MadeUp bool `json:"madeUp"`
+}
+
+// String returns a human readable explanation.
+func (r *Reason) String() string {
+	var explanation string
+	if r.MadeUp {
+		explanation = fmt.Sprintf("no page teaches %s, so it has to be read on its own", r.SubjectID)
+	} else {
+		explanation = fmt.Sprintf("teaches %s at level %d", r.SubjectID, r.TaughtLevel)
+		if r.TaughtLevel < r.Level {
+			explanation += fmt.Sprintf(" (level %d is needed)", r.Level)
+		}
+	}
+	if r.NeededByID == "" {
+		return explanation + ", which is one of the targets"
+	}
+	return explanation + fmt.Sprintf(", which is required by %s", r.NeededByID)
+}
+
+// Step is one page in the plan.
+type Step struct {
+	PageID  string    `json:"pageId"`
+	Reasons []*Reason `json:"reasons"`
+}
+
+// Plan is the result of planning.
+type Plan struct {
+	// Pages to read, in order
+	Steps []*Step `json:"steps"`
+	// Subject id -> node for all the subjects we considered
+	RequirementMap map[string]*RequirementNode `json:"requirementMap"`
+	// Page id -> node for all the pages we considered
+	TutorMap map[string]*TutorNode `json:"tutorMap"`
+}
+
+// PageIDs returns the ids of the pages to read, in order.
+func (plan *Plan) PageIDs() []string {
+	pageIDs := make([]string, 0, len(plan.Steps))
+	for _, step := range plan.Steps {
+		pageIDs = append(pageIDs, step.PageID)
+	}
+	return pageIDs
+}
+
+// planner holds the state used while computing a plan.
+type planner struct {
+	graph         *Graph
+	masteryLevels map[string]int
+	plan          *Plan
+
+	// Requirement ids, sorted
+	requirementIDs []string
+	// Tutor id -> subjects it teaches, with the level it teaches them at
+	teaches map[string][]*Edge
+	// Subject id -> ids of the tutors that require it
+	requiredBy map[string][]string
+	// Tutor id -> number of its requirements whose cost isn't known yet
+	pending map[string]int
+	// Subject id -> cheapest known cost of learning it
+	tentativeCosts map[string]int
+	// Subject ids whose cost is known
+	finalSubjects map[string]bool
+	// Subject ids we couldn't find a way to learn
+	madeUpSubjects map[string]bool
+}
+
+// NewPlan computes the cheapest way to learn the targets, given the levels at
+// which the user already knows some subjects (subject id -> level).
+//
+// The cost of a page is 1 plus its lens cost plus the cost of learning all of
+// its requirements; the cost of a subject is the cost of its cheapest tutor.
+// Costs are computed in increasing order, so the result doesn't depend on map
+// iteration order. When the remaining subjects all depend on each other, the
+// cycle is broken at the subject on it with the smallest id.
+func NewPlan(graph *Graph, targets []*Target, masteryLevels map[string]int) *Plan {
+	p := &planner{
+		graph:         graph,
+		masteryLevels: masteryLevels,
+		plan: &Plan{
+			Steps:          make([]*Step, 0),
+			RequirementMap: make(map[string]*RequirementNode),
+			TutorMap:       make(map[string]*TutorNode),
+		},
+		teaches:        make(map[string][]*Edge),
+		requiredBy:     make(map[string][]string),
+		pending:        make(map[string]int),
+		tentativeCosts: make(map[string]int),
+		finalSubjects:  make(map[string]bool),
+		madeUpSubjects: make(map[string]bool),
+	}
+	p.collectNodes(targets)
+	p.computeCosts()
+	p.buildSteps(targets)
+	return p.plan
+}
+
+// hasMastery returns true if the user already knows the subject at the given level.
+func (p *planner) hasMastery(subjectID string, level int) bool {
+	return p.masteryLevels[subjectID] >= normalizeLevel(level)
+}
+
+// collectNodes finds all the subjects the user might need to learn, and all
+// the pages that teach them.
+func (p *planner) collectNodes(targets []*Target) {
+	queue := make([]string, 0)
+	addRequirement := func(subjectID string, level int) {
+		node, ok := p.plan.RequirementMap[subjectID]
+		if !ok {
+			node = &RequirementNode{PageID: subjectID, TutorIDs: make([]string, 0)}
+			p.plan.RequirementMap[subjectID] = node
+			queue = append(queue, subjectID)
+		}
+		if level > node.Level {
+			node.Level = level
+		}
+	}
+	for _, target := range targets {
+		if !p.hasMastery(target.PageID, target.Level) {
+			addRequirement(target.PageID, normalizeLevel(target.Level))
+		}
+	}
+
+	for len(queue) > 0 {
+		subjectID := queue[0]
+		queue = queue[1:]
+		node := p.plan.RequirementMap[subjectID]
+		for _, tutorEdge := range sortedEdges(p.graph.Tutors[subjectID]) {
+			tutorID := tutorEdge.PageID
+			node.TutorIDs = append(node.TutorIDs, tutorID)
+			p.teaches[tutorID] = append(p.teaches[tutorID], &Edge{PageID: subjectID, Level: tutorEdge.Level})
+			if _, ok := p.plan.TutorMap[tutorID]; ok {
+				continue
+			}
+			tutor := &TutorNode{
+				PageID:         tutorID,
+				LensIndex:      p.graph.LensIndexes[tutorID],
+				RequirementIDs: make([]string, 0),
+			}
+			p.plan.TutorMap[tutorID] = tutor
+			for _, reqEdge := range sortedEdges(p.graph.Requirements[tutorID]) {
+				if p.hasMastery(reqEdge.PageID, reqEdge.Level) {
+					continue
+				}
+				tutor.RequirementIDs = append(tutor.RequirementIDs, reqEdge.PageID)
+				p.requiredBy[reqEdge.PageID] = append(p.requiredBy[reqEdge.PageID], tutorID)
+				addRequirement(reqEdge.PageID, reqEdge.Level)
+			}
+		}
+	}
+
+	for subjectID := range p.plan.RequirementMap {
+		p.requirementIDs = append(p.requirementIDs, subjectID)
+	}
+	sort.Strings(p.requirementIDs)
+}
+
+// computeCosts computes the cost and the best tutor of every subject.
+func (p *planner) computeCosts() {
+	tutorIDs := make([]string, 0, len(p.plan.TutorMap))
+	for tutorID, tutor := range p.plan.TutorMap {
+		tutorIDs = append(tutorIDs, tutorID)
+		p.pending[tutorID] = len(tutor.RequirementIDs)
+	}
+	sort.Strings(tutorIDs)
+	for _, tutorID := range tutorIDs {
+		if p.pending[tutorID] <= 0 {
+			p.finalizeTutor(tutorID)
+		}
+	}
+	for _, subjectID := range p.requirementIDs {
+		if len(p.plan.RequirementMap[subjectID].TutorIDs) <= 0 {
+			p.tentativeCosts[subjectID] = PenaltyCost
+		}
+	}
+
+	for {
+		// Pick the cheapest subject whose cost isn't final yet
+		bestID := ""
+		bestCost := 0
+		for _, subjectID := range p.requirementIDs {
+			cost, ok := p.tentativeCosts[subjectID]
+			if p.finalSubjects[subjectID] || !ok {
+				continue
+			}
+			if bestID == "" || cost < bestCost {
+				bestID, bestCost = subjectID, cost
+			}
+		}
+		if bestID == "" {
+			// The subjects left depend on each other. Break a cycle by pretending
+			// the first subject on it teaches itself.
+			for _, subjectID := range p.requirementIDs {
+				if !p.finalSubjects[subjectID] && p.isOnCycle(subjectID) {
+					bestID = subjectID
+					break
+				}
+			}
+			if bestID == "" {
+				break
+			}
+			p.tentativeCosts[bestID] = PenaltyCost
+		}
+		p.finalizeSubject(bestID)
+	}
+}
+
+// isOnCycle returns true if learning the subject requires learning the subject,
+// only counting subjects and tutors whose costs aren't known yet.
+func (p *planner) isOnCycle(subjectID string) bool {
+	visited := make(map[string]bool)
+	queue := []string{subjectID}
+	for len(queue) > 0 {
+		currentID := queue[0]
+		queue = queue[1:]
+		for _, tutorID := range p.plan.RequirementMap[currentID].TutorIDs {
+			if p.pending[tutorID] <= 0 {
+				continue
+			}
+			for _, reqID := range p.plan.TutorMap[tutorID].RequirementIDs {
+				if reqID == subjectID {
+					return true
+				}
+				if !p.finalSubjects[reqID] && !visited[reqID] {
+					visited[reqID] = true
+					queue = append(queue, reqID)
+				}
+			}
+		}
+	}
+	return false
+}
+
+// finalizeSubject fixes the subject's cost, and computes the cost of the tutors
+// that were only waiting for it.
+func (p *planner) finalizeSubject(subjectID string) {
+	node := p.plan.RequirementMap[subjectID]
+	node.Cost = p.tentativeCosts[subjectID]
+	p.finalSubjects[subjectID] = true
+	if node.BestTutorID == "" {
+		// Nothing teaches it, so the subject will have to teach itself
+		p.madeUpSubjects[subjectID] = true
+		node.BestTutorID = subjectID
+		if _, ok := p.plan.TutorMap[subjectID]; !ok {
+			p.plan.TutorMap[subjectID] = &TutorNode{
+				PageID:         subjectID,
+				RequirementIDs: make([]string, 0),
+				Cost:           PenaltyCost,
+				MadeUp:         true,
+			}
+		}
+	}
+
+	for _, tutorID := range p.requiredBy[subjectID] {
+		p.pending[tutorID]--
+		if p.pending[tutorID] == 0 {
+			p.finalizeTutor(tutorID)
+		}
+	}
+}
+
+// finalizeTutor computes the cost of the tutor, whose requirements all have
+// their final cost, and updates the costs of the subjects it teaches.
+func (p *planner) finalizeTutor(tutorID string) {
+	tutor := p.plan.TutorMap[tutorID]
+	tutor.Cost = 1 + tutor.LensIndex*LensCost
+	for _, reqID := range tutor.RequirementIDs {
+		tutor.Cost += p.plan.RequirementMap[reqID].Cost
+	}
+	sort.Sort(&requirementIDList{ids: tutor.RequirementIDs, requirementMap: p.plan.RequirementMap})
+
+	for _, edge := range p.teaches[tutorID] {
+		if p.finalSubjects[edge.PageID] {
+			continue
+		}
+		node := p.plan.RequirementMap[edge.PageID]
+		cost := tutor.Cost
+		if edge.Level < node.Level {
+			cost += (node.Level - edge.Level) * LevelShortfallCost
+		}
+		current, ok := p.tentativeCosts[edge.PageID]
+		if !ok || cost < current || (cost == current && tutorID < node.BestTutorID) {
+			p.tentativeCosts[edge.PageID] = cost
+			node.BestTutorID = tutorID
+		}
+	}
+}
+
+// buildSteps orders the pages, so that each page comes after the pages that
+// teach its requirements.
+func (p *planner) buildSteps(targets []*Target) {
+	// Subject id -> level at which the pages so far teach it
+	learnedLevels := make(map[string]int)
+	stepMap := make(map[string]*Step)
+	// Tutors we are currently processing, to avoid following a cycle forever
+	inProgress := make(map[string]bool)
+
+	var visit func(subjectID string, level int, neededByID string)
+	visit = func(subjectID string, level int, neededByID string) {
+		if p.hasMastery(subjectID, level) || learnedLevels[subjectID] >= level {
+			return
+		}
+		node, ok := p.plan.RequirementMap[subjectID]
+		if !ok || inProgress[node.BestTutorID] {
+			return
+		}
+		tutorID := node.BestTutorID
+		tutor := p.plan.TutorMap[tutorID]
+
+		inProgress[tutorID] = true
+		for _, reqID := range tutor.RequirementIDs {
+			visit(reqID, p.requirementLevel(tutorID, reqID), tutorID)
+		}
+		inProgress[tutorID] = false
+
+		step, ok := stepMap[tutorID]
+		if !ok {
+			step = &Step{PageID: tutorID, Reasons: make([]*Reason, 0)}
+			stepMap[tutorID] = step
+			p.plan.Steps = append(p.plan.Steps, step)
+		}
+		reason := &Reason{
+			SubjectID:   subjectID,
+			Level:       level,
+			NeededByID:  neededByID,
+			TaughtLevel: level,
+			MadeUp:      p.madeUpSubjects[subjectID],
+		}
+		for _, edge := range p.teaches[tutorID] {
+			if edge.PageID == subjectID && !reason.MadeUp {
+				reason.TaughtLevel = edge.Level
+			}
+			if edge.Level > learnedLevels[edge.PageID] {
+				learnedLevels[edge.PageID] = edge.Level
+			}
+		}
+		step.Reasons = append(step.Reasons, reason)
+		// Even if the page falls short, there is nothing better to read
+		if level > learnedLevels[subjectID] {
+			learnedLevels[subjectID] = level
+		}
+	}
+
+	for _, target := range targets {
+		visit(target.PageID, normalizeLevel(target.Level), "")
+	}
+}
+
+// requirementLevel returns the level at which the page requires the subject.
+func (p *planner) requirementLevel(pageID, subjectID string) int {
+	for _, edge := range p.graph.Requirements[pageID] {
+		if edge.PageID == subjectID {
+			return edge.Level
+		}
+	}
+	return normalizeLevel(0)
+}
+
+// edgeList implements sort.Interface, ordering edges by page id.
+type edgeList []*Edge
+
+func (l edgeList) Len() int           { return len(l) }
+func (l edgeList) Less(i, j int) bool { return l[i].PageID < l[j].PageID }
+func (l edgeList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// sortedEdges returns a sorted copy of the edges.
+func sortedEdges(edges []*Edge) []*Edge {
+	sorted := make(edgeList, len(edges))
+	copy(sorted, edges)
+	sort.Sort(sorted)
+	return sorted
+}
+
+// requirementIDList implements sort.Interface, ordering requirements by cost and then by id.
+type requirementIDList struct {
+	ids            []string
+	requirementMap map[string]*RequirementNode
+}
+
+func (l *requirementIDList) Len() int      { return len(l.ids) }
+func (l *requirementIDList) Swap(i, j int) { l.ids[i], l.ids[j] = l.ids[j], l.ids[i] }
+func (l *requirementIDList) Less(i, j int) bool {
+	costI, costJ := l.requirementMap[l.ids[i]].Cost, l.requirementMap[l.ids[j]].Cost
+	if costI != costJ {
+		return costI < costJ
+	}
+	return l.ids[i] < l.ids[j]
+}
@@ -0,0 +1,214 @@
+package learning
+
+import (
+	"reflect"
+	"testing"
+
+	"zanaduu3/src/core"
+)
+
+// newTestGraph creates a graph from subject -> tutors and page -> requirements
+// maps, with every edge at the lowest level.
+func newTestGraph(tutors map[string][]string, requirements map[string][]string) *Graph {
+	graph := NewGraph()
+	for subjectID, tutorIDs := range tutors {
+		for _, tutorID := range tutorIDs {
+			graph.AddTutor(subjectID, tutorID, core.LooseMasteryLevel)
+		}
+	}
+	for pageID, subjectIDs := range requirements {
+		for _, subjectID := range subjectIDs {
+			graph.AddRequirement(pageID, subjectID, core.LooseMasteryLevel)
+		}
+	}
+	return graph
+}
+
+func targetsFor(pageIDs ...string) []*Target {
+	targets := make([]*Target, 0)
+	for _, pageID := range pageIDs {
+		targets = append(targets, &Target{PageID: pageID})
+	}
+	return targets
+}
+
+// Make sure we can match a tutor with a requirement
+func TestOneTutor(t *testing.T) {
+	graph := newTestGraph(map[string][]string{"1": {"2"}}, nil)
+	plan := NewPlan(graph, targetsFor("1"), nil)
+	if plan.RequirementMap["1"].BestTutorID != "2" {
+		t.Errorf("Invalid best tutor: %v, expected 2", plan.RequirementMap["1"].BestTutorID)
+	}
+	if !reflect.DeepEqual(plan.PageIDs(), []string{"2"}) {
+		t.Errorf("Unexpected plan: %v", plan.PageIDs())
+	}
+}
+
+// Make sure we pick the best tutor, given that some of them have unteachable requirements.
+func TestTeachableReqs(t *testing.T) {
+	graph := newTestGraph(
+		map[string][]string{"1": {"3", "4", "5"}},
+		map[string][]string{"3": {"2"}, "5": {"2"}})
+	plan := NewPlan(graph, targetsFor("1"), nil)
+	if plan.RequirementMap["1"].BestTutorID != "4" {
+		t.Errorf("Invalid best tutor: %v, expected 4", plan.RequirementMap["1"].BestTutorID)
+	}
+}
+
+// Make sure we pick the best tutor, given that some of them have more requirements.
+func TestTutorWithLeastReqs(t *testing.T) {
+	graph := newTestGraph(
+		map[string][]string{"1": {"4", "5", "6"}, "2": {"7"}, "3": {"8"}},
+		map[string][]string{"4": {"2", "3"}, "5": {"2"}, "6": {"2", "3"}})
+	plan := NewPlan(graph, targetsFor("1"), nil)
+	if plan.RequirementMap["1"].BestTutorID != "5" {
+		t.Errorf("Invalid best tutor: %v, expected 5", plan.RequirementMap["1"].BestTutorID)
+	}
+	if !reflect.DeepEqual(plan.PageIDs(), []string{"7", "5"}) {
+		t.Errorf("Unexpected plan: %v", plan.PageIDs())
+	}
+
+	// Each page says why it's there
+	reason := plan.Steps[0].Reasons[0]
+	if reason.SubjectID != "2" || reason.NeededByID != "5" || reason.MadeUp {
+		t.Errorf("Unexpected reason: %+v", reason)
+	}
+	if reason.String() != "teaches 2 at level 1, which is required by 5" {
+		t.Errorf("Unexpected explanation: %s", reason.String())
+	}
+	if plan.Steps[1].Reasons[0].NeededByID != "" {
+		t.Errorf("Target should not be needed by another page: %+v", plan.Steps[1].Reasons[0])
+	}
+}
+
+// Make sure requirements without a tutor are read on their own.
+func TestUnteachableRequirement(t *testing.T) {
+	graph := newTestGraph(map[string][]string{"1": {"2"}}, map[string][]string{"2": {"3"}})
+	plan := NewPlan(graph, targetsFor("1"), nil)
+	if !reflect.DeepEqual(plan.PageIDs(), []string{"3", "2"}) {
+		t.Fatalf("Unexpected plan: %v", plan.PageIDs())
+	}
+	if !plan.TutorMap["3"].MadeUp || !plan.Steps[0].Reasons[0].MadeUp {
+		t.Errorf("Requirement 3 should be made up")
+	}
+	if plan.RequirementMap["1"].Cost != PenaltyCost+1 {
+		t.Errorf("Unexpected cost: %d", plan.RequirementMap["1"].Cost)
+	}
+}
+
+// Make sure the user's masteries are taken into account at the right level.
+func TestMasteryLevels(t *testing.T) {
+	graph := NewGraph()
+	graph.AddTutor("1", "2", core.TechnicalMasteryLevel)
+	graph.AddRequirement("2", "3", core.BasicMasteryLevel)
+	graph.AddTutor("3", "4", core.BasicMasteryLevel)
+
+	targets := []*Target{{PageID: "1", Level: core.TechnicalMasteryLevel}}
+	plan := NewPlan(graph, targets, map[string]int{"3": core.LooseMasteryLevel})
+	if !reflect.DeepEqual(plan.PageIDs(), []string{"4", "2"}) {
+		t.Errorf("Loose mastery shouldn't be enough: %v", plan.PageIDs())
+	}
+
+	plan = NewPlan(graph, targets, map[string]int{"3": core.BasicMasteryLevel})
+	if !reflect.DeepEqual(plan.PageIDs(), []string{"2"}) {
+		t.Errorf("Basic mastery should be enough: %v", plan.PageIDs())
+	}
+
+	plan = NewPlan(graph, targets, map[string]int{"1": core.ResearchMasteryLevel})
+	if len(plan.Steps) != 0 || len(plan.RequirementMap) != 0 {
+		t.Errorf("Target is already known: %v", plan.PageIDs())
+	}
+}
+
+// Make sure we prefer pages that teach the subject at the needed level.
+func TestTutorLevels(t *testing.T) {
+	graph := NewGraph()
+	graph.AddTutor("1", "2", core.BasicMasteryLevel)
+	graph.AddTutor("1", "3", core.TechnicalMasteryLevel)
+	graph.AddRequirement("3", "4", core.LooseMasteryLevel)
+	graph.AddTutor("4", "5", core.LooseMasteryLevel)
+
+	plan := NewPlan(graph, []*Target{{PageID: "1", Level: core.TechnicalMasteryLevel}}, nil)
+	if !reflect.DeepEqual(plan.PageIDs(), []string{"5", "3"}) {
+		t.Errorf("Unexpected plan: %v", plan.PageIDs())
+	}
+
+	plan = NewPlan(graph, []*Target{{PageID: "1", Level: core.BasicMasteryLevel}}, nil)
+	if !reflect.DeepEqual(plan.PageIDs(), []string{"2"}) {
+		t.Errorf("Unexpected plan: %v", plan.PageIDs())
+	}
+
+	// If no page teaches the subject well enough, we take the best we have
+	graph = NewGraph()
+	graph.AddTutor("1", "2", core.BasicMasteryLevel)
+	plan = NewPlan(graph, []*Target{{PageID: "1", Level: core.ResearchMasteryLevel}}, nil)
+	reason := plan.Steps[0].Reasons[0]
+	if reason.TaughtLevel != core.BasicMasteryLevel || reason.Level != core.ResearchMasteryLevel {
+		t.Errorf("Unexpected reason: %+v", reason)
+	}
+}
+
+// Make sure a page that teaches a subject well enough for everyone is read once.
+func TestSubjectLearnedOnce(t *testing.T) {
+	graph := NewGraph()
+	graph.AddTutor("1", "2", core.LooseMasteryLevel)
+	graph.AddRequirement("2", "3", core.LooseMasteryLevel)
+	graph.AddRequirement("2", "4", core.LooseMasteryLevel)
+	graph.AddTutor("3", "5", core.LooseMasteryLevel)
+	graph.AddTutor("4", "6", core.LooseMasteryLevel)
+	graph.AddRequirement("5", "7", core.BasicMasteryLevel)
+	graph.AddRequirement("6", "7", core.LooseMasteryLevel)
+	graph.AddTutor("7", "8", core.BasicMasteryLevel)
+
+	plan := NewPlan(graph, targetsFor("1"), nil)
+	if !reflect.DeepEqual(plan.PageIDs(), []string{"8", "5", "6", "2"}) {
+		t.Errorf("Unexpected plan: %v", plan.PageIDs())
+	}
+}
+
+// Make sure lenses further down the list cost more.
+func TestLensCost(t *testing.T) {
+	graph := newTestGraph(map[string][]string{"1": {"2", "3"}}, nil)
+	graph.LensIndexes["2"] = 2
+	plan := NewPlan(graph, targetsFor("1"), nil)
+	if plan.RequirementMap["1"].BestTutorID != "3" {
+		t.Errorf("Invalid best tutor: %v, expected 3", plan.RequirementMap["1"].BestTutorID)
+	}
+	if plan.TutorMap["2"].Cost != 1+2*LensCost {
+		t.Errorf("Unexpected lens cost: %d", plan.TutorMap["2"].Cost)
+	}
+}
+
+// Make sure cycles are broken the same way every time.
+func TestCycle(t *testing.T) {
+	graph := newTestGraph(
+		map[string][]string{"1": {"2"}, "3": {"4"}, "5": {"6"}},
+		map[string][]string{"2": {"3"}, "4": {"5"}, "6": {"3"}})
+	plan := NewPlan(graph, targetsFor("1"), nil)
+	expected := []string{"3", "2"}
+	if !reflect.DeepEqual(plan.PageIDs(), expected) {
+		t.Fatalf("Unexpected plan: %v", plan.PageIDs())
+	}
+	if !plan.Steps[0].Reasons[0].MadeUp {
+		t.Errorf("Cycle should be broken at 3: %+v", plan.Steps[0].Reasons[0])
+	}
+	for n := 0; n < 20; n++ {
+		plan = NewPlan(graph, targetsFor("1"), nil)
+		if !reflect.DeepEqual(plan.PageIDs(), expected) {
+			t.Fatalf("Plan changed between runs: %v", plan.PageIDs())
+		}
+	}
+}
+
+// Make sure ties are broken by id, so the plan doesn't depend on map order.
+func TestDeterministicTies(t *testing.T) {
+	graph := newTestGraph(
+		map[string][]string{"1": {"9", "8", "7"}, "2": {"6"}, "3": {"5"}},
+		map[string][]string{"9": {"2", "3"}, "8": {"3", "2"}, "7": {"2", "3"}})
+	for n := 0; n < 20; n++ {
+		plan := NewPlan(graph, targetsFor("1"), nil)
+		if !reflect.DeepEqual(plan.PageIDs(), []string{"6", "5", "7"}) {
+			t.Fatalf("Unexpected plan: %v", plan.PageIDs())
+		}
+	}
+}
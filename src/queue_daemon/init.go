@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"zanaduu3/src/database"
+	"zanaduu3/src/elastic"
 	"zanaduu3/src/sessions"
 	"zanaduu3/src/tasks"
 
@@ -38,7 +39,14 @@ func processTask(c sessions.Context) error {
 	var task tasks.QueueTask
 	taskPrototypes := []tasks.QueueTask{
 		tasks.AtMentionUpdateTask{},
+		tasks.BackfillPageEstimatesTask{},
+		tasks.BulkEditTask{},
 		tasks.CheckAnsweredMarksTask{},
+		tasks.CheckRequisiteGraphTask{},
+		tasks.CheckSearchIndexTask{},
+		tasks.CompactEditsTask{},
+		tasks.ComputePathAnalyticsTask{},
+		tasks.ComputeSimilarPagesTask{},
 		tasks.CopyPagesTask{},
 		tasks.DomainWideNewUpdateTask{},
 		tasks.EmailUpdatesTask{},
@@ -46,7 +54,9 @@ func processTask(c sessions.Context) error {
 		tasks.MemberUpdateTask{},
 		tasks.NewUpdateTask{},
 		tasks.PopulateElasticTask{},
+		tasks.PublishScheduledEditTask{},
 		tasks.PublishPagePairTask{},
+		tasks.RemindMasteryReviewsTask{},
 		tasks.SendFeedbackEmailTask{},
 		tasks.SendInviteTask{},
 		tasks.SendOneEmailTask{},
@@ -54,6 +64,7 @@ func processTask(c sessions.Context) error {
 		tasks.UpdateElasticPageTask{},
 		tasks.UpdateFeaturedPagesTask{},
 		tasks.UpdateMetadataTask{},
+		tasks.UpdatePageDifficultyTask{},
 		tasks.UpdatePagePairsTask{},
 	}
 	taskPrototypeMap := make(map[string]tasks.QueueTask)
@@ -131,6 +142,39 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		c.Debugf("UpdateFeaturedPagesTask enqueue error: %v", err)
 	}
+	var computeSimilarPagesTask tasks.ComputeSimilarPagesTask
+	err = tasks.Enqueue(c, &computeSimilarPagesTask, &tasks.TaskOptions{Name: computeSimilarPagesTask.Tag()})
+	if err != nil {
+		c.Debugf("ComputeSimilarPagesTask enqueue error: %v", err)
+	}
+	var computePathAnalyticsTask tasks.ComputePathAnalyticsTask
+	err = tasks.Enqueue(c, &computePathAnalyticsTask, &tasks.TaskOptions{Name: computePathAnalyticsTask.Tag()})
+	if err != nil {
+		c.Debugf("ComputePathAnalyticsTask enqueue error: %v", err)
+	}
+	var checkRequisiteGraphTask tasks.CheckRequisiteGraphTask
+	err = tasks.Enqueue(c, &checkRequisiteGraphTask, &tasks.TaskOptions{Name: checkRequisiteGraphTask.Tag()})
+	if err != nil {
+		c.Debugf("CheckRequisiteGraphTask enqueue error: %v", err)
+	}
+	var remindMasteryReviewsTask tasks.RemindMasteryReviewsTask
+	err = tasks.Enqueue(c, &remindMasteryReviewsTask, &tasks.TaskOptions{Name: remindMasteryReviewsTask.Tag()})
+	if err != nil {
+		c.Debugf("RemindMasteryReviewsTask enqueue error: %v", err)
+	}
+
+	// The search suggestions need the title.suggest field, which only exists
+	// once the pages index has been rebuilt
+	hasSuggestField, err := elastic.HasPageIndexField(c, "title.suggest")
+	if err != nil {
+		c.Errorf("Couldn't check the page index mapping: %v", err)
+	} else if !hasSuggestField {
+		var populateElasticTask tasks.PopulateElasticTask
+		err = tasks.Enqueue(c, &populateElasticTask, &tasks.TaskOptions{Name: "populateElasticTitleSuggest"})
+		if err != nil {
+			c.Debugf("PopulateElasticTask enqueue error: %v", err)
+		}
+	}
 
 	for {
 		if err := processTask(c); err != nil {
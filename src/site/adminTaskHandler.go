@@ -47,6 +47,29 @@ func adminTaskHandlerFunc(params *pages.HandlerParams) *pages.Result {
 		if err := tasks.Enqueue(c, &task, nil); err != nil {
 			return pages.Fail("Couldn't enqueue a task", err)
 		}
+	} else if task == "computeSimilarPages" {
+		var task tasks.ComputeSimilarPagesTask
+		task.RunOnce = true
+		if err := tasks.Enqueue(c, &task, nil); err != nil {
+			return pages.Fail("Couldn't enqueue a task", err)
+		}
+	} else if task == "computePathAnalytics" {
+		var task tasks.ComputePathAnalyticsTask
+		task.RunOnce = true
+		if err := tasks.Enqueue(c, &task, nil); err != nil {
+			return pages.Fail("Couldn't enqueue a task", err)
+		}
+	} else if task == "checkRequisiteGraph" {
+		var task tasks.CheckRequisiteGraphTask
+		task.RunOnce = true
+		if err := tasks.Enqueue(c, &task, nil); err != nil {
+			return pages.Fail("Couldn't enqueue a task", err)
+		}
+	} else if task == "backfillPageEstimates" {
+		var task tasks.BackfillPageEstimatesTask
+		if err := tasks.Enqueue(c, &task, nil); err != nil {
+			return pages.Fail("Couldn't enqueue a task", err)
+		}
 	} else if task == "tick" {
 		var task tasks.TickTask
 		if err := tasks.Enqueue(c, &task, nil); err != nil {
@@ -95,6 +95,22 @@ func approvePageEditProposalHandlerFunc(params *pages.HandlerParams) *pages.Resu
 			}
 		}
 
+		// Record the review
+		review := &core.EditProposalReview{
+			ChangeLogID: data.ChangeLogID,
+			PageID:      proposedEdit.PageID,
+			ReviewerID:  u.ID,
+			Action:      core.ApproveReviewAction,
+		}
+		if data.Dismiss {
+			review.Action = core.DismissReviewAction
+		} else {
+			review.ResultEdit = proposedEdit.Edit
+		}
+		if err := core.AddEditProposalReview(tx, review); err != nil {
+			return sessions.NewError("Couldn't add the review", err)
+		}
+
 		// Update change log's type
 		hashmap := make(database.InsertMap)
 		hashmap["id"] = data.ChangeLogID
@@ -0,0 +1,84 @@
+// authorRevertPreviewJsonHandler.go lists the pages that would be reverted by revertAuthorEditsHandler.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+)
+
+// authorRevertData contains parameters passed in via the request.
+type authorRevertData struct {
+	AuthorID string
+	DomainID string
+	// Only live edits from the last this many hours are reverted
+	SinceHours int
+}
+
+var authorRevertPreviewHandler = siteHandler{
+	URI:         "/json/authorRevertPreview/",
+	HandlerFunc: authorRevertPreviewHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+func authorRevertPreviewHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	var data authorRevertData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	since, result := checkAuthorRevertData(params, &data)
+	if result != nil {
+		return result
+	}
+
+	revertPages, err := core.LoadAuthorRevertPages(db, data.AuthorID, data.DomainID, since)
+	if err != nil {
+		return pages.Fail("Couldn't load pages", err)
+	}
+	for _, revertPage := range revertPages {
+		core.AddPageToMap(revertPage.PageID, returnData.PageMap, core.TitlePlusLoadOptions)
+	}
+	core.AddUserIDToMap(data.AuthorID, returnData.UserMap)
+	returnData.ResultMap["revertPages"] = revertPages
+
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+	return pages.Success(returnData)
+}
+
+// checkAuthorRevertData validates the request and checks that the current user
+// can revert edits in the domain. Returns the time from which edits are reverted.
+func checkAuthorRevertData(params *pages.HandlerParams, data *authorRevertData) (string, *pages.Result) {
+	u := params.U
+	if !core.IsIDValid(data.AuthorID) {
+		return "", pages.Fail("Invalid author id", nil).Status(http.StatusBadRequest)
+	}
+	if !core.IsIntIDValid(data.DomainID) {
+		return "", pages.Fail("Invalid domain id", nil).Status(http.StatusBadRequest)
+	}
+	if data.AuthorID == u.ID {
+		return "", pages.Fail("Can't revert your own edits", nil).Status(http.StatusBadRequest)
+	}
+	if data.SinceHours <= 0 {
+		data.SinceHours = core.DefaultAuthorRevertHours
+	}
+	if !core.RoleAtLeast(u.GetDomainMembershipRole(data.DomainID), core.ArbiterDomainRole) && !u.IsAdmin {
+		return "", pages.Fail("Have to be an arbiter in the domain", nil).Status(http.StatusForbidden)
+	}
+	since := time.Now().UTC().Add(-time.Duration(data.SinceHours) * time.Hour).Format(database.TimeLayout)
+	return since, nil
+}
@@ -0,0 +1,79 @@
+// autocompleteJsonHandler.go contains the handler for quickly matching a partial
+// query against pages' ids, aliases, and titles.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+const (
+	// How many results to return to the FE
+	autocompleteSize = 10
+)
+
+type autocompleteJSONData struct {
+	Term string
+}
+
+var autocompleteHandler = siteHandler{
+	URI:         "/json/autocomplete/",
+	HandlerFunc: autocompleteJSONHandler,
+}
+
+// autocompleteJSONHandler handles the request.
+func autocompleteJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data autocompleteJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if core.NormalizePageTrieKey(data.Term) == "" {
+		return pages.Fail("No search term specified", nil).Status(http.StatusBadRequest)
+	}
+
+	pageIDs, err := core.SearchPageAutocomplete(db, u, data.Term, autocompleteSize)
+	if err != nil {
+		return pages.Fail("Couldn't search autocomplete index", err)
+	}
+	usedElastic := false
+
+	// If we didn't find enough pages, fall back to the full text search
+	if len(pageIDs) < autocompleteSize {
+		usedElastic = true
+		jsonStr := buildParentsSearchQuery(data.Term, core.GetVisibleDomainIDs(u))
+		results, err := loadSearchResults(params, jsonStr, returnData)
+		if err != nil {
+			return pages.Fail("Error with elastic search", err)
+		}
+		for _, hit := range results.Hits.Hits {
+			if len(pageIDs) >= autocompleteSize {
+				break
+			}
+			if !core.IsStringInList(hit.ID, pageIDs) {
+				pageIDs = append(pageIDs, hit.ID)
+			}
+		}
+	}
+
+	for _, pageID := range pageIDs {
+		core.AddPageToMap(pageID, returnData.PageMap, core.TitlePlusLoadOptions)
+	}
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["pageIds"] = pageIDs
+	returnData.ResultMap["usedElastic"] = usedElastic
+	return pages.Success(returnData)
+}
@@ -0,0 +1,72 @@
+// bulkEditJsonHandler.go returns the progress of a bulk edit and the pages it changed.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+)
+
+const (
+	// Max number of changed pages to return
+	bulkEditPagesLimit = 500
+)
+
+// bulkEditJSONData contains parameters passed in via the request.
+type bulkEditJSONData struct {
+	BulkEditID string
+}
+
+var bulkEditHandler = siteHandler{
+	URI:         "/json/bulkEdit/",
+	HandlerFunc: bulkEditJSONHandler,
+	Options: pages.PageOptions{
+		AdminOnly: true,
+	},
+}
+
+// bulkEditJSONHandler handles the request.
+func bulkEditJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data bulkEditJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+
+	bulkEdit, err := core.LoadBulkEdit(db, data.BulkEditID)
+	if err != nil {
+		return pages.Fail("Couldn't load the bulk edit", err)
+	} else if bulkEdit == nil {
+		return pages.Fail("Couldn't find the bulk edit", nil).Status(http.StatusBadRequest)
+	}
+
+	bulkEditPages, err := core.LoadBulkEditPages(db, bulkEdit.ID, database.NewQuery(`
+		ORDER BY pageId
+		LIMIT ?`, bulkEditPagesLimit))
+	if err != nil {
+		return pages.Fail("Couldn't load changed pages", err)
+	}
+
+	// Load data
+	core.AddUserIDToMap(bulkEdit.CreatedBy, returnData.UserMap)
+	for _, bulkEditPage := range bulkEditPages {
+		core.AddPageIDToMap(bulkEditPage.PageID, returnData.PageMap)
+	}
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["bulkEdit"] = bulkEdit
+	returnData.ResultMap["pages"] = bulkEditPages
+	return pages.Success(returnData)
+}
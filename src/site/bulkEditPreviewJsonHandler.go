@@ -0,0 +1,116 @@
+// bulkEditPreviewJsonHandler.go shows which pages a bulk find-and-replace would
+// change, without changing anything.
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+)
+
+const (
+	// Max number of pages to look at for the preview
+	bulkEditPreviewScanLimit = 2000
+	// Max number of changed pages to return diffs for
+	bulkEditPreviewResultLimit = 50
+)
+
+// bulkEditData contains the parameters of a bulk edit.
+type bulkEditData struct {
+	FindText    string
+	ReplaceText string
+	IsRegexp    bool
+	// Optional filters
+	DomainID string
+	PageIDs  []string
+}
+
+// bulkEditPreviewPage is a page that would be changed by the bulk edit.
+type bulkEditPreviewPage struct {
+	PageID       string           `json:"pageId"`
+	Edit         int              `json:"edit"`
+	Replacements int              `json:"replacements"`
+	Hunks        []*core.DiffHunk `json:"hunks"`
+}
+
+var bulkEditPreviewHandler = siteHandler{
+	URI:         "/json/bulkEditPreview/",
+	HandlerFunc: bulkEditPreviewJSONHandler,
+	Options: pages.PageOptions{
+		AdminOnly: true,
+	},
+}
+
+// bulkEditPreviewJSONHandler handles the request.
+func bulkEditPreviewJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data bulkEditData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	replacer, err := core.NewBulkEditReplacer(data.FindText, data.ReplaceText, data.IsRegexp)
+	if err != nil {
+		return pages.Fail("Invalid find text", err).Status(http.StatusBadRequest)
+	}
+
+	pagesScanned := 0
+	pagesChanged := 0
+	totalReplacements := 0
+	previewPages := make([]*bulkEditPreviewPage, 0)
+	rows := database.NewQuery(`
+		SELECT p.pageId,p.edit,p.text
+		FROM pages AS p
+		JOIN pageInfos AS pi
+		ON (p.pageId=pi.pageId AND p.isLiveEdit)
+		WHERE`).AddPart(core.PageInfosFilter(nil)).Add(`
+			AND`).AddPart(core.NewBulkEditFilter(data.DomainID, data.PageIDs)).Add(`
+		ORDER BY pi.pageId
+		LIMIT ?`, bulkEditPreviewScanLimit).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var previewPage bulkEditPreviewPage
+		var text string
+		err := rows.Scan(&previewPage.PageID, &previewPage.Edit, &text)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		pagesScanned++
+		newText, count := replacer.Replace(text)
+		if count <= 0 {
+			return nil
+		}
+		pagesChanged++
+		totalReplacements += count
+		if len(previewPages) < bulkEditPreviewResultLimit {
+			previewPage.Replacements = count
+			previewPage.Hunks = core.DiffText(text, newText).GetHunks()
+			previewPages = append(previewPages, &previewPage)
+			core.AddPageIDToMap(previewPage.PageID, returnData.PageMap)
+		}
+		return nil
+	})
+	if err != nil {
+		return pages.Fail("Couldn't load pages", err)
+	}
+
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["pages"] = previewPages
+	returnData.ResultMap["pagesScanned"] = pagesScanned
+	returnData.ResultMap["pagesChanged"] = pagesChanged
+	returnData.ResultMap["replacements"] = totalReplacements
+	returnData.ResultMap["isTruncated"] = pagesScanned >= bulkEditPreviewScanLimit
+	return pages.Success(returnData)
+}
@@ -0,0 +1,71 @@
+// cancelScheduledPublishHandler.go cancels a pending scheduled publish. The
+// scheduled edit stays around as a snapshot.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+// cancelScheduledPublishData contains parameters passed in via the request.
+type cancelScheduledPublishData struct {
+	ID string
+}
+
+var cancelScheduledPublishHandler = siteHandler{
+	URI:         "/cancelScheduledPublish/",
+	HandlerFunc: cancelScheduledPublishHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// cancelScheduledPublishHandlerFunc handles the request.
+func cancelScheduledPublishHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+
+	// Decode data
+	var data cancelScheduledPublishData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIntIDValid(data.ID) {
+		return pages.Fail("Invalid id", nil).Status(http.StatusBadRequest)
+	}
+
+	sp, err := core.LoadScheduledPublish(db, data.ID)
+	if err != nil {
+		return pages.Fail("Couldn't load the scheduled publish", err)
+	} else if sp == nil {
+		return pages.Fail("Couldn't find the scheduled publish", nil).Status(http.StatusBadRequest)
+	} else if sp.UserID != u.ID {
+		return pages.Fail("Can only cancel your own scheduled publishes", nil).Status(http.StatusForbidden)
+	} else if sp.Status != core.PendingScheduledPublish {
+		return pages.Fail("This publish is not pending anymore", nil).Status(http.StatusBadRequest)
+	}
+
+	// The publish could have started in the meantime
+	var isCancelled bool
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		var err error
+		isCancelled, err = core.SetScheduledPublishStatus(tx, sp.ID, core.CancelledScheduledPublish, "")
+		if err != nil {
+			return sessions.NewError("Couldn't cancel the scheduled publish", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	} else if !isCancelled {
+		return pages.Fail("This publish is not pending anymore", nil).Status(http.StatusBadRequest)
+	}
+	return pages.Success(nil)
+}
@@ -150,5 +150,12 @@ func deletePagePairHandlerFunc(params *pages.HandlerParams) *pages.Result {
 		return pages.FailWith(err2)
 	}
 
+	if pagePair.Type == core.RequirementPagePairType {
+		var task tasks.UpdatePageDifficultyTask
+		task.PageID = pagePair.ChildID
+		if err := tasks.Enqueue(c, &task, nil); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
 	return pages.Success(nil)
 }
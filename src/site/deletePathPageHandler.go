@@ -60,6 +60,13 @@ func deletePathPageHandlerFunc(params *pages.HandlerParams) *pages.Result {
 		if _, err := statement.Exec(); err != nil {
 			return sessions.NewError("Couldn't delete the pathPage", err)
 		}
+
+		// Alternates can't exist without the page they are for
+		statement = database.NewQuery(`
+			DELETE FROM pathPages WHERE branchOf=?`, data.ID).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't delete the alternates", err)
+		}
 		return nil
 	})
 	if err2 != nil {
@@ -0,0 +1,57 @@
+// deleteSearchSynonymHandler.go removes a group of search synonyms.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+)
+
+// deleteSearchSynonymData contains data given to us in the request.
+type deleteSearchSynonymData struct {
+	ID string
+}
+
+var deleteSearchSynonymHandler = siteHandler{
+	URI:         "/deleteSearchSynonym/",
+	HandlerFunc: deleteSearchSynonymHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// deleteSearchSynonymHandlerFunc handles the request.
+func deleteSearchSynonymHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	u := params.U
+	db := params.DB
+
+	var data deleteSearchSynonymData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode json", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIntIDValid(data.ID) {
+		return pages.Fail("Invalid id", nil).Status(http.StatusBadRequest)
+	}
+
+	group, err := core.LoadSearchSynonymGroup(db, data.ID)
+	if err != nil {
+		return pages.Fail("Couldn't load the search synonym group", err)
+	} else if group == nil {
+		return pages.Fail("Couldn't find the search synonym group", nil).Status(http.StatusBadRequest)
+	}
+	if !core.CanUserEditSearchSynonyms(u, group.DomainID) {
+		return pages.Fail("Don't have permissions to change search synonyms in this domain", nil).Status(http.StatusForbidden)
+	}
+
+	statement := database.NewQuery(`
+		DELETE FROM searchSynonyms WHERE id=?`, data.ID).ToStatement(db)
+	if _, err := statement.Exec(); err != nil {
+		return pages.Fail("Couldn't delete search synonyms", err)
+	}
+	return pages.Success(nil)
+}
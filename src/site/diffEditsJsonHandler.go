@@ -0,0 +1,185 @@
+// diffEditsJsonHandler.go returns the differences between two edits of a page.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+// diffEditsJSONData contains parameters passed in via the request.
+type diffEditsJSONData struct {
+	PageID   string
+	FromEdit int
+	ToEdit   int
+}
+
+// summaryDiff describes how one of the page's summaries changed.
+type summaryDiff struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	OldText string `json:"oldText"`
+	NewText string `json:"newText"`
+}
+
+// linksDiff describes which links were added and removed.
+type linksDiff struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Unchanged []string `json:"unchanged"`
+	// Map: alias -> page id, for the links that point to existing pages
+	AliasToPageID map[string]string `json:"aliasToPageId"`
+}
+
+var diffEditsHandler = siteHandler{
+	URI:         "/json/diffEdits/",
+	HandlerFunc: diffEditsJSONHandler,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// diffEditsJSONHandler handles the request.
+func diffEditsJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data diffEditsJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.PageID) {
+		return pages.Fail("Invalid page id", nil).Status(http.StatusBadRequest)
+	}
+	if data.FromEdit <= 0 || data.ToEdit <= 0 {
+		return pages.Fail("Invalid edit numbers", nil).Status(http.StatusBadRequest)
+	}
+
+	// Load both edits
+	loadEdit := func(edit int) (*core.Page, *pages.Result) {
+		p, err := core.LoadFullEdit(db, data.PageID, u, returnData.DomainMap, &core.LoadEditOptions{LoadSpecificEdit: edit})
+		if err != nil {
+			return nil, pages.Fail("Couldn't load the edit", err)
+		} else if p == nil {
+			return nil, pages.Fail("Couldn't find the edit", nil).Status(http.StatusBadRequest)
+		}
+		if !p.Permissions.Edit.Has {
+			return nil, pages.Fail("Can't view the diff: "+p.Permissions.Edit.Reason, nil).Status(http.StatusForbidden)
+		}
+		// Other users' autosaves and snapshots are private
+		if (p.IsAutosave || p.IsSnapshot) && p.EditCreatorID != u.ID {
+			return nil, pages.Fail("Can't view someone else's draft", nil).Status(http.StatusForbidden)
+		}
+		return p, nil
+	}
+	fromPage, result := loadEdit(data.FromEdit)
+	if result != nil {
+		return result
+	}
+	toPage, result := loadEdit(data.ToEdit)
+	if result != nil {
+		return result
+	}
+
+	// Compute diffs for all the text fields
+	fieldDiffs := map[string]*core.TextDiff{
+		"title":     core.DiffText(fromPage.Title, toPage.Title),
+		"clickbait": core.DiffText(fromPage.Clickbait, toPage.Clickbait),
+		"text":      core.DiffText(fromPage.Text, toPage.Text),
+		"metaText":  core.DiffText(fromPage.MetaText, toPage.MetaText),
+	}
+
+	// Compare summaries
+	fromSummaries, _ := core.ExtractSummaries(fromPage.PageID, fromPage.Text)
+	toSummaries, _ := core.ExtractSummaries(toPage.PageID, toPage.Text)
+	summaryDiffs := make([]*summaryDiff, 0)
+	for name, oldText := range fromSummaries {
+		diff := &summaryDiff{Name: name, OldText: oldText, Type: core.DeleteDiffType}
+		if newText, ok := toSummaries[name]; ok {
+			diff.NewText = newText
+			diff.Type = core.EqualDiffType
+			if newText != oldText {
+				diff.Type = core.ChangeDiffType
+			}
+		}
+		summaryDiffs = append(summaryDiffs, diff)
+	}
+	for name, newText := range toSummaries {
+		if _, ok := fromSummaries[name]; !ok {
+			summaryDiffs = append(summaryDiffs, &summaryDiff{Name: name, NewText: newText, Type: core.InsertDiffType})
+		}
+	}
+	sort.Sort(summaryDiffList(summaryDiffs))
+
+	// Compare links
+	fromLinks := lowercaseLinks(core.ExtractPageLinks(fromPage.Text, sessions.GetDomain()))
+	toLinks := lowercaseLinks(core.ExtractPageLinks(toPage.Text, sessions.GetDomain()))
+	links := &linksDiff{Added: make([]string, 0), Removed: make([]string, 0), Unchanged: make([]string, 0)}
+	for _, alias := range fromLinks {
+		if core.IsStringInList(alias, toLinks) {
+			links.Unchanged = append(links.Unchanged, alias)
+		} else {
+			links.Removed = append(links.Removed, alias)
+		}
+	}
+	for _, alias := range toLinks {
+		if !core.IsStringInList(alias, fromLinks) {
+			links.Added = append(links.Added, alias)
+		}
+	}
+	allLinks := append(append(append([]string{}, links.Added...), links.Removed...), links.Unchanged...)
+	links.AliasToPageID, err = core.LoadAliasToPageIDMap(db, u, allLinks)
+	if err != nil {
+		return pages.Fail("Couldn't load links", err)
+	}
+	for _, pageID := range links.AliasToPageID {
+		core.AddPageToMap(pageID, returnData.PageMap, core.TitlePlusLoadOptions)
+	}
+
+	// Load data
+	returnData.EditMap[data.PageID] = toPage
+	core.AddPageIDToMap(data.PageID, returnData.PageMap)
+	core.AddUserIDToMap(fromPage.EditCreatorID, returnData.UserMap)
+	core.AddUserIDToMap(toPage.EditCreatorID, returnData.UserMap)
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["fromEdit"] = fromPage
+	returnData.ResultMap["toEdit"] = toPage
+	returnData.ResultMap["diffs"] = fieldDiffs
+	returnData.ResultMap["summaries"] = summaryDiffs
+	returnData.ResultMap["links"] = links
+	return pages.Success(returnData)
+}
+
+// lowercaseLinks lowercases the given aliases and removes duplicates, since
+// that's how they are stored in the links table.
+func lowercaseLinks(aliases []string) []string {
+	result := make([]string, 0)
+	for _, alias := range aliases {
+		alias = strings.ToLower(alias)
+		if !core.IsStringInList(alias, result) {
+			result = append(result, alias)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Sort summaries by name
+type summaryDiffList []*summaryDiff
+
+func (a summaryDiffList) Len() int           { return len(a) }
+func (a summaryDiffList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a summaryDiffList) Less(i, j int) bool { return a[i].Name < a[j].Name }
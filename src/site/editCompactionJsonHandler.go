@@ -0,0 +1,60 @@
+// editCompactionJsonHandler.go returns the progress of an edit history compaction,
+// including how much space it reclaimed.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+// editCompactionJSONData contains parameters passed in via the request.
+type editCompactionJSONData struct {
+	// If not set, the latest compaction is returned
+	CompactionID string
+}
+
+var editCompactionHandler = siteHandler{
+	URI:         "/json/editCompaction/",
+	HandlerFunc: editCompactionJSONHandler,
+	Options: pages.PageOptions{
+		AdminOnly: true,
+	},
+}
+
+// editCompactionJSONHandler handles the request.
+func editCompactionJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data editCompactionJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+
+	compactionID := data.CompactionID
+	if compactionID == "" {
+		compactionID, err = core.LoadLatestEditCompactionID(db)
+		if err != nil {
+			return pages.Fail("Couldn't load the latest compaction", err)
+		} else if compactionID == "" {
+			return pages.Success(returnData)
+		}
+	}
+
+	compaction, err := core.LoadEditCompaction(db, compactionID)
+	if err != nil {
+		return pages.Fail("Couldn't load the compaction", err)
+	} else if compaction == nil {
+		return pages.Fail("Couldn't find the compaction", nil).Status(http.StatusBadRequest)
+	}
+
+	returnData.ResultMap["compaction"] = compaction
+	return pages.Success(returnData)
+}
@@ -97,6 +97,16 @@ func editJSONInternalHandler(params *pages.HandlerParams, data *editJSONData) *p
 		}
 	}
 
+	// Show who is editing the page together
+	presences, err := core.LoadEditSessionPresences(db, pageID)
+	if err != nil {
+		return pages.Fail("Couldn't load editing session presences", err)
+	}
+	for _, presence := range presences {
+		core.AddUserIDToMap(presence.UserID, returnData.UserMap)
+	}
+	returnData.ResultMap["editSessionPresences"] = presences
+
 	// Load additional pages (for which we need to display a greenlink)
 	if data.AdditionalPageIDs == nil {
 		data.AdditionalPageIDs = make([]string, 0)
@@ -7,8 +7,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"regexp"
 	"strings"
+	"time"
 
 	"zanaduu3/src/core"
 	"zanaduu3/src/database"
@@ -42,9 +42,15 @@ type editPageData struct {
 	IsProposal bool
 	// Edit that FE thinks is the current edit
 	CurrentEdit int
+	// If set, the edit will be saved as a snapshot and published at this time
+	// (in database.TimeLayout, UTC)
+	PublishAt string
 
 	// These parameters are only accepted from internal BE calls
 	RevertToEdit int `json:"-"`
+	// If set, called at the end of the edit's transaction with the number of the
+	// new edit and whether it became the live edit
+	OnSaved func(tx *database.Tx, newEdit int, isLiveEdit bool) sessions.Error `json:"-"`
 }
 
 var editPageHandler = siteHandler{
@@ -91,13 +97,42 @@ func editPageInternalHandler(params *pages.HandlerParams, data *editPageData) *p
 
 	// If the client think the current edit is X, but it's actually Y where X!=Y
 	// (e.g. if someone else published a new version since we started editing), then
+	// try to merge our changes with theirs. If that fails, save a snapshot instead.
+	// Type of the changeLog recording the merge attempt (if any)
+	var mergeChangeLogType string
+	// Edit our changes were based on
+	mergeBaseEdit := data.PrevEdit
 	if oldPage.WasPublished && data.RevertToEdit == 0 && data.CurrentEdit != oldPage.Edit {
-		// Notify the client with an error
-		returnData.ResultMap["obsoleteEdit"] = oldPage
-		// And save a snapshot
-		data.IsAutosave = false
-		data.IsSnapshot = true
-		data.SnapshotText = fmt.Sprintf("Automatically saved snapshot (%s)", database.Now())
+		var mergeResults map[string]*core.MergeResult
+		if !data.IsAutosave && !data.IsSnapshot && mergeBaseEdit > 0 {
+			var result *pages.Result
+			mergeResults, result = mergeWithLiveEdit(db, u, returnData, data, oldPage)
+			if result != nil {
+				return result
+			}
+		}
+		if mergeResults != nil && areMergesClean(mergeResults) {
+			// Publish the merged version as if it was based on the live edit
+			data.Title = mergeResults["title"].Text
+			data.Clickbait = mergeResults["clickbait"].Text
+			data.Text = mergeResults["text"].Text
+			data.MetaText = mergeResults["metaText"].Text
+			data.PrevEdit = oldPage.Edit
+			data.CurrentEdit = oldPage.Edit
+			mergeChangeLogType = core.MergeEditsChangeLog
+			returnData.ResultMap["mergedEdit"] = oldPage.Edit
+		} else {
+			if mergeResults != nil {
+				returnData.ResultMap["mergeConflicts"] = mergeResults
+				mergeChangeLogType = core.EditConflictChangeLog
+			}
+			// Notify the client with an error
+			returnData.ResultMap["obsoleteEdit"] = oldPage
+			// And save a snapshot
+			data.IsAutosave = false
+			data.IsSnapshot = true
+			data.SnapshotText = fmt.Sprintf("Automatically saved snapshot (%s)", database.Now())
+		}
 	}
 
 	// Load additional info
@@ -111,6 +146,11 @@ func editPageInternalHandler(params *pages.HandlerParams, data *editPageData) *p
 	if err != nil {
 		return pages.Fail("Couldn't load additional page info", err)
 	}
+	// While a collaborative editing session is going on, presence replaces the lock
+	isEditSessionActive, err := core.IsEditSessionActive(db, data.PageID)
+	if err != nil {
+		return pages.Fail("Couldn't check the editing session", err)
+	}
 
 	// If this edit will be visible to the public
 	isPublicEdit := !data.IsAutosave && !data.IsSnapshot
@@ -167,6 +207,31 @@ func editPageInternalHandler(params *pages.HandlerParams, data *editPageData) *p
 		}
 	}
 
+	// Handle scheduled publishing
+	isScheduledPublish := data.PublishAt != "" && returnData.ResultMap["obsoleteEdit"] == nil
+	if isScheduledPublish {
+		if !isNewCurrentEdit || data.RevertToEdit != 0 {
+			return pages.Fail("Only a new live edit can be scheduled", nil).Status(http.StatusBadRequest)
+		}
+		if oldPage.Type == core.CommentPageType {
+			return pages.Fail("Can't schedule publishing of a comment", nil).Status(http.StatusBadRequest)
+		}
+		if !oldPage.WasPublished && core.IsIntIDValid(oldPage.SubmitToDomainID) {
+			return pages.Fail("Can't schedule publishing of a page submitted to a domain", nil).Status(http.StatusBadRequest)
+		}
+		publishAt, err := time.Parse(database.TimeLayout, data.PublishAt)
+		if err != nil {
+			return pages.Fail("Couldn't parse publish time", err).Status(http.StatusBadRequest)
+		} else if !publishAt.After(time.Now().UTC()) {
+			return pages.Fail("Publish time has to be in the future", nil).Status(http.StatusBadRequest)
+		}
+		// Until then, the edit is just a snapshot
+		isPublicEdit = false
+		isNewCurrentEdit = false
+		data.IsSnapshot = true
+		data.SnapshotText = fmt.Sprintf("Scheduled to be published at %s", data.PublishAt)
+	}
+
 	// Load parents for comments
 	var commentParentID string
 	var commentPrimaryPageID string
@@ -216,6 +281,8 @@ func editPageInternalHandler(params *pages.HandlerParams, data *editPageData) *p
 	var editChangeLogID int64
 	// Whether we created a changeLog for this edit
 	var createEditChangeLog bool
+	// The id of the scheduled publish for this edit
+	var scheduledPublishID int64
 
 	// Begin the transaction.
 	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
@@ -283,20 +350,11 @@ func editPageInternalHandler(params *pages.HandlerParams, data *editPageData) *p
 
 		// Update summaries
 		if isNewCurrentEdit {
-			// Delete old summaries
-			statement = database.NewQuery(`
-				DELETE FROM pageSummaries WHERE pageId=?`, data.PageID).ToTxStatement(tx)
-			if _, err := statement.Exec(); err != nil {
-				return sessions.NewError("Couldn't delete existing page summaries", err)
+			if err := core.UpdatePageSummaries(tx, data.PageID, data.Text); err != nil {
+				return sessions.NewError("Couldn't update page summaries", err)
 			}
-
-			// Insert new summaries
-			_, summaryValues := core.ExtractSummaries(data.PageID, data.Text)
-			statement = tx.DB.NewStatement(`
-				INSERT INTO pageSummaries (pageId,name,text)
-				VALUES ` + database.ArgsPlaceholder(len(summaryValues), 3)).WithTx(tx)
-			if _, err := statement.Exec(summaryValues...); err != nil {
-				return sessions.NewError("Couldn't insert page summaries", err)
+			if err := core.UpdatePageEstimates(tx, data.PageID, data.Text); err != nil {
+				return sessions.NewError("Couldn't update page estimates", err)
 			}
 		}
 
@@ -320,7 +378,7 @@ func editPageInternalHandler(params *pages.HandlerParams, data *editPageData) *p
 		}
 		if isPublicEdit {
 			hashmap["lockedUntil"] = database.Now()
-		} else if data.IsAutosave {
+		} else if data.IsAutosave && !isEditSessionActive {
 			hashmap["lockedBy"] = u.ID
 			hashmap["lockedUntil"] = core.GetPageLockedUntilTime()
 		}
@@ -396,6 +454,59 @@ func editPageInternalHandler(params *pages.HandlerParams, data *editPageData) *p
 			}
 		}
 
+		// The editing session now builds on the new live edit
+		if isNewCurrentEdit && isEditSessionActive {
+			statement = database.NewQuery(`
+				UPDATE editSessions
+				SET baseEdit=?`, newEditNum).Add(`
+				WHERE pageId=?`, data.PageID).ToTxStatement(tx)
+			if _, err = statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't update the editing session", err)
+			}
+		}
+
+		// Record the result of merging with the live edit
+		if mergeChangeLogType != "" {
+			hashmap = make(database.InsertMap)
+			hashmap["pageId"] = data.PageID
+			hashmap["edit"] = newEditNum
+			hashmap["userId"] = u.ID
+			hashmap["createdAt"] = database.Now()
+			hashmap["type"] = mergeChangeLogType
+			hashmap["oldSettingsValue"] = fmt.Sprintf("%d", mergeBaseEdit)
+			hashmap["newSettingsValue"] = fmt.Sprintf("%d", oldPage.Edit)
+			statement = tx.DB.NewInsertStatement("changeLogs", hashmap).WithTx(tx)
+			if _, err = statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't insert merge change log", err)
+			}
+		}
+
+		// Record the scheduled publish
+		if isScheduledPublish {
+			baseEdit := 0
+			if oldPage.WasPublished {
+				baseEdit = oldPage.Edit
+			}
+			hashmap = make(database.InsertMap)
+			hashmap["pageId"] = data.PageID
+			hashmap["edit"] = newEditNum
+			hashmap["userId"] = u.ID
+			hashmap["baseEdit"] = baseEdit
+			hashmap["publishAt"] = data.PublishAt
+			hashmap["status"] = core.PendingScheduledPublish
+			hashmap["createdAt"] = database.Now()
+			hashmap["updatedAt"] = database.Now()
+			statement = tx.DB.NewInsertStatement("scheduledPublishes", hashmap).WithTx(tx)
+			result, err := statement.Exec()
+			if err != nil {
+				return sessions.NewError("Couldn't schedule the publish", err)
+			}
+			scheduledPublishID, err = result.LastInsertId()
+			if err != nil {
+				return sessions.NewError("Couldn't get id of the scheduled publish", err)
+			}
+		}
+
 		// Subscribe this user to the parent comment
 		if !oldPage.WasPublished && isNewCurrentEdit &&
 			oldPage.Type == core.CommentPageType && core.IsIDValid(commentParentID) {
@@ -435,6 +546,12 @@ func editPageInternalHandler(params *pages.HandlerParams, data *editPageData) *p
 				}
 			}
 		}
+
+		if data.OnSaved != nil {
+			if err := data.OnSaved(tx, newEditNum, isNewCurrentEdit); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 	if err2 != nil {
@@ -475,99 +592,75 @@ func editPageInternalHandler(params *pages.HandlerParams, data *editPageData) *p
 	// === Once the transaction has succeeded, we can't really fail on anything
 	// else. So we print out errors, but don't return an error. ===
 
-	if isPublicEdit {
-		// Generate "edit" update for users who are subscribed to this page.
-		if oldPage.WasPublished && !data.IsMinorEdit && createEditChangeLog && oldPage.Type != core.CommentPageType {
-			var task tasks.NewUpdateTask
-			task.UserID = u.ID
-			task.GoToPageID = data.PageID
-			task.SubscribedToID = data.PageID
-			task.ChangeLogID = editChangeLogID
-			if oldPage.IsDeleted {
-				task.UpdateType = core.ChangeLogUpdateType
-			} else {
-				task.UpdateType = core.PageEditUpdateType
-			}
-			if err := tasks.Enqueue(c, &task, nil); err != nil {
-				c.Errorf("Couldn't enqueue a task: %v", err)
-			}
+	if isScheduledPublish {
+		var task tasks.PublishScheduledEditTask
+		task.ID = fmt.Sprintf("%d", scheduledPublishID)
+		publishAt, _ := time.Parse(database.TimeLayout, data.PublishAt)
+		options := &tasks.TaskOptions{Delay: int(publishAt.Sub(time.Now().UTC()).Seconds())}
+		if err := tasks.Enqueue(c, &task, options); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
 		}
+		returnData.ResultMap["scheduledPublishId"] = task.ID
 	}
 
-	if isNewCurrentEdit {
-		// Update elastic
-		var task tasks.UpdateElasticPageTask
-		task.PageID = data.PageID
-		if err := tasks.Enqueue(c, &task, nil); err != nil {
-			c.Errorf("Couldn't enqueue a task: %v", err)
-		}
+	tasks.EnqueueEditTasks(c, &tasks.EditTasksData{
+		PageID:               data.PageID,
+		UserID:               u.ID,
+		PageType:             oldPage.Type,
+		Text:                 data.Text,
+		IsPublicEdit:         isPublicEdit,
+		IsNewCurrentEdit:     isNewCurrentEdit,
+		IsMinorEdit:          data.IsMinorEdit,
+		WasPublished:         oldPage.WasPublished,
+		WasDeleted:           oldPage.IsDeleted,
+		IsEditorComment:      oldPage.IsEditorComment,
+		IsApprovedComment:    oldPage.IsApprovedComment,
+		CommentParentID:      commentParentID,
+		CommentPrimaryPageID: commentPrimaryPageID,
+		EditChangeLogID:      editChangeLogID,
+	})
 
-		// Generate updates for users who are subscribed to the author.
-		if !oldPage.WasPublished && oldPage.Type != core.CommentPageType && !data.IsMinorEdit {
-			var task tasks.NewUpdateTask
-			task.UserID = u.ID
-			task.UpdateType = core.NewPageByUserUpdateType
-			task.SubscribedToID = u.ID
-			task.GoToPageID = data.PageID
-			if createEditChangeLog {
-				task.ChangeLogID = editChangeLogID
-			}
-			if err := tasks.Enqueue(c, &task, nil); err != nil {
-				c.Errorf("Couldn't enqueue a task: %v", err)
-			}
-		}
+	return pages.Success(returnData)
+}
 
-		// Do some stuff for a new comment.
-		if !oldPage.WasPublished && oldPage.Type == core.CommentPageType {
-			// Send "new comment" updates.
-			if !data.IsMinorEdit {
-				var task tasks.NewUpdateTask
-				task.UserID = u.ID
-				task.GoToPageID = data.PageID
-				task.ForceMaintainersOnly = oldPage.IsEditorComment || !oldPage.IsApprovedComment
-				if createEditChangeLog {
-					task.ChangeLogID = editChangeLogID
-				}
-				if core.IsIDValid(commentParentID) {
-					// This is a new reply
-					task.UpdateType = core.ReplyUpdateType
-					task.SubscribedToID = commentParentID
-				} else {
-					// This is a new top level comment
-					task.UpdateType = core.TopLevelCommentUpdateType
-					task.SubscribedToID = commentPrimaryPageID
-				}
-				if err := tasks.Enqueue(c, &task, nil); err != nil {
-					c.Errorf("Couldn't enqueue a task: %v", err)
-				}
-			}
+// mergeWithLiveEdit does a three-way merge of the submitted edit with the live
+// edit, using the edit the submission was based on as the base. Returns a map:
+// field name -> merge result. Returns nil if the base edit couldn't be found.
+// The base has to be a published edit or one of the user's own drafts.
+func mergeWithLiveEdit(db *database.DB, u *core.CurrentUser, returnData *core.CommonHandlerData,
+	data *editPageData, livePage *core.Page) (map[string]*core.MergeResult, *pages.Result) {
+	basePage, err := core.LoadFullEdit(db, data.PageID, u, returnData.DomainMap, &core.LoadEditOptions{LoadSpecificEdit: data.PrevEdit})
+	if err != nil {
+		return nil, pages.Fail("Couldn't load the base edit", err)
+	} else if basePage == nil {
+		return nil, nil
+	}
+	// Other users' autosaves and snapshots are private
+	if (basePage.IsAutosave || basePage.IsSnapshot) && basePage.EditCreatorID != u.ID {
+		return nil, pages.Fail("Can't merge with someone else's draft", nil).Status(http.StatusForbidden)
+	}
 
-			// Generate updates for @mentions
-			// Find ids and aliases using [@text] syntax.
-			exp := regexp.MustCompile("\\[@([0-9]+)\\]")
-			submatches := exp.FindAllStringSubmatch(data.Text, -1)
-			for _, submatch := range submatches {
-				var task tasks.AtMentionUpdateTask
-				task.UserID = u.ID
-				task.MentionedUserID = submatch[1]
-				task.GoToPageID = data.PageID
-				if err := tasks.Enqueue(c, &task, nil); err != nil {
-					c.Errorf("Couldn't enqueue a task: %v", err)
-				}
-			}
-		}
+	// Standardize our text the same way the stored edits were standardized
+	ourText, err := core.StandardizeLinks(db, strings.Replace(data.Text, "\r\n", "\n", -1))
+	if err != nil {
+		return nil, pages.Fail("Couldn't standardize links", err)
+	}
+	ourMetaText := strings.Replace(data.MetaText, "\r\n", "\n", -1)
+
+	return map[string]*core.MergeResult{
+		"title":     core.Merge3(basePage.Title, data.Title, livePage.Title),
+		"clickbait": core.Merge3(basePage.Clickbait, data.Clickbait, livePage.Clickbait),
+		"text":      core.Merge3(basePage.Text, ourText, livePage.Text),
+		"metaText":  core.Merge3(basePage.MetaText, ourMetaText, livePage.MetaText),
+	}, nil
+}
 
-		// Create a task to check if any of the relationships need to be published
-		// TODO: condition: this page went from unpublished to published or deleted to undeleted
-		{
-			var task tasks.UpdatePagePairsTask
-			task.PageID = data.PageID
-			if err := tasks.Enqueue(c, &task, nil); err != nil {
-				c.Errorf("Couldn't enqueue a task: %v", err)
-			}
+// areMergesClean returns true iff none of the merges had conflicts.
+func areMergesClean(mergeResults map[string]*core.MergeResult) bool {
+	for _, result := range mergeResults {
+		if !result.IsClean() {
+			return false
 		}
-
 	}
-
-	return pages.Success(returnData)
+	return true
 }
@@ -0,0 +1,147 @@
+// editProposalJsonHandler.go returns everything needed to review an edit proposal.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+// editProposalJSONData contains parameters passed in via the request.
+type editProposalJSONData struct {
+	ChangeLogID string
+}
+
+var editProposalHandler = siteHandler{
+	URI:         "/json/editProposal/",
+	HandlerFunc: editProposalJSONHandler,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// editProposal contains all the edits involved in reviewing a proposal.
+type editProposal struct {
+	changeLog *core.ChangeLog
+	// Currently live edit of the page
+	livePage *core.Page
+	// The proposed edit
+	proposedEdit *core.Page
+	// Edit the proposal was based on (live edit if we can't find it)
+	baseEdit *core.Page
+}
+
+// editProposalJSONHandler handles the request.
+func editProposalJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data editProposalJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+
+	proposal, result := loadEditProposal(params, returnData, data.ChangeLogID)
+	if result != nil {
+		return result
+	}
+	if !proposal.livePage.Permissions.Edit.Has && proposal.changeLog.UserID != u.ID {
+		return pages.Fail("Can't review: "+proposal.livePage.Permissions.Edit.Reason, nil).Status(http.StatusForbidden)
+	}
+
+	// Compute diffs between the base and the proposal
+	textDiff := core.DiffText(proposal.baseEdit.Text, proposal.proposedEdit.Text)
+	fieldDiffs := map[string]*core.TextDiff{
+		"title":     core.DiffText(proposal.baseEdit.Title, proposal.proposedEdit.Title),
+		"clickbait": core.DiffText(proposal.baseEdit.Clickbait, proposal.proposedEdit.Clickbait),
+		"text":      textDiff,
+		"metaText":  core.DiffText(proposal.baseEdit.MetaText, proposal.proposedEdit.MetaText),
+	}
+
+	comments, err := core.LoadEditProposalComments(db, data.ChangeLogID)
+	if err != nil {
+		return pages.Fail("Couldn't load comments", err)
+	}
+	reviewMap, err := core.LoadEditProposalReviews(db, []string{data.ChangeLogID})
+	if err != nil {
+		return pages.Fail("Couldn't load reviews", err)
+	}
+	reviews := reviewMap[data.ChangeLogID]
+	if reviews == nil {
+		reviews = make([]*core.EditProposalReview, 0)
+	}
+
+	// Load data
+	core.AddPageIDToMap(proposal.changeLog.PageID, returnData.PageMap)
+	core.AddUserIDToMap(proposal.changeLog.UserID, returnData.UserMap)
+	for _, comment := range comments {
+		core.AddUserIDToMap(comment.UserID, returnData.UserMap)
+	}
+	for _, review := range reviews {
+		core.AddUserIDToMap(review.ReviewerID, returnData.UserMap)
+	}
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["changeLog"] = proposal.changeLog
+	returnData.ResultMap["proposedEdit"] = proposal.proposedEdit
+	returnData.ResultMap["baseEdit"] = proposal.baseEdit
+	returnData.ResultMap["liveEdit"] = proposal.livePage.Edit
+	returnData.ResultMap["diffs"] = fieldDiffs
+	returnData.ResultMap["hunks"] = textDiff.GetHunks()
+	returnData.ResultMap["comments"] = comments
+	returnData.ResultMap["reviews"] = reviews
+	return pages.Success(returnData)
+}
+
+// loadEditProposal loads the pending edit proposal with the given change log id,
+// along with the edits needed to review it.
+func loadEditProposal(params *pages.HandlerParams, returnData *core.CommonHandlerData, changeLogID string) (*editProposal, *pages.Result) {
+	db := params.DB
+	u := params.U
+	proposal := &editProposal{}
+
+	var err error
+	proposal.changeLog, err = core.LoadEditProposal(db, changeLogID)
+	if err != nil {
+		return nil, pages.Fail("Couldn't load changelog", err)
+	} else if proposal.changeLog == nil {
+		return nil, pages.Fail("Couldn't find a pending edit proposal", nil).Status(http.StatusBadRequest)
+	}
+	pageID := proposal.changeLog.PageID
+
+	proposal.livePage, err = core.LoadFullEdit(db, pageID, u, returnData.DomainMap, nil)
+	if err != nil {
+		return nil, pages.Fail("Couldn't load the live page", err)
+	} else if proposal.livePage == nil {
+		return nil, pages.Fail("Couldn't find the live page", nil).Status(http.StatusBadRequest)
+	}
+
+	proposal.proposedEdit, err = core.LoadFullEdit(db, pageID, u, returnData.DomainMap,
+		&core.LoadEditOptions{LoadSpecificEdit: proposal.changeLog.Edit})
+	if err != nil {
+		return nil, pages.Fail("Couldn't load the proposed edit", err)
+	} else if proposal.proposedEdit == nil {
+		return nil, pages.Fail("Couldn't find the proposed edit", nil).Status(http.StatusBadRequest)
+	}
+
+	proposal.baseEdit = proposal.livePage
+	if proposal.proposedEdit.PrevEdit > 0 && proposal.proposedEdit.PrevEdit != proposal.livePage.Edit {
+		baseEdit, err := core.LoadFullEdit(db, pageID, u, returnData.DomainMap,
+			&core.LoadEditOptions{LoadSpecificEdit: proposal.proposedEdit.PrevEdit})
+		if err != nil {
+			return nil, pages.Fail("Couldn't load the base edit", err)
+		} else if baseEdit != nil {
+			proposal.baseEdit = baseEdit
+		}
+	}
+	return proposal, nil
+}
@@ -0,0 +1,175 @@
+// editSessionJsonHandler.go joins the collaborative editing session for a page,
+// starting one if necessary.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+// editSessionJSONData contains parameters passed in via the request.
+type editSessionJSONData struct {
+	PageID string
+}
+
+var editSessionHandler = siteHandler{
+	URI:         "/json/editSession/",
+	HandlerFunc: editSessionJSONHandler,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// editSessionJSONHandler handles the request.
+func editSessionJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data editSessionJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.PageID) {
+		return pages.Fail("Invalid page id", nil).Status(http.StatusBadRequest)
+	}
+
+	p, result := loadEditSessionPage(params, returnData, data.PageID)
+	if result != nil {
+		return result
+	}
+
+	isActive, err := core.IsEditSessionActive(db, data.PageID)
+	if err != nil {
+		return pages.Fail("Couldn't check the session", err)
+	}
+
+	var session *core.EditSession
+	var isLockedByOther bool
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		session, err = core.LoadEditSessionForUpdate(tx, data.PageID)
+		if err != nil {
+			return sessions.NewError("Couldn't load the session", err)
+		}
+
+		// Don't take over the page from someone editing it outside of the session
+		var lockedBy, lockedUntil string
+		row := database.NewQuery(`
+			SELECT lockedBy,lockedUntil
+			FROM pageInfos
+			WHERE pageId=?
+			FOR UPDATE`, data.PageID).ToTxStatement(tx).QueryRow()
+		if _, err := row.Scan(&lockedBy, &lockedUntil); err != nil {
+			return sessions.NewError("Couldn't load the page lock", err)
+		}
+		if lockedUntil > database.Now() && lockedBy != u.ID {
+			isLockedByOther = true
+			return nil
+		}
+
+		if session == nil || (session.IsStale() && !isActive) {
+			// Start a new session from the current text of the page
+			session = &core.EditSession{
+				PageID:         data.PageID,
+				Text:           p.Text,
+				BaseEdit:       p.Edit,
+				CreatedBy:      u.ID,
+				CreatedAt:      database.Now(),
+				UpdatedAt:      database.Now(),
+				LastAutosaveAt: database.Now(),
+			}
+			hashmap := make(database.InsertMap)
+			hashmap["pageId"] = session.PageID
+			hashmap["revision"] = session.Revision
+			hashmap["text"] = session.Text
+			hashmap["baseEdit"] = session.BaseEdit
+			hashmap["createdBy"] = session.CreatedBy
+			hashmap["createdAt"] = session.CreatedAt
+			hashmap["updatedAt"] = session.UpdatedAt
+			hashmap["lastAutosaveAt"] = session.LastAutosaveAt
+			statement := tx.DB.NewInsertStatement("editSessions", hashmap, hashmap.GetKeys()...).WithTx(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't create the session", err)
+			}
+
+			// Operations from the old session don't apply anymore, and neither do
+			// the revisions its users are at
+			statement = database.NewQuery(`
+				DELETE FROM editSessionOps
+				WHERE pageId=?`, data.PageID).ToTxStatement(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't delete old operations", err)
+			}
+			if err := core.DeleteStaleEditSessionPresences(tx, data.PageID, true); err != nil {
+				return sessions.NewError("Couldn't delete old presences", err)
+			}
+		} else if err := core.DeleteStaleEditSessionPresences(tx, data.PageID, false); err != nil {
+			return sessions.NewError("Couldn't delete stale presences", err)
+		}
+
+		// Presence in the session replaces the user's own page lock
+		statement := database.NewQuery(`
+			UPDATE pageInfos
+			SET lockedUntil=?`, database.Now()).Add(`
+			WHERE pageId=? AND lockedBy=?`, data.PageID, u.ID).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't release the page lock", err)
+		}
+
+		if err := core.UpdateEditSessionPresence(tx, u, data.PageID, 0, 0); err != nil {
+			return sessions.NewError("Couldn't update presence", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	} else if isLockedByOther {
+		return pages.Fail("Another editor is currently working on the page", nil).Status(http.StatusForbidden)
+	}
+
+	presences, err := core.LoadEditSessionPresences(db, data.PageID)
+	if err != nil {
+		return pages.Fail("Couldn't load presences", err)
+	}
+
+	// Load data
+	core.AddPageToMap(data.PageID, returnData.PageMap, core.TitlePlusLoadOptions)
+	for _, presence := range presences {
+		core.AddUserIDToMap(presence.UserID, returnData.UserMap)
+	}
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["session"] = session
+	returnData.ResultMap["presences"] = presences
+	return pages.Success(returnData)
+}
+
+// loadEditSessionPage loads the page the same way we do when saving an edit and
+// checks that the user can edit it. Edit rights can be taken away while the
+// session is going on, so this is done for every request, not just on join.
+func loadEditSessionPage(params *pages.HandlerParams, returnData *core.CommonHandlerData, pageID string) (*core.Page, *pages.Result) {
+	p, err := core.LoadFullEdit(params.DB, pageID, params.U, returnData.DomainMap, &core.LoadEditOptions{
+		LoadNonliveEdit: true,
+		PreferLiveEdit:  true,
+	})
+	if err != nil {
+		return nil, pages.Fail("Couldn't load the page", err)
+	} else if p == nil {
+		return nil, pages.Fail("Couldn't find the page", nil).Status(http.StatusBadRequest)
+	}
+	if !p.Permissions.Edit.Has {
+		return nil, pages.Fail("Can't edit: "+p.Permissions.Edit.Reason, nil).Status(http.StatusForbidden)
+	}
+	return p, nil
+}
@@ -0,0 +1,178 @@
+// editSessionOpHandler.go applies a change to the text of a collaborative
+// editing session.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+// editSessionOpData contains parameters passed in via the request.
+type editSessionOpData struct {
+	PageID string
+	// Revision of the session text the operation was made against
+	Revision int
+	Ops      core.TextOperation
+	// User's selection after the operation was applied on their side
+	SelectionStart int
+	SelectionEnd   int
+}
+
+var editSessionOpHandler = siteHandler{
+	URI:         "/editSessionOp/",
+	HandlerFunc: editSessionOpHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// editSessionOpHandlerFunc handles the request.
+func editSessionOpHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	c := params.C
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data editSessionOpData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.PageID) {
+		return pages.Fail("Invalid page id", nil).Status(http.StatusBadRequest)
+	}
+	if err := data.Ops.Validate(); err != nil {
+		return pages.Fail("Invalid operation", err).Status(http.StatusBadRequest)
+	}
+
+	p, result := loadEditSessionPage(params, returnData, data.PageID)
+	if result != nil {
+		return result
+	}
+
+	var session *core.EditSession
+	joined := false
+	// The operation, transformed to apply to the latest revision
+	ops := data.Ops
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		session, err = core.LoadEditSessionForUpdate(tx, data.PageID)
+		if err != nil {
+			return sessions.NewError("Couldn't load the session", err)
+		} else if session == nil {
+			return sessions.NewError("Editing session doesn't exist", nil)
+		}
+		// Users who timed out or joined a session that has since restarted have to
+		// rejoin, since their revision doesn't refer to this session's text
+		joined, err = core.IsInEditSession(tx, u, data.PageID)
+		if err != nil {
+			return sessions.NewError("Couldn't check presence", err)
+		} else if !joined {
+			return nil
+		}
+		if data.Revision < 0 || data.Revision > session.Revision {
+			return sessions.NewError("Invalid revision", nil)
+		}
+
+		// Transform the operation past everything the user hasn't seen yet. Their
+		// selection has to be moved past those operations as well.
+		concurrentOps, err := core.LoadEditSessionOps(tx.DB, data.PageID, data.Revision)
+		if err != nil {
+			return sessions.NewError("Couldn't load concurrent operations", err)
+		}
+		for _, concurrentOp := range concurrentOps {
+			var concurrentPrime core.TextOperation
+			ops, concurrentPrime, err = core.TransformTextOperations(ops, concurrentOp.Ops)
+			if err != nil {
+				return sessions.NewError("Couldn't transform the operation", err)
+			}
+			data.SelectionStart = core.TransformTextPosition(data.SelectionStart, concurrentPrime)
+			data.SelectionEnd = core.TransformTextPosition(data.SelectionEnd, concurrentPrime)
+		}
+		session.Text, err = ops.Apply(session.Text)
+		if err != nil {
+			return sessions.NewError("Couldn't apply the operation", err)
+		}
+		session.Revision++
+		session.UpdatedAt = database.Now()
+
+		opsJSON, err := json.Marshal(ops)
+		if err != nil {
+			return sessions.NewError("Couldn't encode the operation", err)
+		}
+		hashmap := make(database.InsertMap)
+		hashmap["pageId"] = data.PageID
+		hashmap["revision"] = session.Revision
+		hashmap["userId"] = u.ID
+		hashmap["ops"] = string(opsJSON)
+		hashmap["createdAt"] = session.UpdatedAt
+		statement := tx.DB.NewInsertStatement("editSessionOps", hashmap).WithTx(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't insert the operation", err)
+		}
+
+		statement = database.NewQuery(`
+			UPDATE editSessions
+			SET revision=?,text=?,updatedAt=?`, session.Revision, session.Text, session.UpdatedAt).Add(`
+			WHERE pageId=?`, data.PageID).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't update the session", err)
+		}
+
+		err = core.UpdateEditSessionPresence(tx, u, data.PageID, data.SelectionStart, data.SelectionEnd)
+		if err != nil {
+			return sessions.NewError("Couldn't update presence", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	} else if !joined {
+		return pages.Fail("You aren't in this editing session", nil).Status(http.StatusForbidden)
+	}
+
+	// Periodically save the session text through the normal autosave path
+	if session.NeedsAutosave() {
+		result := autosaveEditSession(params, session, p)
+		if result.Err != nil {
+			c.Errorf("Couldn't autosave the editing session: %v", result.Err)
+		} else {
+			statement := database.NewQuery(`
+				UPDATE editSessions
+				SET lastAutosaveAt=?`, database.Now()).Add(`
+				WHERE pageId=?`, data.PageID).ToStatement(db)
+			if _, err := statement.Exec(); err != nil {
+				return pages.Fail("Couldn't update the session", err)
+			}
+		}
+	}
+
+	returnData.ResultMap["revision"] = session.Revision
+	returnData.ResultMap["ops"] = ops
+	return pages.Success(returnData)
+}
+
+// autosaveEditSession saves the session text as the current user's autosave.
+// The rest of the page's fields aren't edited collaboratively, so they are taken
+// from p, the edit the user would see in the editor.
+func autosaveEditSession(params *pages.HandlerParams, session *core.EditSession, p *core.Page) *pages.Result {
+	return editPageInternalHandler(params, &editPageData{
+		PageID:        session.PageID,
+		PrevEdit:      session.BaseEdit,
+		CurrentEdit:   p.Edit,
+		Title:         p.Title,
+		Clickbait:     p.Clickbait,
+		Text:          session.Text,
+		MetaText:      p.MetaText,
+		AnchorContext: p.AnchorContext,
+		AnchorText:    p.AnchorText,
+		AnchorOffset:  p.AnchorOffset,
+		IsAutosave:    true,
+	})
+}
@@ -0,0 +1,136 @@
+// editSessionPollJsonHandler.go waits for changes in a collaborative editing
+// session and returns them.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+const (
+	// How long we wait for new changes before returning (in seconds)
+	editSessionPollDuration = 20
+	// How often we check for new changes while waiting
+	editSessionPollInterval = time.Second
+)
+
+// editSessionPollJSONData contains parameters passed in via the request.
+type editSessionPollJSONData struct {
+	PageID string
+	// Latest revision the user has seen
+	Revision       int
+	SelectionStart int
+	SelectionEnd   int
+}
+
+var editSessionPollHandler = siteHandler{
+	URI:         "/json/editSessionPoll/",
+	HandlerFunc: editSessionPollJSONHandler,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// editSessionPollJSONHandler handles the request.
+func editSessionPollJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data editSessionPollJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.PageID) {
+		return pages.Fail("Invalid page id", nil).Status(http.StatusBadRequest)
+	}
+
+	_, result := loadEditSessionPage(params, returnData, data.PageID)
+	if result != nil {
+		return result
+	}
+
+	pollStart := database.Now()
+	joined := false
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		// Lock the session so it can't restart between the check and the update
+		session, err := core.LoadEditSessionForUpdate(tx, data.PageID)
+		if err != nil {
+			return sessions.NewError("Couldn't load the session", err)
+		} else if session == nil {
+			return nil
+		}
+		joined, err = core.IsInEditSession(tx, u, data.PageID)
+		if err != nil {
+			return sessions.NewError("Couldn't check presence", err)
+		} else if !joined {
+			return nil
+		}
+		err = core.UpdateEditSessionPresence(tx, u, data.PageID, data.SelectionStart, data.SelectionEnd)
+		if err != nil {
+			return sessions.NewError("Couldn't update presence", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	} else if !joined {
+		return pages.Fail("You aren't in this editing session", nil).Status(http.StatusForbidden)
+	}
+
+	// Wait until someone changes the text or moves their cursor
+	var ops []*core.EditSessionOp
+	var presences []*core.EditSessionPresence
+	deadline := time.Now().Add(editSessionPollDuration * time.Second)
+	for {
+		ops, err = core.LoadEditSessionOps(db, data.PageID, data.Revision)
+		if err != nil {
+			return pages.Fail("Couldn't load operations", err)
+		}
+		presences, err = core.LoadEditSessionPresences(db, data.PageID)
+		if err != nil {
+			return pages.Fail("Couldn't load presences", err)
+		}
+		if len(ops) > 0 || time.Now().After(deadline) {
+			break
+		}
+		presenceChanged := false
+		for _, presence := range presences {
+			if presence.UserID != u.ID && presence.UpdatedAt > pollStart {
+				presenceChanged = true
+			}
+		}
+		if presenceChanged {
+			break
+		}
+		time.Sleep(editSessionPollInterval)
+	}
+
+	revision := data.Revision
+	if len(ops) > 0 {
+		revision = ops[len(ops)-1].Revision
+	}
+
+	// Load data
+	for _, presence := range presences {
+		core.AddUserIDToMap(presence.UserID, returnData.UserMap)
+	}
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["revision"] = revision
+	returnData.ResultMap["ops"] = ops
+	returnData.ResultMap["presences"] = presences
+	return pages.Success(returnData)
+}
@@ -0,0 +1,175 @@
+// evaluateSearchRankingJsonHandler.go replays a set of search queries with the
+// current and the proposed ranking rules and reports how well each one does.
+
+package site
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/elastic"
+	"zanaduu3/src/pages"
+)
+
+const (
+	// Max number of queries we'll replay in one request
+	maxSearchRankingEvalCases = 50
+)
+
+// searchRankingEvalCase is a query together with the pages we expect to see,
+// ordered from most to least relevant.
+type searchRankingEvalCase struct {
+	Term            string   `json:"term"`
+	ExpectedPageIDs []string `json:"expectedPageIds"`
+}
+
+type evaluateSearchRankingData struct {
+	// Ranking we want to test; its DomainID determines the current ranking we compare against
+	Ranking *core.SearchRanking
+	Cases   []*searchRankingEvalCase
+}
+
+// searchRankingEvalResult is the result for one case.
+type searchRankingEvalResult struct {
+	Term                string   `json:"term"`
+	CurrentPageIDs      []string `json:"currentPageIds"`
+	ProposedPageIDs     []string `json:"proposedPageIds"`
+	CurrentNDCG         float64  `json:"currentNdcg"`
+	ProposedNDCG        float64  `json:"proposedNdcg"`
+	MissingExpectedHits []string `json:"missingExpectedHits"`
+}
+
+var evaluateSearchRankingHandler = siteHandler{
+	URI:         "/json/evaluateSearchRanking/",
+	HandlerFunc: evaluateSearchRankingJSONHandler,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// evaluateSearchRankingJSONHandler handles the request.
+func evaluateSearchRankingJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data evaluateSearchRankingData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	proposed := data.Ranking
+	if proposed == nil {
+		return pages.Fail("No ranking given", nil).Status(http.StatusBadRequest)
+	}
+	if !core.IsIntIDValid(proposed.DomainID) {
+		proposed.DomainID = core.DefaultSearchRankingDomainID
+	}
+	if err := proposed.Validate(); err != nil {
+		return pages.Fail("Invalid ranking", err).Status(http.StatusBadRequest)
+	}
+	if len(data.Cases) <= 0 || len(data.Cases) > maxSearchRankingEvalCases {
+		return pages.Fail("Invalid number of cases", nil).Status(http.StatusBadRequest)
+	}
+	if !core.CanUserEditSearchRanking(u, proposed.DomainID) {
+		return pages.Fail("Don't have permissions to change search ranking in this domain", nil).Status(http.StatusForbidden)
+	}
+
+	current, err := core.LoadSearchRanking(db, proposed.DomainID)
+	if err != nil {
+		return pages.Fail("Couldn't load search ranking", err)
+	}
+
+	synonyms, err := loadSearchSynonyms(db, proposed.DomainID)
+	if err != nil {
+		return pages.Fail("Couldn't load search synonyms", err)
+	}
+
+	results := make([]*searchRankingEvalResult, 0)
+	var currentTotal, proposedTotal float64
+	for _, evalCase := range data.Cases {
+		if evalCase.Term == "" {
+			return pages.Fail("Case without a search term", nil).Status(http.StatusBadRequest)
+		}
+		query, err := buildSearchQuery(u, &searchJSONData{
+			Term:                  evalCase.Term,
+			PreferredEditDomainID: proposed.DomainID,
+		}, synonyms)
+		if err != nil {
+			return pages.Fail("Error constructing ElasticSearch query", err)
+		}
+		searchResults, err := loadSearchResults(params, query, returnData)
+		if err != nil {
+			return pages.Fail("Error with elastic search", err)
+		}
+
+		result := &searchRankingEvalResult{Term: evalCase.Term}
+		result.CurrentPageIDs = hitPageIDs(rankSearchHits(searchResults.Hits.Hits, returnData.PageMap, current, u))
+		result.ProposedPageIDs = hitPageIDs(rankSearchHits(searchResults.Hits.Hits, returnData.PageMap, proposed, u))
+		result.CurrentNDCG = computeNDCG(result.CurrentPageIDs, evalCase.ExpectedPageIDs, returnSearchSize)
+		result.ProposedNDCG = computeNDCG(result.ProposedPageIDs, evalCase.ExpectedPageIDs, returnSearchSize)
+		result.MissingExpectedHits = findMissingExpectedHits(result.CurrentPageIDs, evalCase.ExpectedPageIDs, returnSearchSize)
+		currentTotal += result.CurrentNDCG
+		proposedTotal += result.ProposedNDCG
+		results = append(results, result)
+	}
+
+	returnData.ResultMap["cases"] = results
+	returnData.ResultMap["currentNdcg"] = currentTotal / float64(len(results))
+	returnData.ResultMap["proposedNdcg"] = proposedTotal / float64(len(results))
+	return pages.Success(returnData)
+}
+
+// hitPageIDs returns the ids of the pages in the given hits list, in order.
+func hitPageIDs(hits elastic.HitsList) []string {
+	pageIDs := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		pageIDs = append(pageIDs, hit.ID)
+	}
+	return pageIDs
+}
+
+// findMissingExpectedHits returns the expected pages that are not in the top k
+// results, using the same cut-off as computeNDCG.
+func findMissingExpectedHits(resultIDs []string, expectedIDs []string, k int) []string {
+	if len(resultIDs) > k {
+		resultIDs = resultIDs[:k]
+	}
+	missingIDs := make([]string, 0)
+	for _, pageID := range expectedIDs {
+		if !core.IsStringInList(pageID, resultIDs) {
+			missingIDs = append(missingIDs, pageID)
+		}
+	}
+	return missingIDs
+}
+
+// computeNDCG computes normalized discounted cumulative gain for the top k
+// results. The first expected page is the most relevant one, and each next
+// expected page is less relevant by one point.
+func computeNDCG(resultIDs []string, expectedIDs []string, k int) float64 {
+	relevanceMap := make(map[string]float64)
+	for n, pageID := range expectedIDs {
+		relevanceMap[pageID] = float64(len(expectedIDs) - n)
+	}
+
+	dcg := func(ids []string) float64 {
+		var sum float64
+		for n, pageID := range ids {
+			if n >= k {
+				break
+			}
+			sum += relevanceMap[pageID] / math.Log2(float64(n+2))
+		}
+		return sum
+	}
+
+	idealDCG := dcg(expectedIDs)
+	if idealDCG <= 0 {
+		return 0
+	}
+	return dcg(resultIDs) / idealDCG
+}
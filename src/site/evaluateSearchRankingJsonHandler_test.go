@@ -0,0 +1,45 @@
+package site
+
+import (
+	"math"
+	"testing"
+)
+
+// Make sure the perfect ordering gets the full score.
+func TestNDCGPerfectOrder(t *testing.T) {
+	expected := []string{"1", "2", "3"}
+	if score := computeNDCG([]string{"1", "2", "3", "4"}, expected, 10); math.Abs(score-1) > 1e-9 {
+		t.Errorf("Invalid NDCG: %v, expected 1", score)
+	}
+}
+
+// Make sure a worse ordering gets a lower score, and no expected hits get 0.
+func TestNDCGOrdering(t *testing.T) {
+	expected := []string{"1", "2", "3"}
+	swapped := computeNDCG([]string{"3", "2", "1"}, expected, 10)
+	if swapped >= 1 || swapped <= 0 {
+		t.Errorf("Invalid NDCG for swapped results: %v", swapped)
+	}
+	missing := computeNDCG([]string{"2", "3"}, expected, 10)
+	if missing >= 1 || missing <= 0 {
+		t.Errorf("Invalid NDCG for missing result: %v", missing)
+	}
+	if score := computeNDCG([]string{"4", "5"}, expected, 10); score != 0 {
+		t.Errorf("Invalid NDCG: %v, expected 0", score)
+	}
+}
+
+// Make sure results past k don't count.
+func TestNDCGCutoff(t *testing.T) {
+	if score := computeNDCG([]string{"4", "1"}, []string{"1"}, 1); score != 0 {
+		t.Errorf("Invalid NDCG: %v, expected 0", score)
+	}
+}
+
+// Make sure expected pages past k are reported missing.
+func TestFindMissingExpectedHits(t *testing.T) {
+	missing := findMissingExpectedHits([]string{"4", "1", "2"}, []string{"1", "2", "3"}, 2)
+	if len(missing) != 2 || missing[0] != "2" || missing[1] != "3" {
+		t.Errorf("Invalid missing hits: %v, expected [2 3]", missing)
+	}
+}
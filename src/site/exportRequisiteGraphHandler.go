@@ -0,0 +1,65 @@
+// exportRequisiteGraphHandler.go exports the graph of relationships between a domain's pages.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+// exportRequisiteGraphData contains the data we get in the request.
+type exportRequisiteGraphData struct {
+	DomainID string
+	// Either "json" or "graphml"
+	Format string
+}
+
+var exportRequisiteGraphHandler = siteHandler{
+	URI:         "/json/exportRequisiteGraph/",
+	HandlerFunc: exportRequisiteGraphHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// exportRequisiteGraphHandlerFunc handles requests to export a domain's graph.
+func exportRequisiteGraphHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	decoder := json.NewDecoder(params.R.Body)
+	var data exportRequisiteGraphData
+	err := decoder.Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode json", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIntIDValid(data.DomainID) {
+		return pages.Fail("Invalid domain id", nil).Status(http.StatusBadRequest)
+	}
+	if data.Format == "" {
+		data.Format = core.JSONGraphFormat
+	}
+	if data.Format != core.JSONGraphFormat && data.Format != core.GraphMLGraphFormat {
+		return pages.Fail("Unknown format", nil).Status(http.StatusBadRequest)
+	}
+
+	graph, err := core.LoadDomainGraph(db, u, data.DomainID)
+	if err != nil {
+		return pages.Fail("Couldn't load the graph", err)
+	}
+
+	if data.Format == core.GraphMLGraphFormat {
+		output, err := core.FormatGraphML(graph)
+		if err != nil {
+			return pages.Fail("Couldn't format the graph", err)
+		}
+		returnData.ResultMap["graphml"] = string(output)
+	} else {
+		returnData.ResultMap["graph"] = graph
+	}
+	return pages.Success(returnData)
+}
@@ -0,0 +1,184 @@
+// forkPageHandler.go creates a new page that starts as a copy of an existing one.
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+	"zanaduu3/src/tasks"
+)
+
+// forkPageData contains parameters passed in via the request.
+type forkPageData struct {
+	PageID string
+	// Optional title for the new page. Defaults to the original title.
+	Title string
+}
+
+var forkPageHandler = siteHandler{
+	URI:         "/forkPage/",
+	HandlerFunc: forkPageHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// forkPageHandlerFunc handles the request.
+func forkPageHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	c := params.C
+	db := params.DB
+	u := params.U
+
+	var data forkPageData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.PageID) {
+		return pages.Fail("PageId isn't set", nil).Status(http.StatusBadRequest)
+	}
+
+	// Load the page we are forking
+	original, err := core.LoadFullEdit(db, data.PageID, u, make(map[string]*core.Domain), nil)
+	if err != nil {
+		return pages.Fail("Couldn't load the page", err)
+	} else if original == nil || original.IsDeleted {
+		return pages.Fail("Couldn't find the page", nil).Status(http.StatusBadRequest)
+	} else if original.Type == core.CommentPageType {
+		return pages.Fail("Can't fork a comment", nil).Status(http.StatusBadRequest)
+	}
+	parentIDs, tagIDs, err := core.LoadParentAndTagIDs(db, data.PageID)
+	if err != nil {
+		return pages.Fail("Couldn't load the page's relationships", err)
+	}
+
+	// Check that the user could create each copied relationship on the new page
+	domainMap := make(map[string]*core.Domain)
+	editDomain, err := core.LoadDomainByID(db, u.MyDomainID())
+	if err != nil {
+		return pages.Fail("Couldn't load the edit domain", err)
+	}
+	domainMap[editDomain.ID] = editDomain
+	newPage := core.NewPage("")
+	newPage.Type = original.Type
+	newPage.SeeDomainID = params.PrivateDomain.ID
+	newPage.EditDomainID = u.MyDomainID()
+	newPage.WasPublished = true
+	newPage.ComputePermissions(c, u, domainMap)
+	checkRelationships := func(pageIDs []string, relationshipType string) *pages.Result {
+		for _, pageID := range pageIDs {
+			parent, err := core.LoadFullEdit(db, pageID, u, domainMap, nil)
+			if err != nil {
+				return pages.Fail("Couldn't load the parent page", err)
+			} else if parent == nil {
+				return pages.Fail("Couldn't find a page of the relationship", nil).Status(http.StatusBadRequest)
+			}
+			permissionError, err := core.CanAffectRelationship(c, parent, newPage, relationshipType)
+			if err != nil {
+				return pages.Fail("Error verifying permissions", err)
+			} else if permissionError != "" {
+				return pages.Fail(permissionError, nil).Status(http.StatusForbidden)
+			}
+		}
+		return nil
+	}
+	if result := checkRelationships(parentIDs, core.ParentPagePairType); result != nil {
+		return result
+	}
+	if result := checkRelationships(tagIDs, core.TagPagePairType); result != nil {
+		return result
+	}
+	if data.Title == "" {
+		data.Title = original.Title
+	}
+
+	var newPageID string
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		var err error
+		newPageID, err = core.CreateNewPage(db, u, &core.CreateNewPageOptions{
+			Type:         original.Type,
+			SeeDomainID:  params.PrivateDomain.ID,
+			EditDomainID: u.MyDomainID(),
+			Title:        data.Title,
+			Clickbait:    original.Clickbait,
+			Text:         original.Text,
+			IsPublished:  true,
+			ParentIDs:    parentIDs,
+			TagIDs:       tagIDs,
+			Tx:           tx,
+		})
+		if err != nil {
+			return sessions.NewError("Couldn't create the new page", err)
+		}
+		if err := core.UpdatePageLinks(tx, newPageID, original.Text, sessions.GetDomain()); err != nil {
+			return sessions.NewError("Couldn't update links", err)
+		}
+		if err := core.UpdatePageSummaries(tx, newPageID, original.Text); err != nil {
+			return sessions.NewError("Couldn't update summaries", err)
+		}
+		if err := core.UpdatePageEstimates(tx, newPageID, original.Text); err != nil {
+			return sessions.NewError("Couldn't update estimates", err)
+		}
+
+		// Record where the new page came from on both pages
+		changeLogs := []*core.ChangeLog{
+			&core.ChangeLog{
+				PageID: newPageID,
+				UserID: u.ID,
+				Edit:   1,
+				Type:   core.NewEditChangeLog,
+			},
+			&core.ChangeLog{
+				PageID:           newPageID,
+				UserID:           u.ID,
+				Edit:             1,
+				Type:             core.ForkedFromChangeLog,
+				AuxPageID:        original.PageID,
+				NewSettingsValue: fmt.Sprintf("%d", original.Edit),
+			},
+			&core.ChangeLog{
+				PageID:    original.PageID,
+				UserID:    u.ID,
+				Edit:      original.Edit,
+				Type:      core.ForkPageChangeLog,
+				AuxPageID: newPageID,
+			},
+		}
+		for _, changeLog := range changeLogs {
+			if _, err := core.InsertChangeLog(tx, changeLog); err != nil {
+				return sessions.NewError("Couldn't insert change log", err)
+			}
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	}
+
+	// Publish the copied relationships and add the new page to the search index
+	var pagePairsTask tasks.UpdatePagePairsTask
+	pagePairsTask.PageID = newPageID
+	if err := tasks.Enqueue(c, &pagePairsTask, nil); err != nil {
+		c.Errorf("Couldn't enqueue a task: %v", err)
+	}
+	var elasticTask tasks.UpdateElasticPageTask
+	elasticTask.PageID = newPageID
+	if err := tasks.Enqueue(c, &elasticTask, nil); err != nil {
+		c.Errorf("Couldn't enqueue a task: %v", err)
+	}
+
+	returnData := core.NewHandlerData(u)
+	returnData.ResultMap["pageId"] = newPageID
+	core.AddPageToMap(newPageID, returnData.PageMap, core.TitlePlusLoadOptions)
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+	return pages.Success(returnData)
+}
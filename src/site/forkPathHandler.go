@@ -0,0 +1,86 @@
+// forkPathHandler.go starts the user on a copy of someone else's path instance
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+var forkPathHandler = siteHandler{
+	URI:         "/json/forkPath/",
+	HandlerFunc: forkPathHandlerFunc,
+}
+
+type forkPathData struct {
+	// Id of the shared path instance
+	InstanceID string
+	// Share key of the instance
+	ShareKey string
+}
+
+func forkPathHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	u := params.U
+	db := params.DB
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data forkPathData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	userID := u.GetSomeID()
+	if userID == "" {
+		return pages.Fail("No user id or session id", nil).Status(http.StatusBadRequest)
+	}
+
+	// Load the shared path instance
+	original, err := core.LoadSharedPathInstance(db, data.InstanceID, data.ShareKey, u)
+	if err != nil {
+		return pages.Fail("Couldn't load the path instance: %v", err)
+	} else if original == nil {
+		return pages.Fail("Couldn't find the path instance, or the share key is wrong", nil).Status(http.StatusForbidden)
+	} else if original.IsByCurrentUser {
+		returnData.ResultMap["path"] = original
+		return pages.Success(returnData)
+	}
+
+	// Continue the copy the user already started, if there is one
+	id, err := core.LoadPathInstanceCopyID(db, userID, original.ID)
+	if err != nil {
+		return pages.Fail("Couldn't load the path copy", err)
+	}
+	if id == "" {
+		instance := core.NewPathInstanceCopy(original)
+		err = core.UpdatePathInstancePages(db, u, instance)
+		if err != nil {
+			return pages.Fail("Couldn't resolve the path", err)
+		}
+
+		// Begin the transaction.
+		err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+			newID, err := core.InsertPathInstance(tx, userID, instance)
+			if err != nil {
+				return sessions.NewError("Couldn't copy the path", err)
+			}
+			id = fmt.Sprintf("%d", newID)
+			return nil
+		})
+		if err2 != nil {
+			return pages.FailWith(err2)
+		}
+	}
+
+	returnData.ResultMap["path"], err = core.LoadPathInstance(db, id, u)
+	if err != nil {
+		return pages.Fail("Couldn't load the path: %v", err)
+	}
+	return pages.Success(returnData)
+}
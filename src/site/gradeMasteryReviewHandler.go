@@ -0,0 +1,97 @@
+// gradeMasteryReviewHandler.go records how well the user remembered a mastery
+// and schedules the next review.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+// gradeMasteryReviewData contains parameters passed in via the request.
+type gradeMasteryReviewData struct {
+	MasteryID string
+	// How well the user remembered the mastery, from 0 to 5
+	Grade int
+}
+
+var gradeMasteryReviewHandler = siteHandler{
+	URI:         "/gradeMasteryReview/",
+	HandlerFunc: gradeMasteryReviewHandlerFunc,
+	Options:     pages.PageOptions{},
+}
+
+// gradeMasteryReviewHandlerFunc handles the request.
+func gradeMasteryReviewHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	var data gradeMasteryReviewData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.MasteryID) {
+		return pages.Fail("Invalid mastery id", nil).Status(http.StatusBadRequest)
+	}
+	if data.Grade < core.MinMasteryReviewGrade || data.Grade > core.MaxMasteryReviewGrade {
+		return pages.Fail("Invalid grade", nil).Status(http.StatusBadRequest)
+	}
+	userID := u.GetSomeID()
+	if userID == "" {
+		return pages.Fail("No user id or session id", nil).Status(http.StatusBadRequest)
+	}
+
+	review, err := core.LoadMasteryReview(db, userID, data.MasteryID)
+	if err != nil {
+		return pages.Fail("Couldn't load the review", err)
+	} else if review == nil {
+		return pages.Fail("You don't have this mastery", nil).Status(http.StatusBadRequest)
+	}
+	core.ApplyMasteryReviewGrade(review, data.Grade, time.Now())
+
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		if err := core.SaveMasteryReview(tx, review); err != nil {
+			return sessions.NewError("Couldn't save the review", err)
+		}
+
+		// The reminder is no longer needed once the user is done with their due reviews
+		var dueCount int
+		row := database.NewQuery(`
+			SELECT COUNT(*)
+			FROM masteryReviews AS mr
+			JOIN userMasteryPairs AS ump
+			ON (ump.userId=mr.userId AND ump.masteryId=mr.masteryId)
+			WHERE mr.userId=?`, userID).Add(`
+				AND ump.has
+				AND mr.dueAt<=?`, database.Now()).ToTxStatement(tx).QueryRow()
+		if _, err := row.Scan(&dueCount); err != nil {
+			return sessions.NewError("Couldn't count due reviews", err)
+		}
+		if dueCount > 0 {
+			return nil
+		}
+		statement := database.NewQuery(`
+			UPDATE updates
+			SET seen=TRUE
+			WHERE userId=?`, userID).Add(`
+				AND type=?`, core.MasteryReviewUpdateType).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't mark reminders as seen", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	}
+
+	returnData.ResultMap["review"] = review
+	return pages.Success(returnData)
+}
@@ -0,0 +1,208 @@
+// importRequisiteGraphHandler.go creates the relationships from an uploaded graph.
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+	"zanaduu3/src/tasks"
+)
+
+const (
+	// Maximum number of relationships we'll import at once
+	maxImportedGraphEdges = 1000
+	// Maximum number of problems we report back
+	maxImportGraphErrors = 10
+)
+
+// importRequisiteGraphData contains the data we get in the request.
+type importRequisiteGraphData struct {
+	// Either "json" or "graphml"
+	Format string
+	Data   string
+}
+
+var importRequisiteGraphHandler = siteHandler{
+	URI:         "/importRequisiteGraph/",
+	HandlerFunc: importRequisiteGraphHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// importRequisiteGraphHandlerFunc handles requests to import a graph. Either
+// all the new relationships are created, or none of them are.
+func importRequisiteGraphHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	c := params.C
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	decoder := json.NewDecoder(params.R.Body)
+	var data importRequisiteGraphData
+	err := decoder.Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode json", err).Status(http.StatusBadRequest)
+	}
+	graph, err := core.ParseGraph(data.Format, []byte(data.Data))
+	if err != nil {
+		return pages.Fail("Couldn't parse the graph", err).Status(http.StatusBadRequest)
+	}
+	if len(graph.Edges) > maxImportedGraphEdges {
+		return pages.Fail(fmt.Sprintf("Can't import more than %d relationships at once", maxImportedGraphEdges), nil).Status(http.StatusBadRequest)
+	}
+
+	// Validate the relationships and skip the ones that already exist
+	problems := make([]string, 0)
+	addProblem := func(edge *core.ExportedGraphEdge, problem string) {
+		problems = append(problems, fmt.Sprintf("%s %s->%s: %s", edge.Type, edge.ParentID, edge.ChildID, problem))
+	}
+	newEdges := make([]*core.ExportedGraphEdge, 0)
+	// Relationships that already exist, but with a different level or strength.
+	// They are left as they are.
+	changedEdges := make([]string, 0)
+	seenEdges := make(map[string]bool)
+	for _, edge := range graph.Edges {
+		if !core.IsIDValid(edge.ParentID) || !core.IsIDValid(edge.ChildID) {
+			addProblem(edge, "invalid page id")
+			continue
+		}
+		edge.Type, err = core.CorrectPagePairType(edge.Type)
+		if err != nil {
+			addProblem(edge, "incorrect type")
+			continue
+		}
+		if edge.ParentID == edge.ChildID &&
+			edge.Type != core.SubjectPagePairType &&
+			edge.Type != core.RequirementPagePairType {
+			addProblem(edge, "parent equals child")
+			continue
+		}
+		edgeKey := edge.ParentID + ":" + edge.ChildID + ":" + edge.Type
+		if seenEdges[edgeKey] {
+			continue
+		}
+		seenEdges[edgeKey] = true
+
+		var existing *core.PagePair
+		queryPart := database.NewQuery(`WHERE pp.parentId=? AND pp.childId=? AND pp.type=?`, edge.ParentID, edge.ChildID, edge.Type)
+		err = core.LoadPagePairs(db, queryPart, func(db *database.DB, pp *core.PagePair) error {
+			existing = pp
+			return nil
+		})
+		if err != nil {
+			return pages.Fail("Failed to check for existing page pair", err)
+		} else if existing == nil {
+			newEdges = append(newEdges, edge)
+		} else if existing.Level != edge.Level || existing.IsStrong != edge.IsStrong {
+			changedEdges = append(changedEdges, fmt.Sprintf("%s %s->%s: exists with level %d, isStrong %v",
+				edge.Type, edge.ParentID, edge.ChildID, existing.Level, existing.IsStrong))
+		}
+	}
+
+	// Check that the new parent relationships don't create a cycle, either
+	// among themselves or with the existing ones. Any such cycle goes from the
+	// parent of a new edge up through its existing ancestors.
+	newParentIDs := make([]string, 0)
+	for _, edge := range newEdges {
+		if edge.Type == core.ParentPagePairType {
+			newParentIDs = append(newParentIDs, edge.ParentID)
+		}
+	}
+	if len(newParentIDs) > 0 {
+		existingEdges, err := core.LoadAncestorParentEdges(db, newParentIDs)
+		if err != nil {
+			return pages.Fail("Failed to load existing parents", err)
+		}
+		if cycle := core.FindParentCycle(append(existingEdges, newEdges...)); cycle != nil {
+			problems = append(problems, fmt.Sprintf("parent relationships form a cycle: %s", strings.Join(cycle, ",")))
+		}
+	}
+
+	// Check edit permissions
+	editLoadOptions := &core.LoadEditOptions{
+		LoadNonliveEdit: true,
+		PreferLiveEdit:  true,
+	}
+	pageMap := make(map[string]*core.Page)
+	loadPage := func(pageID string) (*core.Page, error) {
+		if page, ok := pageMap[pageID]; ok {
+			return page, nil
+		}
+		page, err := core.LoadFullEdit(db, pageID, u, returnData.DomainMap, editLoadOptions)
+		pageMap[pageID] = page
+		return page, err
+	}
+	for _, edge := range newEdges {
+		parent, err := loadPage(edge.ParentID)
+		if err != nil {
+			return pages.Fail("Error while loading parent page", err)
+		}
+		child, err := loadPage(edge.ChildID)
+		if err != nil {
+			return pages.Fail("Error while loading child page", err)
+		}
+		if parent == nil || child == nil {
+			addProblem(edge, "page doesn't exist")
+			continue
+		}
+		permissionError, err := core.CanAffectRelationship(c, parent, child, edge.Type)
+		if err != nil {
+			return pages.Fail("Error verifying permissions", err)
+		} else if permissionError != "" {
+			addProblem(edge, permissionError)
+		}
+	}
+
+	if len(problems) > 0 {
+		if len(problems) > maxImportGraphErrors {
+			problems = append(problems[:maxImportGraphErrors], fmt.Sprintf("and %d more", len(problems)-maxImportGraphErrors))
+		}
+		return pages.Fail("Couldn't import the graph: "+strings.Join(problems, "; "), nil).Status(http.StatusBadRequest)
+	}
+
+	// Do it!
+	pagePairIDs := make([]string, 0)
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		for _, edge := range newEdges {
+			pagePairID, err := core.CreateNewPagePair(db, u, &core.CreateNewPagePairOptions{
+				ParentID: edge.ParentID,
+				ChildID:  edge.ChildID,
+				Type:     edge.Type,
+				Level:    edge.Level,
+				IsStrong: edge.IsStrong,
+				Tx:       tx,
+			})
+			if err != nil {
+				return sessions.NewError("Couldn't insert pagePair", err)
+			}
+			pagePairIDs = append(pagePairIDs, pagePairID)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	}
+
+	// Only publish the relationships once they are all committed
+	for _, pagePairID := range pagePairIDs {
+		var task tasks.PublishPagePairTask
+		task.UserID = u.ID
+		task.PagePairID = pagePairID
+		if err := tasks.Enqueue(c, &task, nil); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
+
+	returnData.ResultMap["createdCount"] = len(newEdges)
+	returnData.ResultMap["skippedCount"] = len(graph.Edges) - len(newEdges) - len(changedEdges)
+	returnData.ResultMap["changedEdges"] = changedEdges
+	return pages.Success(returnData)
+}
@@ -75,7 +75,12 @@ func init() {
 	s.HandleFunc(approveCommentHandler.URI, handlerWrapper(approveCommentHandler)).Methods("POST")
 	s.HandleFunc(approvePageToDomainHandler.URI, handlerWrapper(approvePageToDomainHandler)).Methods("POST")
 	s.HandleFunc(approvePageEditProposalHandler.URI, handlerWrapper(approvePageEditProposalHandler)).Methods("POST")
+	s.HandleFunc(authorRevertPreviewHandler.URI, handlerWrapper(authorRevertPreviewHandler)).Methods("POST")
+	s.HandleFunc(autocompleteHandler.URI, handlerWrapper(autocompleteHandler)).Methods("POST")
 	s.HandleFunc(bellUpdatesHandler.URI, handlerWrapper(bellUpdatesHandler)).Methods("POST")
+	s.HandleFunc(bulkEditHandler.URI, handlerWrapper(bulkEditHandler)).Methods("POST")
+	s.HandleFunc(bulkEditPreviewHandler.URI, handlerWrapper(bulkEditPreviewHandler)).Methods("POST")
+	s.HandleFunc(cancelScheduledPublishHandler.URI, handlerWrapper(cancelScheduledPublishHandler)).Methods("POST")
 	s.HandleFunc(changeSpeedHandler.URI, handlerWrapper(changeSpeedHandler)).Methods("POST")
 	s.HandleFunc(childrenHandler.URI, handlerWrapper(childrenHandler)).Methods("POST")
 	s.HandleFunc(commentThreadHandler.URI, handlerWrapper(commentThreadHandler)).Methods("POST")
@@ -89,33 +94,50 @@ func init() {
 	s.HandleFunc(deletePagePairHandler.URI, handlerWrapper(deletePagePairHandler)).Methods("POST")
 	s.HandleFunc(deletePathPageHandler.URI, handlerWrapper(deletePathPageHandler)).Methods("POST")
 	s.HandleFunc(deleteSearchStringHandler.URI, handlerWrapper(deleteSearchStringHandler)).Methods("POST")
+	s.HandleFunc(deleteSearchSynonymHandler.URI, handlerWrapper(deleteSearchSynonymHandler)).Methods("POST")
+	s.HandleFunc(diffEditsHandler.URI, handlerWrapper(diffEditsHandler)).Methods("POST")
 	s.HandleFunc(discardPageHandler.URI, handlerWrapper(discardPageHandler)).Methods("POST")
 	s.HandleFunc(discussionModeHandler.URI, handlerWrapper(discussionModeHandler)).Methods("POST")
 	s.HandleFunc(dismissUpdateHandler.URI, handlerWrapper(dismissUpdateHandler)).Methods("POST")
 	s.HandleFunc(domainPageHandler.URI, handlerWrapper(domainPageHandler)).Methods("POST")
 	s.HandleFunc(domainsPageHandler.URI, handlerWrapper(domainsPageHandler)).Methods("POST")
+	s.HandleFunc(editCompactionHandler.URI, handlerWrapper(editCompactionHandler)).Methods("POST")
 	s.HandleFunc(editHandler.URI, handlerWrapper(editHandler)).Methods("POST")
 	s.HandleFunc(editPageHandler.URI, handlerWrapper(editPageHandler)).Methods("POST")
 	s.HandleFunc(editPageInfoHandler.URI, handlerWrapper(editPageInfoHandler)).Methods("POST")
+	s.HandleFunc(editProposalHandler.URI, handlerWrapper(editProposalHandler)).Methods("POST")
+	s.HandleFunc(editSessionHandler.URI, handlerWrapper(editSessionHandler)).Methods("POST")
+	s.HandleFunc(editSessionOpHandler.URI, handlerWrapper(editSessionOpHandler)).Methods("POST")
+	s.HandleFunc(editSessionPollHandler.URI, handlerWrapper(editSessionPollHandler)).Methods("POST")
+	s.HandleFunc(evaluateSearchRankingHandler.URI, handlerWrapper(evaluateSearchRankingHandler)).Methods("POST")
 	s.HandleFunc(exploreHandler.URI, handlerWrapper(exploreHandler)).Methods("POST")
+	s.HandleFunc(exportRequisiteGraphHandler.URI, handlerWrapper(exportRequisiteGraphHandler)).Methods("POST")
 	s.HandleFunc(externalUrlHandler.URI, handlerWrapper(externalUrlHandler)).Methods("POST")
 	s.HandleFunc(feedbackHandler.URI, handlerWrapper(feedbackHandler)).Methods("POST")
 	s.HandleFunc(feedPageHandler.URI, handlerWrapper(feedPageHandler)).Methods("POST")
 	s.HandleFunc(forgotPasswordHandler.URI, handlerWrapper(forgotPasswordHandler)).Methods("POST")
+	s.HandleFunc(forkPageHandler.URI, handlerWrapper(forkPageHandler)).Methods("POST")
+	s.HandleFunc(forkPathHandler.URI, handlerWrapper(forkPathHandler)).Methods("POST")
+	s.HandleFunc(gradeMasteryReviewHandler.URI, handlerWrapper(gradeMasteryReviewHandler)).Methods("POST")
 	s.HandleFunc(hedonsModeHandler.URI, handlerWrapper(hedonsModeHandler)).Methods("POST")
+	s.HandleFunc(importRequisiteGraphHandler.URI, handlerWrapper(importRequisiteGraphHandler)).Methods("POST")
 	s.HandleFunc(indexHandler.URI, handlerWrapper(indexHandler)).Methods("POST")
 	s.HandleFunc(intrasitePopoverHandler.URI, handlerWrapper(intrasitePopoverHandler)).Methods("POST")
 	s.HandleFunc(learnHandler.URI, handlerWrapper(learnHandler)).Methods("POST")
+	s.HandleFunc(leaveEditSessionHandler.URI, handlerWrapper(leaveEditSessionHandler)).Methods("POST")
 	s.HandleFunc(lensHandler.URI, handlerWrapper(lensHandler)).Methods("POST")
 	s.HandleFunc(loginHandler.URI, handlerWrapper(loginHandler)).Methods("POST")
 	s.HandleFunc(logoutHandler.URI, handlerWrapper(logoutHandler)).Methods("POST")
 	s.HandleFunc(mailchimpSignupHandler.URI, handlerWrapper(mailchimpSignupHandler)).Methods("POST")
 	s.HandleFunc(maintenanceModeHandler.URI, handlerWrapper(maintenanceModeHandler)).Methods("POST")
 	s.HandleFunc(marksHandler.URI, handlerWrapper(marksHandler)).Methods("POST")
+	s.HandleFunc(masteryReviewsHandler.URI, handlerWrapper(masteryReviewsHandler)).Methods("POST")
+	s.HandleFunc(mergePagesHandler.URI, handlerWrapper(mergePagesHandler)).Methods("POST")
 	s.HandleFunc(mergeQuestionsHandler.URI, handlerWrapper(mergeQuestionsHandler)).Methods("POST")
 	s.HandleFunc(moreRelationshipsHandler.URI, handlerWrapper(moreRelationshipsHandler)).Methods("POST")
 	s.HandleFunc(mostTodosHandler.URI, handlerWrapper(mostTodosHandler)).Methods("POST")
 	s.HandleFunc(newAnswerHandler.URI, handlerWrapper(newAnswerHandler)).Methods("POST")
+	s.HandleFunc(newEditProposalCommentHandler.URI, handlerWrapper(newEditProposalCommentHandler)).Methods("POST")
 	s.HandleFunc(newFeedPageHandler.URI, handlerWrapper(newFeedPageHandler)).Methods("POST")
 	s.HandleFunc(newInviteHandler.URI, handlerWrapper(newInviteHandler)).Methods("POST")
 	s.HandleFunc(newLensHandler.URI, handlerWrapper(newLensHandler)).Methods("POST")
@@ -127,15 +149,21 @@ func init() {
 	s.HandleFunc(newPagePairHandler.URI, handlerWrapper(newPagePairHandler)).Methods("POST")
 	s.HandleFunc(newPathPageHandler.URI, handlerWrapper(newPathPageHandler)).Methods("POST")
 	s.HandleFunc(newSearchStringHandler.URI, handlerWrapper(newSearchStringHandler)).Methods("POST")
+	s.HandleFunc(newSearchSynonymHandler.URI, handlerWrapper(newSearchSynonymHandler)).Methods("POST")
 	s.HandleFunc(newVoteHandler.URI, handlerWrapper(newVoteHandler)).Methods("POST")
 	s.HandleFunc(newsletterHandler.URI, handlerWrapper(newsletterHandler)).Methods("POST")
 	s.HandleFunc(pagesWithDraftHandler.URI, handlerWrapper(pagesWithDraftHandler)).Methods("POST")
+	s.HandleFunc(pageTemplatesHandler.URI, handlerWrapper(pageTemplatesHandler)).Methods("POST")
 	s.HandleFunc(parentsHandler.URI, handlerWrapper(parentsHandler)).Methods("POST")
 	s.HandleFunc(parentsSearchHandler.URI, handlerWrapper(parentsSearchHandler)).Methods("POST")
+	s.HandleFunc(pathAnalyticsHandler.URI, handlerWrapper(pathAnalyticsHandler)).Methods("POST")
+	s.HandleFunc(pathGroupProgressHandler.URI, handlerWrapper(pathGroupProgressHandler)).Methods("POST")
+	s.HandleFunc(pendingEditProposalsHandler.URI, handlerWrapper(pendingEditProposalsHandler)).Methods("POST")
 	s.HandleFunc(pendingModeHandler.URI, handlerWrapper(pendingModeHandler)).Methods("POST")
 	s.HandleFunc(primaryPageHandler.URI, handlerWrapper(primaryPageHandler)).Methods("POST")
 	s.HandleFunc(projectHandler.URI, handlerWrapper(projectHandler)).Methods("POST")
 	s.HandleFunc(projectsHandler.URI, handlerWrapper(projectsHandler)).Methods("POST")
+	s.HandleFunc(quizStatsHandler.URI, handlerWrapper(quizStatsHandler)).Methods("POST")
 	s.HandleFunc(readModeHandler.URI, handlerWrapper(readModeHandler)).Methods("POST")
 	s.HandleFunc(recentChangesHandler.URI, handlerWrapper(recentChangesHandler)).Methods("POST")
 	s.HandleFunc(recentlyCreatedCommentHandler.URI, handlerWrapper(recentlyCreatedCommentHandler)).Methods("POST")
@@ -145,15 +173,25 @@ func init() {
 	s.HandleFunc(requisitesHandler.URI, handlerWrapper(requisitesHandler)).Methods("POST")
 	s.HandleFunc(resolveMarkHandler.URI, handlerWrapper(resolveMarkHandler)).Methods("POST")
 	s.HandleFunc(resolveThreadHandler.URI, handlerWrapper(resolveThreadHandler)).Methods("POST")
+	s.HandleFunc(revertAuthorEditsHandler.URI, handlerWrapper(revertAuthorEditsHandler)).Methods("POST")
 	s.HandleFunc(revertPageHandler.URI, handlerWrapper(revertPageHandler)).Methods("POST")
+	s.HandleFunc(reviewEditProposalHandler.URI, handlerWrapper(reviewEditProposalHandler)).Methods("POST")
+	s.HandleFunc(scheduledPublishesHandler.URI, handlerWrapper(scheduledPublishesHandler)).Methods("POST")
 	s.HandleFunc(searchHandler.URI, handlerWrapper(searchHandler)).Methods("POST")
+	s.HandleFunc(searchRankingHandler.URI, handlerWrapper(searchRankingHandler)).Methods("POST")
+	s.HandleFunc(searchSynonymsHandler.URI, handlerWrapper(searchSynonymsHandler)).Methods("POST")
 	s.HandleFunc(sendSlackInviteHandler.URI, handlerWrapper(sendSlackInviteHandler)).Methods("POST")
 	s.HandleFunc(settingsPageHandler.URI, handlerWrapper(settingsPageHandler)).Methods("POST")
 	s.HandleFunc(signupHandler.URI, handlerWrapper(signupHandler)).Methods("POST")
 	s.HandleFunc(similarPageSearchHandler.URI, handlerWrapper(similarPageSearchHandler)).Methods("POST")
+	s.HandleFunc(similarPagesHandler.URI, handlerWrapper(similarPagesHandler)).Methods("POST")
+	s.HandleFunc(splitPageHandler.URI, handlerWrapper(splitPageHandler)).Methods("POST")
+	s.HandleFunc(startBulkEditHandler.URI, handlerWrapper(startBulkEditHandler)).Methods("POST")
+	s.HandleFunc(startEditCompactionHandler.URI, handlerWrapper(startEditCompactionHandler)).Methods("POST")
 	s.HandleFunc(startPathHandler.URI, handlerWrapper(startPathHandler)).Methods("POST")
 	s.HandleFunc(titleHandler.URI, handlerWrapper(titleHandler)).Methods("POST")
 	s.HandleFunc(unassessedPagesHandler.URI, handlerWrapper(unassessedPagesHandler)).Methods("POST")
+	s.HandleFunc(undoBulkEditHandler.URI, handlerWrapper(undoBulkEditHandler)).Methods("POST")
 	s.HandleFunc(updateDomainHandler.URI, handlerWrapper(updateDomainHandler)).Methods("POST")
 	s.HandleFunc(updateDomainRoleHandler.URI, handlerWrapper(updateDomainRoleHandler)).Methods("POST")
 	s.HandleFunc(updateLensNameHandler.URI, handlerWrapper(updateLensNameHandler)).Methods("POST")
@@ -164,8 +202,12 @@ func init() {
 	s.HandleFunc(updateMemberHandler.URI, handlerWrapper(updateMemberHandler)).Methods("POST")
 	s.HandleFunc(updatePageObjectHandler.URI, handlerWrapper(updatePageObjectHandler)).Methods("POST")
 	s.HandleFunc(updatePagePairHandler.URI, handlerWrapper(updatePagePairHandler)).Methods("POST")
+	s.HandleFunc(updatePageTemplateHandler.URI, handlerWrapper(updatePageTemplateHandler)).Methods("POST")
 	s.HandleFunc(updatePathOrderHandler.URI, handlerWrapper(updatePathOrderHandler)).Methods("POST")
 	s.HandleFunc(updatePathHandler.URI, handlerWrapper(updatePathHandler)).Methods("POST")
+	s.HandleFunc(updatePathPageHandler.URI, handlerWrapper(updatePathPageHandler)).Methods("POST")
+	s.HandleFunc(updateQuizHandler.URI, handlerWrapper(updateQuizHandler)).Methods("POST")
+	s.HandleFunc(updateSearchRankingHandler.URI, handlerWrapper(updateSearchRankingHandler)).Methods("POST")
 	s.HandleFunc(updateSettingsHandler.URI, handlerWrapper(updateSettingsHandler)).Methods("POST")
 	s.HandleFunc(updateSubscriptionHandler.URI, handlerWrapper(updateSubscriptionHandler)).Methods("POST")
 	s.HandleFunc(userPopoverHandler.URI, handlerWrapper(userPopoverHandler)).Methods("POST")
@@ -174,6 +216,8 @@ func init() {
 
 	// Admin stuff
 	s.HandleFunc(adminTaskHandler.URI, handlerWrapper(adminTaskHandler)).Methods("GET")
+	s.HandleFunc(searchIndexCheckHandler.URI, handlerWrapper(searchIndexCheckHandler)).Methods("POST")
+	s.HandleFunc(startSearchIndexCheckHandler.URI, handlerWrapper(startSearchIndexCheckHandler)).Methods("POST")
 
 	// Various internal handlers
 	s.HandleFunc("/mon", reportMonitoring).Methods("POST")
@@ -6,12 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"sort"
 	"strings"
 
 	"zanaduu3/src/core"
 	"zanaduu3/src/database"
-	"zanaduu3/src/logger"
+	"zanaduu3/src/learning"
 	"zanaduu3/src/pages"
 )
 
@@ -24,13 +23,10 @@ type learnJSONData struct {
 	PageAliases []string
 	// If set, only learn pages that are marked as wanted
 	OnlyWanted bool
+	// Level at which to learn the pages. Defaults to the lowest mastery level.
+	Level int
 }
 
-const (
-	PenaltyCost = 10000
-	LensCost    = 10
-)
-
 type learnOption struct {
 	// If true, the page will be appended in the path after its requisites are learned
 	AppendToPath bool `json:"appendToPath"`
@@ -38,58 +34,9 @@ type learnOption struct {
 	MustBeWanted bool `json:"mustBeWanted"`
 }
 
-// Requirement the user needs to acquire in order to read a tutor page
-type requirementNode struct {
-	PageID    string `json:"pageId"`
-	LensIndex int    `json:"-"`
-	// Which pages can teach this requirement
-	TutorIDs []string `json:"tutorIds"`
-	// Best tutor
-	BestTutorID string `json:"bestTutorId"`
-	// Cost assigned to learning this node
-	Cost int `json:"cost"`
-	// Set to true when the node has been processed
-	Processed bool `json:"-"`
-}
-
-// Page that will teach the user about stuff.
-type tutorNode struct {
-	PageID    string `json:"pageId"`
-	LensIndex int    `json:"-"`
-	// To read this page, the user needs these requirements
-	RequirementIDs []string `json:"requirementIds"`
-	// Cost assigned to learning this node
-	Cost int `json:"cost"`
-	// Set to true when the node has been processed
-	Processed bool `json:"-"`
-	// Set when a requsite doesn't have a tutor, so we pretend it teaches itself.
-	MadeUp bool `json:"madeUp"`
-
-	// Need to set this map for sorting to work
-	RequirementMap map[string]*requirementNode `json:"-"`
-}
-
-// Sort node's requirements
-func (t *tutorNode) Len() int { return len(t.RequirementIDs) }
-func (t *tutorNode) Swap(i, j int) {
-	t.RequirementIDs[i], t.RequirementIDs[j] = t.RequirementIDs[j], t.RequirementIDs[i]
-}
-func (t *tutorNode) Less(i, j int) bool {
-	return t.RequirementMap[t.RequirementIDs[i]].Cost < t.RequirementMap[t.RequirementIDs[j]].Cost
-}
-
-func newRequirementNode(pageID string) *requirementNode {
-	return &requirementNode{PageID: pageID, TutorIDs: make([]string, 0), Cost: 10000000}
-}
-
-func newTutorNode(pageID string) *tutorNode {
-	return &tutorNode{PageID: pageID, RequirementIDs: make([]string, 0)}
-}
-
 func learnJSONHandler(params *pages.HandlerParams) *pages.Result {
 	u := params.U
 	db := params.DB
-	c := params.C
 	returnData := core.NewHandlerData(u).SetResetEverything()
 
 	// Decode data
@@ -141,7 +88,7 @@ func learnJSONHandler(params *pages.HandlerParams) *pages.Result {
 		optionsMap[pageID] = aliasOptionsMap[alias]
 	}
 
-	// Remove requirements that the user already has
+	// Remove pages the user doesn't want to learn
 	masteryMap := make(map[string]*core.Mastery)
 	userID := u.GetSomeID()
 	if len(pageIDs) > 0 && userID != "" {
@@ -163,298 +110,66 @@ func learnJSONHandler(params *pages.HandlerParams) *pages.Result {
 			return pages.Fail("Error while checking if already knows", err)
 		}
 	}
+	masteryLevels, err := learning.LoadMasteryLevels(db, userID)
+	if err != nil {
+		return pages.Fail("Couldn't load mastery levels", err)
+	}
 
 	// What to load for the pages
 	loadOptions := (&core.PageLoadOptions{
 		Tags: true,
 	}).Add(core.TitlePlusLoadOptions)
 
-	// Track which requirements we need to process in the next step
-	requirementIDs := make([]string, 0)
+	targets := make([]*learning.Target, 0)
+	targetIDs := make([]string, 0)
 	for _, pageID := range pageIDs {
 		core.AddPageToMap(pageID, returnData.PageMap, loadOptions)
 		mastery, ok := masteryMap[pageID]
-		add := !ok || !mastery.Has
 		if ok && optionsMap[pageID].MustBeWanted && !mastery.Wants {
-			add = false
-		}
-		if add {
-			requirementIDs = append(requirementIDs, pageID)
+			continue
 		}
+		targets = append(targets, &learning.Target{PageID: pageID, Level: data.Level})
+		targetIDs = append(targetIDs, pageID)
 	}
-	// Leave only the page ids we need to process
-	pageIDs = append(make([]string, 0), requirementIDs...)
 
-	// Which tutor pages to load in the next step
-	tutorIDs := make([]string, 0)
-
-	// Create the maps which will store all the nodes: page id -> node
-	tutorMap := make(map[string]*tutorNode)
-	requirementMap := make(map[string]*requirementNode)
-	for _, reqID := range requirementIDs {
-		requirementMap[reqID] = newRequirementNode(reqID)
+	// Find which pages the user has to read
+	graph, err := learning.LoadGraph(db, u, targetIDs, masteryLevels)
+	if err != nil {
+		return pages.Fail("Couldn't load the requisite graph", err)
 	}
-
-	// Add a tutor for the tutorMap
-	var addTutor = func(parentID, childID string, lensIndex int) {
-		// Get the requirement node and update its tutors
-		requirementNode := requirementMap[parentID]
-		requirementNode.TutorIDs = append(requirementNode.TutorIDs, childID)
-		c.Infof("Updated requirement node: %+v", requirementNode)
-		// Recursively load requirements for the tutor, unless we already processed it
-		if _, ok := tutorMap[childID]; !ok {
-			tutorIDs = append(tutorIDs, childID)
-			tutorMap[childID] = newTutorNode(childID)
-			tutorMap[childID].LensIndex = lensIndex
-		}
+	plan := learning.NewPlan(graph, targets, masteryLevels)
+	for pageID := range plan.RequirementMap {
+		core.AddPageToMap(pageID, returnData.PageMap, loadOptions)
 	}
-
-	// Recursively find which pages the user has to read
-	for maxCount := 0; len(requirementIDs) > 0 && maxCount < 20; maxCount++ {
-		c.Infof("RequirementIds: %+v", requirementIDs)
-		// Load which pages teach the requirements
-		tutorIDs = make([]string, 0)
-		rows := database.NewQuery(`
-			SELECT pp.parentId,pp.childId,IFNULL(l.lensIndex,0)
-			FROM pagePairs AS pp
-			JOIN pageInfos AS pi
-			ON (pp.childId=pi.pageId)
-			LEFT JOIN lenses AS l
-			ON (pi.pageId=l.lensId)
-			WHERE pp.parentId IN`).AddArgsGroupStr(requirementIDs).Add(`
-				AND pp.type=?`, core.SubjectPagePairType).Add(`
-				AND`).AddPart(core.PageInfosFilter(u)).ToStatement(db).Query()
-		err = rows.Process(func(db *database.DB, rows *database.Rows) error {
-			var parentID, childID string
-			var lensIndex int
-			err := rows.Scan(&parentID, &childID, &lensIndex)
-			if err != nil {
-				return fmt.Errorf("Failed to scan: %v", err)
-			}
-			c.Infof("Found tutor: %s %s", parentID, childID)
-			addTutor(parentID, childID, lensIndex)
-			return nil
-		})
-		if err != nil {
-			return pages.Fail("Error while loading tutors", err)
-		}
-		// If we haven't found a tutor for a page we want to learn, we'll pretend
-		// that the page can teach itself.
-		for reqID, requirementNode := range requirementMap {
-			_, ok := tutorMap[reqID]
-			if len(requirementNode.TutorIDs) <= 0 && !ok {
-				c.Infof("No tutor found for %s, so we are making it teach itself.", reqID)
-				addTutor(reqID, reqID, 0)
-				tutorMap[reqID].MadeUp = true
-			}
-		}
-		c.Infof("TutorIds: %+v", tutorIDs)
-		if len(tutorIDs) <= 0 {
-			break
-		}
-
-		// Load the requirements for the tutors
-		requirementIDs = make([]string, 0)
-		rows = database.NewQuery(`
-			SELECT pp.parentId,pp.childId,IFNULL(l.lensIndex,0)
-			FROM pagePairs AS pp
-			JOIN pageInfos AS pi
-			ON (pp.parentId=pi.pageId)
-			LEFT JOIN userMasteryPairs AS mp
-			ON (pp.parentId=mp.masteryId AND mp.userId=?)`, userID).Add(`
-			LEFT JOIN lenses AS l
-			ON (pi.pageId=l.lensId)
-			WHERE pp.childId IN`).AddArgsGroupStr(tutorIDs).Add(`
-				AND pp.type=?`, core.RequirementPagePairType).Add(`
-				AND (NOT mp.has OR ISNULL(mp.has))
-				AND`).AddPart(core.PageInfosFilter(u)).ToStatement(db).Query()
-		err = rows.Process(func(db *database.DB, rows *database.Rows) error {
-			var parentID, childID string
-			var lensIndex int
-			err := rows.Scan(&parentID, &childID, &lensIndex)
-			if err != nil {
-				return fmt.Errorf("Failed to scan: %v", err)
-			}
-			c.Infof("Found requirement: %s %s", parentID, childID)
-
-			// Get the tutor node and update its requirements
-			tutorNode := tutorMap[childID]
-			tutorNode.RequirementIDs = append(tutorNode.RequirementIDs, parentID)
-			c.Infof("Updated tutor node: %+v", tutorNode)
-			if _, ok := requirementMap[parentID]; !ok {
-				requirementIDs = append(requirementIDs, parentID)
-				requirementMap[parentID] = newRequirementNode(parentID)
-				requirementMap[parentID].LensIndex = lensIndex
-			}
-			return nil
-		})
-		if err != nil {
-			return pages.Fail("Error while loading requirements", err)
-		}
-		if maxCount >= 15 {
-			c.Warningf("Max count is close to maximum: %d", maxCount)
+	for pageID := range plan.TutorMap {
+		core.AddPageToMap(pageID, returnData.PageMap, loadOptions)
+	}
+	// Leave only the page ids we need to process
+	pageIDs = make([]string, 0)
+	for _, pageID := range targetIDs {
+		if _, ok := plan.RequirementMap[pageID]; ok {
+			pageIDs = append(pageIDs, pageID)
 		}
 	}
 
-	computeLearningPath(c, pageIDs, requirementMap, tutorMap, loadOptions, returnData)
-
 	// Load pages
 	err = core.ExecuteLoadPipeline(db, returnData)
 	if err != nil {
 		return pages.Fail("Pipeline error", err)
 	}
 
-	returnData.ResultMap["tutorMap"] = tutorMap
-	returnData.ResultMap["requirementMap"] = requirementMap
+	returnData.ResultMap["tutorMap"] = plan.TutorMap
+	returnData.ResultMap["requirementMap"] = plan.RequirementMap
+	returnData.ResultMap["steps"] = plan.Steps
 	returnData.ResultMap["pageIds"] = pageIDs
 	returnData.ResultMap["optionsMap"] = optionsMap
-	return pages.Success(returnData)
-}
-
-func computeLearningPath(pl logger.Logger,
-	pageIDs []string,
-	requirementMap map[string]*requirementNode,
-	tutorMap map[string]*tutorNode,
-	loadOptions *core.PageLoadOptions,
-	returnData *core.CommonHandlerData) {
-
-	pl.Infof("================ COMPUTING LEARNING PATH  ==================")
-
-	// Mark all requirements with no teachers as processed. Also set the initial cost.
-	for _, req := range requirementMap {
-		req.Cost = 10000000
-		if len(req.TutorIDs) > 0 {
-			continue
-		}
-		req.Cost = PenaltyCost
-		req.Processed = true
-		core.AddPageToMap(req.PageID, returnData.PageMap, loadOptions)
-		pl.Infof("Requirement '%s' pre-processed with cost %d", req.PageID, req.Cost)
-	}
-
-	done := false
-	graphChanged := true
-	// Keep processing all the nodes until we processed the node we want to learn
-	for !done {
-		if !graphChanged {
-			// We didn't make any progress in the last iteration, which means there is
-			// a cycle. Arbitrarily mark a requirement as processed.
-			for _, req := range requirementMap {
-				if req.Processed {
-					continue
-				}
 
-				// Print the cycle, but also find a node that's actually definitely in the cycle
-				cycleIDs := make([]string, 0)
-				cycleIDs = append(cycleIDs, req.PageID)
-				cycleReqMap := make(map[string]bool) // store all requirements we've met
-				cycleReqMap[req.PageID] = true
-				continueCycle := true
-				for continueCycle {
-					// Get first eligible tutor
-					var cycleTutor *tutorNode
-					for _, tutorID := range req.TutorIDs {
-						cycleTutor = tutorMap[tutorID]
-						if !cycleTutor.Processed {
-							cycleIDs = append(cycleIDs, cycleTutor.PageID)
-							break
-						}
-					}
-					// Get first eligible requirement
-					for _, reqID := range cycleTutor.RequirementIDs {
-						req = requirementMap[reqID]
-						if !req.Processed {
-							cycleIDs = append(cycleIDs, req.PageID)
-							if _, ok := cycleReqMap[req.PageID]; ok {
-								continueCycle = false
-							} else {
-								cycleReqMap[req.PageID] = true
-							}
-							break
-						}
-					}
-				}
-				pl.Infof("CYCLE: %v", cycleIDs)
-
-				// Force the picked requirement to be processed
-				req.Processed = true
-				if req.BestTutorID == "" {
-					if len(req.TutorIDs) > 0 {
-						// Just take the first tutor
-						req.BestTutorID = req.TutorIDs[0]
-					}
-					req.Cost = PenaltyCost
-				}
-				req.Cost += req.LensIndex * LensCost
-				core.AddPageToMap(req.PageID, returnData.PageMap, loadOptions)
-				pl.Infof("Requirement '%s' (tutors: %v) forced to processed with cost %d and best tutor '%s'", req.PageID, req.TutorIDs, req.Cost, req.BestTutorID)
-				break
-			}
-		}
-
-		graphChanged = false
-		// Process all requirements
-		for _, req := range requirementMap {
-			if req.Processed {
-				continue
-			}
-			// We can mark a requirement processed when we processed all its tutors
-			allTutorsProcessed := true
-			for _, tutorID := range req.TutorIDs {
-				tutor := tutorMap[tutorID]
-				if !tutor.Processed {
-					allTutorsProcessed = false
-					continue
-				}
-				if req.Cost > tutor.Cost {
-					req.Cost = tutor.Cost
-					req.BestTutorID = tutorID
-				}
-			}
-			if allTutorsProcessed {
-				req.Cost += req.LensIndex * LensCost
-				req.Processed = true
-				graphChanged = true
-				core.AddPageToMap(req.PageID, returnData.PageMap, loadOptions)
-				pl.Infof("Requirement '%s' (tutors: %v) processed with cost %d and best tutor '%s'", req.PageID, req.TutorIDs, req.Cost, req.BestTutorID)
-			}
-		}
-
-		// Process all tutors
-		for _, tutor := range tutorMap {
-			if tutor.Processed {
-				continue
-			}
-			// We can mark a tutor processed when we processed all its requirements
-			allReqsProcessed := true
-			tutor.Cost = 0
-			for _, reqID := range tutor.RequirementIDs {
-				requirement := requirementMap[reqID]
-				if !requirement.Processed {
-					allReqsProcessed = false
-					continue
-				}
-				tutor.Cost += requirement.Cost
-			}
-			if allReqsProcessed {
-				tutor.Cost += tutor.LensIndex * LensCost
-				tutor.Processed = true
-				tutor.Cost++
-				tutor.RequirementMap = requirementMap
-				sort.Sort(tutor)
-				graphChanged = true
-				core.AddPageToMap(tutor.PageID, returnData.PageMap, loadOptions)
-				pl.Infof("Tutor '%s' processed with cost %d and reqs %v", tutor.PageID, tutor.Cost, tutor.RequirementIDs)
-			}
-		}
-
-		// Check if we are done
-		done = true
-		for _, pageID := range pageIDs {
-			if !requirementMap[pageID].Processed {
-				done = false
-				break
-			}
-		}
+	// Estimate how long the plan will take
+	estimateMap, err := core.LoadPageEstimates(db, plan.PageIDs())
+	if err != nil {
+		return pages.Fail("Couldn't load page estimates", err)
 	}
+	returnData.ResultMap["estimateMap"] = estimateMap
+	returnData.ResultMap["estimate"] = core.TotalPageEstimates(plan.PageIDs(), estimateMap)
+	return pages.Success(returnData)
 }
@@ -0,0 +1,54 @@
+// leaveEditSessionHandler.go removes the current user from a collaborative
+// editing session.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+// leaveEditSessionData contains parameters passed in via the request.
+type leaveEditSessionData struct {
+	PageID string
+}
+
+var leaveEditSessionHandler = siteHandler{
+	URI:         "/leaveEditSession/",
+	HandlerFunc: leaveEditSessionHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// leaveEditSessionHandlerFunc handles the request.
+func leaveEditSessionHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+
+	// Decode data
+	var data leaveEditSessionData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.PageID) {
+		return pages.Fail("Invalid page id", nil).Status(http.StatusBadRequest)
+	}
+
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		if err := core.DeleteEditSessionPresence(tx, u, data.PageID); err != nil {
+			return sessions.NewError("Couldn't leave the session", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	}
+	return pages.Success(nil)
+}
@@ -7,8 +7,10 @@ import (
 	"net/http"
 
 	"zanaduu3/src/core"
+	"zanaduu3/src/database"
 	"zanaduu3/src/okta"
 	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
 )
 
 // loginHandlerData is the data received from the request.
@@ -57,6 +59,15 @@ func loginHandlerInternalFunc(params *pages.HandlerParams, data *loginHandlerDat
 
 // Helper function for logging in the user
 func setUserInternalFunc(params *pages.HandlerParams, data *loginHandlerData) *pages.Result {
+	c := params.C
+	db := params.DB
+
+	// Remember the session the user had before logging in
+	var sessionID string
+	if params.U != nil && params.U.ID == "" {
+		sessionID = params.U.SessionID
+	}
+
 	// Set the cookie
 	_, _, err := core.SaveCookie(params.W, params.R, data.Email)
 	if err != nil {
@@ -64,11 +75,30 @@ func setUserInternalFunc(params *pages.HandlerParams, data *loginHandlerData) *p
 	}
 
 	// Load the user object from the cookie
-	params.U, err = core.LoadCurrentUser(params.W, params.R, params.DB)
+	params.U, err = core.LoadCurrentUser(params.W, params.R, db)
 	if err != nil {
 		return pages.Fail("Couldn't load user", err)
 	}
 
+	// Move what the user did while logged out into their account. This shouldn't
+	// stop them from logging in, so we only log the errors.
+	if sessionID != "" && params.U.ID != "" {
+		var result *core.SessionMergeResult
+		err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+			var err error
+			result, err = core.MergeSessionProgress(tx, sessionID, params.U.ID)
+			if err != nil {
+				return sessions.NewError("Couldn't merge session progress", err)
+			}
+			return nil
+		})
+		if err2 != nil {
+			c.Errorf("%v", sessions.ToError(err2))
+		} else {
+			c.Infof("Merged session progress into user %s: %+v", params.U.ID, result)
+		}
+	}
+
 	return pages.Success(core.NewHandlerData(params.U))
 }
 
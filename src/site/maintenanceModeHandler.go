@@ -44,6 +44,34 @@ func maintenanceModeHandlerFunc(params *pages.HandlerParams) *pages.Result {
 
 	returnData.ResultMap["modeRows"] = combineModeRows(data.NumPagesToLoad, rows)
 
+	// Load the latest requisite graph report for the domains the user reviews
+	check, err := core.LoadLatestRequisiteGraphCheck(db)
+	if err != nil {
+		return pages.Fail("Error loading requisite graph check", err)
+	} else if check != nil {
+		var domainIDs []string
+		if !u.IsAdmin {
+			domainIDs = make([]string, 0)
+			for domainID := range u.DomainMembershipMap {
+				if core.RoleAtLeast(u.GetDomainMembershipRole(domainID), core.ReviewerDomainRole) {
+					domainIDs = append(domainIDs, domainID)
+				}
+			}
+		}
+		findings, err := core.LoadRequisiteGraphCheckFindings(db, check.ID, domainIDs, FullModeRowCount)
+		if err != nil {
+			return pages.Fail("Error loading requisite graph findings", err)
+		}
+		for _, finding := range findings {
+			core.AddPageToMap(finding.PageID, returnData.PageMap, core.TitlePlusLoadOptions)
+			if finding.AuxPageID != "" {
+				core.AddPageToMap(finding.AuxPageID, returnData.PageMap, core.TitlePlusLoadOptions)
+			}
+		}
+		returnData.ResultMap["requisiteGraphCheck"] = check
+		returnData.ResultMap["requisiteGraphFindings"] = findings
+	}
+
 	// Load and update lastMaintenanceModeView for this user
 	returnData.ResultMap["lastView"], err = core.LoadAndUpdateLastView(db, u, core.LastMaintenanceModeView)
 	if err != nil {
@@ -0,0 +1,69 @@
+// masteryReviewsJsonHandler.go returns the masteries the user should review now.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+// masteryReviewsData contains parameters passed in via the request.
+type masteryReviewsData struct {
+	NumToLoad int
+}
+
+var masteryReviewsHandler = siteHandler{
+	URI:         "/json/masteryReviews/",
+	HandlerFunc: masteryReviewsHandlerFunc,
+	Options:     pages.PageOptions{},
+}
+
+// masteryReviewsHandlerFunc handles the request.
+func masteryReviewsHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	var data masteryReviewsData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if data.NumToLoad <= 0 {
+		data.NumToLoad = DefaultModeRowCount
+	}
+	userID := u.GetSomeID()
+	if userID == "" {
+		return pages.Fail("No user id or session id", nil).Status(http.StatusBadRequest)
+	}
+
+	reviews, err := core.LoadDueMasteryReviews(db, userID, data.NumToLoad)
+	if err != nil {
+		return pages.Fail("Couldn't load reviews", err)
+	}
+	err = core.LoadMasteryReviewPages(db, u, reviews)
+	if err != nil {
+		return pages.Fail("Couldn't load review pages", err)
+	}
+
+	// Load the masteries with their summaries, and the pages to review them with
+	masteryLoadOptions := (&core.PageLoadOptions{Summaries: true}).Add(core.TitlePlusLoadOptions)
+	for _, review := range reviews {
+		core.AddPageToMap(review.MasteryID, returnData.PageMap, masteryLoadOptions)
+		if review.ReviewPageID != "" {
+			core.AddPageToMap(review.ReviewPageID, returnData.PageMap, masteryLoadOptions)
+		}
+		if review.QuizPageID != "" {
+			core.AddPageToMap(review.QuizPageID, returnData.PageMap, core.TitlePlusLoadOptions)
+		}
+	}
+	returnData.ResultMap["reviews"] = reviews
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+	return pages.Success(returnData)
+}
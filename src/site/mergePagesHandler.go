@@ -0,0 +1,447 @@
+// mergePagesHandler.go merges one wiki page into another.
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+	"zanaduu3/src/tasks"
+)
+
+// mergePagesData is the data received from the request.
+type mergePagesData struct {
+	PageID     string
+	IntoPageID string
+	// If set, this text is appended to the text of the page we merge into
+	AppendText string
+}
+
+var mergePagesHandler = siteHandler{
+	URI:         "/mergePages/",
+	HandlerFunc: mergePagesHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// mergePagesHandlerFunc handles the request.
+func mergePagesHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	u := params.U
+	c := params.C
+	db := params.DB
+
+	decoder := json.NewDecoder(params.R.Body)
+	var data mergePagesData
+	err := decoder.Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode json", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.PageID) || !core.IsIDValid(data.IntoPageID) {
+		return pages.Fail("One of the ids is invalid", nil).Status(http.StatusBadRequest)
+	}
+	if data.PageID == data.IntoPageID {
+		return pages.Fail("Can't merge a page into itself", nil).Status(http.StatusBadRequest)
+	}
+
+	// Load the pages
+	domainMap := make(map[string]*core.Domain)
+	page, err := core.LoadFullEdit(db, data.PageID, u, domainMap, nil)
+	if err != nil {
+		return pages.Fail("Couldn't load the page", err)
+	}
+	intoPage, err := core.LoadFullEdit(db, data.IntoPageID, u, domainMap, nil)
+	if err != nil {
+		return pages.Fail("Couldn't load the page to merge into", err)
+	}
+	if page == nil || page.IsDeleted || page.Type != core.WikiPageType {
+		return pages.Fail("PageId isn't a wiki page", nil).Status(http.StatusBadRequest)
+	}
+	if intoPage == nil || intoPage.IsDeleted || intoPage.Type != core.WikiPageType {
+		return pages.Fail("IntoPageId isn't a wiki page", nil).Status(http.StatusBadRequest)
+	}
+	if !page.Permissions.Edit.Has {
+		return pages.Fail("Can't edit: "+page.Permissions.Edit.Reason, nil).Status(http.StatusForbidden)
+	}
+	if !page.Permissions.Delete.Has {
+		return pages.Fail("Can't delete: "+page.Permissions.Delete.Reason, nil).Status(http.StatusForbidden)
+	}
+	if !intoPage.Permissions.Edit.Has {
+		return pages.Fail("Can't edit: "+intoPage.Permissions.Edit.Reason, nil).Status(http.StatusForbidden)
+	}
+
+	var mergeChangeLogID int64
+	var permissionError string
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		changeLogs := make([]*core.ChangeLog, 0)
+
+		var pairChangeLogs []*core.ChangeLog
+		var err2 sessions.Error
+		pairChangeLogs, permissionError, err2 = mergePagePairsTx(tx, params, domainMap, page.PageID, intoPage)
+		if err2 != nil {
+			return err2
+		} else if permissionError != "" {
+			return sessions.NewError(permissionError, nil)
+		}
+		changeLogs = append(changeLogs, pairChangeLogs...)
+
+		lensChangeLogs, err2 := mergeLensesTx(tx, u, page.PageID, intoPage.PageID)
+		if err2 != nil {
+			return err2
+		}
+		changeLogs = append(changeLogs, lensChangeLogs...)
+
+		// Move the subscriptions
+		for _, table := range []string{core.DiscussionSubscriptionTable, core.MaintainerSubscriptionTable} {
+			statement := database.NewQuery(`
+				INSERT IGNORE INTO `+table+` (userId,toPageId,createdAt)
+				SELECT userId,?,createdAt`, intoPage.PageID).Add(`
+				FROM `+table+`
+				WHERE toPageId=?`, page.PageID).ToTxStatement(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't copy subscriptions", err)
+			}
+			statement = database.NewQuery(`
+				DELETE FROM `+table+`
+				WHERE toPageId=?`, page.PageID).ToTxStatement(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't delete subscriptions", err)
+			}
+		}
+
+		statement := database.NewQuery(`
+			UPDATE marks
+			SET resolvedPageId=?`, intoPage.PageID).Add(`
+			WHERE resolvedPageId=?`, page.PageID).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't update marks", err)
+		}
+
+		intoEdit := intoPage.Edit
+		if data.AppendText != "" {
+			newEdit, ok, err := core.SaveNewLiveEdit(tx, &core.NewLiveEditOptions{
+				PageID:        intoPage.PageID,
+				CreatorID:     u.ID,
+				ExpectedEdit:  intoPage.Edit,
+				Title:         intoPage.Title,
+				Clickbait:     intoPage.Clickbait,
+				Text:          intoPage.Text + "\n\n" + data.AppendText,
+				MetaText:      intoPage.MetaText,
+				AnchorContext: intoPage.AnchorContext,
+				AnchorText:    intoPage.AnchorText,
+				AnchorOffset:  intoPage.AnchorOffset,
+				EditSummary:   "Merged " + page.PageID,
+				ChangeLogType: core.NewEditChangeLog,
+			})
+			if err != nil {
+				return sessions.NewError("Couldn't save the new edit", err)
+			} else if !ok {
+				return sessions.NewError("The page was changed in the meantime", nil)
+			}
+			intoEdit = newEdit
+		}
+
+		changeLogs = append(changeLogs, &core.ChangeLog{
+			PageID:    page.PageID,
+			UserID:    u.ID,
+			Edit:      page.Edit,
+			Type:      core.MergedIntoChangeLog,
+			AuxPageID: intoPage.PageID,
+		})
+		for _, changeLog := range changeLogs {
+			if _, err := core.InsertChangeLog(tx, changeLog); err != nil {
+				return sessions.NewError("Couldn't insert change log", err)
+			}
+		}
+		mergeChangeLogID, err = core.InsertChangeLog(tx, &core.ChangeLog{
+			PageID:    intoPage.PageID,
+			UserID:    u.ID,
+			Edit:      intoEdit,
+			Type:      core.MergePageChangeLog,
+			AuxPageID: page.PageID,
+		})
+		if err != nil {
+			return sessions.NewError("Couldn't insert change log", err)
+		}
+
+		statement = database.NewQuery(`
+			UPDATE pageInfos
+			SET mergedInto=?`, intoPage.PageID).Add(`
+			WHERE pageId=?`, page.PageID).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't update pageInfos", err)
+		}
+
+		// Delete the merged page
+		deletePageData := &deletePageData{
+			PageID:         page.PageID,
+			GenerateUpdate: false,
+		}
+		return deletePageTx(tx, params, deletePageData, page)
+	})
+	if permissionError != "" {
+		return pages.Fail(permissionError, nil).Status(http.StatusForbidden)
+	} else if err2 != nil {
+		return pages.FailWith(err2)
+	}
+
+	// Generate "merge" update for users who are subscribed to the page now
+	var updateTask tasks.NewUpdateTask
+	updateTask.UserID = u.ID
+	updateTask.GoToPageID = data.IntoPageID
+	updateTask.SubscribedToID = data.IntoPageID
+	updateTask.UpdateType = core.ChangeLogUpdateType
+	updateTask.ChangeLogID = mergeChangeLogID
+	if err := tasks.Enqueue(c, &updateTask, nil); err != nil {
+		c.Errorf("Couldn't enqueue a task: %v", err)
+	}
+	if data.AppendText != "" {
+		var elasticTask tasks.UpdateElasticPageTask
+		elasticTask.PageID = data.IntoPageID
+		if err := tasks.Enqueue(c, &elasticTask, nil); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
+
+	return pages.Success(nil)
+}
+
+// mergePagePairsTx moves all the relationships of the page to intoPage.
+// Relationships intoPage already has are deleted instead. Returns the change
+// logs to write, or the reason the user can't move one of the relationships.
+func mergePagePairsTx(tx *database.Tx, params *pages.HandlerParams, domainMap map[string]*core.Domain, pageID string, intoPage *core.Page) ([]*core.ChangeLog, string, sessions.Error) {
+	u := params.U
+	intoPageID := intoPage.PageID
+	changeLogs := make([]*core.ChangeLog, 0)
+
+	// Pages at the other end of the moved relationships, for checking permissions
+	pageMap := map[string]*core.Page{intoPageID: intoPage}
+	loadPage := func(pageID string) (*core.Page, error) {
+		if page, ok := pageMap[pageID]; ok {
+			return page, nil
+		}
+		page, err := core.LoadFullEdit(params.DB, pageID, u, domainMap, nil)
+		pageMap[pageID] = page
+		return page, err
+	}
+
+	// Load the relationships intoPageID already has
+	existingPairs := make(map[string]bool)
+	pairKey := func(parentID, childID, pairType string) string {
+		return fmt.Sprintf("%s,%s,%s", parentID, childID, pairType)
+	}
+	rows := database.NewQuery(`
+		SELECT parentId,childId,type
+		FROM pagePairs
+		WHERE parentId=? OR childId=?`, intoPageID, intoPageID).ToTxStatement(tx).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var parentID, childID, pairType string
+		if err := rows.Scan(&parentID, &childID, &pairType); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		existingPairs[pairKey(parentID, childID, pairType)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, "", sessions.NewError("Couldn't load relationships", err)
+	}
+
+	var pagePairs []*core.PagePair
+	queryPart := database.NewQuery(`WHERE pp.parentId=? OR pp.childId=?`, pageID, pageID)
+	err = core.LoadPagePairs(tx.DB, queryPart, func(db *database.DB, pp *core.PagePair) error {
+		pagePairs = append(pagePairs, pp)
+		return nil
+	})
+	if err != nil {
+		return nil, "", sessions.NewError("Couldn't load page pairs", err)
+	}
+
+	for _, pp := range pagePairs {
+		newParentID, newChildID := pp.ParentID, pp.ChildID
+		if newParentID == pageID {
+			newParentID = intoPageID
+		}
+		if newChildID == pageID {
+			newChildID = intoPageID
+		}
+		key := pairKey(newParentID, newChildID, pp.Type)
+		isMoved := false
+		if newParentID == newChildID || existingPairs[key] {
+			statement := database.NewQuery(`
+				DELETE FROM pagePairs
+				WHERE id=?`, pp.ID).ToTxStatement(tx)
+			if _, err := statement.Exec(); err != nil {
+				return nil, "", sessions.NewError("Couldn't delete page pair", err)
+			}
+		} else {
+			// Check that the user could create the relationship
+			parent, err := loadPage(newParentID)
+			if err != nil {
+				return nil, "", sessions.NewError("Couldn't load the parent page", err)
+			}
+			child, err := loadPage(newChildID)
+			if err != nil {
+				return nil, "", sessions.NewError("Couldn't load the child page", err)
+			}
+			if parent == nil || child == nil {
+				return nil, "", sessions.NewError("Couldn't find a page of the relationship", nil)
+			}
+			permissionError, err := core.CanAffectRelationship(params.C, parent, child, pp.Type)
+			if err != nil {
+				return nil, "", sessions.NewError("Error verifying permissions", err)
+			} else if permissionError != "" {
+				return nil, permissionError, nil
+			}
+
+			statement := database.NewQuery(`
+				UPDATE pagePairs
+				SET parentId=?,childId=?`, newParentID, newChildID).Add(`
+				WHERE id=?`, pp.ID).ToTxStatement(tx)
+			if _, err := statement.Exec(); err != nil {
+				return nil, "", sessions.NewError("Couldn't update page pair", err)
+			}
+			existingPairs[key] = true
+			isMoved = true
+		}
+
+		// Log the change on the page at the other end of the relationship, and
+		// on both pages being merged
+		otherPageID, forChild := pp.ChildID, true
+		if pp.ChildID == pageID {
+			otherPageID, forChild = pp.ParentID, false
+		}
+		changeLogs = append(changeLogs, &core.ChangeLog{
+			PageID:    pageID,
+			UserID:    u.ID,
+			Type:      getDeletePagePairChangeLogType(pp.Type, !forChild),
+			AuxPageID: otherPageID,
+		})
+		if otherPageID == intoPageID || otherPageID == pageID {
+			continue
+		}
+		changeLogs = append(changeLogs, &core.ChangeLog{
+			PageID:    otherPageID,
+			UserID:    u.ID,
+			Type:      getDeletePagePairChangeLogType(pp.Type, forChild),
+			AuxPageID: pageID,
+		})
+		if isMoved {
+			changeLogs = append(changeLogs, &core.ChangeLog{
+				PageID:    otherPageID,
+				UserID:    u.ID,
+				Type:      getNewPagePairChangeLogType(pp.Type, forChild),
+				AuxPageID: intoPageID,
+			}, &core.ChangeLog{
+				PageID:    intoPageID,
+				UserID:    u.ID,
+				Type:      getNewPagePairChangeLogType(pp.Type, !forChild),
+				AuxPageID: otherPageID,
+			})
+		}
+	}
+	return changeLogs, "", nil
+}
+
+// mergeLensesTx moves the lenses of the page to intoPageID, after the lenses it
+// already has. Returns the change logs to write.
+func mergeLensesTx(tx *database.Tx, u *core.CurrentUser, pageID, intoPageID string) ([]*core.ChangeLog, sessions.Error) {
+	changeLogs := make([]*core.ChangeLog, 0)
+
+	// A lens can't be a lens of itself
+	statement := database.NewQuery(`
+		DELETE FROM lenses
+		WHERE pageId=? AND lensId=?`, pageID, intoPageID).ToTxStatement(tx)
+	if _, err := statement.Exec(); err != nil {
+		return nil, sessions.NewError("Couldn't delete the lens", err)
+	}
+
+	lensIndex := 0
+	row := database.NewQuery(`
+		SELECT IFNULL(MAX(lensIndex)+1,0)
+		FROM lenses
+		WHERE pageId=?`, intoPageID).ToTxStatement(tx).QueryRow()
+	if _, err := row.Scan(&lensIndex); err != nil {
+		return nil, sessions.NewError("Couldn't load lensIndex", err)
+	}
+
+	lensIDs := make([]string, 0)
+	rows := database.NewQuery(`
+		SELECT lensId
+		FROM lenses
+		WHERE pageId=?
+		ORDER BY lensIndex`, pageID).ToTxStatement(tx).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var lensID string
+		if err := rows.Scan(&lensID); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		lensIDs = append(lensIDs, lensID)
+		return nil
+	})
+	if err != nil {
+		return nil, sessions.NewError("Couldn't load lenses", err)
+	}
+
+	for _, lensID := range lensIDs {
+		statement := database.NewQuery(`
+			UPDATE lenses
+			SET pageId=?,lensIndex=?,updatedBy=?,updatedAt=?`, intoPageID, lensIndex, u.ID, database.Now()).Add(`
+			WHERE lensId=?`, lensID).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return nil, sessions.NewError("Couldn't move the lens", err)
+		}
+		lensIndex++
+		changeLogs = append(changeLogs, &core.ChangeLog{
+			PageID:           lensID,
+			UserID:           u.ID,
+			Type:             core.MovedLensChangeLog,
+			AuxPageID:        intoPageID,
+			OldSettingsValue: pageID,
+			NewSettingsValue: intoPageID,
+		})
+	}
+	return changeLogs, nil
+}
+
+// getNewPagePairChangeLogType returns the change log type for adding a
+// relationship, as seen from the parent or the child.
+func getNewPagePairChangeLogType(pairType string, forChild bool) string {
+	if forChild {
+		return map[string]string{
+			core.ParentPagePairType:      core.NewParentChangeLog,
+			core.TagPagePairType:         core.NewTagChangeLog,
+			core.RequirementPagePairType: core.NewRequirementChangeLog,
+			core.SubjectPagePairType:     core.NewSubjectChangeLog,
+		}[pairType]
+	}
+	return map[string]string{
+		core.ParentPagePairType:      core.NewChildChangeLog,
+		core.TagPagePairType:         core.NewUsedAsTagChangeLog,
+		core.RequirementPagePairType: core.NewRequiredByChangeLog,
+		core.SubjectPagePairType:     core.NewTeacherChangeLog,
+	}[pairType]
+}
+
+// getDeletePagePairChangeLogType returns the change log type for removing a
+// relationship, as seen from the parent or the child.
+func getDeletePagePairChangeLogType(pairType string, forChild bool) string {
+	if forChild {
+		return map[string]string{
+			core.ParentPagePairType:      core.DeleteParentChangeLog,
+			core.TagPagePairType:         core.DeleteTagChangeLog,
+			core.RequirementPagePairType: core.DeleteRequirementChangeLog,
+			core.SubjectPagePairType:     core.DeleteSubjectChangeLog,
+		}[pairType]
+	}
+	return map[string]string{
+		core.ParentPagePairType:      core.DeleteChildChangeLog,
+		core.TagPagePairType:         core.DeleteUsedAsTagChangeLog,
+		core.RequirementPagePairType: core.DeleteRequiredByChangeLog,
+		core.SubjectPagePairType:     core.DeleteTeacherChangeLog,
+	}[pairType]
+}
@@ -0,0 +1,106 @@
+// newEditProposalCommentHandler.go adds a comment to a line of an edit proposal's diff.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/tasks"
+)
+
+// newEditProposalCommentData contains parameters passed in via the request.
+type newEditProposalCommentData struct {
+	ChangeLogID string
+	// Line numbers of the diff line being commented on (see core.DiffLine)
+	OldLineNum int
+	NewLineNum int
+	Text       string
+}
+
+var newEditProposalCommentHandler = siteHandler{
+	URI:         "/newEditProposalComment/",
+	HandlerFunc: newEditProposalCommentHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// newEditProposalCommentHandlerFunc handles the request.
+func newEditProposalCommentHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data newEditProposalCommentData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if data.Text == "" {
+		return pages.Fail("Comment text has to be set", nil).Status(http.StatusBadRequest)
+	}
+	if data.OldLineNum < 0 || data.NewLineNum < 0 || (data.OldLineNum == 0 && data.NewLineNum == 0) {
+		return pages.Fail("Invalid line numbers", nil).Status(http.StatusBadRequest)
+	}
+
+	proposal, result := loadEditProposal(params, returnData, data.ChangeLogID)
+	if result != nil {
+		return result
+	}
+	// Reviewers and the author can discuss the proposal
+	if !proposal.livePage.Permissions.Edit.Has && proposal.changeLog.UserID != u.ID {
+		return pages.Fail("Can't comment: "+proposal.livePage.Permissions.Edit.Reason, nil).Status(http.StatusForbidden)
+	}
+
+	hashmap := make(database.InsertMap)
+	hashmap["changeLogId"] = data.ChangeLogID
+	hashmap["pageId"] = proposal.changeLog.PageID
+	hashmap["userId"] = u.ID
+	hashmap["oldLineNum"] = data.OldLineNum
+	hashmap["newLineNum"] = data.NewLineNum
+	hashmap["text"] = data.Text
+	hashmap["createdAt"] = database.Now()
+	statement := db.NewInsertStatement("editProposalComments", hashmap)
+	resp, err := statement.Exec()
+	if err != nil {
+		return pages.Fail("Couldn't add the comment", err)
+	}
+	commentID, err := resp.LastInsertId()
+	if err != nil {
+		return pages.Fail("Couldn't get comment id", err)
+	}
+
+	// Let the author know
+	if proposal.changeLog.UserID != u.ID {
+		enqueueEditProposalUpdate(params, proposal.changeLog, core.EditProposalCommentUpdateType)
+	}
+
+	returnData.ResultMap["commentId"] = strconv.FormatInt(commentID, 10)
+	return pages.Success(returnData)
+}
+
+// enqueueEditProposalUpdate creates an update for the author of the edit proposal.
+func enqueueEditProposalUpdate(params *pages.HandlerParams, changeLog *core.ChangeLog, updateType string) {
+	c := params.C
+	changeLogID, err := strconv.ParseInt(changeLog.ID, 10, 64)
+	if err != nil {
+		c.Errorf("Couldn't parse changeLog id: %v", err)
+		return
+	}
+	var task tasks.NewUpdateTask
+	task.UserID = params.U.ID
+	task.ForUserID = changeLog.UserID
+	task.UpdateType = updateType
+	task.SubscribedToID = changeLog.PageID
+	task.GoToPageID = changeLog.PageID
+	task.ChangeLogID = changeLogID
+	if err := tasks.Enqueue(c, &task, nil); err != nil {
+		c.Errorf("Couldn't enqueue a task: %v", err)
+	}
+}
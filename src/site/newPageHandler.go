@@ -5,6 +5,7 @@ package site
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"zanaduu3/src/core"
 	"zanaduu3/src/pages"
@@ -27,6 +28,10 @@ type newPageData struct {
 	SubmitToDomainID string
 	// If creating a new comment, this is the id of the primary page
 	CommentPrimaryPageID string
+	// Optional id of the template page to start from
+	TemplateID string
+	// Optional title, used for the {{title}} placeholder in the template
+	Title string
 }
 
 // newPageHandlerFunc handles the request.
@@ -47,16 +52,27 @@ func newPageHandlerFunc(params *pages.HandlerParams) *pages.Result {
 func newPageInternalHandler(params *pages.HandlerParams, data *newPageData) *pages.Result {
 	u := params.U
 
-	pageID, err := core.CreateNewPage(params.DB, params.U, &core.CreateNewPageOptions{
+	options := &core.CreateNewPageOptions{
 		Alias:                data.Alias,
 		Type:                 data.Type,
 		SeeDomainID:          params.PrivateDomain.ID,
 		EditDomainID:         u.MyDomainID(),
 		SubmitToDomainID:     data.SubmitToDomainID,
 		IsEditorComment:      data.IsEditorComment,
+		Title:                data.Title,
 		ParentIDs:            data.ParentIDs,
 		CommentPrimaryPageID: data.CommentPrimaryPageID,
-	})
+	}
+	if data.TemplateID != "" {
+		if data.Type == core.CommentPageType {
+			return pages.Fail("Comments can't be created from a template", nil).Status(http.StatusBadRequest)
+		}
+		if result := applyPageTemplate(params, data, options); result != nil {
+			return result
+		}
+	}
+
+	pageID, err := core.CreateNewPage(params.DB, params.U, options)
 	if err != nil {
 		return pages.Fail("Couldn't create new page", err)
 	}
@@ -66,3 +82,55 @@ func newPageInternalHandler(params *pages.HandlerParams, data *newPageData) *pag
 	}
 	return editJSONInternalHandler(params, editData)
 }
+
+// applyPageTemplate fills in the new page's content and relationships from the template.
+func applyPageTemplate(params *pages.HandlerParams, data *newPageData, options *core.CreateNewPageOptions) *pages.Result {
+	db := params.DB
+	u := params.U
+
+	isTemplate, err := core.IsPageTemplateInDomains(db, data.TemplateID, core.GetVisibleDomainIDs(u))
+	if err != nil {
+		return pages.Fail("Couldn't check the template", err)
+	} else if !isTemplate {
+		return pages.Fail("This page is not a template", nil).Status(http.StatusBadRequest)
+	}
+	template, err := core.LoadFullEdit(db, data.TemplateID, u, make(map[string]*core.Domain), nil)
+	if err != nil {
+		return pages.Fail("Couldn't load the template", err)
+	} else if template == nil {
+		return pages.Fail("Couldn't find the template", nil).Status(http.StatusBadRequest)
+	}
+	parentIDs, tagIDs, err := core.LoadParentAndTagIDs(db, data.TemplateID)
+	if err != nil {
+		return pages.Fail("Couldn't load template relationships", err)
+	}
+
+	// The parents passed in take precedence over the template's
+	for _, parentID := range parentIDs {
+		if !core.IsStringInList(parentID, options.ParentIDs) {
+			options.ParentIDs = append(options.ParentIDs, parentID)
+		}
+	}
+	options.TagIDs = tagIDs
+
+	values := &core.TemplateValues{
+		Title:  data.Title,
+		Author: u.FullName(),
+		Date:   time.Now().UTC().Format("2006-01-02"),
+	}
+	if len(options.ParentIDs) > 0 {
+		// Only fill in the parent's title if the user can see the parent
+		parent, err := core.LoadFullEdit(db, options.ParentIDs[0], u, make(map[string]*core.Domain), nil)
+		if err != nil {
+			return pages.Fail("Couldn't load the parent", err)
+		} else if parent != nil {
+			values.Parent = parent.Title
+		}
+	}
+	if options.Title == "" {
+		options.Title = core.ExpandTemplatePlaceholders(template.Title, values)
+	}
+	options.Clickbait = core.ExpandTemplatePlaceholders(template.Clickbait, values)
+	options.Text = core.ExpandTemplatePlaceholders(template.Text, values)
+	return nil
+}
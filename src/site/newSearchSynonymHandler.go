@@ -0,0 +1,77 @@
+// newSearchSynonymHandler.go adds a group of search synonyms to a domain.
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+)
+
+// newSearchSynonymData contains data given to us in the request.
+type newSearchSynonymData struct {
+	DomainID string
+	Terms    []string
+}
+
+var newSearchSynonymHandler = siteHandler{
+	URI:         "/newSearchSynonym/",
+	HandlerFunc: newSearchSynonymHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// newSearchSynonymHandlerFunc handles the request.
+func newSearchSynonymHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	u := params.U
+	db := params.DB
+	returnData := core.NewHandlerData(u)
+
+	var data newSearchSynonymData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode json", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIntIDValid(data.DomainID) {
+		data.DomainID = core.DefaultSearchRankingDomainID
+	}
+	terms := core.NormalizeSearchSynonymTerms(data.Terms)
+	if len(terms) < 2 {
+		return pages.Fail("Need at least two different terms", nil).Status(http.StatusBadRequest)
+	}
+	termsStr := strings.Join(terms, ",")
+	if len(termsStr) > 1024 {
+		return pages.Fail("Too many terms", nil).Status(http.StatusBadRequest)
+	}
+	if !core.CanUserEditSearchSynonyms(u, data.DomainID) {
+		return pages.Fail("Don't have permissions to change search synonyms in this domain", nil).Status(http.StatusForbidden)
+	}
+
+	hashmap := make(database.InsertMap)
+	hashmap["domainId"] = data.DomainID
+	hashmap["terms"] = termsStr
+	hashmap["createdBy"] = u.ID
+	hashmap["createdAt"] = database.Now()
+	statement := db.NewInsertStatement("searchSynonyms", hashmap)
+	result, err := statement.Exec()
+	if err != nil {
+		return pages.Fail("Couldn't insert search synonyms", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return pages.Fail("Couldn't get search synonym group id", err)
+	}
+
+	group, err := core.LoadSearchSynonymGroup(db, fmt.Sprintf("%d", id))
+	if err != nil {
+		return pages.Fail("Couldn't load the search synonym group", err)
+	}
+	returnData.ResultMap["searchSynonymGroup"] = group
+	return pages.Success(returnData)
+}
@@ -0,0 +1,63 @@
+// pageTemplatesJsonHandler.go returns the templates the user can create new pages from.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+// pageTemplatesJSONData contains parameters passed in via the request.
+type pageTemplatesJSONData struct {
+	// If set, only return templates of this domain
+	DomainID string
+}
+
+var pageTemplatesHandler = siteHandler{
+	URI:         "/json/pageTemplates/",
+	HandlerFunc: pageTemplatesJSONHandler,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// pageTemplatesJSONHandler handles the request.
+func pageTemplatesJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data pageTemplatesJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+
+	domainIDs := core.GetVisibleDomainIDs(u)
+	if data.DomainID != "" {
+		if !core.IsStringInList(data.DomainID, domainIDs) {
+			return pages.Fail("Can't see this domain", nil).Status(http.StatusForbidden)
+		}
+		domainIDs = []string{data.DomainID}
+	}
+	templates, err := core.LoadPageTemplates(db, domainIDs)
+	if err != nil {
+		return pages.Fail("Couldn't load templates", err)
+	}
+
+	// Load data
+	for _, template := range templates {
+		core.AddPageToMap(template.PageID, returnData.PageMap, core.TitlePlusLoadOptions)
+	}
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["templates"] = templates
+	return pages.Success(returnData)
+}
@@ -32,10 +32,17 @@ func parentsSearchJSONHandler(params *pages.HandlerParams) *pages.Result {
 	}
 
 	domainIDs := []string{params.PrivateDomain.ID}
-	escapedTerm := elastic.EscapeMatchTerm(data.Term)
+	jsonStr := buildParentsSearchQuery(data.Term, domainIDs)
+	return searchJSONInternalHandler(params, jsonStr, params.PrivateDomain.ID)
+}
+
+// buildParentsSearchQuery constructs the Elastic query JSON for matching the
+// given term against pages in the given domains.
+func buildParentsSearchQuery(term string, domainIDs []string) string {
+	escapedTerm := elastic.EscapeMatchTerm(term)
 
 	// Construct the search JSON
-	jsonStr := fmt.Sprintf(`{
+	return fmt.Sprintf(`{
 		"min_score": %[1]v,
 		"size": %[2]d,
 		"query": {
@@ -73,5 +80,4 @@ func parentsSearchJSONHandler(params *pages.HandlerParams) *pages.Result {
 		},
 		"_source": []
 	}`, minSearchScore, searchSize, escapedTerm, strings.Join(domainIDs, ","))
-	return searchJSONInternalHandler(params, jsonStr)
 }
@@ -0,0 +1,70 @@
+// pathAnalyticsJsonHandler.go returns how readers go through the path of a guide.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+// pathAnalyticsData contains parameters passed in via the request.
+type pathAnalyticsData struct {
+	GuideID string
+}
+
+var pathAnalyticsHandler = siteHandler{
+	URI:         "/json/pathAnalytics/",
+	HandlerFunc: pathAnalyticsHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// pathAnalyticsHandlerFunc handles the request.
+func pathAnalyticsHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	var data pathAnalyticsData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.GuideID) {
+		return pages.Fail("Invalid guide id", nil).Status(http.StatusBadRequest)
+	}
+
+	// Only the people who can edit the path get to see the analytics
+	page, err := core.LoadFullEdit(db, data.GuideID, u, returnData.DomainMap, nil)
+	if err != nil {
+		return pages.Fail("Couldn't load the guide", err)
+	} else if page == nil {
+		return pages.Fail("Couldn't find the guide", nil).Status(http.StatusBadRequest)
+	}
+	if !page.Permissions.Edit.Has {
+		return pages.Fail("Can't edit: "+page.Permissions.Edit.Reason, nil).Status(http.StatusForbidden)
+	}
+
+	// The analytics are computed periodically by ComputePathAnalyticsTask
+	analytics, err := core.LoadPathAnalytics(db, data.GuideID)
+	if err != nil {
+		return pages.Fail("Couldn't load path analytics", err)
+	}
+
+	core.AddPageToMap(data.GuideID, returnData.PageMap, core.TitlePlusLoadOptions)
+	if analytics != nil {
+		for _, stepAnalytics := range append(analytics.Steps, analytics.Requisites...) {
+			core.AddPageToMap(stepAnalytics.PageID, returnData.PageMap, core.TitlePlusLoadOptions)
+		}
+	}
+	returnData.ResultMap["analytics"] = analytics
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+	return pages.Success(returnData)
+}
@@ -0,0 +1,65 @@
+// pathGroupProgressJsonHandler.go returns the progress of the students who
+// started a teacher's group path.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+// pathGroupProgressData contains parameters passed in via the request.
+type pathGroupProgressData struct {
+	// Id of the teacher's path instance
+	InstanceID string
+}
+
+var pathGroupProgressHandler = siteHandler{
+	URI:         "/json/pathGroupProgress/",
+	HandlerFunc: pathGroupProgressHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// pathGroupProgressHandlerFunc handles the request.
+func pathGroupProgressHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	var data pathGroupProgressData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+
+	instance, err := core.LoadPathInstance(db, data.InstanceID, u)
+	if err != nil {
+		return pages.Fail("Couldn't load the path instance: %v", err)
+	} else if instance == nil {
+		return pages.Fail("Couldn't find the path instance", nil).Status(http.StatusBadRequest)
+	} else if !instance.IsByCurrentUser || !instance.IsGroup {
+		return pages.Fail("Only the teacher can see the group's progress", nil).Status(http.StatusForbidden)
+	}
+
+	members, err := core.LoadPathGroupMembers(db, instance.ID)
+	if err != nil {
+		return pages.Fail("Couldn't load the group's progress", err)
+	}
+	for _, member := range members {
+		core.AddUserIDToMap(member.UserID, returnData.UserMap)
+	}
+
+	core.AddPageToMap(instance.GuideID, returnData.PageMap, core.TitlePlusLoadOptions)
+	returnData.ResultMap["path"] = instance
+	returnData.ResultMap["members"] = members
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+	return pages.Success(returnData)
+}
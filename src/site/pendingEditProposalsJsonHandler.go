@@ -0,0 +1,73 @@
+// pendingEditProposalsJsonHandler.go returns the queue of edit proposals waiting
+// for review in a domain.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+const (
+	defaultPendingEditProposalsLimit = 50
+)
+
+// pendingEditProposalsJSONData contains parameters passed in via the request.
+type pendingEditProposalsJSONData struct {
+	DomainID string
+	Limit    int
+}
+
+var pendingEditProposalsHandler = siteHandler{
+	URI:         "/json/pendingEditProposals/",
+	HandlerFunc: pendingEditProposalsJSONHandler,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// pendingEditProposalsJSONHandler handles the request.
+func pendingEditProposalsJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data pendingEditProposalsJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIntIDValid(data.DomainID) {
+		return pages.Fail("Invalid domain id", nil).Status(http.StatusBadRequest)
+	}
+	if !core.CanUserSeeDomain(u, data.DomainID) {
+		return pages.Fail("You are not a member of this domain", nil).Status(http.StatusForbidden)
+	}
+	if data.Limit <= 0 || data.Limit > defaultPendingEditProposalsLimit {
+		data.Limit = defaultPendingEditProposalsLimit
+	}
+
+	proposals, err := core.LoadPendingEditProposals(db, u, returnData, data.DomainID, data.Limit)
+	if err != nil {
+		return pages.Fail("Couldn't load pending proposals", err)
+	}
+
+	// Load data
+	for _, proposal := range proposals {
+		core.AddPageToMap(proposal.ChangeLog.PageID, returnData.PageMap, core.TitlePlusLoadOptions)
+		if proposal.LastReview != nil {
+			core.AddUserIDToMap(proposal.LastReview.ReviewerID, returnData.UserMap)
+		}
+	}
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["proposals"] = proposals
+	return pages.Success(returnData)
+}
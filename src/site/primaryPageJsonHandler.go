@@ -26,6 +26,8 @@ type primaryPageJSONData struct {
 	MarkID string
 	// Optional path instance id that was specified in the url
 	PathInstanceID string
+	// Share key of the path instance, if it's someone else's
+	PathShareKey string
 	// Optional id of the page that specifies the path the user is on
 	PathPageID string
 }
@@ -165,11 +167,11 @@ func primaryPageJSONHandler(params *pages.HandlerParams) *pages.Result {
 
 	if data.PathInstanceID != "" {
 		// Load the path instance
-		instance, err := core.LoadPathInstance(db, data.PathInstanceID, u)
+		instance, err := core.LoadSharedPathInstance(db, data.PathInstanceID, data.PathShareKey, u)
 		if err != nil {
 			return pages.Fail("Couldn't load the path instance: %v", err)
 		} else if instance == nil {
-			return pages.Fail("Couldn't find the path instance", nil).Status(http.StatusBadRequest)
+			return pages.Fail("Couldn't find the path instance, or the share key is wrong", nil).Status(http.StatusForbidden)
 		}
 		returnData.ResultMap["path"] = instance
 		core.AddPageIDToMap(instance.GuideID, returnData.PageMap)
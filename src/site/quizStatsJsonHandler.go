@@ -0,0 +1,78 @@
+// quizStatsJsonHandler.go returns how users answer the quiz questions in a page.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+)
+
+// quizStatsData contains parameters passed in via the request.
+type quizStatsData struct {
+	PageID string
+}
+
+var quizStatsHandler = siteHandler{
+	URI:         "/json/quizStats/",
+	HandlerFunc: quizStatsHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// quizStatsHandlerFunc handles the request.
+func quizStatsHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	var data quizStatsData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.PageID) {
+		return pages.Fail("Invalid page id", nil).Status(http.StatusBadRequest)
+	}
+
+	// Only the people who can write the answer key get to see the stats
+	page, err := core.LoadFullEdit(db, data.PageID, u, returnData.DomainMap, nil)
+	if err != nil {
+		return pages.Fail("Couldn't load the page", err)
+	} else if page == nil {
+		return pages.Fail("Couldn't find the page", nil).Status(http.StatusBadRequest)
+	}
+	if !page.Permissions.Edit.Has {
+		return pages.Fail("Can't edit: "+page.Permissions.Edit.Reason, nil).Status(http.StatusForbidden)
+	}
+
+	stats, err := core.LoadQuizStats(db, data.PageID)
+	if err != nil {
+		return pages.Fail("Couldn't load quiz stats", err)
+	}
+
+	// Count how many users got their masteries from this page
+	var passedCount int
+	_, err = database.NewQuery(`
+		SELECT COUNT(DISTINCT ump.userId)
+		FROM userMasteryPairs AS ump
+		JOIN quizMasteryGrants AS qmg
+		ON (ump.masteryId=qmg.masteryId AND ump.taughtBy=qmg.pageId)
+		WHERE qmg.pageId=?`, data.PageID).ToStatement(db).QueryRow().Scan(&passedCount)
+	if err != nil {
+		return pages.Fail("Couldn't load passed count", err)
+	}
+
+	core.AddPageToMap(data.PageID, returnData.PageMap, core.TitlePlusLoadOptions)
+	returnData.ResultMap["quizStats"] = stats
+	returnData.ResultMap["passedCount"] = passedCount
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+	return pages.Success(returnData)
+}
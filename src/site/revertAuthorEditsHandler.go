@@ -0,0 +1,161 @@
+// revertAuthorEditsHandler.go reverts all the pages in a domain where a given
+// user made the live edit, e.g. to clean up after a vandal.
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+	"zanaduu3/src/tasks"
+)
+
+// revertAuthorEditsData contains parameters passed in via the request.
+type revertAuthorEditsData struct {
+	authorRevertData
+	// Optional subset of the pages to revert. If empty, all pages are reverted.
+	PageIDs []string
+	// If true, the author is also banned from the domain
+	BanAuthor bool
+}
+
+var revertAuthorEditsHandler = siteHandler{
+	URI:         "/revertAuthorEdits/",
+	HandlerFunc: revertAuthorEditsHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+func revertAuthorEditsHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	c := params.C
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	var data revertAuthorEditsData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	since, result := checkAuthorRevertData(params, &data.authorRevertData)
+	if result != nil {
+		return result
+	}
+	if data.BanAuthor && !core.CanCurrentUserGiveRole(u, data.DomainID, core.BannedDomainRole) {
+		return pages.Fail("Don't have permissions to ban users in this domain", nil).Status(http.StatusForbidden)
+	}
+
+	revertPages, err := core.LoadAuthorRevertPages(db, data.AuthorID, data.DomainID, since)
+	if err != nil {
+		return pages.Fail("Couldn't load pages", err)
+	}
+
+	// Revert all the pages as one batch
+	var authorRevertID int64
+	revertedPages := make([]*core.AuthorRevertPage, 0)
+	skippedPageIDs := make([]string, 0)
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		hashmap := make(database.InsertMap)
+		hashmap["domainId"] = data.DomainID
+		hashmap["authorId"] = data.AuthorID
+		hashmap["since"] = since
+		hashmap["createdBy"] = u.ID
+		hashmap["createdAt"] = database.Now()
+		statement := tx.DB.NewInsertStatement("authorReverts", hashmap).WithTx(tx)
+		result, err := statement.Exec()
+		if err != nil {
+			return sessions.NewError("Couldn't create author revert", err)
+		}
+		authorRevertID, err = result.LastInsertId()
+		if err != nil {
+			return sessions.NewError("Couldn't get author revert id", err)
+		}
+
+		for _, revertPage := range revertPages {
+			if len(data.PageIDs) > 0 && !core.IsStringInList(revertPage.PageID, data.PageIDs) {
+				continue
+			}
+			if revertPage.ToEdit <= 0 {
+				skippedPageIDs = append(skippedPageIDs, revertPage.PageID)
+				continue
+			}
+			changeLogID, ok, err := core.RevertToEdit(tx, revertPage.PageID, u.ID, revertPage.RevertedEdit, revertPage.ToEdit)
+			if err != nil {
+				return sessions.NewError(fmt.Sprintf("Couldn't revert page %s", revertPage.PageID), err)
+			} else if !ok {
+				// Someone else changed the page in the meantime
+				skippedPageIDs = append(skippedPageIDs, revertPage.PageID)
+				continue
+			}
+			revertPage.ChangeLogID = changeLogID
+
+			hashmap = make(database.InsertMap)
+			hashmap["authorRevertId"] = authorRevertID
+			hashmap["pageId"] = revertPage.PageID
+			hashmap["revertedEdit"] = revertPage.RevertedEdit
+			hashmap["toEdit"] = revertPage.ToEdit
+			hashmap["changeLogId"] = changeLogID
+			statement = tx.DB.NewInsertStatement("authorRevertPages", hashmap).WithTx(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't add author revert page", err)
+			}
+			revertedPages = append(revertedPages, revertPage)
+		}
+
+		statement = database.NewQuery(`
+			UPDATE authorReverts
+			SET pagesReverted=?`, len(revertedPages)).Add(`
+			WHERE id=?`, authorRevertID).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't update author revert", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	}
+
+	for _, revertPage := range revertedPages {
+		var task tasks.UpdateElasticPageTask
+		task.PageID = revertPage.PageID
+		if err := tasks.Enqueue(c, &task, nil); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
+
+	if data.BanAuthor {
+		roleData := &updateDomainRoleData{
+			UserID:   data.AuthorID,
+			DomainID: data.DomainID,
+			Role:     core.BannedDomainRole,
+		}
+		if result := updateDomainRoleInternalHandlerFunc(params, roleData); result.Err != nil {
+			return result
+		}
+		statement := database.NewQuery(`
+			UPDATE authorReverts
+			SET isAuthorBanned=TRUE
+			WHERE id=?`, authorRevertID).ToStatement(db)
+		if _, err := statement.Exec(); err != nil {
+			return pages.Fail("Couldn't update author revert", err)
+		}
+	}
+
+	for _, revertPage := range revertedPages {
+		core.AddPageToMap(revertPage.PageID, returnData.PageMap, core.TitlePlusLoadOptions)
+	}
+	returnData.ResultMap["authorRevertId"] = fmt.Sprintf("%d", authorRevertID)
+	returnData.ResultMap["revertedPages"] = revertedPages
+	returnData.ResultMap["skippedPageIds"] = skippedPageIDs
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+	return pages.Success(returnData)
+}
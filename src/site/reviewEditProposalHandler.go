@@ -0,0 +1,171 @@
+// reviewEditProposalHandler.go handles reviewers requesting changes to, rejecting,
+// or partially accepting an edit proposal.
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+// reviewEditProposalData contains parameters passed in via the request.
+type reviewEditProposalData struct {
+	ChangeLogID string
+	// One of the review actions in core/editProposalReview.go
+	Action string
+	Reason string
+	// For partial accepts, indexes of the text diff hunks to accept
+	HunkIndexes []int
+}
+
+var reviewEditProposalHandler = siteHandler{
+	URI:         "/reviewEditProposal/",
+	HandlerFunc: reviewEditProposalHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// reviewEditProposalHandlerFunc handles the request.
+func reviewEditProposalHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data reviewEditProposalData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsValidReviewAction(data.Action) {
+		return pages.Fail("Invalid action", nil).Status(http.StatusBadRequest)
+	}
+	if data.Action != core.PartialAcceptReviewAction && data.Reason == "" {
+		return pages.Fail("Reason has to be set", nil).Status(http.StatusBadRequest)
+	}
+	if data.Action == core.PartialAcceptReviewAction && len(data.HunkIndexes) <= 0 {
+		return pages.Fail("No hunks selected", nil).Status(http.StatusBadRequest)
+	}
+
+	proposal, result := loadEditProposal(params, returnData, data.ChangeLogID)
+	if result != nil {
+		return result
+	}
+	if !proposal.livePage.Permissions.Edit.Has {
+		return pages.Fail("Can't review: "+proposal.livePage.Permissions.Edit.Reason, nil).Status(http.StatusForbidden)
+	}
+
+	review := &core.EditProposalReview{
+		ChangeLogID:   data.ChangeLogID,
+		PageID:        proposal.changeLog.PageID,
+		ReviewerID:    u.ID,
+		Action:        data.Action,
+		Reason:        data.Reason,
+		AcceptedHunks: data.HunkIndexes,
+	}
+
+	// Records the review and takes the proposal out of the pending queue
+	saveReview := func(tx *database.Tx) sessions.Error {
+		if err := core.AddEditProposalReview(tx, review); err != nil {
+			return sessions.NewError("Couldn't add the review", err)
+		}
+
+		newType := ""
+		if data.Action == core.RejectReviewAction {
+			newType = core.RejectEditProposalChangeLog
+		} else if data.Action == core.PartialAcceptReviewAction {
+			newType = core.AcceptedHunksChangeLog
+		}
+		if newType != "" {
+			hashmap := make(database.InsertMap)
+			hashmap["id"] = data.ChangeLogID
+			hashmap["type"] = newType
+			statement := tx.DB.NewInsertStatement("changeLogs", hashmap, "type").WithTx(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't update change log", err)
+			}
+		}
+		return nil
+	}
+
+	if data.Action == core.PartialAcceptReviewAction {
+		// Only the text is split into hunks, so other changes can't be partially accepted
+		if proposal.proposedEdit.Title != proposal.baseEdit.Title ||
+			proposal.proposedEdit.Clickbait != proposal.baseEdit.Clickbait ||
+			proposal.proposedEdit.MetaText != proposal.baseEdit.MetaText {
+			return pages.Fail("The proposal changes more than the text, so it can't be partially accepted", nil).Status(http.StatusBadRequest)
+		}
+
+		hunks := core.DiffText(proposal.baseEdit.Text, proposal.proposedEdit.Text).GetHunks()
+		for _, index := range data.HunkIndexes {
+			if index < 0 || index >= len(hunks) {
+				return pages.Fail("Invalid hunk index", nil).Status(http.StatusBadRequest)
+			}
+		}
+		acceptedText := core.ApplyDiffHunks(proposal.baseEdit.Text, proposal.proposedEdit.Text, data.HunkIndexes)
+		// The page might have changed since the proposal was made
+		merge := core.Merge3(proposal.baseEdit.Text, acceptedText, proposal.livePage.Text)
+		if !merge.IsClean() {
+			return pages.Fail("Accepted hunks conflict with the live edit", nil).Status(http.StatusBadRequest)
+		} else if merge.Text == proposal.livePage.Text {
+			return pages.Fail("Accepted hunks don't change the live edit", nil).Status(http.StatusBadRequest)
+		}
+
+		// Publish the accepted part of the proposal as a new edit, recording the
+		// review in the same transaction
+		summary := data.Reason
+		if summary == "" {
+			summary = fmt.Sprintf("Partially accepted edit proposal #%d", proposal.proposedEdit.Edit)
+		}
+		result := editPageInternalHandler(params, &editPageData{
+			PageID:        proposal.livePage.PageID,
+			PrevEdit:      proposal.livePage.Edit,
+			CurrentEdit:   proposal.livePage.Edit,
+			Title:         proposal.livePage.Title,
+			Clickbait:     proposal.livePage.Clickbait,
+			Text:          merge.Text,
+			MetaText:      proposal.livePage.MetaText,
+			AnchorContext: proposal.livePage.AnchorContext,
+			AnchorText:    proposal.livePage.AnchorText,
+			AnchorOffset:  proposal.livePage.AnchorOffset,
+			EditSummary:   summary,
+			OnSaved: func(tx *database.Tx, newEdit int, isLiveEdit bool) sessions.Error {
+				if !isLiveEdit {
+					// Someone published a new edit while we were reviewing
+					return sessions.NewError("The page has changed, please review the proposal again", nil)
+				}
+				review.ResultEdit = newEdit
+				return saveReview(tx)
+			},
+		})
+		if result.Err != nil {
+			return result
+		}
+	} else {
+		err2 := db.Transaction(saveReview)
+		if err2 != nil {
+			return pages.FailWith(err2)
+		}
+	}
+
+	// Let the author know
+	if proposal.changeLog.UserID != u.ID {
+		updateType := core.ChangesRequestedUpdateType
+		if data.Action == core.RejectReviewAction {
+			updateType = core.EditProposalRejectedUpdateType
+		} else if data.Action == core.PartialAcceptReviewAction {
+			updateType = core.EditProposalPartialUpdateType
+		}
+		enqueueEditProposalUpdate(params, proposal.changeLog, updateType)
+	}
+
+	returnData.ResultMap["review"] = review
+	return pages.Success(returnData)
+}
@@ -0,0 +1,63 @@
+// scheduledPublishesJsonHandler.go returns the current user's pending scheduled publishes.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+)
+
+// scheduledPublishesJSONData contains parameters passed in via the request.
+type scheduledPublishesJSONData struct {
+	// Optionally, only return publishes for this page
+	PageID string
+}
+
+var scheduledPublishesHandler = siteHandler{
+	URI:         "/json/scheduledPublishes/",
+	HandlerFunc: scheduledPublishesJSONHandler,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// scheduledPublishesJSONHandler handles the request.
+func scheduledPublishesJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data scheduledPublishesJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+
+	queryPart := database.NewQuery(`
+		WHERE userId=? AND status=?`, u.ID, core.PendingScheduledPublish)
+	if data.PageID != "" {
+		queryPart.Add(`AND pageId=?`, data.PageID)
+	}
+	queryPart.Add(`ORDER BY publishAt`)
+	scheduledPublishes, err := core.LoadScheduledPublishes(db, queryPart)
+	if err != nil {
+		return pages.Fail("Couldn't load scheduled publishes", err)
+	}
+
+	// Load data
+	for _, sp := range scheduledPublishes {
+		core.AddPageToMap(sp.PageID, returnData.PageMap, core.TitlePlusLoadOptions)
+	}
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["scheduledPublishes"] = scheduledPublishes
+	return pages.Success(returnData)
+}
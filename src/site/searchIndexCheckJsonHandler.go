@@ -0,0 +1,78 @@
+// searchIndexCheckJsonHandler.go returns the summary of a search index consistency check.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+const (
+	// Max number of findings to return
+	searchIndexCheckFindingsLimit = 500
+)
+
+// searchIndexCheckJSONData contains parameters passed in via the request.
+type searchIndexCheckJSONData struct {
+	// If not set, the latest check is returned
+	CheckID string
+}
+
+var searchIndexCheckHandler = siteHandler{
+	URI:         "/json/searchIndexCheck/",
+	HandlerFunc: searchIndexCheckJSONHandler,
+	Options: pages.PageOptions{
+		AdminOnly: true,
+	},
+}
+
+// searchIndexCheckJSONHandler handles the request.
+func searchIndexCheckJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data searchIndexCheckJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+
+	checkID := data.CheckID
+	if checkID == "" {
+		checkID, err = core.LoadLatestSearchIndexCheckID(db)
+		if err != nil {
+			return pages.Fail("Couldn't load the latest check", err)
+		} else if checkID == "" {
+			return pages.Success(returnData)
+		}
+	}
+
+	check, err := core.LoadSearchIndexCheck(db, checkID)
+	if err != nil {
+		return pages.Fail("Couldn't load the check", err)
+	} else if check == nil {
+		return pages.Fail("Couldn't find the check", nil).Status(http.StatusBadRequest)
+	}
+
+	findings, err := core.LoadSearchIndexCheckFindings(db, checkID, searchIndexCheckFindingsLimit)
+	if err != nil {
+		return pages.Fail("Couldn't load findings", err)
+	}
+	for _, finding := range findings {
+		core.AddPageIDToMap(finding.PageID, returnData.PageMap)
+	}
+
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["check"] = check
+	returnData.ResultMap["findings"] = findings
+	return pages.Success(returnData)
+}
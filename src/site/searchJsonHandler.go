@@ -5,12 +5,13 @@ package site
 import (
 	"encoding/json"
 	"fmt"
-	"math"
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"zanaduu3/src/core"
+	"zanaduu3/src/database"
 	"zanaduu3/src/elastic"
 	"zanaduu3/src/pages"
 )
@@ -41,8 +42,6 @@ var searchHandler = siteHandler{
 
 // searchJsonHandler handles the request.
 func searchJSONHandler(params *pages.HandlerParams) *pages.Result {
-	u := params.U
-
 	// Decode data
 	var data searchJSONData
 	decoder := json.NewDecoder(params.R.Body)
@@ -54,6 +53,34 @@ func searchJSONHandler(params *pages.HandlerParams) *pages.Result {
 		return pages.Fail("No search term specified", nil).Status(http.StatusBadRequest)
 	}
 
+	rankingDomainID := params.PrivateDomain.ID
+	if core.IsIntIDValid(data.PreferredEditDomainID) {
+		rankingDomainID = data.PreferredEditDomainID
+	}
+	synonyms, err := loadSearchSynonyms(params.DB, rankingDomainID)
+	if err != nil {
+		return pages.Fail("Couldn't load search synonyms", err)
+	}
+
+	jsonStr, err := buildSearchQuery(params.U, &data, synonyms)
+	if err != nil {
+		return pages.Fail("Error constructing ElasticSearch query", err)
+	}
+	return searchJSONInternalHandler(params, jsonStr, rankingDomainID)
+}
+
+// loadSearchSynonyms loads the site-wide synonyms and the synonyms for the given domain.
+func loadSearchSynonyms(db *database.DB, domainID string) ([]*core.SearchSynonymGroup, error) {
+	domainIDs := []string{core.DefaultSearchRankingDomainID}
+	if core.IsIntIDValid(domainID) {
+		domainIDs = append(domainIDs, domainID)
+	}
+	return core.LoadSearchSynonyms(db, domainIDs)
+}
+
+// buildSearchQuery constructs the Elastic query JSON for the given search data.
+// The term is also expanded using the given synonyms.
+func buildSearchQuery(u *core.CurrentUser, data *searchJSONData, synonyms []*core.SearchSynonymGroup) (string, error) {
 	var domainIDs []string
 	for domainID := range u.DomainMembershipMap {
 		if core.CanUserSeeDomain(u, domainID) {
@@ -62,7 +89,7 @@ func searchJSONHandler(params *pages.HandlerParams) *pages.Result {
 	}
 	domainIDs = append(domainIDs, "\"0\"")
 
-	data.Term = elastic.EscapeMatchTerm(data.Term)
+	term := elastic.EscapeMatchTerm(data.Term)
 
 	// Filter by type of the page
 	mandatoryTypeFilter := ""
@@ -70,20 +97,20 @@ func searchJSONHandler(params *pages.HandlerParams) *pages.Result {
 		mandatoryTypeFilter = fmt.Sprintf(`{"term": { "type": "%s" } },`, elastic.EscapeMatchTerm(data.PageType))
 	}
 	forbiddenTypeFilter := ""
-	if types := data.FilterPageTypes; len(types) != 0 {
-		for i, s := range types {
+	if len(data.FilterPageTypes) != 0 {
+		types := make([]string, len(data.FilterPageTypes))
+		for i, s := range data.FilterPageTypes {
 			types[i] = elastic.EscapeMatchTerm(s)
 		}
 		b, err := json.Marshal(types)
-		if err == nil {
-			forbiddenTypeFilter = fmt.Sprintf(`{"terms": { "type": %s } },`, b)
-		} else {
-			return pages.Fail("Error constructing ElasticSearch query: %v", err)
+		if err != nil {
+			return "", err
 		}
+		forbiddenTypeFilter = fmt.Sprintf(`{"terms": { "type": %s } },`, b)
 	}
 
 	// To allow for partial matching on the last word, we have to split it off
-	lastSpaceIndex := strings.LastIndex(data.Term, " ")
+	lastSpaceIndex := strings.LastIndex(term, " ")
 	textMatch := fmt.Sprintf(`
 		{
 			"match_phrase_prefix": {
@@ -98,10 +125,10 @@ func searchJSONHandler(params *pages.HandlerParams) *pages.Result {
 		},
 		{
 			"match_phrase_prefix": { "text": "%[1]s" }
-		},`, data.Term)
+		},`, term)
 	if lastSpaceIndex > 0 {
-		matchTerm := data.Term[:lastSpaceIndex]
-		prefixTerm := data.Term[lastSpaceIndex+1:]
+		matchTerm := term[:lastSpaceIndex]
+		prefixTerm := term[lastSpaceIndex+1:]
 		textMatch = fmt.Sprintf(`
 		{
 			"match": {
@@ -134,6 +161,47 @@ func searchJSONHandler(params *pages.HandlerParams) *pages.Result {
 		`, matchTerm, prefixTerm)
 	}
 
+	// Allow for typos in titles and aliases
+	aliasTerm := strings.Replace(strings.ToLower(term), " ", "_", -1)
+	textMatch += fmt.Sprintf(`
+		{
+			"match": {
+				"title": {
+					"query": "%[1]s",
+					"fuzziness": "AUTO",
+					"prefix_length": 1
+				}
+			}
+		},
+		{
+			"fuzzy": {
+				"alias": {
+					"value": "%[2]s",
+					"fuzziness": "AUTO",
+					"prefix_length": 1
+				}
+			}
+		},`, term, aliasTerm)
+
+	// Also search for the term with synonyms substituted in
+	for _, expansion := range core.ExpandSearchTerm(data.Term, synonyms) {
+		textMatch += fmt.Sprintf(`
+		{
+			"match_phrase": {
+				"title": {
+					"query": "%[1]s",
+					"boost": 3
+				}
+			}
+		},
+		{
+			"match": { "clickbait": "%[1]s" }
+		},
+		{
+			"match": { "text": "%[1]s" }
+		},`, elastic.EscapeMatchTerm(expansion))
+	}
+
 	// Construct the search JSON
 	jsonStr := fmt.Sprintf(`{
 		"min_score": %[1]v,
@@ -187,22 +255,115 @@ func searchJSONHandler(params *pages.HandlerParams) *pages.Result {
 				}
 			}
 		},
+		"suggest": {
+			"titleSuggestion": {
+				"text": "%[3]s",
+				"phrase": {
+					"field": "title.suggest",
+					"size": 1,
+					"max_errors": 2,
+					"direct_generator": [
+						{ "field": "title.suggest" }
+					],
+					"collate": {
+						"query": {
+							"inline": {
+								"bool": {
+									"must": { "match_phrase": { "title.suggest": "{{suggestion}}" } },
+									"filter": { "terms": { "seeDomainId": [%[4]s] } }
+								}
+							}
+						},
+						"prune": false
+					}
+				}
+			},
+			"aliasSuggestion": {
+				"text": "%[6]s",
+				"term": {
+					"field": "alias",
+					"size": 1
+				}
+			}
+		},
 		"_source": []
-	}`, minSearchScore, searchSize, data.Term, strings.Join(domainIDs, ","), data.PreferredEditDomainID)
-	return searchJSONInternalHandler(params, jsonStr)
+	}`, minSearchScore, searchSize, term, strings.Join(domainIDs, ","), data.PreferredEditDomainID, aliasTerm)
+	return jsonStr, nil
 }
 
-func searchJSONInternalHandler(params *pages.HandlerParams, query string) *pages.Result {
+// searchJSONInternalHandler performs the given search and ranks the results
+// using the search ranking rules of the given domain.
+func searchJSONInternalHandler(params *pages.HandlerParams, query string, rankingDomainID string) *pages.Result {
 	db := params.DB
 	u := params.U
 	returnData := core.NewHandlerData(u)
 
+	ranking, err := core.LoadSearchRanking(db, rankingDomainID)
+	if err != nil {
+		return pages.Fail("Couldn't load search ranking", err)
+	}
+
 	// Perform search.
-	results, err := elastic.SearchPageIndex(params.C, query)
+	results, err := loadSearchResults(params, query, returnData)
 	if err != nil {
 		return pages.Fail("Error with elastic search", err)
 	}
 
+	hits := rankSearchHits(results.Hits.Hits, returnData.PageMap, ranking, u)
+	if returnSearchSize < len(hits) {
+		hits = hits[0:returnSearchSize]
+	}
+	results.Hits.Hits = hits
+	returnData.ResultMap["search"] = results.Hits
+
+	didYouMean, err := computeDidYouMean(db, u, results)
+	if err != nil {
+		return pages.Fail("Couldn't compute search suggestion", err)
+	}
+	if didYouMean != "" {
+		returnData.ResultMap["didYouMean"] = didYouMean
+	}
+	return pages.Success(returnData)
+}
+
+// computeDidYouMean returns the corrected search term suggested by Elastic, or
+// "" if there is no suggestion. Title suggestions are preferred over aliases.
+func computeDidYouMean(db *database.DB, u *core.CurrentUser, results *elastic.SearchResult) (string, error) {
+	if suggestion := results.BestSuggestion("titleSuggestion"); suggestion != "" {
+		return suggestion, nil
+	}
+
+	alias := results.BestSuggestion("aliasSuggestion")
+	if alias == "" {
+		return "", nil
+	}
+	// Elastic doesn't check visibility for term suggestions, so make sure the
+	// user can actually see a page with this alias.
+	var count int
+	_, err := database.NewQuery(`
+		SELECT COUNT(*)
+		FROM pageInfos AS pi
+		WHERE pi.alias=?`, alias).Add(`
+			AND`).AddPart(core.PageInfosFilter(u)).ToStatement(db).QueryRow().Scan(&count)
+	if err != nil {
+		return "", err
+	} else if count <= 0 {
+		return "", nil
+	}
+	return alias, nil
+}
+
+// loadSearchResults runs the query against Elastic and loads the pages for
+// all the hits into returnData.
+func loadSearchResults(params *pages.HandlerParams, query string, returnData *core.CommonHandlerData) (*elastic.SearchResult, error) {
+	db := params.DB
+	u := params.U
+
+	results, err := elastic.SearchPageIndex(params.C, query)
+	if err != nil {
+		return nil, err
+	}
+
 	loadOptions := (&core.PageLoadOptions{
 		Tags:     true,
 		Creators: u.ID != "",
@@ -216,56 +377,25 @@ func searchJSONInternalHandler(params *pages.HandlerParams, query string) *pages
 	// Load pages.
 	err = core.ExecuteLoadPipeline(db, returnData)
 	if err != nil {
-		return pages.Fail("error while loading pages", err)
+		return nil, fmt.Errorf("error while loading pages: %v", err)
 	}
+	return results, nil
+}
 
-	// Adjust results' scores: tag id -> multiplier factor
-	tagMultiplierMap := map[string]float32{
-		"22t": 0.75, // Just a requisite
-		"15r": 0.85, // Out of date
-		"4v":  0.75, // Work in progress
-		"72":  0.65, // Stub
-		"6cc": 2.0,  // Concept
-	}
-	for _, hit := range results.Hits.Hits {
-		if page, ok := returnData.PageMap[hit.Source.PageID]; ok {
-			// Adjust the score based on tags
-			for _, tagID := range page.TagIDs {
-				if penalty, ok := tagMultiplierMap[tagID]; ok {
-					hit.Score *= penalty
-				}
-			}
-			// Adjust the score based on likes
-			if page.LikeScore > 0 {
-				hit.Score *= float32(math.Log(float64(page.LikeScore))/10) + 1
-			}
-			if page.MyLikeValue > 0 {
-				hit.Score *= 1.2
-			}
-			// Adjust the score if the user created the page
-			if u.ID != "" {
-				for _, creatorID := range page.CreatorIDs {
-					if creatorID == u.ID {
-						hit.Score *= 1.2
-						break
-					}
-				}
-			}
-			// Adjust the score if it's a user page
-			if page.Type == core.GroupPageType {
-				hit.Score *= 0.2
-			}
+// rankSearchHits adjusts the hits' scores based on the given ranking and returns
+// a new list of hits sorted from best to worst. The given hits aren't modified.
+func rankSearchHits(hits elastic.HitsList, pageMap map[string]*core.Page, ranking *core.SearchRanking, u *core.CurrentUser) elastic.HitsList {
+	now := time.Now().UTC()
+	rankedHits := make(elastic.HitsList, 0, len(hits))
+	for _, hit := range hits {
+		rankedHit := *hit
+		if page, ok := pageMap[hit.Source.PageID]; ok {
+			rankedHit.Score *= float32(ranking.ComputeMultiplier(page, u, now))
 		} else {
-			hit.Score = 0
+			rankedHit.Score = 0
 		}
+		rankedHits = append(rankedHits, &rankedHit)
 	}
-
-	sort.Sort(results.Hits.Hits)
-	if returnSearchSize < len(results.Hits.Hits) {
-		results.Hits.Hits = results.Hits.Hits[0:returnSearchSize]
-	} else {
-		results.Hits.Hits = results.Hits.Hits[0:len(results.Hits.Hits)]
-	}
-	returnData.ResultMap["search"] = results.Hits
-	return pages.Success(returnData)
+	sort.Sort(rankedHits)
+	return rankedHits
 }
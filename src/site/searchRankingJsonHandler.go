@@ -0,0 +1,57 @@
+// searchRankingJsonHandler.go returns the search ranking rules for a domain.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+type searchRankingJSONData struct {
+	DomainID string
+}
+
+var searchRankingHandler = siteHandler{
+	URI:         "/json/searchRanking/",
+	HandlerFunc: searchRankingJSONHandler,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// searchRankingJSONHandler handles the request.
+func searchRankingJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data searchRankingJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIntIDValid(data.DomainID) {
+		data.DomainID = core.DefaultSearchRankingDomainID
+	}
+
+	ranking, err := core.LoadSearchRanking(db, data.DomainID)
+	if err != nil {
+		return pages.Fail("Couldn't load search ranking", err)
+	}
+	for tagID := range ranking.TagMultipliers {
+		core.AddPageToMap(tagID, returnData.PageMap, core.TitlePlusLoadOptions)
+	}
+
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+
+	returnData.ResultMap["searchRanking"] = ranking
+	returnData.ResultMap["canEdit"] = core.CanUserEditSearchRanking(u, data.DomainID)
+	return pages.Success(returnData)
+}
@@ -0,0 +1,49 @@
+// searchSynonymsJsonHandler.go returns the search synonyms for a domain.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+type searchSynonymsJSONData struct {
+	DomainID string
+}
+
+var searchSynonymsHandler = siteHandler{
+	URI:         "/json/searchSynonyms/",
+	HandlerFunc: searchSynonymsJSONHandler,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// searchSynonymsJSONHandler handles the request.
+func searchSynonymsJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data searchSynonymsJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIntIDValid(data.DomainID) {
+		data.DomainID = core.DefaultSearchRankingDomainID
+	}
+
+	groups, err := core.LoadSearchSynonyms(db, []string{data.DomainID})
+	if err != nil {
+		return pages.Fail("Couldn't load search synonyms", err)
+	}
+
+	returnData.ResultMap["searchSynonyms"] = groups
+	returnData.ResultMap["canEdit"] = core.CanUserEditSearchSynonyms(u, data.DomainID)
+	return pages.Success(returnData)
+}
@@ -85,5 +85,5 @@ func similarPageSearchJSONHandler(params *pages.HandlerParams) *pages.Result {
 		},
 		"_source": []
 	}`, minSearchScore, searchSize, escapedTitle, escapedClickbait, escapedText, strings.Join(domainIDs, ","))
-	return searchJSONInternalHandler(params, jsonStr)
+	return searchJSONInternalHandler(params, jsonStr, params.PrivateDomain.ID)
 }
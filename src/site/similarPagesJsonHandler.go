@@ -0,0 +1,51 @@
+// similarPagesJsonHandler.go contains the handler for returning JSON with the pages
+// whose text is similar to the given page.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/pages"
+)
+
+// similarPagesJSONData contains parameters passed in via the request.
+type similarPagesJSONData struct {
+	PageID string
+}
+
+var similarPagesHandler = siteHandler{
+	URI:         "/json/similarPages/",
+	HandlerFunc: similarPagesJSONHandler,
+}
+
+// similarPagesJSONHandler handles the request. Similar pages aren't part of the
+// primary page load anymore, so clients that want them have to ask here.
+func similarPagesJSONHandler(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+
+	// Decode data
+	var data similarPagesJSONData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.PageID) {
+		return pages.Fail("Need a valid pageId", nil).Status(http.StatusBadRequest)
+	}
+
+	returnData := core.NewHandlerData(params.U)
+
+	// Load the similar pages
+	loadOptions := (&core.PageLoadOptions{
+		SimilarPages: true,
+	}).Add(core.TitlePlusLoadOptions)
+	core.AddPageToMap(data.PageID, returnData.PageMap, loadOptions)
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+	return pages.Success(returnData)
+}
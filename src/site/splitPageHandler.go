@@ -0,0 +1,347 @@
+// splitPageHandler.go moves sections of a page into new pages of their own.
+
+package site
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+	"zanaduu3/src/tasks"
+)
+
+// splitPageSection describes one of the new pages.
+type splitPageSection struct {
+	// Index into the page's sections, as returned by core.SplitTextIntoSections
+	Index int
+	// Title of the new page. Defaults to the section's heading.
+	Title string
+	// Comments and marks to move to the new page
+	CommentIDs []string
+	MarkIDs    []string
+}
+
+// splitPageData contains parameters passed in via the request.
+type splitPageData struct {
+	PageID   string
+	Sections []*splitPageSection
+}
+
+var splitPageHandler = siteHandler{
+	URI:         "/splitPage/",
+	HandlerFunc: splitPageHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// splitPageHandlerFunc handles the request.
+func splitPageHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	c := params.C
+	db := params.DB
+	u := params.U
+
+	var data splitPageData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.PageID) {
+		return pages.Fail("PageId isn't set", nil).Status(http.StatusBadRequest)
+	}
+	if len(data.Sections) <= 0 {
+		return pages.Fail("No sections given", nil).Status(http.StatusBadRequest)
+	}
+
+	page, err := core.LoadFullEdit(db, data.PageID, u, make(map[string]*core.Domain), nil)
+	if err != nil {
+		return pages.Fail("Couldn't load the page", err)
+	} else if page == nil || page.IsDeleted {
+		return pages.Fail("Couldn't find the page", nil).Status(http.StatusBadRequest)
+	} else if page.Type == core.CommentPageType {
+		return pages.Fail("Can't split a comment", nil).Status(http.StatusBadRequest)
+	} else if !page.Permissions.Edit.Has {
+		return pages.Fail("Can't edit: "+page.Permissions.Edit.Reason, nil).Status(http.StatusForbidden)
+	}
+
+	// Check the sections
+	textSections := core.SplitTextIntoSections(page.Text)
+	splitIndexes := make(map[int]bool)
+	for _, section := range data.Sections {
+		if section.Index < 0 || section.Index >= len(textSections) {
+			return pages.Fail(fmt.Sprintf("Invalid section index: %d", section.Index), nil).Status(http.StatusBadRequest)
+		} else if splitIndexes[section.Index] {
+			return pages.Fail(fmt.Sprintf("Section %d is given twice", section.Index), nil).Status(http.StatusBadRequest)
+		}
+		splitIndexes[section.Index] = true
+		if section.Title == "" {
+			section.Title = textSections[section.Index].Heading
+		}
+		if section.Title == "" {
+			return pages.Fail(fmt.Sprintf("Section %d needs a title", section.Index), nil).Status(http.StatusBadRequest)
+		}
+	}
+	if len(splitIndexes) >= len(textSections) {
+		return pages.Fail("At least one section has to stay on the page", nil).Status(http.StatusBadRequest)
+	}
+
+	// Check that the comments and marks belong to the page
+	replyIDs, err := loadSplitCommentReplies(db, data.PageID)
+	if err != nil {
+		return pages.Fail("Couldn't load comments", err)
+	}
+	movedCommentIDs := make(map[string]bool)
+	movedMarkIDs := make(map[string]bool)
+	for _, section := range data.Sections {
+		for _, commentID := range section.CommentIDs {
+			if _, ok := replyIDs[commentID]; !ok {
+				return pages.Fail("Not a top level comment on the page: "+commentID, nil).Status(http.StatusBadRequest)
+			} else if movedCommentIDs[commentID] {
+				return pages.Fail("Comment is moved twice: "+commentID, nil).Status(http.StatusBadRequest)
+			}
+			movedCommentIDs[commentID] = true
+		}
+		for _, markID := range section.MarkIDs {
+			if movedMarkIDs[markID] {
+				return pages.Fail("Mark is moved twice: "+markID, nil).Status(http.StatusBadRequest)
+			}
+			movedMarkIDs[markID] = true
+		}
+	}
+	if len(movedMarkIDs) > 0 {
+		markIDs := make([]string, 0)
+		for markID := range movedMarkIDs {
+			markIDs = append(markIDs, markID)
+		}
+		var count int
+		row := database.NewQuery(`
+			SELECT COUNT(*)
+			FROM marks
+			WHERE pageId=?`, data.PageID).Add(`AND id IN`).AddArgsGroupStr(markIDs).ToStatement(db).QueryRow()
+		if _, err := row.Scan(&count); err != nil {
+			return pages.Fail("Couldn't check marks", err)
+		} else if count != len(movedMarkIDs) {
+			return pages.Fail("Some of the marks aren't on the page", nil).Status(http.StatusBadRequest)
+		}
+	}
+
+	newPageIDs := make([]string, 0)
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		newText := ""
+		sectionsByIndex := make(map[int]*splitPageSection)
+		for _, section := range data.Sections {
+			sectionsByIndex[section.Index] = section
+		}
+		for index, textSection := range textSections {
+			section, ok := sectionsByIndex[index]
+			if !ok {
+				newText += textSection.Text
+				continue
+			}
+			newPageID, err2 := splitPageSectionTx(tx, params, page, textSection, section, replyIDs)
+			if err2 != nil {
+				return err2
+			}
+			newPageIDs = append(newPageIDs, newPageID)
+
+			// Leave the heading and a link to the new page behind
+			if textSection.Heading != "" {
+				newText += strings.SplitAfterN(textSection.Text, "\n", 2)[0]
+				if !strings.HasSuffix(newText, "\n") {
+					newText += "\n"
+				}
+				newText += "\n"
+			}
+			newText += fmt.Sprintf("[%s]\n\n", newPageID)
+		}
+
+		_, ok, err := core.SaveNewLiveEdit(tx, &core.NewLiveEditOptions{
+			PageID:        page.PageID,
+			CreatorID:     u.ID,
+			ExpectedEdit:  page.Edit,
+			Title:         page.Title,
+			Clickbait:     page.Clickbait,
+			Text:          newText,
+			MetaText:      page.MetaText,
+			AnchorContext: page.AnchorContext,
+			AnchorText:    page.AnchorText,
+			AnchorOffset:  page.AnchorOffset,
+			EditSummary:   "Split into " + strings.Join(newPageIDs, ", "),
+			ChangeLogType: core.NewEditChangeLog,
+		})
+		if err != nil {
+			return sessions.NewError("Couldn't save the new edit", err)
+		} else if !ok {
+			return sessions.NewError("The page was changed in the meantime", nil)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	}
+
+	var elasticTask tasks.UpdateElasticPageTask
+	elasticTask.PageID = data.PageID
+	if err := tasks.Enqueue(c, &elasticTask, nil); err != nil {
+		c.Errorf("Couldn't enqueue a task: %v", err)
+	}
+	for _, newPageID := range newPageIDs {
+		var pagePairsTask tasks.UpdatePagePairsTask
+		pagePairsTask.PageID = newPageID
+		if err := tasks.Enqueue(c, &pagePairsTask, nil); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
+		}
+		var elasticTask tasks.UpdateElasticPageTask
+		elasticTask.PageID = newPageID
+		if err := tasks.Enqueue(c, &elasticTask, nil); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
+
+	returnData := core.NewHandlerData(u)
+	returnData.ResultMap["pageIds"] = newPageIDs
+	core.AddPageToMap(data.PageID, returnData.PageMap, core.TitlePlusLoadOptions)
+	for _, newPageID := range newPageIDs {
+		core.AddPageToMap(newPageID, returnData.PageMap, core.TitlePlusLoadOptions)
+	}
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+	return pages.Success(returnData)
+}
+
+// loadSplitCommentReplies returns the top level comments on the page, each
+// mapped to the ids of its replies.
+func loadSplitCommentReplies(db *database.DB, pageID string) (map[string][]string, error) {
+	parentIDs := make(map[string][]string)
+	rows := database.NewQuery(`
+		SELECT pp.childId,pp2.parentId
+		FROM pagePairs AS pp
+		JOIN pageInfos AS pi
+		ON (pi.pageId=pp.childId)
+		LEFT JOIN pagePairs AS pp2
+		ON (pp2.childId=pp.childId AND pp2.type=? AND pp2.parentId!=pp.parentId)`, core.ParentPagePairType).Add(`
+		WHERE pp.parentId=? AND pp.type=? AND pi.type=?`, pageID, core.ParentPagePairType, core.CommentPageType).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var commentID string
+		var otherParentID sql.NullString
+		if err := rows.Scan(&commentID, &otherParentID); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		if _, ok := parentIDs[commentID]; !ok {
+			parentIDs[commentID] = make([]string, 0)
+		}
+		if otherParentID.Valid {
+			parentIDs[commentID] = append(parentIDs[commentID], otherParentID.String)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A comment is a reply if one of its other parents is a comment on the page
+	replyIDs := make(map[string][]string)
+	for commentID, otherParentIDs := range parentIDs {
+		isReply := false
+		for _, otherParentID := range otherParentIDs {
+			if _, ok := parentIDs[otherParentID]; ok {
+				isReply = true
+			}
+		}
+		if !isReply {
+			replyIDs[commentID] = make([]string, 0)
+		}
+	}
+	for commentID, otherParentIDs := range parentIDs {
+		for _, otherParentID := range otherParentIDs {
+			if _, ok := replyIDs[otherParentID]; ok {
+				replyIDs[otherParentID] = append(replyIDs[otherParentID], commentID)
+			}
+		}
+	}
+	return replyIDs, nil
+}
+
+// splitPageSectionTx creates a new page from the section, and moves the
+// section's comments and marks to it.
+func splitPageSectionTx(tx *database.Tx, params *pages.HandlerParams, page *core.Page, textSection *core.TextSection,
+	section *splitPageSection, replyIDs map[string][]string) (string, sessions.Error) {
+	u := params.U
+	text := textSection.Body()
+	newPageID, err := core.CreateNewPage(tx.DB, u, &core.CreateNewPageOptions{
+		SeeDomainID:  page.SeeDomainID,
+		EditDomainID: page.EditDomainID,
+		Title:        section.Title,
+		Text:         text,
+		IsPublished:  true,
+		ParentIDs:    []string{page.PageID},
+		Tx:           tx,
+	})
+	if err != nil {
+		return "", sessions.NewError("Couldn't create a new page", err)
+	}
+	if err := core.UpdatePageLinks(tx, newPageID, text, sessions.GetDomain()); err != nil {
+		return "", sessions.NewError("Couldn't update links", err)
+	}
+	if err := core.UpdatePageSummaries(tx, newPageID, text); err != nil {
+		return "", sessions.NewError("Couldn't update summaries", err)
+	}
+	if err := core.UpdatePageEstimates(tx, newPageID, text); err != nil {
+		return "", sessions.NewError("Couldn't update estimates", err)
+	}
+
+	changeLogs := []*core.ChangeLog{
+		&core.ChangeLog{PageID: newPageID, UserID: u.ID, Edit: 1, Type: core.NewEditChangeLog},
+		&core.ChangeLog{PageID: newPageID, UserID: u.ID, Edit: 1, Type: core.SplitFromChangeLog, AuxPageID: page.PageID},
+		&core.ChangeLog{PageID: page.PageID, UserID: u.ID, Edit: page.Edit, Type: core.SplitPageChangeLog, AuxPageID: newPageID},
+	}
+
+	// Move the comments together with their replies
+	commentIDs := make([]string, 0)
+	for _, commentID := range section.CommentIDs {
+		commentIDs = append(commentIDs, commentID)
+		commentIDs = append(commentIDs, replyIDs[commentID]...)
+	}
+	if len(commentIDs) > 0 {
+		statement := database.NewQuery(`
+			UPDATE pagePairs
+			SET parentId=?`, newPageID).Add(`
+			WHERE parentId=? AND type=?`, page.PageID, core.ParentPagePairType).Add(`
+				AND childId IN`).AddArgsGroupStr(commentIDs).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return "", sessions.NewError("Couldn't move comments", err)
+		}
+		for _, commentID := range commentIDs {
+			changeLogs = append(changeLogs,
+				&core.ChangeLog{PageID: commentID, UserID: u.ID, Type: core.DeleteParentChangeLog, AuxPageID: page.PageID},
+				&core.ChangeLog{PageID: commentID, UserID: u.ID, Type: core.NewParentChangeLog, AuxPageID: newPageID},
+				&core.ChangeLog{PageID: page.PageID, UserID: u.ID, Type: core.DeleteChildChangeLog, AuxPageID: commentID},
+				&core.ChangeLog{PageID: newPageID, UserID: u.ID, Type: core.NewChildChangeLog, AuxPageID: commentID})
+		}
+	}
+
+	if len(section.MarkIDs) > 0 {
+		statement := database.NewQuery(`
+			UPDATE marks
+			SET pageId=?,edit=1`, newPageID).Add(`
+			WHERE pageId=? AND id IN`, page.PageID).AddArgsGroupStr(section.MarkIDs).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return "", sessions.NewError("Couldn't move marks", err)
+		}
+	}
+
+	for _, changeLog := range changeLogs {
+		if _, err := core.InsertChangeLog(tx, changeLog); err != nil {
+			return "", sessions.NewError("Couldn't insert change log", err)
+		}
+	}
+	return newPageID, nil
+}
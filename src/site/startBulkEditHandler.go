@@ -0,0 +1,109 @@
+// startBulkEditHandler.go kicks off a bulk find-and-replace across pages.
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/tasks"
+)
+
+// startBulkEditData contains parameters passed in via the request.
+type startBulkEditData struct {
+	bulkEditData
+	EditSummary string
+	// If set, resume this unfinished bulk edit instead of starting a new one
+	ResumeBulkEditID string
+}
+
+var startBulkEditHandler = siteHandler{
+	URI:         "/startBulkEdit/",
+	HandlerFunc: startBulkEditHandlerFunc,
+	Options: pages.PageOptions{
+		AdminOnly: true,
+	},
+}
+
+// startBulkEditHandlerFunc handles the request.
+func startBulkEditHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data startBulkEditData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if _, err := core.LoadBulkEditUserID(db); err != nil {
+		return pages.Fail("Bulk edit account isn't set up", err)
+	}
+
+	bulkEditID := data.ResumeBulkEditID
+	if bulkEditID != "" {
+		bulkEdit, err := core.LoadBulkEdit(db, bulkEditID)
+		if err != nil {
+			return pages.Fail("Couldn't load the bulk edit", err)
+		} else if bulkEdit == nil {
+			return pages.Fail("Couldn't find the bulk edit", nil).Status(http.StatusBadRequest)
+		} else if bulkEdit.Status != core.RunningBulkEditStatus && bulkEdit.Status != core.UndoingBulkEditStatus {
+			return pages.Fail("This bulk edit is already "+bulkEdit.Status, nil).Status(http.StatusBadRequest)
+		}
+	} else {
+		if _, err := core.NewBulkEditReplacer(data.FindText, data.ReplaceText, data.IsRegexp); err != nil {
+			return pages.Fail("Invalid find text", err).Status(http.StatusBadRequest)
+		}
+		if data.EditSummary == "" {
+			return pages.Fail("Edit summary has to be set", nil).Status(http.StatusBadRequest)
+		}
+		if data.DomainID == "" {
+			data.DomainID = "0"
+		} else if !core.IsIntIDValid(data.DomainID) {
+			return pages.Fail("Invalid domain id", nil).Status(http.StatusBadRequest)
+		}
+		for _, pageID := range data.PageIDs {
+			if !core.IsIDValid(pageID) {
+				return pages.Fail("Invalid page id: "+pageID, nil).Status(http.StatusBadRequest)
+			}
+		}
+
+		now := database.Now()
+		hashmap := make(database.InsertMap)
+		hashmap["createdBy"] = u.ID
+		hashmap["createdAt"] = now
+		hashmap["updatedAt"] = now
+		hashmap["findText"] = data.FindText
+		hashmap["replaceText"] = data.ReplaceText
+		hashmap["isRegexp"] = data.IsRegexp
+		hashmap["domainId"] = data.DomainID
+		hashmap["pageIds"] = strings.Join(data.PageIDs, ",")
+		hashmap["editSummary"] = data.EditSummary
+		hashmap["status"] = core.RunningBulkEditStatus
+		statement := db.NewInsertStatement("bulkEdits", hashmap)
+		result, err := statement.Exec()
+		if err != nil {
+			return pages.Fail("Couldn't create a bulk edit", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return pages.Fail("Couldn't get bulk edit id", err)
+		}
+		bulkEditID = fmt.Sprintf("%d", id)
+	}
+
+	var task tasks.BulkEditTask
+	task.BulkEditID = bulkEditID
+	if err := tasks.Enqueue(params.C, &task, nil); err != nil {
+		return pages.Fail("Couldn't enqueue a task", err)
+	}
+
+	returnData.ResultMap["bulkEditId"] = bulkEditID
+	return pages.Success(returnData)
+}
@@ -0,0 +1,92 @@
+// startEditCompactionHandler.go kicks off the edit history compaction.
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/tasks"
+)
+
+// startEditCompactionData contains parameters passed in via the request.
+type startEditCompactionData struct {
+	// If true, only report what would be removed
+	IsDryRun bool
+	// Minor edits older than this many days can be collapsed
+	MinorEditRetentionDays int
+	// If set, resume this unfinished compaction instead of starting a new one
+	ResumeCompactionID string
+}
+
+var startEditCompactionHandler = siteHandler{
+	URI:         "/startEditCompaction/",
+	HandlerFunc: startEditCompactionHandlerFunc,
+	Options: pages.PageOptions{
+		AdminOnly: true,
+	},
+}
+
+// startEditCompactionHandlerFunc handles the request.
+func startEditCompactionHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data startEditCompactionData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if data.MinorEditRetentionDays < 0 {
+		return pages.Fail("Invalid retention period", nil).Status(http.StatusBadRequest)
+	} else if data.MinorEditRetentionDays == 0 {
+		data.MinorEditRetentionDays = core.DefaultMinorEditRetentionDays
+	}
+
+	compactionID := data.ResumeCompactionID
+	if compactionID != "" {
+		compaction, err := core.LoadEditCompaction(db, compactionID)
+		if err != nil {
+			return pages.Fail("Couldn't load the compaction", err)
+		} else if compaction == nil {
+			return pages.Fail("Couldn't find the compaction", nil).Status(http.StatusBadRequest)
+		} else if compaction.IsFinished {
+			return pages.Fail("This compaction is already finished", nil).Status(http.StatusBadRequest)
+		}
+	} else {
+		now := database.Now()
+		cutoff := time.Now().UTC().AddDate(0, 0, -data.MinorEditRetentionDays)
+		hashmap := make(database.InsertMap)
+		hashmap["createdBy"] = u.ID
+		hashmap["createdAt"] = now
+		hashmap["updatedAt"] = now
+		hashmap["isDryRun"] = data.IsDryRun
+		hashmap["minorEditCutoff"] = cutoff.Format(database.TimeLayout)
+		statement := db.NewInsertStatement("editCompactions", hashmap)
+		result, err := statement.Exec()
+		if err != nil {
+			return pages.Fail("Couldn't create an edit compaction", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return pages.Fail("Couldn't get compaction id", err)
+		}
+		compactionID = fmt.Sprintf("%d", id)
+	}
+
+	var task tasks.CompactEditsTask
+	task.CompactionID = compactionID
+	if err := tasks.Enqueue(params.C, &task, nil); err != nil {
+		return pages.Fail("Couldn't enqueue a task", err)
+	}
+
+	returnData.ResultMap["compactionId"] = compactionID
+	return pages.Success(returnData)
+}
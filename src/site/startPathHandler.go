@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"zanaduu3/src/core"
 	"zanaduu3/src/database"
@@ -21,6 +20,10 @@ var startPathHandler = siteHandler{
 
 type startPathData struct {
 	GuideID string
+	// Speed the user prefers: -1 for slower pages, 1 for faster ones, 0 for either
+	Speed int
+	// If true, a teacher is starting the path for a group of students
+	IsGroup bool
 }
 
 func startPathHandlerFunc(params *pages.HandlerParams) *pages.Result {
@@ -37,49 +40,41 @@ func startPathHandlerFunc(params *pages.HandlerParams) *pages.Result {
 	if !core.IsIDValid(data.GuideID) {
 		return pages.Fail("Invalid guideId", nil).Status(http.StatusBadRequest)
 	}
+	if data.Speed < -1 || data.Speed > 1 {
+		return pages.Fail("Invalid speed", nil).Status(http.StatusBadRequest)
+	}
+	if data.IsGroup && u.ID == "" {
+		return pages.Fail("Have to be logged in to start a path for a group", nil).Status(http.StatusForbidden)
+	}
 
-	// Load path pages
-	pathPageIDs := []string{data.GuideID}
-	queryPart := database.NewQuery(`
-		WHERE pathp.guideId=?`, data.GuideID).Add(`
-		ORDER BY pathp.pathIndex`)
-	err = core.LoadPathPages(db, queryPart, nil, func(db *database.DB, pathPage *core.PathPage) error {
-		pathPageIDs = append(pathPageIDs, pathPage.PathPageID)
-		return nil
-	})
+	// Resolve which pages the user will read
+	steps, err := core.LoadPathSteps(db, data.GuideID)
 	if err != nil {
 		return pages.Fail("Couldn't load the path pages: %v", err)
-	} else if len(pathPageIDs) <= 0 {
+	} else if len(steps) <= 0 {
 		return pages.Fail("No path pages found for this guide", nil).Status(http.StatusBadRequest)
 	}
-
-	// Create the sourcePageIds
-	sourcePageIDs := make([]string, 0)
-	for range pathPageIDs {
-		sourcePageIDs = append(sourcePageIDs, data.GuideID)
+	context, err := core.LoadPathStepContext(db, u, steps, data.Speed)
+	if err != nil {
+		return pages.Fail("Couldn't load the path context", err)
 	}
+	instance := core.NewPathInstance()
+	instance.GuideID = data.GuideID
+	instance.Speed = data.Speed
+	instance.Progress = 1
+	instance.IsGroup = data.IsGroup
+	instance.Pages = append(instance.Pages, &core.PathInstancePage{PageID: data.GuideID, SourceID: data.GuideID})
+	seen := map[string]bool{data.GuideID: true}
+	instance.Pages = append(instance.Pages, core.ResolvePathSteps(data.GuideID, steps, context, seen)...)
 
 	// Begin the transaction.
 	var id int64
 	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
 		// Start the path
-		hashmap := make(database.InsertMap)
-		hashmap["userId"] = u.GetSomeID()
-		hashmap["guideId"] = data.GuideID
-		hashmap["pageIds"] = strings.Join(pathPageIDs, ",")
-		hashmap["sourcePageIds"] = strings.Join(sourcePageIDs, ",")
-		hashmap["progress"] = 1
-		hashmap["createdAt"] = database.Now()
-		hashmap["updatedAt"] = database.Now()
-		statement := db.NewInsertStatement("pathInstances", hashmap).WithTx(tx)
-		result, err := statement.Exec()
-		if err != nil {
-			return sessions.NewError("Couldn't insert pathInstance", err)
-		}
-
-		id, err = result.LastInsertId()
+		var err error
+		id, err = core.InsertPathInstance(tx, u.GetSomeID(), instance)
 		if err != nil {
-			return sessions.NewError("Couldn't get lens id", err)
+			return sessions.NewError("Couldn't start the path", err)
 		}
 		return nil
 	})
@@ -0,0 +1,82 @@
+// startSearchIndexCheckHandler.go kicks off a consistency check between the db and the search index.
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/tasks"
+)
+
+// startSearchIndexCheckData contains parameters passed in via the request.
+type startSearchIndexCheckData struct {
+	// If true, fix the problems found, instead of just reporting them
+	Repair bool
+	// If set, resume this unfinished check instead of starting a new one
+	ResumeCheckID string
+}
+
+var startSearchIndexCheckHandler = siteHandler{
+	URI:         "/startSearchIndexCheck/",
+	HandlerFunc: startSearchIndexCheckHandlerFunc,
+	Options: pages.PageOptions{
+		AdminOnly: true,
+	},
+}
+
+// startSearchIndexCheckHandlerFunc handles the request.
+func startSearchIndexCheckHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data startSearchIndexCheckData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+
+	checkID := data.ResumeCheckID
+	if checkID != "" {
+		check, err := core.LoadSearchIndexCheck(db, checkID)
+		if err != nil {
+			return pages.Fail("Couldn't load the check", err)
+		} else if check == nil {
+			return pages.Fail("Couldn't find the check", nil).Status(http.StatusBadRequest)
+		} else if check.IsFinished {
+			return pages.Fail("This check is already finished", nil).Status(http.StatusBadRequest)
+		}
+	} else {
+		now := database.Now()
+		hashmap := make(database.InsertMap)
+		hashmap["createdBy"] = u.ID
+		hashmap["createdAt"] = now
+		hashmap["updatedAt"] = now
+		hashmap["repair"] = data.Repair
+		statement := db.NewInsertStatement("searchIndexChecks", hashmap)
+		result, err := statement.Exec()
+		if err != nil {
+			return pages.Fail("Couldn't create a search index check", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return pages.Fail("Couldn't get check id", err)
+		}
+		checkID = fmt.Sprintf("%d", id)
+	}
+
+	var task tasks.CheckSearchIndexTask
+	task.CheckID = checkID
+	if err := tasks.Enqueue(params.C, &task, nil); err != nil {
+		return pages.Fail("Couldn't enqueue a task", err)
+	}
+
+	returnData.ResultMap["checkId"] = checkID
+	return pages.Success(returnData)
+}
@@ -100,6 +100,7 @@ app.run(function($http, $location, arb) {
 			pathPageId: $location.search().pathPageId,
 			// Load the path if it's not loaded already
 			pathInstanceId: arb.stateService.path ? arb.stateService.path.id : $location.search().pathId,
+			pathShareKey: arb.stateService.path ? undefined : $location.search().pathKey,
 		};
 		$http({method: 'POST', url: '/json/primaryPage/', data: JSON.stringify(postData)})
 		.success($scope.getSuccessFunc(function(data): any {
@@ -107,7 +108,10 @@ app.run(function($http, $location, arb) {
 			var page = arb.stateService.pageMap[primaryPageId];
 			var pageTemplate = '<arb-primary-page></arb-primary-page>';
 
-			if (data.result.path) {
+			if (data.result.path && !data.result.path.isByCurrentUser) {
+				// Someone shared their path with us
+				arb.pathService.forkPath(data.result.path.id, postData.pathShareKey);
+			} else if (data.result.path) {
 				arb.stateService.path = data.result.path;
 			} else if (arb.stateService.path && !$location.search().pathId) {
 				// We are off the path. Forget the path if it was finished.
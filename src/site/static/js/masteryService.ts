@@ -278,6 +278,22 @@ app.service('masteryService', function($http, $compile, $location, $mdToast, $ro
 		this.pageObjectMap[options.pageId][options.object] = options;
 
 		$http({method: 'POST', url: '/updatePageObject/', data: JSON.stringify(options)})
+		.success(function(data) {
+			// Passing a quiz can give the user new masteries
+			var grantedMasteries = data && data.result && data.result.grantedMasteries;
+			if (!grantedMasteries) return;
+			for (var n = 0; n < grantedMasteries.length; n++) {
+				var grant = grantedMasteries[n];
+				var mastery = that.masteryMap[grant.masteryId];
+				if (!mastery) {
+					mastery = {pageId: grant.masteryId};
+					that.masteryMap[grant.masteryId] = mastery;
+				}
+				mastery.has = true;
+				mastery.wants = false;
+				mastery.level = grant.level;
+			}
+		})
 		.error(function(data, status) {
 			console.error('Failed to update page object:'); console.log(data); console.log(status);
 		});
@@ -42,10 +42,12 @@ app.service('pathService', function($http, $compile, $location, $mdToast, $rootS
 
 	// ============= NEW PATH STUFF =================
 
-	// Start the path associated with the given guide for the current user
-	this.startPath = function(guideId) {
+	// Start the path associated with the given guide for the current user.
+	// speed: -1 to prefer slower pages, 1 to prefer faster ones (optional)
+	this.startPath = function(guideId, speed) {
 		var params = {
 			guideId: guideId,
+			speed: speed || 0,
 		};
 		stateService.postData('/json/startPath/', params, function(data) {
 			stateService.path = data.result.path;
@@ -53,6 +55,14 @@ app.service('pathService', function($http, $compile, $location, $mdToast, $rootS
 		});
 	};
 
+	// Start a copy of someone else's path instance for the current user
+	this.forkPath = function(instanceId, shareKey) {
+		stateService.postData('/json/forkPath/', {instanceId: instanceId, shareKey: shareKey}, function(data) {
+			stateService.path = data.result.path;
+			that.goToPathPage();
+		});
+	};
+
 	// Add/remove the given pageIds to the path at the current point
 	this.extendPath = function(index, pageIds) {
 		if (!that.isOnPath()) return;
@@ -101,6 +111,7 @@ app.service('pathService', function($http, $compile, $location, $mdToast, $rootS
 		stateService.path.isFinished = true;
 		that.updateProgress(stateService.path.progress);
 		$location.replace().search("pathId", undefined);
+		$location.replace().search("pathKey", undefined);
 	};
 
 	// Go to the page that the path's progress says we should be on
@@ -112,7 +123,10 @@ app.service('pathService', function($http, $compile, $location, $mdToast, $rootS
 				that.updateProgress(path.progress + 1);
 				return;
 			}
-			var url = urlService.getPageUrl(path.pages[path.progress].pageId, {pathInstanceId: path.id});
+			var url = urlService.getPageUrl(path.pages[path.progress].pageId, {
+				pathInstanceId: path.id,
+				pathShareKey: path.shareKey,
+			});
 		} else {
 			var url = urlService.getPageUrl(path.guideId);
 			stateService.path = undefined;
@@ -82,10 +82,16 @@ app.directive('arbDeletedPageUpdateRow', getUpdateRowDirectiveFunc(versionUrl('s
 app.directive('arbPageToDomainSubmissionUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/pageToDomainSubmissionUpdateRow.html')));
 app.directive('arbPageToDomainAcceptedUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/pageToDomainAcceptedUpdateRow.html')));
 app.directive('arbEditProposalAcceptedUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/editProposalAcceptedUpdateRow.html')));
+app.directive('arbEditProposalPartiallyAcceptedUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/editProposalPartiallyAcceptedUpdateRow.html')));
+app.directive('arbEditProposalCommentUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/editProposalCommentUpdateRow.html')));
+app.directive('arbEditProposalChangesRequestedUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/editProposalChangesRequestedUpdateRow.html')));
+app.directive('arbEditProposalRejectedUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/editProposalRejectedUpdateRow.html')));
 app.directive('arbRelationshipUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/relationshipUpdateRow.html')));
 app.directive('arbResolvedThreadUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/resolvedThreadUpdateRow.html')));
 app.directive('arbSettingsUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/settingsUpdateRow.html')));
 app.directive('arbPageEditUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/pageEditUpdateRow.html')));
+app.directive('arbMasteryReviewUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/masteryReviewUpdateRow.html')));
+app.directive('arbScheduledPublishFailedUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/scheduledPublishFailedUpdateRow.html')));
 
 app.directive('arbCommentUpdateRow', getUpdateRowDirectiveFunc(versionUrl('static/html/rows/updates/commentUpdateRow.html'),
 	function($scope) {
@@ -216,6 +222,19 @@ app.directive('arbInviteReceivedModeRow', function(arb) {
 	};
 });
 
+// arb-requisite-graph-finding-row is the directive for showing a problem found by the requisite graph check
+app.directive('arbRequisiteGraphFindingRow', function(arb) {
+	return {
+		templateUrl: versionUrl('static/html/rows/requisiteGraphFindingRow.html'),
+		scope: {
+			finding: '=',
+		},
+		controller: function($scope) {
+			$scope.arb = arb;
+		},
+	};
+});
+
 // arb-user-trust-mode-row is the directive for showing that the user trust has changed
 app.directive('arbUserTrustModeRow', function(arb) {
 	return {
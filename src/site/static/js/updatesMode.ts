@@ -23,6 +23,8 @@ app.directive('arbUpdatesPanel', function($http, arb) {
 				function(data) {
 					$scope.modeRows = data.result.modeRows;
 					$scope.lastView = data.result.lastView;
+					// Only sent for maintenance updates
+					$scope.requisiteGraphFindings = data.result.requisiteGraphFindings;
 				});
 
 			$scope.dismissRow = function(allRows, index) {
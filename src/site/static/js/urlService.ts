@@ -150,6 +150,8 @@ app.service('urlService', function($http, $location, $rootScope, stateService) {
 		markId?: string,
 		// If set, the user is on the given path
 		pathInstanceId?: string,
+		// Key needed to open someone else's path instance
+		pathShareKey?: string,
 		// If set, this page determines what arc the user is on
 		pathPageId?: string,
 		// If set, the user is doing an arc starting from this hub page
@@ -203,6 +205,9 @@ app.service('urlService', function($http, $location, $rootScope, stateService) {
 		if (options.pathInstanceId) {
 			url += url.indexOf('?') < 0 ? '?' : '&';
 			url += 'pathId=' + options.pathInstanceId;
+			if (options.pathShareKey) {
+				url += '&pathKey=' + options.pathShareKey;
+			}
 		}
 
 		if (options.pathPageId) {
@@ -0,0 +1,65 @@
+// undoBulkEditHandler.go reverts all the pages changed by a bulk edit.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/tasks"
+)
+
+// undoBulkEditData contains parameters passed in via the request.
+type undoBulkEditData struct {
+	BulkEditID string
+}
+
+var undoBulkEditHandler = siteHandler{
+	URI:         "/undoBulkEdit/",
+	HandlerFunc: undoBulkEditHandlerFunc,
+	Options: pages.PageOptions{
+		AdminOnly: true,
+	},
+}
+
+// undoBulkEditHandlerFunc handles the request.
+func undoBulkEditHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+
+	// Decode data
+	var data undoBulkEditData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if _, err := core.LoadBulkEditUserID(db); err != nil {
+		return pages.Fail("Bulk edit account isn't set up", err)
+	}
+
+	bulkEdit, err := core.LoadBulkEdit(db, data.BulkEditID)
+	if err != nil {
+		return pages.Fail("Couldn't load the bulk edit", err)
+	} else if bulkEdit == nil {
+		return pages.Fail("Couldn't find the bulk edit", nil).Status(http.StatusBadRequest)
+	} else if bulkEdit.Status != core.FinishedBulkEditStatus {
+		return pages.Fail("Only finished bulk edits can be undone", nil).Status(http.StatusBadRequest)
+	}
+
+	statement := database.NewQuery(`
+		UPDATE bulkEdits
+		SET status=?,updatedAt=?`, core.UndoingBulkEditStatus, database.Now()).Add(`
+		WHERE id=? AND status=?`, bulkEdit.ID, core.FinishedBulkEditStatus).ToStatement(db)
+	if _, err := statement.Exec(); err != nil {
+		return pages.Fail("Couldn't update the bulk edit", err)
+	}
+
+	var task tasks.BulkEditTask
+	task.BulkEditID = bulkEdit.ID
+	if err := tasks.Enqueue(params.C, &task, nil); err != nil {
+		return pages.Fail("Couldn't enqueue a task", err)
+	}
+	return pages.Success(nil)
+}
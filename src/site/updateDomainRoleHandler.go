@@ -26,15 +26,19 @@ var updateDomainRoleHandler = siteHandler{
 }
 
 func updateDomainRoleHandlerFunc(params *pages.HandlerParams) *pages.Result {
-	db := params.DB
-	u := params.U
-
 	// Decode data
 	var data updateDomainRoleData
 	err := json.NewDecoder(params.R.Body).Decode(&data)
 	if err != nil {
 		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
 	}
+	return updateDomainRoleInternalHandlerFunc(params, &data)
+}
+
+func updateDomainRoleInternalHandlerFunc(params *pages.HandlerParams, data *updateDomainRoleData) *pages.Result {
+	db := params.DB
+	u := params.U
+
 	if !core.IsIDValid(data.UserID) {
 		return pages.Fail("ToId is incorrect", nil).Status(http.StatusBadRequest)
 	}
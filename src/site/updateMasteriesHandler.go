@@ -36,6 +36,7 @@ func updateMasteriesHandlerFunc(params *pages.HandlerParams) *pages.Result {
 }
 
 func updateMasteriesInternalHandlerFunc(params *pages.HandlerParams, data *updateMasteries) *pages.Result {
+	c := params.C
 	db := params.DB
 	u := params.U
 
@@ -81,5 +82,10 @@ func updateMasteriesInternalHandlerFunc(params *pages.HandlerParams, data *updat
 		return pages.FailWith(serr)
 	}
 
+	// Skip or add pages on the user's paths
+	if err := core.RecomputeUserPathInstances(db, u); err != nil {
+		c.Errorf("Couldn't recompute path instances: %v", err)
+	}
+
 	return pages.Success(nil)
 }
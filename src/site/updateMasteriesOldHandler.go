@@ -42,6 +42,7 @@ func updateMasteriesOldHandlerFunc(params *pages.HandlerParams) *pages.Result {
 }
 
 func updateMasteriesInternalOldHandlerFunc(params *pages.HandlerParams, data *updateMasteriesOld) *pages.Result {
+	c := params.C
 	db := params.DB
 	u := params.U
 	returnData := core.NewHandlerData(u)
@@ -141,6 +142,11 @@ func updateMasteriesInternalOldHandlerFunc(params *pages.HandlerParams, data *up
 		return pages.FailWith(err2)
 	}
 
+	// Skip or add pages on the user's paths
+	if err := core.RecomputeUserPathInstances(db, u); err != nil {
+		c.Errorf("Couldn't recompute path instances: %v", err)
+	}
+
 	if len(candidateIDs) <= 0 {
 		return pages.Success(nil)
 	}
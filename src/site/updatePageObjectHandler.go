@@ -9,6 +9,7 @@ import (
 	"zanaduu3/src/core"
 	"zanaduu3/src/database"
 	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
 )
 
 // updatePageObject contains the data we get in the request.
@@ -37,8 +38,10 @@ func updatePageObjectHandlerFunc(params *pages.HandlerParams) *pages.Result {
 }
 
 func updatePageObjectInternalHandlerFunc(params *pages.HandlerParams, data *updatePageObject) *pages.Result {
+	c := params.C
 	db := params.DB
 	u := params.U
+	returnData := core.NewHandlerData(u)
 
 	if !core.IsIDValid(data.PageID) {
 		return pages.Fail("Invalid page id", nil).Status(http.StatusBadRequest)
@@ -51,6 +54,46 @@ func updatePageObjectInternalHandlerFunc(params *pages.HandlerParams, data *upda
 		return pages.Fail("No user id or session id", nil).Status(http.StatusBadRequest)
 	}
 
+	// Answers to quiz questions can only be changed a few times, so readers can't
+	// cycle through the answers until they pass. The quiz comes from the page's
+	// live edit, not the edit the client says it's looking at.
+	var currentEdit int
+	_, err := database.NewQuery(`
+		SELECT currentEdit
+		FROM pageInfos
+		WHERE pageId=?`, data.PageID).ToStatement(db).QueryRow().Scan(&currentEdit)
+	if err != nil {
+		return pages.Fail("Couldn't load the page's current edit", err)
+	}
+	quiz, err := core.LoadQuiz(db, data.PageID, currentEdit)
+	if err != nil {
+		return pages.Fail("Couldn't load the quiz", err)
+	}
+	isQuizQuestion := false
+	if quiz != nil {
+		_, isQuizQuestion = quiz.AnswerKeys[data.Object]
+	}
+	attempts := 0
+	if isQuizQuestion {
+		var prevValue string
+		row := database.NewQuery(`
+			SELECT value,attempts
+			FROM userPageObjectPairs
+			WHERE userId=?`, userID).Add(`
+				AND pageId=?`, data.PageID).Add(`
+				AND object=?`, data.Object).ToStatement(db).QueryRow()
+		exists, err := row.Scan(&prevValue, &attempts)
+		if err != nil {
+			return pages.Fail("Couldn't load the previous answer", err)
+		}
+		if !exists || prevValue != data.Value {
+			if attempts >= core.MaxQuizAnswerAttempts {
+				return pages.Fail("You can't change this answer anymore", nil).Status(http.StatusBadRequest)
+			}
+			attempts++
+		}
+	}
+
 	hashmap := make(map[string]interface{})
 	hashmap["userId"] = userID
 	hashmap["pageId"] = data.PageID
@@ -59,10 +102,63 @@ func updatePageObjectInternalHandlerFunc(params *pages.HandlerParams, data *upda
 	hashmap["value"] = data.Value
 	hashmap["createdAt"] = database.Now()
 	hashmap["updatedAt"] = database.Now()
-	statement := db.NewInsertStatement("userPageObjectPairs", hashmap, "edit", "value", "updatedAt")
+	hashmap["attempts"] = attempts
+	updateArgs := []string{"edit", "value", "updatedAt"}
+	// Only answers to quiz questions count as attempts
+	if isQuizQuestion {
+		updateArgs = append(updateArgs, "attempts")
+	}
+	statement := db.NewInsertStatement("userPageObjectPairs", hashmap, updateArgs...)
 	if _, err := statement.Exec(); err != nil {
 		return pages.Fail("Couldn't update a page object", err)
 	}
 
-	return pages.Success(nil)
+	// The answer can change which pages are on the user's paths
+	if err := core.RecomputeUserPathInstances(db, u); err != nil {
+		c.Errorf("Couldn't recompute path instances: %v", err)
+	}
+
+	// If the object is a quiz question, grade the user's answers once they have
+	// answered every question. Until then we don't say which answers are right.
+	if !isQuizQuestion {
+		return pages.Success(nil)
+	}
+	answers, err := core.LoadQuizAnswers(db, userID, quiz)
+	if err != nil {
+		return pages.Fail("Couldn't load the answers", err)
+	}
+	returnData.ResultMap["attemptsLeft"] = core.MaxQuizAnswerAttempts - attempts
+	if !core.IsQuizAnswered(quiz, answers) {
+		return pages.Success(returnData)
+	}
+	grade := core.GradeQuiz(quiz, answers)
+
+	var grantedMasteries []*core.QuizGrant
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		var err error
+		grantedMasteries, err = core.GrantQuizMasteries(tx, userID, data.PageID, core.GetPassedQuizGrants(quiz, grade))
+		if err != nil {
+			return sessions.NewError("Couldn't grant masteries", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	}
+	if len(grantedMasteries) > 0 {
+		if err := core.RecomputeUserPathInstances(db, u); err != nil {
+			c.Errorf("Couldn't recompute path instances: %v", err)
+		}
+	}
+
+	for _, grant := range grantedMasteries {
+		core.AddPageToMap(grant.MasteryID, returnData.PageMap, core.TitlePlusLoadOptions)
+	}
+	returnData.ResultMap["grade"] = grade
+	returnData.ResultMap["grantedMasteries"] = grantedMasteries
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+	return pages.Success(returnData)
 }
@@ -11,6 +11,7 @@ import (
 	"zanaduu3/src/database"
 	"zanaduu3/src/pages"
 	"zanaduu3/src/sessions"
+	"zanaduu3/src/tasks"
 )
 
 // updatePagePairData contains the data we get in the request.
@@ -95,6 +96,14 @@ func updatePagePairHandlerInternal(db *database.DB, u *core.CurrentUser, data *u
 		return pages.FailWith(err2)
 	}
 
+	if pagePair.Type == core.RequirementPagePairType {
+		var task tasks.UpdatePageDifficultyTask
+		task.PageID = pagePair.ChildID
+		if err := tasks.Enqueue(db.C, &task, nil); err != nil {
+			db.C.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
+
 	returnData.ResultMap["pagePair"], err = core.LoadPagePair(db, fmt.Sprintf("%d", pagePairID))
 	if err != nil {
 		return pages.Fail("Error loading the page pair", err)
@@ -0,0 +1,77 @@
+// updatePageTemplateHandler.go marks or unmarks a page as a template for new pages in a domain.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+)
+
+// updatePageTemplateData contains parameters passed in via the request.
+type updatePageTemplateData struct {
+	DomainID   string
+	PageID     string
+	IsTemplate bool
+}
+
+var updatePageTemplateHandler = siteHandler{
+	URI:         "/updatePageTemplate/",
+	HandlerFunc: updatePageTemplateHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// updatePageTemplateHandlerFunc handles the request.
+func updatePageTemplateHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	// Decode data
+	var data updatePageTemplateData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIntIDValid(data.DomainID) {
+		return pages.Fail("Invalid domain id", nil).Status(http.StatusBadRequest)
+	}
+	if !core.RoleAtLeast(u.GetDomainMembershipRole(data.DomainID), core.ArbiterDomainRole) && !u.IsAdmin {
+		return pages.Fail("Don't have permissions to change templates of this domain", nil).Status(http.StatusForbidden)
+	}
+
+	if !data.IsTemplate {
+		statement := database.NewQuery(`
+			DELETE FROM pageTemplates
+			WHERE domainId=? AND pageId=?`, data.DomainID, data.PageID).ToStatement(db)
+		if _, err := statement.Exec(); err != nil {
+			return pages.Fail("Couldn't remove the template", err)
+		}
+		return pages.Success(nil)
+	}
+
+	page, err := core.LoadFullEdit(db, data.PageID, u, returnData.DomainMap, nil)
+	if err != nil {
+		return pages.Fail("Couldn't load the page", err)
+	} else if page == nil {
+		return pages.Fail("Couldn't find the page", nil).Status(http.StatusBadRequest)
+	} else if page.Type == core.CommentPageType {
+		return pages.Fail("A comment can't be a template", nil).Status(http.StatusBadRequest)
+	}
+
+	hashmap := make(database.InsertMap)
+	hashmap["domainId"] = data.DomainID
+	hashmap["pageId"] = data.PageID
+	hashmap["createdBy"] = u.ID
+	hashmap["createdAt"] = database.Now()
+	statement := db.NewInsertStatement("pageTemplates", hashmap, "domainId")
+	if _, err := statement.Exec(); err != nil {
+		return pages.Fail("Couldn't add the template", err)
+	}
+	return pages.Success(nil)
+}
@@ -5,7 +5,6 @@ package site
 import (
 	"encoding/json"
 	"net/http"
-	"strings"
 
 	"zanaduu3/src/core"
 	"zanaduu3/src/database"
@@ -22,6 +21,8 @@ type updatePathData struct {
 	ID         string
 	Progress   int
 	IsFinished bool
+	// If set, change the speed the user prefers for the rest of the path
+	Speed *int
 
 	Pages []*core.PathInstancePage
 }
@@ -44,6 +45,8 @@ func updatePathHandlerFunc(params *pages.HandlerParams) *pages.Result {
 		return pages.Fail("Couldn't load the path instance: %v", err)
 	} else if instance == nil {
 		return pages.Fail("Couldn't find the path instance", nil).Status(http.StatusBadRequest)
+	} else if !instance.IsByCurrentUser {
+		return pages.Fail("Only the owner can update the path instance", nil).Status(http.StatusForbidden)
 	}
 
 	// Update the path as necessary
@@ -54,12 +57,24 @@ func updatePathHandlerFunc(params *pages.HandlerParams) *pages.Result {
 	if data.IsFinished {
 		instance.IsFinished = true
 	}
+	if data.Speed != nil {
+		if *data.Speed < -1 || *data.Speed > 1 {
+			return pages.Fail("Invalid speed", nil).Status(http.StatusBadRequest)
+		}
+		instance.Speed = *data.Speed
+	}
 
-	pageIDs := make([]string, 0)
-	sourceIDs := make([]string, 0)
-	for _, page := range instance.Pages {
-		pageIDs = append(pageIDs, page.PageID)
-		sourceIDs = append(sourceIDs, page.SourceID)
+	// Resolve the pages the user hasn't reached yet
+	if !instance.IsFinished {
+		err = core.UpdatePathInstancePages(db, u, instance)
+		if err != nil {
+			return pages.Fail("Couldn't resolve the path", err)
+		}
+	}
+	pageIDs, sourceIDs, pathPageIDs := core.GetPathInstanceColumns(instance)
+	err = core.LoadPathInstanceEstimate(db, instance)
+	if err != nil {
+		return pages.Fail("Couldn't load the path estimate", err)
 	}
 
 	// Begin the transaction.
@@ -67,10 +82,12 @@ func updatePathHandlerFunc(params *pages.HandlerParams) *pages.Result {
 		// Update the path
 		hashmap := make(database.InsertMap)
 		hashmap["id"] = data.ID
-		hashmap["userId"] = u.ID
+		hashmap["userId"] = u.GetSomeID()
 		hashmap["progress"] = instance.Progress
-		hashmap["pageIds"] = strings.Join(pageIDs, ",")
-		hashmap["sourcePageIds"] = strings.Join(sourceIDs, ",")
+		hashmap["pageIds"] = pageIDs
+		hashmap["sourcePageIds"] = sourceIDs
+		hashmap["pathPageIds"] = pathPageIDs
+		hashmap["speed"] = instance.Speed
 		hashmap["isFinished"] = instance.IsFinished
 		hashmap["updatedAt"] = database.Now()
 		statement := db.NewInsertStatement("pathInstances", hashmap, hashmap.GetKeys()...).WithTx(tx)
@@ -0,0 +1,140 @@
+// updatePathPageHandler.go sets when a path page is skipped or replaced by an alternate
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+// updatePathPageData contains the data we get in the request
+type updatePathPageData struct {
+	ID string
+	// Id of the path page this page is an alternate for, or "" / "0" for none
+	BranchOf      string
+	ConditionType string
+	QuizPageID    string
+	QuizObject    string
+	QuizValue     string
+	// Skip the page if the user has all of these masteries
+	SkipIfMasteryIDs []string
+	// Insert pages for the requisites the user doesn't have before this page
+	InsertRequisites bool
+}
+
+var updatePathPageHandler = siteHandler{
+	URI:         "/json/updatePathPage/",
+	HandlerFunc: updatePathPageHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+func updatePathPageHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	decoder := json.NewDecoder(params.R.Body)
+	var data updatePathPageData
+	err := decoder.Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode json", err).Status(http.StatusBadRequest)
+	}
+	for _, masteryID := range data.SkipIfMasteryIDs {
+		if !core.IsIDValid(masteryID) {
+			return pages.Fail("Invalid mastery id", nil).Status(http.StatusBadRequest)
+		}
+	}
+
+	// Load the path page and the rest of its path
+	pathPage, err := core.LoadPathPage(db, data.ID)
+	if err != nil {
+		return pages.Fail("Couldn't load the path page: %v", err)
+	} else if pathPage == nil {
+		return pages.Fail("Couldn't find the pathPage", nil).Status(http.StatusBadRequest)
+	}
+	steps, err := core.LoadPathSteps(db, pathPage.GuideID)
+	if err != nil {
+		return pages.Fail("Couldn't load the path pages: %v", err)
+	}
+
+	// Check the branch
+	var branchOf int64
+	if data.BranchOf != "" && data.BranchOf != "0" {
+		var mainStep *core.PathPage
+		for _, step := range steps {
+			if fmt.Sprintf("%d", step.ID) == data.BranchOf {
+				mainStep = step
+			}
+			if step.BranchOf == pathPage.ID {
+				return pages.Fail("Other pages are alternates for this page", nil).Status(http.StatusBadRequest)
+			}
+		}
+		if mainStep == nil || mainStep.ID == pathPage.ID || mainStep.BranchOf != 0 {
+			return pages.Fail("Can only be an alternate for another page on the same path", nil).Status(http.StatusBadRequest)
+		}
+		branchOf = mainStep.ID
+	}
+	switch data.ConditionType {
+	case "":
+	case core.SpeedPathCondition:
+	case core.QuizAnswerPathCondition:
+		if !core.IsIDValid(data.QuizPageID) || data.QuizObject == "" || len(data.QuizObject) > 64 {
+			return pages.Fail("Quiz answer condition needs a page and an object", nil).Status(http.StatusBadRequest)
+		}
+	default:
+		return pages.Fail("Invalid condition type", nil).Status(http.StatusBadRequest)
+	}
+	if (branchOf == 0) != (data.ConditionType == "") {
+		return pages.Fail("Alternates need a condition, and only alternates can have one", nil).Status(http.StatusBadRequest)
+	}
+	if data.ConditionType != core.QuizAnswerPathCondition {
+		data.QuizPageID, data.QuizObject, data.QuizValue = "", "", ""
+	}
+
+	// Check permissions
+	pageIDs := []string{pathPage.GuideID}
+	permissionError, err := core.VerifyEditPermissionsForList(db, u, pageIDs)
+	if err != nil {
+		return pages.Fail("Error verifying permissions", err)
+	} else if permissionError != "" {
+		return pages.Fail(permissionError, nil).Status(http.StatusForbidden)
+	}
+
+	// Begin the transaction.
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		hashmap := make(database.InsertMap)
+		hashmap["id"] = pathPage.ID
+		hashmap["branchOf"] = branchOf
+		hashmap["conditionType"] = data.ConditionType
+		hashmap["quizPageId"] = data.QuizPageID
+		hashmap["quizObject"] = data.QuizObject
+		hashmap["quizValue"] = data.QuizValue
+		hashmap["skipIfMasteryIds"] = strings.Join(data.SkipIfMasteryIDs, ",")
+		hashmap["insertRequisites"] = data.InsertRequisites
+		hashmap["updatedBy"] = u.ID
+		hashmap["updatedAt"] = database.Now()
+		statement := db.NewInsertStatement("pathPages", hashmap, hashmap.GetKeys()...).WithTx(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't update the pathPage", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	}
+
+	returnData.ResultMap["pathPage"], err = core.LoadPathPage(db, data.ID)
+	if err != nil {
+		return pages.Fail("Couldn't load the pathPage: %v", err)
+	}
+	return pages.Success(returnData)
+}
@@ -0,0 +1,179 @@
+// updateQuizHandler.go sets the answer key and the mastery grants for the
+// quiz questions in a page.
+
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+// updateQuizData contains parameters passed in via the request.
+type updateQuizData struct {
+	PageID string
+	// Edit the answer key is for. If not set, the current edit is used.
+	Edit int
+	// Object alias -> correct value
+	AnswerKeys map[string]string
+	Grants     []*core.QuizGrant
+}
+
+var updateQuizHandler = siteHandler{
+	URI:         "/updateQuiz/",
+	HandlerFunc: updateQuizHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+// updateQuizHandlerFunc handles the request.
+func updateQuizHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+	returnData := core.NewHandlerData(u)
+
+	var data updateQuizData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	if !core.IsIDValid(data.PageID) {
+		return pages.Fail("Invalid page id", nil).Status(http.StatusBadRequest)
+	}
+	for object := range data.AnswerKeys {
+		if object == "" || len(object) > 64 {
+			return pages.Fail("Invalid object alias", nil).Status(http.StatusBadRequest)
+		}
+	}
+	for _, grant := range data.Grants {
+		if !core.IsIDValid(grant.MasteryID) {
+			return pages.Fail("Invalid mastery id", nil).Status(http.StatusBadRequest)
+		}
+		if grant.Level < core.LooseMasteryLevel || grant.Level > core.ResearchMasteryLevel {
+			return pages.Fail("Invalid mastery level", nil).Status(http.StatusBadRequest)
+		}
+		if grant.PassingScore < 0 || grant.PassingScore > len(data.AnswerKeys) {
+			return pages.Fail("Invalid passing score", nil).Status(http.StatusBadRequest)
+		}
+	}
+	if len(data.Grants) > 0 && len(data.AnswerKeys) <= 0 {
+		return pages.Fail("Can't grant masteries without any questions", nil).Status(http.StatusBadRequest)
+	}
+
+	// Load the page
+	page, err := core.LoadFullEdit(db, data.PageID, u, returnData.DomainMap, nil)
+	if err != nil {
+		return pages.Fail("Couldn't load the page", err)
+	} else if page == nil || page.IsDeleted {
+		return pages.Fail("Couldn't find the page", nil).Status(http.StatusBadRequest)
+	}
+	if !page.Permissions.Edit.Has {
+		return pages.Fail("Can't edit: "+page.Permissions.Edit.Reason, nil).Status(http.StatusForbidden)
+	}
+	// The page can only grant the subjects it teaches, up to the level it teaches them at
+	subjectLevels := make(map[string]int)
+	rows := database.NewQuery(`
+		SELECT parentId,level
+		FROM pagePairs
+		WHERE childId=?`, data.PageID).Add(`
+			AND type=?`, core.SubjectPagePairType).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var subjectID string
+		var level int
+		if err := rows.Scan(&subjectID, &level); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		subjectLevels[subjectID] = level
+		return nil
+	})
+	if err != nil {
+		return pages.Fail("Couldn't load the page's subjects", err)
+	}
+	for _, grant := range data.Grants {
+		level, ok := subjectLevels[grant.MasteryID]
+		if !ok {
+			return pages.Fail("The page doesn't teach mastery "+grant.MasteryID, nil).Status(http.StatusBadRequest)
+		} else if grant.Level > level {
+			return pages.Fail("The page doesn't teach mastery "+grant.MasteryID+" at that level", nil).Status(http.StatusBadRequest)
+		}
+	}
+
+	if data.Edit <= 0 {
+		data.Edit = page.CurrentEdit
+	} else if data.Edit > page.MaxEditEver {
+		return pages.Fail("Invalid edit", nil).Status(http.StatusBadRequest)
+	}
+
+	// Replace the quiz for this edit
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		statement := database.NewQuery(`
+			DELETE FROM quizAnswerKeys
+			WHERE pageId=?`, data.PageID).Add(`
+				AND edit=?`, data.Edit).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't delete old answer keys", err)
+		}
+		statement = database.NewQuery(`
+			DELETE FROM quizMasteryGrants
+			WHERE pageId=?`, data.PageID).Add(`
+				AND edit=?`, data.Edit).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't delete old mastery grants", err)
+		}
+
+		hashmaps := make(database.InsertMaps, 0)
+		for object, answer := range data.AnswerKeys {
+			hashmap := make(database.InsertMap)
+			hashmap["pageId"] = data.PageID
+			hashmap["edit"] = data.Edit
+			hashmap["object"] = object
+			hashmap["answer"] = answer
+			hashmap["createdBy"] = u.ID
+			hashmap["createdAt"] = database.Now()
+			hashmaps = append(hashmaps, hashmap)
+		}
+		if len(hashmaps) > 0 {
+			statement = tx.DB.NewMultipleInsertStatement("quizAnswerKeys", hashmaps).WithTx(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't insert answer keys", err)
+			}
+		}
+
+		hashmaps = make(database.InsertMaps, 0)
+		for _, grant := range data.Grants {
+			hashmap := make(database.InsertMap)
+			hashmap["pageId"] = data.PageID
+			hashmap["edit"] = data.Edit
+			hashmap["masteryId"] = grant.MasteryID
+			hashmap["level"] = grant.Level
+			hashmap["passingScore"] = grant.PassingScore
+			hashmaps = append(hashmaps, hashmap)
+		}
+		if len(hashmaps) > 0 {
+			statement = tx.DB.NewMultipleInsertStatement("quizMasteryGrants", hashmaps, "level", "passingScore").WithTx(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't insert mastery grants", err)
+			}
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	}
+
+	for _, grant := range data.Grants {
+		core.AddPageToMap(grant.MasteryID, returnData.PageMap, core.TitlePlusLoadOptions)
+	}
+	returnData.ResultMap["edit"] = data.Edit
+	err = core.ExecuteLoadPipeline(db, returnData)
+	if err != nil {
+		return pages.Fail("Pipeline error", err)
+	}
+	return pages.Success(returnData)
+}
@@ -0,0 +1,72 @@
+// updateSearchRankingHandler.go handles requests to change the search ranking
+// rules for a domain.
+
+package site
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/pages"
+	"zanaduu3/src/sessions"
+)
+
+type updateSearchRankingData struct {
+	Ranking *core.SearchRanking
+}
+
+var updateSearchRankingHandler = siteHandler{
+	URI:         "/updateSearchRanking/",
+	HandlerFunc: updateSearchRankingHandlerFunc,
+	Options: pages.PageOptions{
+		RequireLogin: true,
+	},
+}
+
+func updateSearchRankingHandlerFunc(params *pages.HandlerParams) *pages.Result {
+	db := params.DB
+	u := params.U
+
+	// Decode data
+	var data updateSearchRankingData
+	err := json.NewDecoder(params.R.Body).Decode(&data)
+	if err != nil {
+		return pages.Fail("Couldn't decode request", err).Status(http.StatusBadRequest)
+	}
+	ranking := data.Ranking
+	if ranking == nil {
+		return pages.Fail("No ranking given", nil).Status(http.StatusBadRequest)
+	}
+	if !core.IsIntIDValid(ranking.DomainID) {
+		ranking.DomainID = core.DefaultSearchRankingDomainID
+	}
+	if ranking.TagMultipliers == nil {
+		ranking.TagMultipliers = make(map[string]float64)
+	}
+	if ranking.TypeMultipliers == nil {
+		ranking.TypeMultipliers = make(map[string]float64)
+	}
+	if err := ranking.Validate(); err != nil {
+		return pages.Fail("Invalid ranking", err).Status(http.StatusBadRequest)
+	}
+
+	if !core.CanUserEditSearchRanking(u, ranking.DomainID) {
+		return pages.Fail("Don't have permissions to change search ranking in this domain", nil).Status(http.StatusForbidden)
+	}
+
+	ranking.UpdatedBy = u.ID
+	ranking.UpdatedAt = database.Now()
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		if err := core.SaveSearchRanking(tx, ranking); err != nil {
+			return sessions.NewError("Couldn't save search ranking", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return pages.FailWith(err2)
+	}
+
+	return pages.Success(nil)
+}
@@ -70,5 +70,5 @@ func userSearchJSONHandler(params *pages.HandlerParams) *pages.Result {
 		},
 		"_source": []
 	}`, escapedTerm, strings.Join(groupIDs, ","), core.GroupPageType)
-	return searchJSONInternalHandler(params, jsonStr)
+	return searchJSONInternalHandler(params, jsonStr, params.PrivateDomain.ID)
 }
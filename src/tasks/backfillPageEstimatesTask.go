@@ -0,0 +1,88 @@
+// backfillPageEstimatesTask.go computes the reading time and difficulty of all
+// the published pages, one batch of pages at a time.
+package tasks
+
+import (
+	"fmt"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+)
+
+const (
+	// How many pages to process per task execution
+	pageEstimatesBackfillBatchSize = 100
+)
+
+// BackfillPageEstimatesTask is the object that's put into the daemon queue.
+type BackfillPageEstimatesTask struct {
+	// Only pages with ids after this one are processed
+	AfterPageID string
+}
+
+func (task BackfillPageEstimatesTask) Tag() string {
+	return "backfillPageEstimates"
+}
+
+// Check if this task is valid, and we can safely execute it.
+func (task BackfillPageEstimatesTask) IsValid() error {
+	if task.AfterPageID != "" && !core.IsIDValid(task.AfterPageID) {
+		return fmt.Errorf("Invalid page id: %s", task.AfterPageID)
+	}
+	return nil
+}
+
+// Execute this task. Called by the actual daemon worker, don't call on BE.
+// For comments on return value see tasks.QueueTask
+func (task BackfillPageEstimatesTask) Execute(db *database.DB) (int, error) {
+	c := db.C
+
+	if err := task.IsValid(); err != nil {
+		return -1, err
+	}
+
+	c.Infof("Backfilling page estimates after page %s", task.AfterPageID)
+
+	// Load the next batch of pages
+	textMap := make(map[string]string)
+	pageIDs := make([]string, 0)
+	rows := database.NewQuery(`
+		SELECT pi.pageId,p.text
+		FROM pageInfos AS pi
+		JOIN pages AS p
+		ON (pi.pageId=p.pageId AND pi.currentEdit=p.edit)
+		WHERE pi.pageId>?`, task.AfterPageID).Add(`
+		ORDER BY pi.pageId
+		LIMIT ?`, pageEstimatesBackfillBatchSize).ToStatement(db).Query()
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var pageID, text string
+		if err := rows.Scan(&pageID, &text); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		pageIDs = append(pageIDs, pageID)
+		textMap[pageID] = text
+		return nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load pages: %v", err)
+	}
+
+	for _, pageID := range pageIDs {
+		if err := core.UpdatePageEstimatesWithoutTx(db, pageID, textMap[pageID]); err != nil {
+			return -1, err
+		}
+	}
+
+	if len(pageIDs) < pageEstimatesBackfillBatchSize {
+		c.Infof("Finished backfilling page estimates")
+		return 0, nil
+	}
+
+	// Process the next batch
+	var nextTask BackfillPageEstimatesTask
+	nextTask.AfterPageID = pageIDs[len(pageIDs)-1]
+	if err := Enqueue(c, &nextTask, nil); err != nil {
+		return -1, fmt.Errorf("Couldn't enqueue the next backfill task: %v", err)
+	}
+	return 0, nil
+}
@@ -0,0 +1,319 @@
+// bulkEditTask.go applies (or undoes) an admin find-and-replace across pages,
+// one batch of pages at a time.
+package tasks
+
+import (
+	"fmt"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/sessions"
+)
+
+const (
+	// How many pages to process per task execution
+	bulkEditBatchSize = 50
+)
+
+// BulkEditTask is the object that's put into the daemon queue.
+type BulkEditTask struct {
+	BulkEditID string
+}
+
+// bulkEditRevision is the content of a new edit created by a bulk edit.
+type bulkEditRevision struct {
+	pageID        string
+	prevEdit      int
+	title         string
+	clickbait     string
+	text          string
+	metaText      string
+	anchorContext string
+	anchorText    string
+	anchorOffset  int
+}
+
+func (task BulkEditTask) Tag() string {
+	return "bulkEdit"
+}
+
+// Check if this task is valid, and we can safely execute it.
+func (task BulkEditTask) IsValid() error {
+	if !core.IsIntIDValid(task.BulkEditID) {
+		return fmt.Errorf("Invalid bulk edit id: %s", task.BulkEditID)
+	}
+	return nil
+}
+
+// Execute this task. Called by the actual daemon worker, don't call on BE.
+// For comments on return value see tasks.QueueTask
+func (task BulkEditTask) Execute(db *database.DB) (delay int, err error) {
+	c := db.C
+
+	if err = task.IsValid(); err != nil {
+		return 0, err
+	}
+
+	bulkEdit, err := core.LoadBulkEdit(db, task.BulkEditID)
+	if err != nil {
+		return -1, err
+	} else if bulkEdit == nil {
+		return 0, fmt.Errorf("Couldn't find bulk edit: %s", task.BulkEditID)
+	}
+
+	// Id of the account the new edits are attributed to
+	var userID string
+	if bulkEdit.Status == core.RunningBulkEditStatus || bulkEdit.Status == core.UndoingBulkEditStatus {
+		userID, err = core.LoadBulkEditUserID(db)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var isDone bool
+	if bulkEdit.Status == core.RunningBulkEditStatus {
+		c.Infof("Applying bulk edit %s after page %s", bulkEdit.ID, bulkEdit.LastPageID)
+		isDone, err = applyBulkEditBatch(db, bulkEdit, userID)
+	} else if bulkEdit.Status == core.UndoingBulkEditStatus {
+		c.Infof("Undoing bulk edit %s", bulkEdit.ID)
+		isDone, err = undoBulkEditBatch(db, bulkEdit, userID)
+	} else {
+		return 0, nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	if isDone {
+		c.Infof("Bulk edit %s is %s: %d pages scanned, %d changed, %d undone", bulkEdit.ID,
+			bulkEdit.Status, bulkEdit.PagesScanned, bulkEdit.PagesChanged, bulkEdit.PagesUndone)
+		return 0, nil
+	}
+
+	// Process the next batch
+	var nextTask BulkEditTask
+	nextTask.BulkEditID = bulkEdit.ID
+	if err := Enqueue(c, &nextTask, nil); err != nil {
+		return -1, fmt.Errorf("Couldn't enqueue the next bulk edit task: %v", err)
+	}
+	return 0, nil
+}
+
+// applyBulkEditBatch creates new edits for the next batch of pages. Returns true
+// if there are no more pages left.
+func applyBulkEditBatch(db *database.DB, bulkEdit *core.BulkEdit, userID string) (bool, error) {
+	replacer, err := core.NewBulkEditReplacer(bulkEdit.FindText, bulkEdit.ReplaceText, bulkEdit.IsRegexp)
+	if err != nil {
+		return false, err
+	}
+
+	// Pages we already changed, in case a previous run died in the middle of a batch
+	doneBulkEditPages, err := core.LoadBulkEditPages(db, bulkEdit.ID, database.NewQuery(`
+		AND pageId>?`, bulkEdit.LastPageID))
+	if err != nil {
+		return false, err
+	}
+	donePageIDs := make(map[string]bool)
+	for _, bulkEditPage := range doneBulkEditPages {
+		donePageIDs[bulkEditPage.PageID] = true
+	}
+
+	// Load the next batch of live edits
+	pageIDs := make([]string, 0)
+	revisions := make([]*bulkEditRevision, 0)
+	replacementCounts := make([]int, 0)
+	rows := database.NewQuery(`
+		SELECT p.pageId,p.edit,p.title,p.clickbait,p.text,p.metaText,p.anchorContext,p.anchorText,p.anchorOffset
+		FROM pages AS p
+		JOIN pageInfos AS pi
+		ON (p.pageId=pi.pageId AND p.isLiveEdit)
+		WHERE pi.pageId>?`, bulkEdit.LastPageID).Add(`
+			AND`).AddPart(core.PageInfosFilter(nil)).Add(`
+			AND`).AddPart(core.NewBulkEditFilter(bulkEdit.DomainID, bulkEdit.PageIDs)).Add(`
+		ORDER BY pi.pageId
+		LIMIT ?`, bulkEditBatchSize).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var rev bulkEditRevision
+		err := rows.Scan(&rev.pageID, &rev.prevEdit, &rev.title, &rev.clickbait, &rev.text, &rev.metaText,
+			&rev.anchorContext, &rev.anchorText, &rev.anchorOffset)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		pageIDs = append(pageIDs, rev.pageID)
+		if donePageIDs[rev.pageID] {
+			return nil
+		}
+		var count int
+		rev.text, count = replacer.Replace(rev.text)
+		if count > 0 {
+			revisions = append(revisions, &rev)
+			replacementCounts = append(replacementCounts, count)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("Couldn't load pages: %v", err)
+	}
+
+	// Create the new edits
+	changedPageIDs := make([]string, 0)
+	for n, rev := range revisions {
+		var changed bool
+		err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+			var newEdit int
+			var err error
+			newEdit, changed, err = core.SaveNewLiveEdit(tx, rev.liveEditOptions(userID, rev.prevEdit, bulkEdit.EditSummary, core.NewEditChangeLog))
+			if err != nil {
+				return sessions.NewError("Couldn't save the new edit", err)
+			} else if !changed {
+				return nil
+			}
+
+			hashmap := make(database.InsertMap)
+			hashmap["bulkEditId"] = bulkEdit.ID
+			hashmap["pageId"] = rev.pageID
+			hashmap["prevEdit"] = rev.prevEdit
+			hashmap["newEdit"] = newEdit
+			hashmap["replacements"] = replacementCounts[n]
+			hashmap["createdAt"] = database.Now()
+			statement := tx.DB.NewInsertStatement("bulkEditPages", hashmap).WithTx(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't insert bulk edit page", err)
+			}
+			return nil
+		})
+		if err2 != nil {
+			return false, sessions.ToError(err2)
+		}
+		if changed {
+			changedPageIDs = append(changedPageIDs, rev.pageID)
+		} else {
+			db.C.Warningf("Page %s was edited while the bulk edit was running; skipping", rev.pageID)
+		}
+	}
+	enqueueBulkEditElasticUpdates(db, changedPageIDs)
+
+	// Move the cursor forward
+	bulkEdit.PagesScanned += len(pageIDs)
+	bulkEdit.PagesChanged += len(changedPageIDs)
+	if len(pageIDs) > 0 {
+		bulkEdit.LastPageID = pageIDs[len(pageIDs)-1]
+	}
+	isDone := len(pageIDs) < bulkEditBatchSize
+	if isDone {
+		bulkEdit.Status = core.FinishedBulkEditStatus
+	}
+	return isDone, updateBulkEdit(db, bulkEdit)
+}
+
+// undoBulkEditBatch reverts the next batch of pages changed by the bulk edit.
+// Returns true if there are no more pages left.
+func undoBulkEditBatch(db *database.DB, bulkEdit *core.BulkEdit, userID string) (bool, error) {
+	bulkEditPages, err := core.LoadBulkEditPages(db, bulkEdit.ID, database.NewQuery(`
+		AND undoEdit=0 AND undoFailure=""
+		ORDER BY pageId
+		LIMIT ?`, bulkEditBatchSize))
+	if err != nil {
+		return false, err
+	}
+
+	undoneCount := 0
+	changedPageIDs := make([]string, 0)
+	editSummary := fmt.Sprintf("Undo bulk edit #%s", bulkEdit.ID)
+	for _, bulkEditPage := range bulkEditPages {
+		err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+			// Load the edit from before the bulk edit
+			rev := &bulkEditRevision{pageID: bulkEditPage.PageID}
+			row := database.NewQuery(`
+				SELECT title,clickbait,text,metaText,anchorContext,anchorText,anchorOffset
+				FROM pages
+				WHERE pageId=? AND edit=?`, bulkEditPage.PageID, bulkEditPage.PrevEdit).ToTxStatement(tx).QueryRow()
+			exists, err := row.Scan(&rev.title, &rev.clickbait, &rev.text, &rev.metaText,
+				&rev.anchorContext, &rev.anchorText, &rev.anchorOffset)
+			if err != nil {
+				return sessions.NewError("Couldn't load the previous edit", err)
+			}
+
+			undoFailure := ""
+			undoEdit := 0
+			if !exists {
+				undoFailure = "The previous edit doesn't exist anymore"
+			} else {
+				var changed bool
+				undoEdit, changed, err = core.SaveNewLiveEdit(tx, rev.liveEditOptions(userID, bulkEditPage.NewEdit, editSummary, core.RevertEditChangeLog))
+				if err != nil {
+					return sessions.NewError("Couldn't save the new edit", err)
+				} else if !changed {
+					undoFailure = "The page was edited after the bulk edit"
+				}
+			}
+
+			statement := database.NewQuery(`
+				UPDATE bulkEditPages
+				SET undoEdit=?,undoFailure=?`, undoEdit, undoFailure).Add(`
+				WHERE bulkEditId=? AND pageId=?`, bulkEdit.ID, bulkEditPage.PageID).ToTxStatement(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't update bulk edit page", err)
+			}
+			if undoFailure == "" {
+				undoneCount++
+				changedPageIDs = append(changedPageIDs, bulkEditPage.PageID)
+			}
+			return nil
+		})
+		if err2 != nil {
+			return false, sessions.ToError(err2)
+		}
+	}
+	enqueueBulkEditElasticUpdates(db, changedPageIDs)
+
+	bulkEdit.PagesUndone += undoneCount
+	isDone := len(bulkEditPages) < bulkEditBatchSize
+	if isDone {
+		bulkEdit.Status = core.UndoneBulkEditStatus
+	}
+	return isDone, updateBulkEdit(db, bulkEdit)
+}
+
+// liveEditOptions returns the options for saving the revision as the page's
+// new live edit, as long as the page's live edit is still expectedEdit.
+func (rev *bulkEditRevision) liveEditOptions(userID string, expectedEdit int, editSummary string, changeLogType string) *core.NewLiveEditOptions {
+	return &core.NewLiveEditOptions{
+		PageID:        rev.pageID,
+		CreatorID:     userID,
+		ExpectedEdit:  expectedEdit,
+		Title:         rev.title,
+		Clickbait:     rev.clickbait,
+		Text:          rev.text,
+		MetaText:      rev.metaText,
+		AnchorContext: rev.anchorContext,
+		AnchorText:    rev.anchorText,
+		AnchorOffset:  rev.anchorOffset,
+		IsMinorEdit:   true,
+		EditSummary:   editSummary,
+		ChangeLogType: changeLogType,
+	}
+}
+
+// updateBulkEdit saves the bulk edit's progress.
+func updateBulkEdit(db *database.DB, bulkEdit *core.BulkEdit) error {
+	statement := database.NewQuery(`
+		UPDATE bulkEdits
+		SET updatedAt=?,status=?,lastPageId=?,`, database.Now(), bulkEdit.Status, bulkEdit.LastPageID).Add(`
+			pagesScanned=?,pagesChanged=?,pagesUndone=?`, bulkEdit.PagesScanned, bulkEdit.PagesChanged, bulkEdit.PagesUndone).Add(`
+		WHERE id=?`, bulkEdit.ID).ToStatement(db)
+	if _, err := statement.Exec(); err != nil {
+		return fmt.Errorf("Couldn't update bulk edit: %v", err)
+	}
+	return nil
+}
+
+// enqueueBulkEditElasticUpdates updates the search index for the changed pages.
+func enqueueBulkEditElasticUpdates(db *database.DB, pageIDs []string) {
+	for _, pageID := range pageIDs {
+		var task UpdateElasticPageTask
+		task.PageID = pageID
+		if err := Enqueue(db.C, &task, nil); err != nil {
+			db.C.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// checkRequisiteGraphTask.go looks for problems in the requirement/subject graph
+// and reports them to the domain reviewers.
+package tasks
+
+import (
+	"fmt"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/learning"
+	"zanaduu3/src/sessions"
+)
+
+const (
+	checkRequisiteGraphPeriod = 24 * 60 * 60 // 1 day
+	// How many findings to insert per statement
+	requisiteFindingsInsertBatchSize = 500
+)
+
+// CheckRequisiteGraphTask is the object that's put into the daemon queue.
+type CheckRequisiteGraphTask struct {
+	// If true, the task won't be rescheduled
+	RunOnce bool
+}
+
+func (task CheckRequisiteGraphTask) Tag() string {
+	return "checkRequisiteGraph"
+}
+
+// Check if this task is valid, and we can safely execute it.
+func (task CheckRequisiteGraphTask) IsValid() error {
+	return nil
+}
+
+// Execute this task. Called by the actual daemon worker, don't call on BE.
+// For comments on return value see tasks.QueueTask
+func (task CheckRequisiteGraphTask) Execute(db *database.DB) (delay int, err error) {
+	delay = checkRequisiteGraphPeriod
+	if task.RunOnce {
+		delay = 0
+	}
+	c := db.C
+
+	if err = task.IsValid(); err != nil {
+		return -1, err
+	}
+
+	c.Infof("==== CHECK REQUISITE GRAPH START ====")
+	defer c.Infof("==== CHECK REQUISITE GRAPH COMPLETED ====")
+
+	// Load all the requirements and subjects of live pages
+	graph := learning.NewGraph()
+	deletedIDs := make(map[string]bool)
+	domainIDs := make(map[string]string)
+	pairsChecked := 0
+	rows := database.NewQuery(`
+		SELECT pp.parentId,pp.childId,pp.type,pp.level,IFNULL(pi.isDeleted,TRUE),
+			IFNULL(pi.editDomainId,""),ci.editDomainId
+		FROM pagePairs AS pp
+		JOIN pageInfos AS ci
+		ON (pp.childId=ci.pageId)
+		LEFT JOIN pageInfos AS pi
+		ON (pp.parentId=pi.pageId)
+		WHERE pp.type IN`).AddArgsGroupStr([]string{core.RequirementPagePairType, core.SubjectPagePairType}).Add(`
+			AND ci.currentEdit>0 AND NOT ci.isDeleted`).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var parentID, childID, pairType, parentDomainID, childDomainID string
+		var level int
+		var isParentDeleted bool
+		err := rows.Scan(&parentID, &childID, &pairType, &level, &isParentDeleted, &parentDomainID, &childDomainID)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		pairsChecked++
+		domainIDs[parentID] = parentDomainID
+		domainIDs[childID] = childDomainID
+		if pairType == core.RequirementPagePairType {
+			graph.AddRequirement(childID, parentID, level)
+			if isParentDeleted {
+				deletedIDs[parentID] = true
+			}
+		} else if !isParentDeleted {
+			graph.AddTutor(parentID, childID, level)
+		}
+		return nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load page pairs: %v", err)
+	}
+
+	findings := learning.FindIntegrityProblems(graph, deletedIDs)
+
+	// Save the report
+	now := database.Now()
+	check := &core.RequisiteGraphCheck{PairsChecked: pairsChecked}
+	hashmaps := make(database.InsertMaps, 0)
+	for _, finding := range findings {
+		switch finding.Type {
+		case core.CycleRequisiteFinding:
+			check.CycleCount++
+		case core.OrphanRequisiteFinding:
+			check.OrphanCount++
+		case core.DeletedRequisiteFinding:
+			check.DeletedCount++
+		case core.RedundantRequisiteFinding:
+			check.RedundantCount++
+		}
+		details := finding.Details
+		if len(details) > 1024 {
+			details = details[:1024]
+		}
+		hashmap := make(database.InsertMap)
+		hashmap["type"] = finding.Type
+		hashmap["pageId"] = finding.PageID
+		hashmap["auxPageId"] = finding.AuxPageID
+		hashmap["domainId"] = domainIDs[finding.PageID]
+		hashmap["details"] = details
+		hashmap["createdAt"] = now
+		hashmaps = append(hashmaps, hashmap)
+	}
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		hashmap := make(database.InsertMap)
+		hashmap["createdAt"] = now
+		hashmap["pairsChecked"] = check.PairsChecked
+		hashmap["cycleCount"] = check.CycleCount
+		hashmap["orphanCount"] = check.OrphanCount
+		hashmap["deletedCount"] = check.DeletedCount
+		hashmap["redundantCount"] = check.RedundantCount
+		statement := tx.DB.NewInsertStatement("requisiteGraphChecks", hashmap).WithTx(tx)
+		result, err := statement.Exec()
+		if err != nil {
+			return sessions.NewError("Couldn't create requisite graph check", err)
+		}
+		checkID, err := result.LastInsertId()
+		if err != nil {
+			return sessions.NewError("Couldn't get check id", err)
+		}
+		check.ID = fmt.Sprintf("%d", checkID)
+
+		for _, hashmap := range hashmaps {
+			hashmap["checkId"] = check.ID
+		}
+		for start := 0; start < len(hashmaps); start += requisiteFindingsInsertBatchSize {
+			end := start + requisiteFindingsInsertBatchSize
+			if end > len(hashmaps) {
+				end = len(hashmaps)
+			}
+			statement := tx.DB.NewMultipleInsertStatement("requisiteGraphCheckFindings", hashmaps[start:end]).WithTx(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't insert findings", err)
+			}
+		}
+		return nil
+	})
+	if err2 != nil {
+		return -1, sessions.ToError(err2)
+	}
+
+	c.Infof("Requisite graph check %s: %d pairs, %d cycles, %d orphans, %d deleted, %d redundant",
+		check.ID, check.PairsChecked, check.CycleCount, check.OrphanCount, check.DeletedCount, check.RedundantCount)
+	return
+}
@@ -0,0 +1,248 @@
+// checkSearchIndexTask.go compares the pages in the db with the pages in the
+// search index, and optionally fixes the differences.
+package tasks
+
+import (
+	"fmt"
+	"strings"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/elastic"
+	"zanaduu3/src/sessions"
+)
+
+const (
+	// How many pages to check per task execution
+	searchIndexCheckBatchSize = 100
+)
+
+// CheckSearchIndexTask is the object that's put into the daemon queue.
+type CheckSearchIndexTask struct {
+	CheckID string
+}
+
+func (task CheckSearchIndexTask) Tag() string {
+	return "checkSearchIndex"
+}
+
+// Check if this task is valid, and we can safely execute it.
+func (task CheckSearchIndexTask) IsValid() error {
+	if !core.IsIntIDValid(task.CheckID) {
+		return fmt.Errorf("Invalid check id: %s", task.CheckID)
+	}
+	return nil
+}
+
+// Execute this task. Called by the actual daemon worker, don't call on BE.
+// For comments on return value see tasks.QueueTask
+func (task CheckSearchIndexTask) Execute(db *database.DB) (delay int, err error) {
+	c := db.C
+
+	if err = task.IsValid(); err != nil {
+		return 0, err
+	}
+
+	check, err := core.LoadSearchIndexCheck(db, task.CheckID)
+	if err != nil {
+		return -1, err
+	} else if check == nil {
+		return 0, fmt.Errorf("Couldn't find search index check: %s", task.CheckID)
+	} else if check.IsFinished {
+		return 0, nil
+	}
+
+	c.Infof("Checking search index (check %s) after page %s", check.ID, check.LastPageID)
+
+	// Load the next batch of pages. We go through all pages, including deleted
+	// and unpublished ones, to catch pages that should have been removed from the index.
+	dbDocMap := make(map[string]*elastic.Document)
+	pageIDs := make([]string, 0)
+	rows := database.NewQuery(`
+		SELECT pi.pageId,pi.currentEdit>0 AND NOT pi.isDeleted,ifnull(p.title,""),pi.seeDomainId,pi.editDomainId
+		FROM pageInfos AS pi
+		LEFT JOIN pages AS p
+		ON (p.pageId=pi.pageId AND p.isLiveEdit)
+		WHERE pi.pageId>?`, check.LastPageID).Add(`
+		ORDER BY pi.pageId
+		LIMIT ?`, searchIndexCheckBatchSize).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var isPublished bool
+		doc := &elastic.Document{}
+		err := rows.Scan(&doc.PageID, &isPublished, &doc.Title, &doc.SeeDomainID, &doc.EditDomainID)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		pageIDs = append(pageIDs, doc.PageID)
+		if isPublished {
+			dbDocMap[doc.PageID] = doc
+		}
+		return nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load pages: %v", err)
+	}
+
+	// Load the same pages from the index
+	indexDocMap, err := elastic.LoadPagesFromIndex(c, pageIDs)
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load pages from the index: %v", err)
+	}
+
+	// Compare
+	now := database.Now()
+	findings := make([]*core.SearchIndexCheckFinding, 0)
+	for _, pageID := range pageIDs {
+		dbDoc, inDb := dbDocMap[pageID]
+		indexDoc, inIndex := indexDocMap[pageID]
+		finding := &core.SearchIndexCheckFinding{CheckID: check.ID, PageID: pageID, CreatedAt: now}
+		if inDb && !inIndex {
+			finding.Type = core.MissingSearchIndexFinding
+			finding.Details = "Published page is not in the index"
+		} else if !inDb && inIndex {
+			finding.Type = core.ExtraSearchIndexFinding
+			finding.Details = "Deleted or unpublished page is in the index"
+		} else if inDb && inIndex {
+			finding.Details = compareSearchIndexDocs(dbDoc, indexDoc)
+			if finding.Details == "" {
+				continue
+			}
+			finding.Type = core.MismatchSearchIndexFinding
+		} else {
+			continue
+		}
+		findings = append(findings, finding)
+	}
+
+	// Fix the problems
+	if check.Repair {
+		for _, finding := range findings {
+			// Only mark the finding repaired once the index write went through
+			if finding.Type == core.ExtraSearchIndexFinding {
+				err = elastic.DeletePageFromIndex(c, finding.PageID)
+				finding.Repaired = err == nil
+			} else {
+				finding.Repaired, err = reindexPage(db, finding.PageID)
+			}
+			if err != nil {
+				c.Warningf("Couldn't repair page %s in the search index: %v", finding.PageID, err)
+			} else if !finding.Repaired {
+				c.Warningf("Couldn't repair page %s in the search index: it's no longer published", finding.PageID)
+			}
+		}
+	}
+
+	// Save the results and move the cursor forward
+	for _, finding := range findings {
+		switch finding.Type {
+		case core.MissingSearchIndexFinding:
+			check.MissingCount++
+		case core.MismatchSearchIndexFinding:
+			check.MismatchCount++
+		case core.ExtraSearchIndexFinding:
+			check.ExtraCount++
+		}
+		if finding.Repaired {
+			check.RepairedCount++
+		}
+	}
+	check.PagesChecked += len(pageIDs)
+	if len(pageIDs) > 0 {
+		check.LastPageID = pageIDs[len(pageIDs)-1]
+	}
+	check.IsFinished = len(pageIDs) < searchIndexCheckBatchSize
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		if len(findings) > 0 {
+			hashmaps := make(database.InsertMaps, 0)
+			for _, finding := range findings {
+				hashmap := make(database.InsertMap)
+				hashmap["checkId"] = finding.CheckID
+				hashmap["pageId"] = finding.PageID
+				hashmap["type"] = finding.Type
+				hashmap["details"] = finding.Details
+				hashmap["repaired"] = finding.Repaired
+				hashmap["createdAt"] = finding.CreatedAt
+				hashmaps = append(hashmaps, hashmap)
+			}
+			statement := tx.DB.NewMultipleInsertStatement("searchIndexCheckFindings", hashmaps, "type", "details", "repaired").WithTx(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't insert findings", err)
+			}
+		}
+
+		hashmap := make(database.InsertMap)
+		hashmap["id"] = check.ID
+		hashmap["updatedAt"] = now
+		hashmap["lastPageId"] = check.LastPageID
+		hashmap["pagesChecked"] = check.PagesChecked
+		hashmap["missingCount"] = check.MissingCount
+		hashmap["mismatchCount"] = check.MismatchCount
+		hashmap["extraCount"] = check.ExtraCount
+		hashmap["repairedCount"] = check.RepairedCount
+		hashmap["isFinished"] = check.IsFinished
+		statement := tx.DB.NewInsertStatement("searchIndexChecks", hashmap, hashmap.GetKeys()...).WithTx(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't update search index check", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return -1, sessions.ToError(err2)
+	}
+
+	if check.IsFinished {
+		c.Infof("Search index check %s finished: %d pages, %d missing, %d mismatched, %d extra, %d repaired",
+			check.ID, check.PagesChecked, check.MissingCount, check.MismatchCount, check.ExtraCount, check.RepairedCount)
+		return 0, nil
+	}
+
+	// Process the next batch
+	var nextTask CheckSearchIndexTask
+	nextTask.CheckID = check.ID
+	if err := Enqueue(c, &nextTask, nil); err != nil {
+		return -1, fmt.Errorf("Couldn't enqueue the next search index check task: %v", err)
+	}
+	return 0, nil
+}
+
+// compareSearchIndexDocs returns the description of the differences between the
+// page in the db and the page in the index, or "" if they match.
+func compareSearchIndexDocs(dbDoc, indexDoc *elastic.Document) string {
+	diffs := make([]string, 0)
+	if dbDoc.Title != indexDoc.Title {
+		diffs = append(diffs, fmt.Sprintf("title: %q vs %q", dbDoc.Title, indexDoc.Title))
+	}
+	if dbDoc.SeeDomainID != indexDoc.SeeDomainID {
+		diffs = append(diffs, fmt.Sprintf("seeDomainId: %s vs %s", dbDoc.SeeDomainID, indexDoc.SeeDomainID))
+	}
+	if dbDoc.EditDomainID != indexDoc.EditDomainID {
+		diffs = append(diffs, fmt.Sprintf("editDomainId: %s vs %s", dbDoc.EditDomainID, indexDoc.EditDomainID))
+	}
+	details := strings.Join(diffs, "; ")
+	if len(details) > 1024 {
+		details = details[:1024]
+	}
+	return details
+}
+
+// reindexPage adds the live version of the given page to the search index.
+// Returns false if the page isn't published, so nothing was written.
+func reindexPage(db *database.DB, pageID string) (bool, error) {
+	rows := database.NewQuery(`
+		SELECT p.pageId,pi.type,p.title,p.clickbait,p.text,pi.alias,pi.seeDomainId,pi.editDomainId,pi.createdBy,pi.externalUrl,pi.hasVote
+		FROM pages AS p
+		JOIN pageInfos AS pi
+		ON (p.pageId=pi.pageId)
+		WHERE p.isLiveEdit
+			AND p.pageId=?`, pageID).Add(`
+			AND`).AddPart(core.PageInfosFilter(nil)).ToStatement(db).Query()
+	indexed := false
+	err := rows.Process(func(db *database.DB, rows *database.Rows) error {
+		if err := populateElasticProcessPage(db, rows); err != nil {
+			return err
+		}
+		indexed = true
+		return nil
+	})
+	return indexed, err
+}
@@ -0,0 +1,188 @@
+// compactEditsTask.go removes stale autosaves and collapses old minor edits,
+// one batch of pages at a time.
+package tasks
+
+import (
+	"fmt"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/sessions"
+)
+
+const (
+	// How many pages to process per task execution
+	editCompactionBatchSize = 100
+)
+
+// CompactEditsTask is the object that's put into the daemon queue.
+type CompactEditsTask struct {
+	CompactionID string
+}
+
+func (task CompactEditsTask) Tag() string {
+	return "compactEdits"
+}
+
+// Check if this task is valid, and we can safely execute it.
+func (task CompactEditsTask) IsValid() error {
+	if !core.IsIntIDValid(task.CompactionID) {
+		return fmt.Errorf("Invalid compaction id: %s", task.CompactionID)
+	}
+	return nil
+}
+
+// Execute this task. Called by the actual daemon worker, don't call on BE.
+// For comments on return value see tasks.QueueTask
+func (task CompactEditsTask) Execute(db *database.DB) (delay int, err error) {
+	c := db.C
+
+	if err = task.IsValid(); err != nil {
+		return 0, err
+	}
+
+	compaction, err := core.LoadEditCompaction(db, task.CompactionID)
+	if err != nil {
+		return -1, err
+	} else if compaction == nil {
+		return 0, fmt.Errorf("Couldn't find edit compaction: %s", task.CompactionID)
+	} else if compaction.IsFinished {
+		return 0, nil
+	}
+
+	c.Infof("Compacting edits (compaction %s) after page %s", compaction.ID, compaction.LastPageID)
+
+	// Load the next batch of pages
+	pageIDs := make([]string, 0)
+	rows := database.NewQuery(`
+		SELECT pageId
+		FROM pageInfos
+		WHERE pageId>?`, compaction.LastPageID).Add(`
+		ORDER BY pageId
+		LIMIT ?`, editCompactionBatchSize).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var pageID string
+		if err := rows.Scan(&pageID); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		pageIDs = append(pageIDs, pageID)
+		return nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load pages: %v", err)
+	}
+
+	for _, pageID := range pageIDs {
+		plan, err := compactPageEdits(db, pageID, compaction)
+		if err != nil {
+			return -1, fmt.Errorf("Couldn't compact edits of page %s: %v", pageID, err)
+		}
+		compaction.AutosavesDeleted += len(plan.StaleAutosaves)
+		compaction.EditsCollapsed += len(plan.CollapsedEdits)
+		compaction.BytesReclaimed += int64(plan.BytesReclaimed)
+	}
+
+	// Save the progress and move the cursor forward
+	compaction.PagesProcessed += len(pageIDs)
+	if len(pageIDs) > 0 {
+		compaction.LastPageID = pageIDs[len(pageIDs)-1]
+	}
+	compaction.IsFinished = len(pageIDs) < editCompactionBatchSize
+	statement := database.NewQuery(`
+		UPDATE editCompactions
+		SET updatedAt=?,lastPageId=?,pagesProcessed=?,`, database.Now(), compaction.LastPageID, compaction.PagesProcessed).Add(`
+			autosavesDeleted=?,editsCollapsed=?,`, compaction.AutosavesDeleted, compaction.EditsCollapsed).Add(`
+			bytesReclaimed=?,isFinished=?`, compaction.BytesReclaimed, compaction.IsFinished).Add(`
+		WHERE id=?`, compaction.ID).ToStatement(db)
+	if _, err := statement.Exec(); err != nil {
+		return -1, fmt.Errorf("Couldn't update edit compaction: %v", err)
+	}
+
+	if compaction.IsFinished {
+		c.Infof("Edit compaction %s finished: %d pages, %d autosaves deleted, %d edits collapsed, %d bytes reclaimed",
+			compaction.ID, compaction.PagesProcessed, compaction.AutosavesDeleted, compaction.EditsCollapsed, compaction.BytesReclaimed)
+		return 0, nil
+	}
+
+	// Process the next batch
+	var nextTask CompactEditsTask
+	nextTask.CompactionID = compaction.ID
+	if err := Enqueue(c, &nextTask, nil); err != nil {
+		return -1, fmt.Errorf("Couldn't enqueue the next compaction task: %v", err)
+	}
+	return 0, nil
+}
+
+// compactPageEdits removes the edits of the given page that the retention
+// policy allows to remove. The page is locked while the plan is made, so nothing
+// can start referencing an edit we are about to remove.
+func compactPageEdits(db *database.DB, pageID string, compaction *core.EditCompaction) (*core.EditCompactionPlan, error) {
+	var plan *core.EditCompactionPlan
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		var lockedPageID string
+		row := database.NewQuery(`
+			SELECT pageId
+			FROM pageInfos
+			WHERE pageId=?
+			FOR UPDATE`, pageID).ToTxStatement(tx).QueryRow()
+		if _, err := row.Scan(&lockedPageID); err != nil {
+			return sessions.NewError("Couldn't lock the page", err)
+		}
+
+		edits, err := core.LoadCompactableEdits(tx, pageID)
+		if err != nil {
+			return sessions.NewError("Couldn't load edits", err)
+		}
+		referencedEdits, err := core.LoadReferencedEdits(tx, pageID)
+		if err != nil {
+			return sessions.NewError("Couldn't load referenced edits", err)
+		}
+		plan = core.PlanEditCompaction(edits, referencedEdits, compaction.MinorEditCutoff)
+		removedEdits := plan.RemovedEdits()
+		if compaction.IsDryRun || len(removedEdits) <= 0 {
+			return nil
+		}
+
+		// Point the change logs of collapsed edits to the edit that replaced them
+		for edit, intoEdit := range plan.CollapsedEdits {
+			statement := database.NewQuery(`
+				UPDATE changeLogs
+				SET edit=?`, intoEdit).Add(`
+				WHERE pageId=? AND edit=? AND type=?`, pageID, edit, core.NewEditChangeLog).ToTxStatement(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't update change logs", err)
+			}
+		}
+
+		for edit, prevEdit := range plan.NewPrevEdits {
+			statement := database.NewQuery(`
+				UPDATE pages
+				SET prevEdit=?`, prevEdit).Add(`
+				WHERE pageId=? AND edit=?`, pageID, edit).ToTxStatement(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't update prevEdit", err)
+			}
+		}
+
+		statement := database.NewQuery(`
+			DELETE FROM pages
+			WHERE pageId=? AND edit IN`, pageID).AddArgsGroup(intsToInterfaces(removedEdits)).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't delete edits", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return nil, sessions.ToError(err2)
+	}
+	return plan, nil
+}
+
+// intsToInterfaces converts the list of ints into a list of query args.
+func intsToInterfaces(values []int) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		result = append(result, value)
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+// computePathAnalyticsTask.go precomputes how readers move through each guide's path.
+package tasks
+
+import (
+	"fmt"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/sessions"
+)
+
+const (
+	computePathAnalyticsPeriod = 6 * 60 * 60 // 6 hours
+)
+
+// ComputePathAnalyticsTask is the object that's put into the daemon queue.
+type ComputePathAnalyticsTask struct {
+	// If true, the task won't be rescheduled
+	RunOnce bool
+}
+
+func (task ComputePathAnalyticsTask) Tag() string {
+	return "computePathAnalytics"
+}
+
+// Check if this task is valid, and we can safely execute it.
+func (task ComputePathAnalyticsTask) IsValid() error {
+	return nil
+}
+
+// Execute this task. Called by the actual daemon worker, don't call on BE.
+// For comments on return value see tasks.QueueTask
+func (task ComputePathAnalyticsTask) Execute(db *database.DB) (delay int, err error) {
+	delay = computePathAnalyticsPeriod
+	if task.RunOnce {
+		delay = 0
+	}
+	c := db.C
+
+	if err = task.IsValid(); err != nil {
+		return -1, err
+	}
+
+	// Find the guides whose paths changed since we last computed their analytics
+	guideIDs := make([]string, 0)
+	rows := database.NewQuery(`
+		SELECT pathi.guideId
+		FROM pathInstances AS pathi
+		LEFT JOIN pathAnalytics AS pa
+		ON (pathi.guideId=pa.guideId)
+		GROUP BY 1
+		HAVING MAX(pathi.updatedAt)>=IFNULL(MAX(pa.computedAt),"")`).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var guideID string
+		if err := rows.Scan(&guideID); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		guideIDs = append(guideIDs, guideID)
+		return nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load guides: %v", err)
+	}
+
+	for _, guideID := range guideIDs {
+		computedAt := database.Now()
+		steps, instances, firstVisits, err := core.LoadPathAnalyticsInput(db, guideID)
+		if err != nil {
+			return -1, fmt.Errorf("Couldn't load path analytics input for %s: %v", guideID, err)
+		}
+		analytics := core.ComputePathAnalytics(guideID, steps, instances, firstVisits)
+		analytics.ComputedAt = computedAt
+
+		err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+			if err := core.SavePathAnalytics(tx, analytics); err != nil {
+				return sessions.NewError("Couldn't save path analytics", err)
+			}
+			return nil
+		})
+		if err2 != nil {
+			return -1, sessions.ToError(err2)
+		}
+	}
+
+	c.Infof("Computed path analytics for %d guides", len(guideIDs))
+	return
+}
@@ -0,0 +1,115 @@
+// computeSimilarPagesTask.go computes which pages have similar text.
+package tasks
+
+import (
+	"fmt"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/sessions"
+)
+
+const (
+	computeSimilarPagesPeriod = 24 * 60 * 60 // 1 day
+	// How many similar pages we store for each page
+	similarPagesPerPage = 10
+	// Pages with lower similarity than this aren't stored
+	minSimilarPageScore = 0.1
+	// How many rows to insert per statement
+	similarPagesInsertBatchSize = 500
+)
+
+// ComputeSimilarPagesTask is the object that's put into the daemon queue.
+type ComputeSimilarPagesTask struct {
+	// If true, the task won't be rescheduled
+	RunOnce bool
+}
+
+func (task ComputeSimilarPagesTask) Tag() string {
+	return "computeSimilarPages"
+}
+
+// Check if this task is valid, and we can safely execute it.
+func (task ComputeSimilarPagesTask) IsValid() error {
+	return nil
+}
+
+// Execute this task. Called by the actual daemon worker, don't call on BE.
+// For comments on return value see tasks.QueueTask
+func (task ComputeSimilarPagesTask) Execute(db *database.DB) (delay int, err error) {
+	delay = computeSimilarPagesPeriod
+	if task.RunOnce {
+		delay = 0
+	}
+	c := db.C
+
+	if err = task.IsValid(); err != nil {
+		return -1, err
+	}
+
+	c.Infof("==== COMPUTE SIMILAR PAGES START ====")
+	defer c.Infof("==== COMPUTE SIMILAR PAGES COMPLETED ====")
+
+	// Load the text of all published pages
+	docs := make(map[string]string)
+	rows := database.NewQuery(`
+		SELECT p.pageId,p.title,p.clickbait,p.text
+		FROM pages AS p
+		JOIN pageInfos AS pi
+		ON (p.pageId=pi.pageId)
+		WHERE p.isLiveEdit
+			AND pi.type!=?`, core.CommentPageType).Add(`
+			AND`).AddPart(core.PageInfosFilter(nil)).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var pageID, title, clickbait, text string
+		err := rows.Scan(&pageID, &title, &clickbait, &text)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		// Count the title twice, since it's the best summary of what the page is about
+		docs[pageID] = title + "\n" + title + "\n" + clickbait + "\n" + text
+		return nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load pages: %v", err)
+	}
+
+	similarMap := core.ComputeSimilarPages(docs, similarPagesPerPage, minSimilarPageScore)
+
+	// Replace the old results
+	now := database.Now()
+	hashmaps := make(database.InsertMaps, 0)
+	for pageID, similarPages := range similarMap {
+		for _, similarPage := range similarPages {
+			hashmap := make(database.InsertMap)
+			hashmap["pageId"] = pageID
+			hashmap["similarPageId"] = similarPage.PageID
+			hashmap["score"] = similarPage.Score
+			hashmap["computedAt"] = now
+			hashmaps = append(hashmaps, hashmap)
+		}
+	}
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		statement := database.NewQuery(`DELETE FROM similarPages`).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't delete old similar pages", err)
+		}
+		for start := 0; start < len(hashmaps); start += similarPagesInsertBatchSize {
+			end := start + similarPagesInsertBatchSize
+			if end > len(hashmaps) {
+				end = len(hashmaps)
+			}
+			statement := tx.DB.NewMultipleInsertStatement("similarPages", hashmaps[start:end]).WithTx(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't insert similar pages", err)
+			}
+		}
+		return nil
+	})
+	if err2 != nil {
+		return -1, sessions.ToError(err2)
+	}
+
+	c.Infof("Computed %d similar page pairs for %d pages", len(hashmaps), len(docs))
+	return
+}
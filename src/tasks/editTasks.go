@@ -0,0 +1,131 @@
+// editTasks.go enqueues the tasks that follow a new edit of a page.
+package tasks
+
+import (
+	"regexp"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/sessions"
+)
+
+// EditTasksData describes an edit that was just saved.
+type EditTasksData struct {
+	PageID string
+	// Id of the user who made the edit
+	UserID   string
+	PageType string
+	Text     string
+	// Set if the edit is visible to the public
+	IsPublicEdit bool
+	// Set if the edit became the page's live edit
+	IsNewCurrentEdit bool
+	IsMinorEdit      bool
+	// State of the page before the edit
+	WasPublished bool
+	WasDeleted   bool
+	// Comment-specific info
+	IsEditorComment      bool
+	IsApprovedComment    bool
+	CommentParentID      string
+	CommentPrimaryPageID string
+	// Id of the changeLog created for the edit (0 if none)
+	EditChangeLogID int64
+}
+
+// EnqueueEditTasks enqueues updates, elastic indexing and relationship
+// publishing for a saved edit. This runs after the edit's transaction has been
+// committed, so errors are only logged.
+func EnqueueEditTasks(c sessions.Context, data *EditTasksData) {
+	if data.IsPublicEdit {
+		// Generate "edit" update for users who are subscribed to this page.
+		if data.WasPublished && !data.IsMinorEdit && data.EditChangeLogID != 0 && data.PageType != core.CommentPageType {
+			var task NewUpdateTask
+			task.UserID = data.UserID
+			task.GoToPageID = data.PageID
+			task.SubscribedToID = data.PageID
+			task.ChangeLogID = data.EditChangeLogID
+			if data.WasDeleted {
+				task.UpdateType = core.ChangeLogUpdateType
+			} else {
+				task.UpdateType = core.PageEditUpdateType
+			}
+			if err := Enqueue(c, &task, nil); err != nil {
+				c.Errorf("Couldn't enqueue a task: %v", err)
+			}
+		}
+	}
+
+	if !data.IsNewCurrentEdit {
+		return
+	}
+
+	// Update elastic
+	{
+		var task UpdateElasticPageTask
+		task.PageID = data.PageID
+		if err := Enqueue(c, &task, nil); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
+
+	// Generate updates for users who are subscribed to the author.
+	if !data.WasPublished && data.PageType != core.CommentPageType && !data.IsMinorEdit {
+		var task NewUpdateTask
+		task.UserID = data.UserID
+		task.UpdateType = core.NewPageByUserUpdateType
+		task.SubscribedToID = data.UserID
+		task.GoToPageID = data.PageID
+		task.ChangeLogID = data.EditChangeLogID
+		if err := Enqueue(c, &task, nil); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
+
+	// Do some stuff for a new comment.
+	if !data.WasPublished && data.PageType == core.CommentPageType {
+		// Send "new comment" updates.
+		if !data.IsMinorEdit {
+			var task NewUpdateTask
+			task.UserID = data.UserID
+			task.GoToPageID = data.PageID
+			task.ForceMaintainersOnly = data.IsEditorComment || !data.IsApprovedComment
+			task.ChangeLogID = data.EditChangeLogID
+			if core.IsIDValid(data.CommentParentID) {
+				// This is a new reply
+				task.UpdateType = core.ReplyUpdateType
+				task.SubscribedToID = data.CommentParentID
+			} else {
+				// This is a new top level comment
+				task.UpdateType = core.TopLevelCommentUpdateType
+				task.SubscribedToID = data.CommentPrimaryPageID
+			}
+			if err := Enqueue(c, &task, nil); err != nil {
+				c.Errorf("Couldn't enqueue a task: %v", err)
+			}
+		}
+
+		// Generate updates for @mentions
+		// Find ids and aliases using [@text] syntax.
+		exp := regexp.MustCompile("\\[@([0-9]+)\\]")
+		submatches := exp.FindAllStringSubmatch(data.Text, -1)
+		for _, submatch := range submatches {
+			var task AtMentionUpdateTask
+			task.UserID = data.UserID
+			task.MentionedUserID = submatch[1]
+			task.GoToPageID = data.PageID
+			if err := Enqueue(c, &task, nil); err != nil {
+				c.Errorf("Couldn't enqueue a task: %v", err)
+			}
+		}
+	}
+
+	// Create a task to check if any of the relationships need to be published
+	// TODO: condition: this page went from unpublished to published or deleted to undeleted
+	{
+		var task UpdatePagePairsTask
+		task.PageID = data.PageID
+		if err := Enqueue(c, &task, nil); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
+}
@@ -31,6 +31,9 @@ type NewUpdateTask struct {
 
 	// Only set if UpdateType is for a mark. Id is a FK into marks table.
 	MarkID string
+
+	// If set, only this user will get the update, regardless of subscriptions.
+	ForUserID string
 }
 
 func (task NewUpdateTask) Tag() string {
@@ -92,22 +95,30 @@ func (task NewUpdateTask) Execute(db *database.DB) (delay int, err error) {
 	}
 
 	var query *database.QueryPart
-	// Iterate through all users who are subscribed to this page/comment.
-	// If it is an editors only comment, only select editor ids.
-	query = database.NewQuery(`SELECT s.userId`)
-	if !task.ForceMaintainersOnly &&
-		(task.UpdateType == core.TopLevelCommentUpdateType || task.UpdateType == core.ReplyUpdateType ||
-			task.UpdateType == core.NewPageByUserUpdateType || task.UpdateType == core.AtMentionUpdateType ||
-			task.UpdateType == core.AddedToGroupUpdateType || task.UpdateType == core.RemovedFromGroupUpdateType ||
-			task.UpdateType == core.InviteReceivedUpdateType || task.UpdateType == core.ResolvedMarkUpdateType ||
-			task.UpdateType == core.AnsweredMarkUpdateType) {
-		// This update can be shown to all users who are subscribed
-		query.Add(`FROM discussionSubscriptions AS s`)
+	if core.IsIDValid(task.ForUserID) {
+		// This update is meant for one specific user
+		query = database.NewQuery(`
+			SELECT s.userId
+			FROM (SELECT id AS userId FROM users WHERE id=?) AS s`, task.ForUserID).Add(`
+			WHERE TRUE`)
 	} else {
-		// This update is only for authors who explicitly opted into maintaining the page
-		query.Add(`FROM maintainerSubscriptions AS s`)
+		// Iterate through all users who are subscribed to this page/comment.
+		// If it is an editors only comment, only select editor ids.
+		query = database.NewQuery(`SELECT s.userId`)
+		if !task.ForceMaintainersOnly &&
+			(task.UpdateType == core.TopLevelCommentUpdateType || task.UpdateType == core.ReplyUpdateType ||
+				task.UpdateType == core.NewPageByUserUpdateType || task.UpdateType == core.AtMentionUpdateType ||
+				task.UpdateType == core.AddedToGroupUpdateType || task.UpdateType == core.RemovedFromGroupUpdateType ||
+				task.UpdateType == core.InviteReceivedUpdateType || task.UpdateType == core.ResolvedMarkUpdateType ||
+				task.UpdateType == core.AnsweredMarkUpdateType) {
+			// This update can be shown to all users who are subscribed
+			query.Add(`FROM discussionSubscriptions AS s`)
+		} else {
+			// This update is only for authors who explicitly opted into maintaining the page
+			query.Add(`FROM maintainerSubscriptions AS s`)
+		}
+		query.Add(`WHERE s.toPageId=?`, task.SubscribedToID)
 	}
-	query.Add(`WHERE s.toPageId=?`, task.SubscribedToID)
 	if len(requiredDomainIDs) > 0 {
 		query = query.Add(`AND
 		(
@@ -123,7 +134,7 @@ func (task NewUpdateTask) Execute(db *database.DB) (delay int, err error) {
 		if err != nil {
 			return fmt.Errorf("failed to scan for domainMembers: %v", err)
 		}
-		if userID == task.UserID {
+		if userID == task.UserID && !core.IsIDValid(task.ForUserID) {
 			return nil
 		}
 
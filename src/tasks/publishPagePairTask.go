@@ -109,6 +109,13 @@ func (task PublishPagePairTask) Execute(db *database.DB) (delay int, err error)
 		return -1, sessions.ToError(err2)
 	}
 
+	if pagePair.Type == core.RequirementPagePairType {
+		var difficultyTask UpdatePageDifficultyTask
+		difficultyTask.PageID = pagePair.ChildID
+		if err := Enqueue(c, &difficultyTask, nil); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
 	return 0, nil
 }
 
@@ -0,0 +1,251 @@
+// publishScheduledEditTask.go makes a scheduled edit live once its time comes.
+package tasks
+
+import (
+	"fmt"
+	"time"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/sessions"
+)
+
+// PublishScheduledEditTask is the object that's put into the daemon queue.
+type PublishScheduledEditTask struct {
+	// Id of the scheduled publish
+	ID string
+}
+
+func (task PublishScheduledEditTask) Tag() string {
+	return "publishScheduledEdit"
+}
+
+// Check if this task is valid, and we can safely execute it.
+func (task PublishScheduledEditTask) IsValid() error {
+	if !core.IsIntIDValid(task.ID) {
+		return fmt.Errorf("Invalid scheduled publish id: %s", task.ID)
+	}
+	return nil
+}
+
+// Execute this task. Called by the actual daemon worker, don't call on BE.
+// For comments on return value see tasks.QueueTask
+func (task PublishScheduledEditTask) Execute(db *database.DB) (delay int, err error) {
+	c := db.C
+
+	if err = task.IsValid(); err != nil {
+		return -1, err
+	}
+
+	sp, err := core.LoadScheduledPublish(db, task.ID)
+	if err != nil {
+		return -1, err
+	} else if sp == nil || sp.Status != core.PendingScheduledPublish {
+		// Cancelled or already done
+		return 0, nil
+	}
+
+	// Wait until it's time
+	publishAt, err := time.Parse(database.TimeLayout, sp.PublishAt)
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't parse publishAt: %v", err)
+	}
+	if wait := int(publishAt.Sub(time.Now().UTC()).Seconds()); wait > 0 {
+		return wait, nil
+	}
+
+	c.Infof("==== PUBLISH SCHEDULED EDIT START ====")
+	defer c.Infof("==== PUBLISH SCHEDULED EDIT COMPLETED ====")
+
+	// The scheduling user has to still be allowed to edit the page: they could
+	// have been banned or lost their domain role in the meantime
+	u, err := core.LoadCurrentUserFromDb(db, sp.UserID, nil)
+	if err != nil {
+		return 0, task.fail(db, sp, "The author's account couldn't be loaded")
+	}
+	if err := core.LoadUserDomainMembership(db, &u.User, nil); err != nil {
+		return -1, fmt.Errorf("Couldn't load domain membership: %v", err)
+	}
+	domainMap := make(map[string]*core.Domain)
+	p, err := core.LoadFullEdit(db, sp.PageID, u, domainMap, &core.LoadEditOptions{
+		LoadNonliveEdit: true,
+		PreferLiveEdit:  true,
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load the page: %v", err)
+	} else if p == nil {
+		return 0, task.fail(db, sp, "The page doesn't exist anymore")
+	}
+	if !p.Permissions.Edit.Has {
+		return 0, task.fail(db, sp, "The author can't edit the page anymore: "+p.Permissions.Edit.Reason)
+	}
+
+	// Load the edit
+	var text, editSummary string
+	var isMinorEdit bool
+	row := database.NewQuery(`
+		SELECT text,editSummary,isMinorEdit
+		FROM pages
+		WHERE pageId=? AND edit=? AND creatorId=?`, sp.PageID, sp.Edit, sp.UserID).ToStatement(db).QueryRow()
+	exists, err := row.Scan(&text, &editSummary, &isMinorEdit)
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load the edit: %v", err)
+	} else if !exists {
+		return 0, task.fail(db, sp, "The edit doesn't exist anymore")
+	}
+
+	// Make the edit live, the same way editPageHandler does
+	var failReason string
+	var isCancelled, wasPublished, isDeleted bool
+	var editChangeLogID int64
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		// Make sure the publish wasn't cancelled, and keep it from being cancelled
+		// while we publish
+		status, err := core.LockScheduledPublishStatus(tx, sp.ID)
+		if err != nil {
+			return sessions.NewError("Couldn't load scheduled publish", err)
+		} else if status != core.PendingScheduledPublish {
+			isCancelled = true
+			return nil
+		}
+
+		// Lock the page's info, so nobody can publish over us in the meantime
+		var currentEdit int
+		row := database.NewQuery(`
+			SELECT currentEdit,isDeleted
+			FROM pageInfos
+			WHERE pageId=?
+			FOR UPDATE`, sp.PageID).ToTxStatement(tx).QueryRow()
+		exists, err := row.Scan(&currentEdit, &isDeleted)
+		if err != nil {
+			return sessions.NewError("Couldn't load pageInfo", err)
+		} else if !exists {
+			failReason = "The page doesn't exist anymore"
+			return nil
+		}
+		wasPublished = currentEdit > 0
+
+		// Don't overwrite changes someone else published in the meantime
+		if currentEdit != sp.BaseEdit {
+			failReason = "The page was edited after the publish was scheduled"
+			return nil
+		}
+
+		statement := database.NewQuery(`
+			UPDATE pages
+			SET isLiveEdit=(edit=?)`, sp.Edit).Add(`
+			WHERE pageId=?`, sp.PageID).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't update isLiveEdit", err)
+		}
+		statement = database.NewQuery(`
+			UPDATE pages
+			SET isSnapshot=FALSE,snapshotText=""
+			WHERE pageId=? AND edit=?`, sp.PageID, sp.Edit).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't update the edit", err)
+		}
+
+		if err := core.UpdatePageSummaries(tx, sp.PageID, text); err != nil {
+			return sessions.NewError("Couldn't update page summaries", err)
+		}
+		if err := core.UpdatePageEstimates(tx, sp.PageID, text); err != nil {
+			return sessions.NewError("Couldn't update page estimates", err)
+		}
+
+		hashmap := make(database.InsertMap)
+		hashmap["pageId"] = sp.PageID
+		hashmap["currentEdit"] = sp.Edit
+		hashmap["lockedUntil"] = database.Now()
+		if isDeleted {
+			hashmap["isDeleted"] = false
+			hashmap["mergedInto"] = ""
+		}
+		if !wasPublished {
+			hashmap["createdAt"] = database.Now()
+			hashmap["createdBy"] = sp.UserID
+		}
+		statement = tx.DB.NewInsertStatement("pageInfos", hashmap, hashmap.GetKeys()...).WithTx(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't update pageInfos", err)
+		}
+
+		hashmap = make(database.InsertMap)
+		hashmap["pageId"] = sp.PageID
+		hashmap["edit"] = sp.Edit
+		hashmap["userId"] = sp.UserID
+		hashmap["createdAt"] = database.Now()
+		hashmap["type"] = core.NewEditChangeLog
+		hashmap["newSettingsValue"] = editSummary
+		statement = tx.DB.NewInsertStatement("changeLogs", hashmap).WithTx(tx)
+		result, err := statement.Exec()
+		if err != nil {
+			return sessions.NewError("Couldn't insert change log", err)
+		}
+		editChangeLogID, err = result.LastInsertId()
+		if err != nil {
+			return sessions.NewError("Couldn't get id of changeLog", err)
+		}
+
+		if err := core.UpdatePageLinks(tx, sp.PageID, text, sessions.GetDomain()); err != nil {
+			return sessions.NewError("Couldn't update links", err)
+		}
+
+		if _, err := core.SetScheduledPublishStatus(tx, sp.ID, core.PublishedScheduledPublish, ""); err != nil {
+			return sessions.NewError("Couldn't update scheduled publish", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return -1, sessions.ToError(err2)
+	} else if isCancelled {
+		return 0, nil
+	} else if failReason != "" {
+		return 0, task.fail(db, sp, failReason)
+	}
+
+	EnqueueEditTasks(c, &EditTasksData{
+		PageID:           sp.PageID,
+		UserID:           sp.UserID,
+		PageType:         p.Type,
+		Text:             text,
+		IsPublicEdit:     true,
+		IsNewCurrentEdit: true,
+		IsMinorEdit:      isMinorEdit,
+		WasPublished:     wasPublished,
+		WasDeleted:       isDeleted,
+		EditChangeLogID:  editChangeLogID,
+	})
+	return 0, nil
+}
+
+// fail marks the scheduled publish as failed and lets the author know. Does
+// nothing if the publish was cancelled in the meantime.
+func (task PublishScheduledEditTask) fail(db *database.DB, sp *core.ScheduledPublish, reason string) error {
+	var isFailed bool
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		var err error
+		isFailed, err = core.SetScheduledPublishStatus(tx, sp.ID, core.FailedScheduledPublish, reason)
+		if err != nil {
+			return sessions.NewError("Couldn't update scheduled publish", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return sessions.ToError(err2)
+	} else if !isFailed {
+		return nil
+	}
+
+	var updateTask NewUpdateTask
+	updateTask.UserID = sp.UserID
+	updateTask.ForUserID = sp.UserID
+	updateTask.UpdateType = core.ScheduledPublishFailedUpdateType
+	updateTask.SubscribedToID = sp.PageID
+	updateTask.GoToPageID = sp.PageID
+	if err := Enqueue(db.C, &updateTask, nil); err != nil {
+		db.C.Errorf("Couldn't enqueue a task: %v", err)
+	}
+	db.C.Infof("Scheduled publish %s failed: %q", sp.ID, reason)
+	return nil
+}
@@ -0,0 +1,183 @@
+// remindMasteryReviewsTask.go schedules reviews for new masteries and reminds
+// users about the reviews that are due.
+package tasks
+
+import (
+	"fmt"
+	"time"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+	"zanaduu3/src/sessions"
+)
+
+const (
+	remindMasteryReviewsPeriod = 60 * 60 // 1 hour
+	// Only masteries learned this recently start being reviewed automatically, so
+	// that masteries from before reviews existed don't all become due at once
+	// (in seconds)
+	newMasteryReviewWindow = 24 * 60 * 60
+	// How many users to remind per task execution
+	masteryReviewRemindersBatchSize = 1000
+)
+
+// RemindMasteryReviewsTask is the object that's put into the daemon queue.
+type RemindMasteryReviewsTask struct {
+}
+
+func (task RemindMasteryReviewsTask) Tag() string {
+	return "remindMasteryReviews"
+}
+
+// Check if this task is valid, and we can safely execute it.
+func (task RemindMasteryReviewsTask) IsValid() error {
+	return nil
+}
+
+// Execute this task. Called by the actual daemon worker, don't call on BE.
+// For comments on return value see tasks.QueueTask
+func (task RemindMasteryReviewsTask) Execute(db *database.DB) (delay int, err error) {
+	delay = remindMasteryReviewsPeriod
+	c := db.C
+
+	if err = task.IsValid(); err != nil {
+		return
+	}
+
+	// Start reviewing the masteries registered users learned recently
+	now := database.Now()
+	windowStart := time.Now().UTC().Add(-newMasteryReviewWindow * time.Second).Format(database.TimeLayout)
+	statement := database.NewQuery(`
+		INSERT IGNORE INTO masteryReviews (userId,masteryId,easiness,intervalDays,repetitions,
+			dueAt,lastReviewedAt,lastGrade,remindedAt,createdAt,updatedAt)
+		SELECT ump.userId,ump.masteryId,?,1,0,`, core.DefaultMasteryReviewEasiness).Add(`
+			DATE_ADD(ump.updatedAt,INTERVAL 1 DAY),ump.updatedAt,0,ump.updatedAt,?,?`, now, now).Add(`
+		FROM userMasteryPairs AS ump
+		JOIN users AS u
+		ON (u.id=ump.userId)
+		LEFT JOIN masteryReviews AS mr
+		ON (ump.userId=mr.userId AND ump.masteryId=mr.masteryId)
+		WHERE ump.has
+			AND ump.updatedAt>=?`, windowStart).Add(`
+			AND mr.userId IS NULL`).ToStatement(db)
+	result, err := statement.Exec()
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't add new mastery reviews: %v", err)
+	}
+	if count, err := result.RowsAffected(); err == nil && count > 0 {
+		c.Infof("Started reviewing %d masteries", count)
+	}
+
+	// Find the users with reviews that became due since we last reminded them.
+	// Each user gets one reminder, pointing at their oldest due review.
+	hashmaps := make(database.InsertMaps, 0)
+	userIDs := make([]interface{}, 0)
+	rows := database.NewQuery(`
+		SELECT DISTINCT mr.userId
+		FROM masteryReviews AS mr
+		JOIN userMasteryPairs AS ump
+		ON (ump.userId=mr.userId AND ump.masteryId=mr.masteryId)
+		JOIN users AS u
+		ON (u.id=mr.userId)
+		WHERE ump.has
+			AND mr.dueAt<=?`, now).Add(`
+			AND mr.remindedAt<mr.dueAt
+		LIMIT ?`, masteryReviewRemindersBatchSize).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		userIDs = append(userIDs, userID)
+		return nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load due mastery reviews: %v", err)
+	}
+	if len(userIDs) <= 0 {
+		return
+	}
+
+	// Oldest due mastery for each user (rows are newest first, so the oldest
+	// one is written last)
+	oldestMasteryIDs := make(map[string]string)
+	rows = database.NewQuery(`
+		SELECT mr.userId,mr.masteryId
+		FROM masteryReviews AS mr
+		JOIN userMasteryPairs AS ump
+		ON (ump.userId=mr.userId AND ump.masteryId=mr.masteryId)
+		WHERE ump.has
+			AND mr.dueAt<=?`, now).Add(`
+			AND mr.userId IN`).AddArgsGroup(userIDs).Add(`
+		ORDER BY mr.dueAt DESC`).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var userID, masteryID string
+		if err := rows.Scan(&userID, &masteryID); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		oldestMasteryIDs[userID] = masteryID
+		return nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load due mastery reviews: %v", err)
+	}
+
+	// Don't pile up reminders for users who haven't looked at the last one yet
+	alreadyReminded := make(map[string]bool)
+	rows = database.NewQuery(`
+		SELECT DISTINCT userId
+		FROM updates
+		WHERE type=?`, core.MasteryReviewUpdateType).Add(`
+			AND NOT seen AND NOT dismissed
+			AND userId IN`).AddArgsGroup(userIDs).ToStatement(db).Query()
+	err = rows.Process(func(db *database.DB, rows *database.Rows) error {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		alreadyReminded[userID] = true
+		return nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Couldn't load existing reminders: %v", err)
+	}
+
+	for _, userID := range userIDs {
+		masteryID := oldestMasteryIDs[userID.(string)]
+		if alreadyReminded[userID.(string)] || masteryID == "" {
+			continue
+		}
+		hashmap := make(database.InsertMap)
+		hashmap["userId"] = userID
+		hashmap["byUserId"] = userID
+		hashmap["type"] = core.MasteryReviewUpdateType
+		hashmap["subscribedToId"] = masteryID
+		hashmap["goToPageId"] = masteryID
+		hashmap["createdAt"] = now
+		hashmaps = append(hashmaps, hashmap)
+	}
+
+	err2 := db.Transaction(func(tx *database.Tx) sessions.Error {
+		if len(hashmaps) > 0 {
+			statement := tx.DB.NewMultipleInsertStatement("updates", hashmaps).WithTx(tx)
+			if _, err := statement.Exec(); err != nil {
+				return sessions.NewError("Couldn't insert review reminders", err)
+			}
+		}
+		statement := database.NewQuery(`
+			UPDATE masteryReviews
+			SET remindedAt=?`, now).Add(`
+			WHERE dueAt<=?`, now).Add(`
+				AND userId IN`).AddArgsGroup(userIDs).ToTxStatement(tx)
+		if _, err := statement.Exec(); err != nil {
+			return sessions.NewError("Couldn't update mastery reviews", err)
+		}
+		return nil
+	})
+	if err2 != nil {
+		return -1, sessions.ToError(err2)
+	}
+
+	c.Infof("Sent mastery review reminders to %d users", len(hashmaps))
+	return
+}
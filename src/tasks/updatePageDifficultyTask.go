@@ -0,0 +1,59 @@
+// updatePageDifficultyTask.go recomputes the difficulty of a page whose
+// requirements changed, and of the pages further down the requirement chain.
+package tasks
+
+import (
+	"fmt"
+
+	"zanaduu3/src/core"
+	"zanaduu3/src/database"
+)
+
+// UpdatePageDifficultyTask is the object that's put into the daemon queue.
+type UpdatePageDifficultyTask struct {
+	PageID string
+}
+
+func (task UpdatePageDifficultyTask) Tag() string {
+	return "updatePageDifficulty"
+}
+
+// Check if this task is valid, and we can safely execute it.
+func (task UpdatePageDifficultyTask) IsValid() error {
+	if !core.IsIDValid(task.PageID) {
+		return fmt.Errorf("Invalid page id: %s", task.PageID)
+	}
+	return nil
+}
+
+// Execute this task. Called by the actual daemon worker, don't call on BE.
+// For comments on return value see tasks.QueueTask
+func (task UpdatePageDifficultyTask) Execute(db *database.DB) (int, error) {
+	c := db.C
+
+	if err := task.IsValid(); err != nil {
+		return -1, err
+	}
+
+	changed, err := core.UpdatePageDifficulty(db, task.PageID)
+	if err != nil {
+		return -1, err
+	} else if !changed {
+		return 0, nil
+	}
+
+	// Pages that require this one build on its difficulty. Since we only go on
+	// when the difficulty changed, this stops once the chain settles.
+	requiringIDs, err := core.LoadRequiringPageIDs(db, task.PageID)
+	if err != nil {
+		return -1, err
+	}
+	for _, pageID := range requiringIDs {
+		var nextTask UpdatePageDifficultyTask
+		nextTask.PageID = pageID
+		if err := Enqueue(c, &nextTask, nil); err != nil {
+			c.Errorf("Couldn't enqueue a task: %v", err)
+		}
+	}
+	return 0, nil
+}